@@ -31,6 +31,13 @@ type CalendarClient interface {
 	ListEventsDay(ctx context.Context, in *ReqByUserByDate, opts ...grpc.CallOption) (*RepEvents, error)
 	ListEventsWeek(ctx context.Context, in *ReqByUserByDate, opts ...grpc.CallOption) (*RepEvents, error)
 	ListEventsMonth(ctx context.Context, in *ReqByUserByDate, opts ...grpc.CallOption) (*RepEvents, error)
+	SearchEvents(ctx context.Context, in *ReqSearch, opts ...grpc.CallOption) (*RepSearch, error)
+	ListUserTags(ctx context.Context, in *ReqByUser, opts ...grpc.CallOption) (*RepTags, error)
+	UploadAttachment(ctx context.Context, opts ...grpc.CallOption) (Calendar_UploadAttachmentClient, error)
+	DownloadAttachment(ctx context.Context, in *ReqByID, opts ...grpc.CallOption) (Calendar_DownloadAttachmentClient, error)
+	DeleteAttachment(ctx context.Context, in *ReqByID, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// ListAttachments lists the attachments of the event of ID.
+	ListAttachments(ctx context.Context, in *ReqByID, opts ...grpc.CallOption) (*RepAttachments, error)
 }
 
 type calendarClient struct {
@@ -113,6 +120,108 @@ func (c *calendarClient) ListEventsMonth(ctx context.Context, in *ReqByUserByDat
 	return out, nil
 }
 
+func (c *calendarClient) SearchEvents(ctx context.Context, in *ReqSearch, opts ...grpc.CallOption) (*RepSearch, error) {
+	out := new(RepSearch)
+	err := c.cc.Invoke(ctx, "/api.Calendar/SearchEvents", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) ListUserTags(ctx context.Context, in *ReqByUser, opts ...grpc.CallOption) (*RepTags, error) {
+	out := new(RepTags)
+	err := c.cc.Invoke(ctx, "/api.Calendar/ListUserTags", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) UploadAttachment(ctx context.Context, opts ...grpc.CallOption) (Calendar_UploadAttachmentClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Calendar_ServiceDesc.Streams[0], "/api.Calendar/UploadAttachment", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &calendarUploadAttachmentClient{stream}
+	return x, nil
+}
+
+type Calendar_UploadAttachmentClient interface {
+	Send(*ReqUploadAttachment) error
+	CloseAndRecv() (*Attachment, error)
+	grpc.ClientStream
+}
+
+type calendarUploadAttachmentClient struct {
+	grpc.ClientStream
+}
+
+func (x *calendarUploadAttachmentClient) Send(m *ReqUploadAttachment) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *calendarUploadAttachmentClient) CloseAndRecv() (*Attachment, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Attachment)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *calendarClient) DownloadAttachment(ctx context.Context, in *ReqByID, opts ...grpc.CallOption) (Calendar_DownloadAttachmentClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Calendar_ServiceDesc.Streams[1], "/api.Calendar/DownloadAttachment", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &calendarDownloadAttachmentClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Calendar_DownloadAttachmentClient interface {
+	Recv() (*RepAttachmentChunk, error)
+	grpc.ClientStream
+}
+
+type calendarDownloadAttachmentClient struct {
+	grpc.ClientStream
+}
+
+func (x *calendarDownloadAttachmentClient) Recv() (*RepAttachmentChunk, error) {
+	m := new(RepAttachmentChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *calendarClient) DeleteAttachment(ctx context.Context, in *ReqByID, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/api.Calendar/DeleteAttachment", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) ListAttachments(ctx context.Context, in *ReqByID, opts ...grpc.CallOption) (*RepAttachments, error) {
+	out := new(RepAttachments)
+	err := c.cc.Invoke(ctx, "/api.Calendar/ListAttachments", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CalendarServer is the server API for Calendar service.
 // All implementations must embed UnimplementedCalendarServer
 // for forward compatibility
@@ -125,6 +234,13 @@ type CalendarServer interface {
 	ListEventsDay(context.Context, *ReqByUserByDate) (*RepEvents, error)
 	ListEventsWeek(context.Context, *ReqByUserByDate) (*RepEvents, error)
 	ListEventsMonth(context.Context, *ReqByUserByDate) (*RepEvents, error)
+	SearchEvents(context.Context, *ReqSearch) (*RepSearch, error)
+	ListUserTags(context.Context, *ReqByUser) (*RepTags, error)
+	UploadAttachment(Calendar_UploadAttachmentServer) error
+	DownloadAttachment(*ReqByID, Calendar_DownloadAttachmentServer) error
+	DeleteAttachment(context.Context, *ReqByID) (*emptypb.Empty, error)
+	// ListAttachments lists the attachments of the event of ID.
+	ListAttachments(context.Context, *ReqByID) (*RepAttachments, error)
 	mustEmbedUnimplementedCalendarServer()
 }
 
@@ -156,6 +272,24 @@ func (UnimplementedCalendarServer) ListEventsWeek(context.Context, *ReqByUserByD
 func (UnimplementedCalendarServer) ListEventsMonth(context.Context, *ReqByUserByDate) (*RepEvents, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListEventsMonth not implemented")
 }
+func (UnimplementedCalendarServer) SearchEvents(context.Context, *ReqSearch) (*RepSearch, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchEvents not implemented")
+}
+func (UnimplementedCalendarServer) ListUserTags(context.Context, *ReqByUser) (*RepTags, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListUserTags not implemented")
+}
+func (UnimplementedCalendarServer) UploadAttachment(Calendar_UploadAttachmentServer) error {
+	return status.Errorf(codes.Unimplemented, "method UploadAttachment not implemented")
+}
+func (UnimplementedCalendarServer) DownloadAttachment(*ReqByID, Calendar_DownloadAttachmentServer) error {
+	return status.Errorf(codes.Unimplemented, "method DownloadAttachment not implemented")
+}
+func (UnimplementedCalendarServer) DeleteAttachment(context.Context, *ReqByID) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteAttachment not implemented")
+}
+func (UnimplementedCalendarServer) ListAttachments(context.Context, *ReqByID) (*RepAttachments, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAttachments not implemented")
+}
 func (UnimplementedCalendarServer) mustEmbedUnimplementedCalendarServer() {}
 
 // UnsafeCalendarServer may be embedded to opt out of forward compatibility for this service.
@@ -313,6 +447,125 @@ func _Calendar_ListEventsMonth_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Calendar_SearchEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReqSearch)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).SearchEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Calendar/SearchEvents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).SearchEvents(ctx, req.(*ReqSearch))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_ListUserTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReqByUser)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).ListUserTags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Calendar/ListUserTags",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).ListUserTags(ctx, req.(*ReqByUser))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_UploadAttachment_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(CalendarServer).UploadAttachment(&calendarUploadAttachmentServer{stream})
+}
+
+type Calendar_UploadAttachmentServer interface {
+	SendAndClose(*Attachment) error
+	Recv() (*ReqUploadAttachment, error)
+	grpc.ServerStream
+}
+
+type calendarUploadAttachmentServer struct {
+	grpc.ServerStream
+}
+
+func (x *calendarUploadAttachmentServer) SendAndClose(m *Attachment) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *calendarUploadAttachmentServer) Recv() (*ReqUploadAttachment, error) {
+	m := new(ReqUploadAttachment)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Calendar_DownloadAttachment_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ReqByID)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CalendarServer).DownloadAttachment(m, &calendarDownloadAttachmentServer{stream})
+}
+
+type Calendar_DownloadAttachmentServer interface {
+	Send(*RepAttachmentChunk) error
+	grpc.ServerStream
+}
+
+type calendarDownloadAttachmentServer struct {
+	grpc.ServerStream
+}
+
+func (x *calendarDownloadAttachmentServer) Send(m *RepAttachmentChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Calendar_DeleteAttachment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReqByID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).DeleteAttachment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Calendar/DeleteAttachment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).DeleteAttachment(ctx, req.(*ReqByID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_ListAttachments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReqByID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).ListAttachments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Calendar/ListAttachments",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).ListAttachments(ctx, req.(*ReqByID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Calendar_ServiceDesc is the grpc.ServiceDesc for Calendar service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -352,7 +605,34 @@ var Calendar_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListEventsMonth",
 			Handler:    _Calendar_ListEventsMonth_Handler,
 		},
+		{
+			MethodName: "SearchEvents",
+			Handler:    _Calendar_SearchEvents_Handler,
+		},
+		{
+			MethodName: "ListUserTags",
+			Handler:    _Calendar_ListUserTags_Handler,
+		},
+		{
+			MethodName: "DeleteAttachment",
+			Handler:    _Calendar_DeleteAttachment_Handler,
+		},
+		{
+			MethodName: "ListAttachments",
+			Handler:    _Calendar_ListAttachments_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "UploadAttachment",
+			Handler:       _Calendar_UploadAttachment_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "DownloadAttachment",
+			Handler:       _Calendar_DownloadAttachment_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "EventServiceInterface.proto",
 }
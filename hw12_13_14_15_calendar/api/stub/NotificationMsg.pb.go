@@ -0,0 +1,229 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.28.1
+// 	protoc        v3.21.12
+// source: NotificationMsg.proto
+
+package api
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// NotificationMsg is the notification queued by the scheduler for the
+// sender. Messages carry the schema version in the x-schema-version header,
+// fields are only added, removed ones are reserved.
+type NotificationMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ID             *int64                 `protobuf:"varint,1,opt,name=ID,proto3,oneof" json:"ID,omitempty"`
+	NotificationID *int64                 `protobuf:"varint,2,opt,name=NotificationID,proto3,oneof" json:"NotificationID,omitempty"`
+	ReminderID     *int64                 `protobuf:"varint,3,opt,name=ReminderID,proto3,oneof" json:"ReminderID,omitempty"`
+	Channel        *string                `protobuf:"bytes,4,opt,name=Channel,proto3,oneof" json:"Channel,omitempty"`
+	DedupKey       *string                `protobuf:"bytes,5,opt,name=DedupKey,proto3,oneof" json:"DedupKey,omitempty"`
+	Title          *string                `protobuf:"bytes,6,opt,name=Title,proto3,oneof" json:"Title,omitempty"`
+	Date           *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=Date,proto3,oneof" json:"Date,omitempty"`
+	UserID         *int64                 `protobuf:"varint,8,opt,name=UserID,proto3,oneof" json:"UserID,omitempty"`
+}
+
+func (x *NotificationMsg) Reset() {
+	*x = NotificationMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_NotificationMsg_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NotificationMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NotificationMsg) ProtoMessage() {}
+
+func (x *NotificationMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_NotificationMsg_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NotificationMsg.ProtoReflect.Descriptor instead.
+func (*NotificationMsg) Descriptor() ([]byte, []int) {
+	return file_NotificationMsg_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *NotificationMsg) GetID() int64 {
+	if x != nil && x.ID != nil {
+		return *x.ID
+	}
+	return 0
+}
+
+func (x *NotificationMsg) GetNotificationID() int64 {
+	if x != nil && x.NotificationID != nil {
+		return *x.NotificationID
+	}
+	return 0
+}
+
+func (x *NotificationMsg) GetReminderID() int64 {
+	if x != nil && x.ReminderID != nil {
+		return *x.ReminderID
+	}
+	return 0
+}
+
+func (x *NotificationMsg) GetChannel() string {
+	if x != nil && x.Channel != nil {
+		return *x.Channel
+	}
+	return ""
+}
+
+func (x *NotificationMsg) GetDedupKey() string {
+	if x != nil && x.DedupKey != nil {
+		return *x.DedupKey
+	}
+	return ""
+}
+
+func (x *NotificationMsg) GetTitle() string {
+	if x != nil && x.Title != nil {
+		return *x.Title
+	}
+	return ""
+}
+
+func (x *NotificationMsg) GetDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Date
+	}
+	return nil
+}
+
+func (x *NotificationMsg) GetUserID() int64 {
+	if x != nil && x.UserID != nil {
+		return *x.UserID
+	}
+	return 0
+}
+
+var File_NotificationMsg_proto protoreflect.FileDescriptor
+
+var file_NotificationMsg_proto_rawDesc = []byte{
+	0x0a, 0x15, 0x4e, 0x6f, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x4d, 0x73,
+	0x67, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x03, 0x61, 0x70, 0x69, 0x1a, 0x1f, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x85, 0x03,
+	0x0a, 0x0f, 0x4e, 0x6f, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x4d, 0x73,
+	0x67, 0x12, 0x13, 0x0a, 0x02, 0x49, 0x44, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x48, 0x00, 0x52,
+	0x02, 0x49, 0x44, 0x88, 0x01, 0x01, 0x12, 0x2b, 0x0a, 0x0e, 0x4e, 0x6f, 0x74, 0x69, 0x66, 0x69,
+	0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x44, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x48, 0x01,
+	0x52, 0x0e, 0x4e, 0x6f, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x44,
+	0x88, 0x01, 0x01, 0x12, 0x23, 0x0a, 0x0a, 0x52, 0x65, 0x6d, 0x69, 0x6e, 0x64, 0x65, 0x72, 0x49,
+	0x44, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x48, 0x02, 0x52, 0x0a, 0x52, 0x65, 0x6d, 0x69, 0x6e,
+	0x64, 0x65, 0x72, 0x49, 0x44, 0x88, 0x01, 0x01, 0x12, 0x1d, 0x0a, 0x07, 0x43, 0x68, 0x61, 0x6e,
+	0x6e, 0x65, 0x6c, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x48, 0x03, 0x52, 0x07, 0x43, 0x68, 0x61,
+	0x6e, 0x6e, 0x65, 0x6c, 0x88, 0x01, 0x01, 0x12, 0x1f, 0x0a, 0x08, 0x44, 0x65, 0x64, 0x75, 0x70,
+	0x4b, 0x65, 0x79, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x48, 0x04, 0x52, 0x08, 0x44, 0x65, 0x64,
+	0x75, 0x70, 0x4b, 0x65, 0x79, 0x88, 0x01, 0x01, 0x12, 0x19, 0x0a, 0x05, 0x54, 0x69, 0x74, 0x6c,
+	0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x48, 0x05, 0x52, 0x05, 0x54, 0x69, 0x74, 0x6c, 0x65,
+	0x88, 0x01, 0x01, 0x12, 0x33, 0x0a, 0x04, 0x44, 0x61, 0x74, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x48, 0x06, 0x52,
+	0x04, 0x44, 0x61, 0x74, 0x65, 0x88, 0x01, 0x01, 0x12, 0x1b, 0x0a, 0x06, 0x55, 0x73, 0x65, 0x72,
+	0x49, 0x44, 0x18, 0x08, 0x20, 0x01, 0x28, 0x03, 0x48, 0x07, 0x52, 0x06, 0x55, 0x73, 0x65, 0x72,
+	0x49, 0x44, 0x88, 0x01, 0x01, 0x42, 0x05, 0x0a, 0x03, 0x5f, 0x49, 0x44, 0x42, 0x11, 0x0a, 0x0f,
+	0x5f, 0x4e, 0x6f, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x44, 0x42,
+	0x0d, 0x0a, 0x0b, 0x5f, 0x52, 0x65, 0x6d, 0x69, 0x6e, 0x64, 0x65, 0x72, 0x49, 0x44, 0x42, 0x0a,
+	0x0a, 0x08, 0x5f, 0x43, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x42, 0x0b, 0x0a, 0x09, 0x5f, 0x44,
+	0x65, 0x64, 0x75, 0x70, 0x4b, 0x65, 0x79, 0x42, 0x08, 0x0a, 0x06, 0x5f, 0x54, 0x69, 0x74, 0x6c,
+	0x65, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x44, 0x61, 0x74, 0x65, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x55,
+	0x73, 0x65, 0x72, 0x49, 0x44, 0x42, 0x0d, 0x5a, 0x0b, 0x2e, 0x2f, 0x73, 0x74, 0x75, 0x62, 0x2f,
+	0x3b, 0x61, 0x70, 0x69, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_NotificationMsg_proto_rawDescOnce sync.Once
+	file_NotificationMsg_proto_rawDescData = file_NotificationMsg_proto_rawDesc
+)
+
+func file_NotificationMsg_proto_rawDescGZIP() []byte {
+	file_NotificationMsg_proto_rawDescOnce.Do(func() {
+		file_NotificationMsg_proto_rawDescData = protoimpl.X.CompressGZIP(file_NotificationMsg_proto_rawDescData)
+	})
+	return file_NotificationMsg_proto_rawDescData
+}
+
+var file_NotificationMsg_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_NotificationMsg_proto_goTypes = []interface{}{
+	(*NotificationMsg)(nil),       // 0: api.NotificationMsg
+	(*timestamppb.Timestamp)(nil), // 1: google.protobuf.Timestamp
+}
+var file_NotificationMsg_proto_depIdxs = []int32{
+	1, // 0: api.NotificationMsg.Date:type_name -> google.protobuf.Timestamp
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_NotificationMsg_proto_init() }
+func file_NotificationMsg_proto_init() {
+	if File_NotificationMsg_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_NotificationMsg_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NotificationMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_NotificationMsg_proto_msgTypes[0].OneofWrappers = []interface{}{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_NotificationMsg_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_NotificationMsg_proto_goTypes,
+		DependencyIndexes: file_NotificationMsg_proto_depIdxs,
+		MessageInfos:      file_NotificationMsg_proto_msgTypes,
+	}.Build()
+	File_NotificationMsg_proto = out.File
+	file_NotificationMsg_proto_rawDesc = nil
+	file_NotificationMsg_proto_goTypes = nil
+	file_NotificationMsg_proto_depIdxs = nil
+}
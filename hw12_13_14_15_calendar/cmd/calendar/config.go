@@ -5,14 +5,17 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/BurntSushi/toml"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/app"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
 )
 
+const envPrefix = "CALENDAR"
+
 var configFile string
 
 func init() {
-	flag.StringVar(&configFile, "config", "/etc/calendar/calendar_config.toml", "Path to configuration file")
+	flag.StringVar(&configFile, "config", "/etc/calendar/calendar_config.toml",
+		"Path to configuration file (toml, yaml or json)")
 	flag.Parse()
 
 	if flag.Arg(0) == "version" {
@@ -26,19 +29,21 @@ type Config struct {
 }
 
 func NewConfig() Config {
-	var config Config
-	if err := config.LoadFileTOML(configFile); err != nil {
+	conf, err := LoadConfig()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Can't load config file:%v error: %v\n", configFile, err)
 		os.Exit(1)
 	}
-	fmt.Println("Config:", config)
-	return config
+	fmt.Println("Config:", conf)
+	return conf
 }
 
-func (c *Config) LoadFileTOML(filename string) error {
-	filedata, err := os.ReadFile(filename)
-	if err != nil {
-		return err
-	}
-	return toml.Unmarshal(filedata, c)
+func LoadConfig() (Config, error) {
+	var conf Config
+	err := conf.Load(configFile)
+	return conf, err
+}
+
+func (c *Config) Load(filename string) error {
+	return config.Load(filename, envPrefix, c)
 }
@@ -1,9 +1,11 @@
 //go:generate protoc --go_out=../../api --proto_path=../../api/ ../../api/EventService.proto
 //go:generate protoc --go-grpc_out=../../api --proto_path=../../api/ ../../api/EventServiceInterface.proto
+//go:generate protoc --go_out=../../api --proto_path=../../api/ ../../api/NotificationMsg.proto
 
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,7 +13,9 @@ import (
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/app"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/logger"
 	internalgrpc "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/server/grpcservice"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/server/health"
 	internalhttp "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/server/http"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/server/ratelimit"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/storage"
 )
 
@@ -20,8 +24,26 @@ func main() {
 	storage := storage.NewStorage(conf.Storage)
 	logger := logger.NewLogger(conf.Logger.Level, os.Stdout)
 	calendar := app.NewCalendar(logger, conf, storage)
-	httpsrv := internalhttp.NewServer(logger, calendar, conf.HTTP.Host, conf.HTTP.Port)
-	grpcsrv, _ := internalgrpc.NewServer(logger, calendar, conf.GRPC.Host, conf.GRPC.Port)
+	httpsrv := internalhttp.NewServer(logger, calendar, conf.HTTP)
+	grpcsrv, _ := internalgrpc.NewServer(logger, calendar, conf.GRPC)
+
+	limiter := ratelimit.New(conf.RateLimit)
+	httpsrv.SetRateLimiter(limiter)
+	grpcsrv.SetRateLimiter(limiter)
+	calendar.OnReload(func(conf app.CalendarConf) {
+		limiter.SetConf(conf.RateLimit)
+	})
+
+	calendar.SetConfLoader(func() (app.CalendarConf, error) {
+		conf, err := LoadConfig()
+		return conf.CalendarConf, err
+	})
+
+	if conf.HealthAddr != "" {
+		healthsrv := health.NewServer(logger, conf.HealthAddr, nil)
+		healthsrv.Start()
+		defer healthsrv.Stop(context.Background())
+	}
 
 	calendar.Run(httpsrv, grpcsrv)
 
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	api "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/api/stub"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	internalgrpc "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/server/grpcservice"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	ErrUsage = errors.New("wrong usage")
+
+	timeLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"}
+)
+
+type command struct {
+	client  api.CalendarClient
+	profile Profile
+	out     io.Writer
+}
+
+func (c command) run(ctx context.Context, name string, args []string) error {
+	switch name {
+	case "create":
+		return c.create(ctx, args)
+	case "update":
+		return c.update(ctx, args)
+	case "delete":
+		return c.delete(ctx, args)
+	case "get":
+		return c.get(ctx, args)
+	case "list":
+		return c.list(ctx, args)
+	case "tags":
+		return c.tags(ctx, args)
+	}
+	return fmt.Errorf("%w: unknown command %q", ErrUsage, name)
+}
+
+// eventFlags are the fields of create and update, update changes only the
+// ones that are set.
+type eventFlags struct {
+	user        int64
+	title       string
+	description string
+	start       string
+	end         string
+	duration    time.Duration
+	remind      string
+	tags        string
+	allDay      bool
+}
+
+func (f *eventFlags) register(flags *flag.FlagSet, user int64) {
+	flags.Int64Var(&f.user, "user", user, "user ID")
+	flags.StringVar(&f.title, "title", "", "title")
+	flags.StringVar(&f.description, "description", "", "description")
+	flags.StringVar(&f.start, "start", "", "start time")
+	flags.StringVar(&f.end, "end", "", "end time")
+	flags.DurationVar(&f.duration, "duration", 0, "duration, instead of end")
+	flags.StringVar(&f.remind, "remind", "", "reminders before start, e.g. 15m,1h:email")
+	flags.StringVar(&f.tags, "tags", "", "comma separated tags, empty to clear")
+	flags.BoolVar(&f.allDay, "allday", false, "all-day event of the dates of start and end, end is exclusive")
+}
+
+func (f *eventFlags) apply(flags *flag.FlagSet, event *model.Event) error {
+	set := map[string]bool{}
+	flags.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+	if set["end"] && set["duration"] {
+		return fmt.Errorf("%w: --end and --duration are exclusive", ErrUsage)
+	}
+
+	length := event.OffTime.Sub(event.OnTime)
+	var err error
+	if set["user"] || event.UserID == 0 {
+		event.UserID = f.user
+	}
+	if set["title"] {
+		event.Title = f.title
+	}
+	if set["description"] {
+		event.Description = f.description
+	}
+	if set["start"] {
+		if event.OnTime, err = parseTime(f.start); err != nil {
+			return err
+		}
+		// moved event keeps its length
+		event.OffTime = event.OnTime.Add(length)
+	}
+	if set["end"] {
+		if event.OffTime, err = parseTime(f.end); err != nil {
+			return err
+		}
+	}
+	if set["duration"] {
+		event.OffTime = event.OnTime.Add(f.duration)
+	}
+	if set["remind"] {
+		if event.Reminders, err = parseReminders(f.remind); err != nil {
+			return err
+		}
+	}
+	if set["tags"] {
+		event.Tags = parseTags(f.tags)
+	}
+	if set["allday"] {
+		event.AllDay = f.allDay
+	}
+	event.NormalizeDates()
+	return nil
+}
+
+func (c command) create(ctx context.Context, args []string) error {
+	var f eventFlags
+	flags := newFlagSet("create")
+	f.register(flags, c.profile.User)
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		return fmt.Errorf("%w: create takes flags only", ErrUsage)
+	}
+	if f.start == "" || (f.end == "" && f.duration == 0 && !f.allDay) {
+		return fmt.Errorf("%w: create needs --start and --end or --duration", ErrUsage)
+	}
+
+	event := model.Event{}
+	if err := f.apply(flags, &event); err != nil {
+		return err
+	}
+	rep, err := c.client.InsertEvent(ctx, &api.ReqByEvent{Event: internalgrpc.Service{}.APIEventFromEvent(&event)})
+	if err != nil {
+		return err
+	}
+	return printID(c.out, c.profile.output(), rep.GetID())
+}
+
+func (c command) update(ctx context.Context, args []string) error {
+	var f eventFlags
+	flags := newFlagSet("update")
+	f.register(flags, c.profile.User)
+	id, err := parseID(flags, args)
+	if err != nil {
+		return err
+	}
+
+	event, err := c.lookup(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := f.apply(flags, &event); err != nil {
+		return err
+	}
+	_, err = c.client.UpdateEvent(ctx, &api.ReqByEvent{Event: internalgrpc.Service{}.APIEventFromEvent(&event)})
+	return err
+}
+
+func (c command) delete(ctx context.Context, args []string) error {
+	id, err := parseID(newFlagSet("delete"), args)
+	if err != nil {
+		return err
+	}
+	_, err = c.client.DeleteEvent(ctx, &api.ReqByID{ID: &id})
+	return err
+}
+
+func (c command) get(ctx context.Context, args []string) error {
+	id, err := parseID(newFlagSet("get"), args)
+	if err != nil {
+		return err
+	}
+	event, err := c.lookup(ctx, id)
+	if err != nil {
+		return err
+	}
+	return printEvents(c.out, c.profile.output(), []model.Event{event}, true)
+}
+
+func (c command) lookup(ctx context.Context, id int64) (model.Event, error) {
+	rep, err := c.client.LookupEvent(ctx, &api.ReqByID{ID: &id})
+	if err != nil {
+		return model.Event{}, err
+	}
+	events := eventsFromAPI(rep.GetEvent())
+	if len(events) == 0 {
+		return model.Event{}, fmt.Errorf("event %v not found", id)
+	}
+	return events[0], nil
+}
+
+func (c command) list(ctx context.Context, args []string) error {
+	flags := newFlagSet("list")
+	user := flags.Int64("user", c.profile.User, "user ID")
+	day := flags.Bool("day", false, "events of the day of --date")
+	week := flags.Bool("week", false, "events of the week of --date")
+	month := flags.Bool("month", false, "events of the month of --date")
+	date := flags.String("date", "", "date of the period, today by default")
+	anyTags := flags.String("any", "", "events having any of the comma separated tags")
+	allTags := flags.String("all", "", "events having all of the comma separated tags")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		return fmt.Errorf("%w: list takes flags only", ErrUsage)
+	}
+	if *user == 0 {
+		return fmt.Errorf("%w: list needs --user", ErrUsage)
+	}
+
+	periods := 0
+	for _, p := range []bool{*day, *week, *month} {
+		if p {
+			periods++
+		}
+	}
+	if periods > 1 {
+		return fmt.Errorf("%w: --day, --week and --month are exclusive", ErrUsage)
+	}
+
+	at := time.Now()
+	if *date != "" {
+		var err error
+		if at, err = parseTime(*date); err != nil {
+			return err
+		}
+	}
+
+	filter := &api.TagFilter{Any: parseTags(*anyTags), All: parseTags(*allTags)}
+	req := &api.ReqByUserByDate{UserID: user, Date: timestamppb.New(at), Tags: filter}
+	if tz := localZone(); tz != "" {
+		req.TimeZone = &tz
+	}
+	var rep *api.RepEvents
+	var err error
+	switch {
+	case *day:
+		rep, err = c.client.ListEventsDay(ctx, req)
+	case *week:
+		rep, err = c.client.ListEventsWeek(ctx, req)
+	case *month:
+		rep, err = c.client.ListEventsMonth(ctx, req)
+	default:
+		rep, err = c.client.ListEvents(ctx, &api.ReqByUser{UserID: user, Tags: filter})
+	}
+	if err != nil {
+		return err
+	}
+	return printEvents(c.out, c.profile.output(), eventsFromAPI(rep.GetEvent()), false)
+}
+
+func (c command) tags(ctx context.Context, args []string) error {
+	flags := newFlagSet("tags")
+	user := flags.Int64("user", c.profile.User, "user ID")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		return fmt.Errorf("%w: tags takes flags only", ErrUsage)
+	}
+	if *user == 0 {
+		return fmt.Errorf("%w: tags needs --user", ErrUsage)
+	}
+
+	rep, err := c.client.ListUserTags(ctx, &api.ReqByUser{UserID: user})
+	if err != nil {
+		return err
+	}
+	tags := make([]model.TagCount, len(rep.GetTag()))
+	for i, t := range rep.GetTag() {
+		tags[i] = model.TagCount{Tag: t.GetTag(), Count: t.GetCount()}
+	}
+	return printTags(c.out, c.profile.output(), tags)
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	return flags
+}
+
+// parseID parses "ID [flags]" as well as "[flags] ID".
+func parseID(flags *flag.FlagSet, args []string) (int64, error) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		args = append(args[1:], args[0])
+	}
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return 0, fmt.Errorf("%w: %v takes an event ID", ErrUsage, flags.Name())
+	}
+	id, err := strconv.ParseInt(flags.Arg(0), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: wrong event ID %q", ErrUsage, flags.Arg(0))
+	}
+	return id, nil
+}
+
+func eventsFromAPI(apiEvents []*api.Event) []model.Event {
+	events := make([]model.Event, 0, len(apiEvents))
+	for _, apiEvent := range apiEvents {
+		event := internalgrpc.Service{}.EventFromAPIEvent(apiEvent)
+		for i, r := range apiEvent.Reminders {
+			if r.NotifyTime.CheckValid() == nil {
+				event.Reminders[i].NotifyTime = r.NotifyTime.AsTime().Local()
+			}
+			event.Reminders[i].Notified = r.GetNotified()
+		}
+		events = append(events, *event)
+	}
+	return events
+}
+
+// localZone is the IANA name of the local time zone, so the calendar lists
+// all-day events by the local dates. It is taken from $TZ or the link of
+// /etc/localtime, empty if neither names one.
+func localZone() string {
+	tz, ok := os.LookupEnv("TZ")
+	if !ok {
+		if tz, ok = zoneName("/etc/localtime"); !ok {
+			return ""
+		}
+	}
+	tz = strings.TrimPrefix(tz, ":")
+	if tz == "" {
+		return "UTC"
+	}
+	if name, ok := zoneName(tz); ok {
+		return name
+	}
+	return tz
+}
+
+// zoneName returns the zone of a zoneinfo path, following a link.
+func zoneName(path string) (string, bool) {
+	if link, err := os.Readlink(path); err == nil {
+		path = link
+	}
+	_, name, ok := strings.Cut(path, "zoneinfo/")
+	return name, ok
+}
+
+// parseTime reads local times unless they have an offset.
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: wrong time %q, use %v", ErrUsage, value, strings.Join(timeLayouts, " or "))
+}
+
+// parseTags splits "a,b", the calendar normalizes the tags.
+func parseTags(value string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// parseReminders parses "15m,1h:email", every reminder is an offset before
+// the start with an optional channel.
+func parseReminders(value string) ([]model.Reminder, error) {
+	reminders := []model.Reminder{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		offset, channel, _ := strings.Cut(part, ":")
+		d, err := time.ParseDuration(offset)
+		if err != nil {
+			return nil, fmt.Errorf("%w: wrong reminder %q", ErrUsage, part)
+		}
+		reminders = append(reminders, model.Reminder{Offset: d, Channel: channel})
+	}
+	return reminders, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReminders(t *testing.T) {
+	reminders, err := parseReminders("15m, 1h:email,,")
+	require.NoError(t, err)
+	require.Equal(t, []model.Reminder{{Offset: 15 * time.Minute}, {Offset: time.Hour, Channel: "email"}}, reminders)
+	require.Equal(t, "15m,1h:email", formatReminders(reminders))
+
+	reminders, err = parseReminders("")
+	require.NoError(t, err)
+	require.Empty(t, reminders, "empty clears the reminders")
+
+	_, err = parseReminders("15m,soon:email")
+	require.ErrorIs(t, err, ErrUsage)
+}
+
+func TestParseID(t *testing.T) {
+	for _, args := range [][]string{{"5", "--title=T"}, {"--title=T", "5"}} {
+		var f eventFlags
+		flags := newFlagSet("update")
+		f.register(flags, 0)
+		id, err := parseID(flags, args)
+		require.NoError(t, err, args)
+		require.Equal(t, int64(5), id)
+		require.Equal(t, "T", f.title)
+	}
+
+	for _, args := range [][]string{{}, {"0"}, {"-1"}, {"five"}, {"5", "6"}, {"5", "--unknown"}} {
+		_, err := parseID(newFlagSet("get"), args)
+		require.ErrorIs(t, err, ErrUsage, args)
+	}
+}
+
+func TestEventFlagsApply(t *testing.T) {
+	parse := func(t *testing.T, event *model.Event, args ...string) error {
+		t.Helper()
+		var f eventFlags
+		flags := newFlagSet("update")
+		f.register(flags, 100)
+		require.NoError(t, flags.Parse(args))
+		return f.apply(flags, event)
+	}
+	start := time.Date(2023, 1, 2, 15, 0, 0, 0, time.Local)
+
+	t.Run("create", func(t *testing.T) {
+		var event model.Event
+		require.NoError(t, parse(t, &event, "--title=Standup", "--start=2023-01-02T15:00", "--duration=15m",
+			"--remind=5m:email", "--tags=work, daily"))
+		require.Equal(t, model.Event{
+			UserID:    100,
+			Title:     "Standup",
+			OnTime:    start,
+			OffTime:   start.Add(15 * time.Minute),
+			Reminders: []model.Reminder{{Offset: 5 * time.Minute, Channel: "email"}},
+			Tags:      []string{"work", "daily"},
+		}, event)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		event := model.Event{
+			UserID: 200, Title: "Standup", Description: "Daily", OnTime: start, OffTime: start.Add(time.Hour),
+			Tags: []string{"work"},
+		}
+		require.NoError(t, parse(t, &event, "--start=2023-01-03T10:00", "--tags="))
+		require.Equal(t, int64(200), event.UserID, "user is kept unless set")
+		require.Equal(t, "Standup", event.Title)
+		require.Equal(t, "Daily", event.Description)
+		require.Equal(t, time.Date(2023, 1, 3, 10, 0, 0, 0, time.Local), event.OnTime)
+		require.Equal(t, time.Hour, event.OffTime.Sub(event.OnTime), "moved event keeps its length")
+		require.Empty(t, event.Tags)
+
+		require.NoError(t, parse(t, &event, "--end=2023-01-03T12:00", "--user=300"))
+		require.Equal(t, time.Date(2023, 1, 3, 12, 0, 0, 0, time.Local), event.OffTime)
+		require.Equal(t, int64(300), event.UserID)
+	})
+
+	t.Run("all_day", func(t *testing.T) {
+		var event model.Event
+		require.NoError(t, parse(t, &event, "--title=Vacation", "--allday", "--start=2023-01-02"))
+		require.True(t, event.AllDay)
+		require.Equal(t, time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC), event.OnTime)
+		require.Equal(t, time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC), event.OffTime, "single day")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		event := model.Event{OnTime: start, OffTime: start.Add(time.Hour)}
+		require.ErrorIs(t, parse(t, &event, "--end=2023-01-02T16:00", "--duration=1h"), ErrUsage)
+		require.ErrorIs(t, parse(t, &event, "--start=tomorrow"), ErrUsage)
+		require.ErrorIs(t, parse(t, &event, "--remind=soon"), ErrUsage)
+	})
+}
+
+func TestLocalZone(t *testing.T) {
+	for tz, want := range map[string]string{
+		"Asia/Tokyo":                     "Asia/Tokyo",
+		":Europe/Moscow":                 "Europe/Moscow",
+		"/usr/share/zoneinfo/Asia/Tokyo": "Asia/Tokyo",
+		"":                               "UTC",
+	} {
+		t.Setenv("TZ", tz)
+		require.Equal(t, want, localZone(), tz)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	envPrefix      = "CALENDARCTL"
+	defaultProfile = "default"
+	defaultAddr    = "localhost:10000"
+	defaultTimeout = 10 * time.Second
+)
+
+var ErrNoProfile = errors.New("no such profile")
+
+// Profile keeps the connection settings of one calendar service.
+type Profile struct {
+	Addr       string        `toml:"addr"`
+	TLS        bool          `toml:"tls"`
+	CA         string        `toml:"ca"`
+	Cert       string        `toml:"cert"`
+	Key        string        `toml:"key"`
+	ServerName string        `toml:"server_name"`
+	Token      string        `toml:"token"`
+	TokenFile  string        `toml:"token_file"`
+	Timeout    time.Duration `toml:"timeout"`
+	User       int64         `toml:"user"`
+	Output     string        `toml:"output"`
+}
+
+// Profiles is the profile file, e.g. ~/.config/calendarctl/config.toml:
+//
+//	current = "prod"
+//
+//	[profiles.prod]
+//	addr = "calendar.example.com:10000"
+//	tls = true
+//	ca = "/etc/calendar/tls/ca.crt"
+//	token_file = "/etc/calendar/token"
+//	user = 200
+type Profiles struct {
+	Current  string             `toml:"current"`
+	Profiles map[string]Profile `toml:"profiles"`
+}
+
+func defaultProfilesFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "calendarctl", "config.toml")
+}
+
+// loadProfile reads the named profile, the current one of the file if name
+// is empty. A missing default file means the default settings.
+func loadProfile(filename, name string, required bool) (Profile, error) {
+	var profiles Profiles
+	if _, err := toml.DecodeFile(filename, &profiles); err != nil {
+		if !required && errors.Is(err, fs.ErrNotExist) {
+			return Profile{}, nil
+		}
+		return Profile{}, err
+	}
+
+	if name == "" {
+		name = profiles.Current
+	}
+	if name == "" {
+		name = defaultProfile
+	}
+	profile, ok := profiles.Profiles[name]
+	if !ok && (name != defaultProfile || len(profiles.Profiles) > 0) {
+		return Profile{}, fmt.Errorf("%w: %v", ErrNoProfile, name)
+	}
+	return profile, nil
+}
+
+func (p Profile) addr() string {
+	if p.Addr == "" {
+		return defaultAddr
+	}
+	return p.Addr
+}
+
+func (p Profile) timeout() time.Duration {
+	if p.Timeout == 0 {
+		return defaultTimeout
+	}
+	return p.Timeout
+}
+
+func (p Profile) output() string {
+	if p.Output == "" {
+		return outputTable
+	}
+	return p.Output
+}
+
+func (p Profile) secure() bool {
+	return p.TLS || p.CA != "" || p.Cert != ""
+}
+
+// token is read from the file on every run, so it may be rotated.
+func (p Profile) token() (string, error) {
+	if p.Token != "" || p.TokenFile == "" {
+		return p.Token, nil
+	}
+	data, err := os.ReadFile(p.TokenFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
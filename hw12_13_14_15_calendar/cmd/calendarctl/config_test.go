@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadProfile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.toml")
+	require.NoError(t, os.WriteFile(filename, []byte(`
+current = "prod"
+
+[profiles.prod]
+addr = "calendar.example.com:10000"
+tls = true
+token_file = "/etc/calendar/token"
+timeout = "30s"
+user = 200
+
+[profiles.dev]
+user = 100
+`), 0o600))
+
+	t.Run("current", func(t *testing.T) {
+		profile, err := loadProfile(filename, "", true)
+		require.NoError(t, err)
+		require.Equal(t, Profile{
+			Addr: "calendar.example.com:10000", TLS: true, TokenFile: "/etc/calendar/token",
+			Timeout: 30 * time.Second, User: 200,
+		}, profile)
+	})
+
+	t.Run("named", func(t *testing.T) {
+		profile, err := loadProfile(filename, "dev", true)
+		require.NoError(t, err)
+		require.Equal(t, Profile{User: 100}, profile)
+		require.Equal(t, defaultAddr, profile.addr())
+		require.Equal(t, defaultTimeout, profile.timeout())
+		require.Equal(t, outputTable, profile.output())
+		require.False(t, profile.secure())
+	})
+
+	t.Run("no_profile", func(t *testing.T) {
+		_, err := loadProfile(filename, "stage", false)
+		require.ErrorIs(t, err, ErrNoProfile)
+	})
+
+	t.Run("no_file", func(t *testing.T) {
+		missing := filepath.Join(dir, "missing.toml")
+		profile, err := loadProfile(missing, "", false)
+		require.NoError(t, err, "missing default file means the default settings")
+		require.Equal(t, Profile{}, profile)
+
+		_, err = loadProfile(missing, "", true)
+		require.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("empty_file", func(t *testing.T) {
+		empty := filepath.Join(dir, "empty.toml")
+		require.NoError(t, os.WriteFile(empty, nil, 0o600))
+		profile, err := loadProfile(empty, "", true)
+		require.NoError(t, err)
+		require.Equal(t, Profile{}, profile)
+	})
+}
+
+func TestOverrideProfile(t *testing.T) {
+	profile := Profile{Addr: "calendar.example.com:10000", TLS: true, TokenFile: "/etc/calendar/token", User: 200}
+
+	var flagProfile Profile
+	flags := flag.NewFlagSet("calendarctl", flag.ContinueOnError)
+	flagProfile.register(flags)
+	require.NoError(t, flags.Parse([]string{"-token=secret", "-user=300", "-o=json"}))
+	overrideProfile(flags, &profile, flagProfile)
+
+	require.Equal(t, Profile{
+		Addr: "calendar.example.com:10000", TLS: true, Token: "secret", User: 300, Output: outputJSON,
+	}, profile, "only the given flags override the profile, -token drops token_file")
+}
+
+func TestProfileToken(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(filename, []byte("secret\n"), 0o600))
+
+	token, err := Profile{TokenFile: filename}.token()
+	require.NoError(t, err)
+	require.Equal(t, "secret", token)
+
+	token, err = Profile{Token: "inline", TokenFile: filename}.token()
+	require.NoError(t, err)
+	require.Equal(t, "inline", token)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+var (
+	ErrCA            = errors.New("no certificates found")
+	ErrInsecureToken = errors.New("token is sent without TLS to loopback addresses only")
+)
+
+// tokenAuth sends the token as "authorization: Bearer <token>" with every
+// call. It is allowed without TLS for local development only, see dial.
+type tokenAuth struct {
+	token  string
+	secure bool
+}
+
+func (t tokenAuth) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + t.token}, nil
+}
+
+func (t tokenAuth) RequireTransportSecurity() bool {
+	return t.secure
+}
+
+func dial(p Profile) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if p.secure() {
+		conf, err := tlsConfig(p)
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(conf)
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+
+	token, err := p.token()
+	if err != nil {
+		return nil, fmt.Errorf("can't read token: %w", err)
+	}
+	if token != "" && !p.secure() && !isLoopback(p.addr()) {
+		return nil, fmt.Errorf("%w, use -tls for %v", ErrInsecureToken, p.addr())
+	}
+	if token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenAuth{token: token, secure: p.secure()}))
+	}
+	return grpc.Dial(p.addr(), opts...)
+}
+
+// isLoopback reports whether addr is localhost or a loopback IP, with or
+// without a port.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// tlsConfig verifies the server by ca, the system roots if it is empty, and
+// presents the client certificate if it is set.
+func tlsConfig(p Profile) (*tls.Config, error) {
+	conf := &tls.Config{ServerName: p.ServerName, MinVersion: tls.VersionTLS12}
+
+	if p.CA != "" {
+		pem, err := os.ReadFile(p.CA)
+		if err != nil {
+			return nil, err
+		}
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w in %v", ErrCA, p.CA)
+		}
+	}
+
+	if p.Cert != "" || p.Key != "" {
+		cert, err := tls.LoadX509KeyPair(p.Cert, p.Key)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsLoopback(t *testing.T) {
+	for _, addr := range []string{"localhost:10000", "127.0.0.1:10000", "[::1]:10000", "127.0.0.2", "localhost"} {
+		require.True(t, isLoopback(addr), addr)
+	}
+	for _, addr := range []string{"calendar.example.com:10000", "10.0.0.1:10000", "[::]:10000", "0.0.0.0:10000"} {
+		require.False(t, isLoopback(addr), addr)
+	}
+}
+
+func TestDialToken(t *testing.T) {
+	_, err := dial(Profile{Addr: "calendar.example.com:10000", Token: "secret"})
+	require.ErrorIs(t, err, ErrInsecureToken)
+
+	for _, p := range []Profile{
+		{Addr: "calendar.example.com:10000"},
+		{Addr: "localhost:10000", Token: "secret"},
+		{Addr: "calendar.example.com:10000", Token: "secret", TLS: true},
+	} {
+		conn, err := dial(p)
+		require.NoError(t, err, p.Addr)
+		require.NoError(t, conn.Close())
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	api "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/api/stub"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/app"
+	"google.golang.org/grpc/status"
+)
+
+const usage = `usage: calendarctl [flags] command [args]
+
+commands:
+  create --user=ID --title=T --start=TIME (--end=TIME | --duration=D) [--description=D] [--remind=15m,1h:email]
+         [--tags=a,b]
+  create --user=ID --title=T --allday --start=DATE [--end=DATE] ...
+  update ID [--title=T] [--description=D] [--start=TIME] [--end=TIME | --duration=D] [--remind=R] [--tags=a,b]
+         [--allday=true|false]
+  delete ID
+  get ID
+  list [--user=ID] [--day | --week | --month] [--date=TIME] [--any=a,b] [--all=a,b]
+  tags [--user=ID]
+  version
+
+TIME is local unless it has an offset: 2023-01-02T15:04:05+03:00, 2023-01-02T15:04 or 2023-01-02.
+All-day events take the dates from start to the day before end, a single day if end is not set
+or is the start date. Their reminders count from midnight UTC of the first date.
+Flags override the profile, the profile is chosen by -profile, $CALENDARCTL_PROFILE or "current"
+of the profile file. The token may also be set by $CALENDARCTL_TOKEN, it is sent without TLS
+to localhost only.
+
+flags:
+`
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	var (
+		flagProfile Profile
+		configFile  string
+		profileName string
+	)
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.StringVar(&configFile, "config", os.Getenv(envPrefix+"_CONFIG"), "profile file, ~/.config/calendarctl/config.toml by default")
+	flag.StringVar(&profileName, "profile", os.Getenv(envPrefix+"_PROFILE"), "profile name")
+	flagProfile.register(flag.CommandLine)
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return 2
+	}
+	if flag.Arg(0) == "version" {
+		app.PrintVersion()
+		return 0
+	}
+
+	required := configFile != ""
+	if !required {
+		configFile = defaultProfilesFile()
+	}
+	profile, err := loadProfile(configFile, profileName, required || profileName != "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Can't load profile:%v\n", err)
+		return 2
+	}
+	if token := os.Getenv(envPrefix + "_TOKEN"); token != "" {
+		profile.Token = token
+	}
+	overrideProfile(flag.CommandLine, &profile, flagProfile)
+	if !isOutput(profile.output()) {
+		fmt.Fprintf(os.Stderr, "Wrong output %q, use one of %v\n", profile.Output, strings.Join(outputs, ", "))
+		return 2
+	}
+
+	conn, err := dial(profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Can't connect to %v:%v\n", profile.addr(), err)
+		return 1
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), profile.timeout())
+	defer cancel()
+
+	cmd := command{client: api.NewCalendarClient(conn), profile: profile, out: os.Stdout}
+	if err := cmd.run(ctx, flag.Arg(0), flag.Args()[1:]); err != nil {
+		if errors.Is(err, ErrUsage) {
+			fmt.Fprintf(os.Stderr, "%v\n\n", err)
+			flag.Usage()
+			return 2
+		}
+		if s, ok := status.FromError(err); ok {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", s.Code(), s.Message())
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return 1
+	}
+	return 0
+}
+
+// register adds the flags of the profile fields, overrideProfile copies the
+// ones given to the profile.
+func (p *Profile) register(flags *flag.FlagSet) {
+	flags.StringVar(&p.Addr, "addr", defaultAddr, "gRPC address of the calendar")
+	flags.BoolVar(&p.TLS, "tls", false, "connect with TLS, implied by -ca and -cert")
+	flags.StringVar(&p.CA, "ca", "", "CA certificate of the server, system roots by default")
+	flags.StringVar(&p.Cert, "cert", "", "client certificate")
+	flags.StringVar(&p.Key, "key", "", "key of the client certificate")
+	flags.StringVar(&p.ServerName, "server-name", "", "server name to verify, the host of -addr by default")
+	flags.StringVar(&p.Token, "token", "", "bearer token")
+	flags.StringVar(&p.TokenFile, "token-file", "", "file with the bearer token")
+	flags.DurationVar(&p.Timeout, "timeout", defaultTimeout, "request timeout")
+	flags.Int64Var(&p.User, "user", 0, "default user ID of create and list")
+	flags.StringVar(&p.Output, "o", outputTable, "output format: "+strings.Join(outputs, ", "))
+}
+
+// overrideProfile sets the fields of the flags given on the command line.
+func overrideProfile(set *flag.FlagSet, profile *Profile, flags Profile) {
+	set.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			profile.Addr = flags.Addr
+		case "tls":
+			profile.TLS = flags.TLS
+		case "ca":
+			profile.CA = flags.CA
+		case "cert":
+			profile.Cert = flags.Cert
+		case "key":
+			profile.Key = flags.Key
+		case "server-name":
+			profile.ServerName = flags.ServerName
+		case "token":
+			profile.Token, profile.TokenFile = flags.Token, ""
+		case "token-file":
+			profile.Token, profile.TokenFile = "", flags.TokenFile
+		case "timeout":
+			profile.Timeout = flags.Timeout
+		case "user":
+			profile.User = flags.User
+		case "o":
+			profile.Output = flags.Output
+		}
+	})
+}
+
+func isOutput(output string) bool {
+	for _, o := range outputs {
+		if o == output {
+			return true
+		}
+	}
+	return false
+}
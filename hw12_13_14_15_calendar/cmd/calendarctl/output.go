@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/archive"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputICS   = "ics"
+
+	tableTime = "2006-01-02 15:04"
+	tableDate = "2006-01-02"
+)
+
+var outputs = []string{outputTable, outputJSON, outputICS}
+
+// printEvents writes the events in the format, a single event of get is
+// printed as a JSON object rather than an array.
+func printEvents(w io.Writer, format string, events []model.Event, single bool) error {
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if single && len(events) == 1 {
+			return enc.Encode(events[0])
+		}
+		return enc.Encode(events)
+
+	case outputICS:
+		return archive.WriteICS(w, time.Now(), events)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tUSER\tSTART\tEND\tTITLE\tREMINDERS\tTAGS")
+	for _, e := range events {
+		start, end := e.OnTime.Local().Format(tableTime), e.OffTime.Local().Format(tableTime)
+		if e.AllDay {
+			start, end = e.OnTime.UTC().Format(tableDate), e.OffTime.UTC().Format(tableDate)
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", e.ID, e.UserID, start, end, e.Title,
+			formatReminders(e.Reminders), strings.Join(e.Tags, ","))
+	}
+	return tw.Flush()
+}
+
+// printTags writes the tag counts, ics has no tag listing and falls back to table.
+func printTags(w io.Writer, format string, tags []model.TagCount) error {
+	if format == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(tags)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TAG\tEVENTS")
+	for _, t := range tags {
+		fmt.Fprintf(tw, "%v\t%v\n", t.Tag, t.Count)
+	}
+	return tw.Flush()
+}
+
+func printID(w io.Writer, format string, id int64) error {
+	if format == outputJSON {
+		return json.NewEncoder(w).Encode(struct {
+			ID int64 `json:"id"`
+		}{ID: id})
+	}
+	_, err := fmt.Fprintln(w, id)
+	return err
+}
+
+// formatReminders is the inverse of parseReminders, e.g. "15m,1h:email".
+func formatReminders(reminders []model.Reminder) string {
+	parts := make([]string, len(reminders))
+	for i, r := range reminders {
+		parts[i] = shortDuration(r.Offset)
+		if r.Channel != "" {
+			parts[i] += ":" + r.Channel
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// shortDuration drops zero units, 1h0m0s is 1h.
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintEvents(t *testing.T) {
+	start := time.Date(2023, 1, 2, 15, 0, 0, 0, time.Local)
+	events := []model.Event{
+		{
+			ID: 1, UserID: 100, Title: "Standup", OnTime: start, OffTime: start.Add(15 * time.Minute),
+			Reminders: []model.Reminder{{Offset: 5 * time.Minute}, {Offset: 90 * time.Minute, Channel: "email"}},
+			Tags:      []string{"work", "daily"},
+		},
+		{
+			ID: 2, UserID: 100, Title: "Vacation", AllDay: true,
+			OnTime:  time.Date(2023, 1, 9, 0, 0, 0, 0, time.UTC),
+			OffTime: time.Date(2023, 1, 14, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	t.Run("table", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, printEvents(&out, outputTable, events, false))
+		require.Equal(t, ""+
+			"ID  USER  START             END               TITLE     REMINDERS       TAGS\n"+
+			"1   100   2023-01-02 15:00  2023-01-02 15:15  Standup   5m,1h30m:email  work,daily\n"+
+			"2   100   2023-01-09        2023-01-14        Vacation                  \n", out.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, printEvents(&out, outputJSON, events, false))
+		var list []model.Event
+		require.NoError(t, json.Unmarshal(out.Bytes(), &list))
+		require.Len(t, list, 2)
+
+		out.Reset()
+		require.NoError(t, printEvents(&out, outputJSON, events[:1], true))
+		var event model.Event
+		require.NoError(t, json.Unmarshal(out.Bytes(), &event), "single event of get is an object")
+		require.Equal(t, "Standup", event.Title)
+	})
+
+	t.Run("ics", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, printEvents(&out, outputICS, events, false))
+		require.Contains(t, out.String(), "BEGIN:VCALENDAR")
+		require.Contains(t, out.String(), "SUMMARY:Vacation")
+	})
+}
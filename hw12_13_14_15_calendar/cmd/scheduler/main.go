@@ -1,23 +1,45 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/app"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/logger"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/server/health"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/storage"
-	internalrmq "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/rabbitmq"
 )
 
 func main() {
 	conf := NewConfig().SchedulerConf
+	switch flag.Arg(0) {
+	case "run-once":
+		os.Exit(runOnce(conf, flag.Args()[1:]))
+	case "restore":
+		os.Exit(restore(conf, flag.Args()[1:]))
+	}
+
 	storage := storage.NewStorage(conf.Storage)
 	logger := logger.NewLogger(conf.Logger.Level, os.Stdout)
-	producer := internalrmq.NewProducer(logger, conf.URLRMQ)
+	producer, brokerHealth := conf.NewProducer(logger)
 	scheduler := app.NewScheduler(logger, conf, storage, producer)
 
+	scheduler.SetConfLoader(func() (app.SchedulerConf, error) {
+		conf, err := LoadConfig()
+		return conf.SchedulerConf, err
+	})
+
+	if conf.HealthAddr != "" {
+		healthsrv := health.NewServer(logger, conf.HealthAddr, map[string]health.Check{
+			conf.Name(): brokerHealth,
+		})
+		healthsrv.Start()
+		defer healthsrv.Stop(context.Background())
+	}
+
 	scheduler.Run()
 
 	filename := filepath.Base(os.Args[0])
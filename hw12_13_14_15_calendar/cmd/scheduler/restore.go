@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/app"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/archive"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/logger"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/storage"
+	internalrmq "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/rabbitmq"
+)
+
+const restoreUsage = "usage: calendar_scheduler [-config=file] restore archive.jsonl.gz..."
+
+// restore inserts the events of archives written by the cleanup job again,
+// e.g. after a too short retention:
+//
+//	calendar_scheduler restore /var/lib/calendar/archive/events-20230102T150405Z-42.jsonl.gz
+//
+// Only jsonl archives can be restored, ics ones are for calendar apps.
+func restore(conf app.SchedulerConf, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, restoreUsage)
+		return 2
+	}
+	for _, filename := range args {
+		if !strings.HasSuffix(filename, ".jsonl.gz") {
+			fmt.Fprintf(os.Stderr, "Can't restore %v: not a jsonl archive\n%v\n", filename, restoreUsage)
+			return 2
+		}
+	}
+
+	log := logger.NewLogger(conf.Logger.Level, os.Stderr)
+	scheduler := app.NewScheduler(log, conf, storage.NewStorage(conf.Storage), internalrmq.NewDummyProducer())
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	var err error
+	for _, filename := range args {
+		var restored int64
+		restored, err = restoreFile(ctx, scheduler, filename)
+		fmt.Printf("%v: %v events restored\n", filename, restored)
+		if err != nil {
+			break
+		}
+	}
+
+	ctxStop, cancelStop := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelStop()
+	scheduler.Stop(ctxStop)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Can't restore events:%v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func restoreFile(ctx context.Context, scheduler *app.Scheduler, filename string) (int64, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	events, err := archive.ReadJSONL(f)
+	if err != nil {
+		return 0, fmt.Errorf("%v:%w", filename, err)
+	}
+	return scheduler.RestoreEvents(ctx, time.Now(), events)
+}
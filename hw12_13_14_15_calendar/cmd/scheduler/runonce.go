@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/app"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/logger"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/storage"
+	internalrmq "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/rabbitmq"
+)
+
+const runOnceUsage = "usage: calendar_scheduler [-config=file] run-once [--at=time] [--dry-run]"
+
+var atLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"}
+
+// runOnce runs the scheduler jobs once and exits, e.g. from cron:
+//
+//	calendar_scheduler run-once [--at=2023-01-02T15:04] [--dry-run]
+//
+// The time is local unless it has an offset, it is now by default.
+func runOnce(conf app.SchedulerConf, args []string) int {
+	flags := flag.NewFlagSet("run-once", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	at := flags.String("at", "", "reference time")
+	dryRun := flags.Bool("dry-run", false, "print what would be sent or deleted")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, runOnceUsage)
+		return 2
+	}
+
+	date := time.Now()
+	if *at != "" {
+		var err error
+		if date, err = parseTime(*at); err != nil {
+			fmt.Fprintf(os.Stderr, "Wrong time %q:%v\n%v\n", *at, err, runOnceUsage)
+			return 2
+		}
+	}
+
+	// a node ID of its own, so the lease of a scheduler sharing the config
+	// isn't renewed and then released by this run
+	conf.NodeID = ""
+	log := logger.NewLogger(conf.Logger.Level, os.Stderr)
+	var producer app.SchedulerProducer = internalrmq.NewDummyProducer()
+	if !*dryRun {
+		producer, _ = conf.NewProducer(log)
+	}
+	scheduler := app.NewScheduler(log, conf, storage.NewStorage(conf.Storage), producer)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	err := scheduler.RunOnce(ctx, date, *dryRun, os.Stdout)
+
+	ctxStop, cancelStop := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelStop()
+	scheduler.Stop(ctxStop)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Can't run jobs:%v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func parseTime(value string) (time.Time, error) {
+	var err error
+	for _, layout := range atLayouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
@@ -5,14 +5,19 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/BurntSushi/toml"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/app"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+	internalnats "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/nats"
+	internalrmq "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/rabbitmq"
 )
 
+const envPrefix = "CALENDAR_SENDER"
+
 var configFile string
 
 func init() {
-	flag.StringVar(&configFile, "config", "/etc/calendar/sender_config.toml", "Path to configuration file")
+	flag.StringVar(&configFile, "config", "/etc/calendar/sender_config.toml",
+		"Path to configuration file (toml, yaml or json)")
 	flag.Parse()
 
 	if flag.Arg(0) == "version" {
@@ -26,19 +31,23 @@ type Config struct {
 }
 
 func NewConfig() Config {
-	var config Config
-	if err := config.LoadFileTOML(configFile); err != nil {
+	conf, err := LoadConfig()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Can't load config file:%v error: %v\n", configFile, err)
 		os.Exit(1)
 	}
-	fmt.Println("Config:", config)
-	return config
+	fmt.Println("Config:", conf)
+	return conf
 }
 
-func (c *Config) LoadFileTOML(filename string) error {
-	filedata, err := os.ReadFile(filename)
-	if err != nil {
-		return err
-	}
-	return toml.Unmarshal(filedata, c)
+func LoadConfig() (Config, error) {
+	var conf Config
+	conf.RabbitMQ = internalrmq.DefaultConf()
+	conf.NATS = internalnats.DefaultConf()
+	err := conf.Load(configFile)
+	return conf, err
+}
+
+func (c *Config) Load(filename string) error {
+	return config.Load(filename, envPrefix, c)
 }
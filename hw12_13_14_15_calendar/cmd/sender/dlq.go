@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/app"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/logger"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/codec"
+)
+
+const dlqUsage = "usage: calendar_sender [-config=file] dlq list|requeue [limit]"
+
+// runDLQ lists or requeues dead-lettered notifications:
+//
+//	calendar_sender dlq list [limit]
+//	calendar_sender dlq requeue [limit]
+func runDLQ(conf app.SenderConf, args []string) int {
+	if len(args) == 0 || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, dlqUsage)
+		return 2
+	}
+
+	limit := 0
+	if len(args) == 2 {
+		var err error
+		if limit, err = strconv.Atoi(args[1]); err != nil || limit < 0 {
+			fmt.Fprintln(os.Stderr, dlqUsage)
+			return 2
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	log := logger.NewLogger(conf.Logger.Level, os.Stderr)
+	dlq := conf.NewDeadLetters(log)
+	if err := dlq.Connect(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Can't connect to %v:%v\n", conf.Name(), err)
+		return 1
+	}
+	defer dlq.Close(ctx)
+
+	switch args[0] {
+	case "list":
+		letters, err := dlq.List(ctx, limit)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Can't list dead letters:%v\n", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "MESSAGE ID\tRETRIES\tREASON\tBODY")
+		for _, l := range letters {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%s\n", l.MessageID, l.Retries, l.Reason, letterBody(l))
+		}
+		w.Flush()
+	case "requeue":
+		requeued, err := dlq.Requeue(ctx, limit)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Can't requeue dead letters:%v\n", err)
+			return 1
+		}
+		fmt.Printf("Requeued: %v\n", requeued)
+	default:
+		fmt.Fprintln(os.Stderr, dlqUsage)
+		return 2
+	}
+	return 0
+}
+
+// letterBody shows protobuf messages as JSON, others as they are.
+func letterBody(l codec.DeadLetter) []byte {
+	contentType, err := codec.ContentType(l.ContentType)
+	if err != nil || contentType == codec.ContentTypeJSON {
+		return l.Body
+	}
+	msg, err := codec.Unmarshal(l.Body, contentType, l.Version)
+	if err != nil {
+		return l.Body
+	}
+	body, err := codec.Marshal(msg, codec.ContentTypeJSON)
+	if err != nil {
+		return l.Body
+	}
+	return body
+}
@@ -1,23 +1,42 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/app"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/logger"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/server/health"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/storage"
-	internalrmq "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/rabbitmq"
 )
 
 func main() {
 	conf := NewConfig().SenderConf
+	if flag.Arg(0) == "dlq" {
+		os.Exit(runDLQ(conf, flag.Args()[1:]))
+	}
+
 	storage := storage.NewStorage(conf.Storage)
 	logger := logger.NewLogger(conf.Logger.Level, os.Stdout)
-	consumer := internalrmq.NewConsumer(logger, conf.URLRMQ)
+	consumer, brokerHealth := conf.NewConsumer(logger)
 	sender := app.NewSender(logger, conf, storage, consumer)
 
+	sender.SetConfLoader(func() (app.SenderConf, error) {
+		conf, err := LoadConfig()
+		return conf.SenderConf, err
+	})
+
+	if conf.HealthAddr != "" {
+		healthsrv := health.NewServer(logger, conf.HealthAddr, map[string]health.Check{
+			conf.Name(): brokerHealth,
+		})
+		healthsrv.Start()
+		defer healthsrv.Stop(context.Background())
+	}
+
 	sender.Run()
 	filename := filepath.Base(os.Args[0])
 	fmt.Printf("%s stopped\n", filename)
@@ -0,0 +1,278 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/blob"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/jobs"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+)
+
+const (
+	defaultAttachmentMaxSize   = 25 << 20
+	defaultAttachmentQuota     = 1 << 30
+	defaultAttachmentOrphanAge = time.Hour
+	defaultAttachmentSweep     = "@every 1h"
+	defaultContentType         = "application/octet-stream"
+	maxAttachmentName          = 255
+	jobSweepAttachments        = "sweep-attachments"
+)
+
+// AttachmentsConf configures the attachments of events, they are disabled
+// while the blob store is not set.
+type AttachmentsConf struct {
+	blob.Conf
+	// MaxSize limits a single attachment, Quota all attachments of a user.
+	MaxSize int64 `toml:"max_size"`
+	Quota   int64 `toml:"quota"`
+	// OrphanAge keeps unreferenced blobs that long, so the sweep does not
+	// take the blobs of uploads in progress.
+	OrphanAge time.Duration `toml:"orphan_age"`
+	Sweep     jobs.Conf     `toml:"sweep"`
+}
+
+func (c AttachmentsConf) Check(v *config.Validator) {
+	c.Conf.Check(v)
+	v.Range("max_size", c.MaxSize, 0, math.MaxInt64)
+	v.Range("quota", c.Quota, 0, math.MaxInt64)
+	v.NotNegative("orphan_age", c.OrphanAge)
+	c.Sweep.Check(v.Section("sweep"))
+}
+
+func (c AttachmentsConf) maxSize() int64 {
+	if c.MaxSize == 0 {
+		return defaultAttachmentMaxSize
+	}
+	return c.MaxSize
+}
+
+func (c AttachmentsConf) quota() int64 {
+	if c.Quota == 0 {
+		return defaultAttachmentQuota
+	}
+	return c.Quota
+}
+
+func (c AttachmentsConf) orphanAge() time.Duration {
+	if c.OrphanAge == 0 {
+		return defaultAttachmentOrphanAge
+	}
+	return c.OrphanAge
+}
+
+func (c *Calendar) attachmentsConf() AttachmentsConf {
+	c.confMu.RLock()
+	defer c.confMu.RUnlock()
+	return c.conf.Attachments
+}
+
+func (c *Calendar) checkAttachment(name, contentType string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("%w: empty", ErrAttachmentName)
+	case utf8.RuneCountInString(name) > maxAttachmentName:
+		return fmt.Errorf("%w: must be <=%v characters", ErrAttachmentName, maxAttachmentName)
+	case strings.ContainsAny(name, `/\`) || strings.IndexFunc(name, unicode.IsControl) >= 0:
+		return fmt.Errorf("%w: %q", ErrAttachmentName, name)
+	}
+	if _, _, err := mime.ParseMediaType(contentType); err != nil {
+		return fmt.Errorf("%w: %v", ErrContentType, err)
+	}
+	return nil
+}
+
+// UploadAttachment stores the content of r as an attachment of the event.
+// The content is read only up to the size the user still has, so a too large
+// upload is stopped early.
+func (c *Calendar) UploadAttachment(ctx context.Context, eventID int64, name, contentType string, r io.Reader,
+) (model.Attachment, error) {
+	if c.blobs == nil {
+		return model.Attachment{}, ErrAttachmentsDisabled
+	}
+	if contentType == "" {
+		contentType = defaultContentType
+	}
+	name = strings.TrimSpace(name)
+	if err := c.checkAttachment(name, contentType); err != nil {
+		return model.Attachment{}, err
+	}
+	event, err := c.LookupEvent(ctx, eventID)
+	if err != nil {
+		return model.Attachment{}, err
+	}
+
+	conf := c.attachmentsConf()
+	used, err := c.userAttachmentsSize(ctx, event.UserID)
+	if err != nil {
+		return model.Attachment{}, err
+	}
+	limit, errLimit := conf.maxSize(), ErrAttachmentSize
+	if left := conf.quota() - used; left < limit {
+		limit, errLimit = left, model.ErrQuotaExceeded
+	}
+	if limit < 0 {
+		limit = 0
+	}
+
+	key := blob.NewKey()
+	hash := sha256.New()
+	size, err := c.blobs.Put(ctx, key, io.TeeReader(io.LimitReader(r, limit+1), hash))
+	if err != nil {
+		return model.Attachment{}, fmt.Errorf("can't store attachment: %w", err)
+	}
+	if size > limit {
+		c.deleteBlob(key)
+		return model.Attachment{}, fmt.Errorf("%w: must be <=%v bytes", errLimit, limit)
+	}
+
+	a := model.Attachment{
+		EventID:     event.ID,
+		UserID:      event.UserID,
+		Name:        name,
+		Size:        size,
+		ContentType: contentType,
+		Checksum:    hex.EncodeToString(hash.Sum(nil)),
+		Key:         key,
+		CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+	}
+	ctxStorage, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	if err := c.storage.InsertAttachment(ctxStorage, &a, conf.quota()); err != nil {
+		c.deleteBlob(key)
+		return model.Attachment{}, err
+	}
+	return a, nil
+}
+
+func (c *Calendar) userAttachmentsSize(ctx context.Context, userID int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	return c.storage.UserAttachmentsSize(ctx, userID)
+}
+
+// deleteBlob does not fail the request, blobs left behind are removed by
+// the sweep.
+func (c *Calendar) deleteBlob(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout())
+	defer cancel()
+	if err := c.blobs.Delete(ctx, key); err != nil {
+		c.log.Errorf("Can't delete blob %v:%v\n", key, err)
+	}
+}
+
+func (c *Calendar) LookupAttachment(ctx context.Context, id int64) (model.Attachment, error) {
+	if c.blobs == nil {
+		return model.Attachment{}, ErrAttachmentsDisabled
+	}
+	if id == 0 {
+		return model.Attachment{}, ErrID
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	return c.storage.LookupAttachment(ctx, id)
+}
+
+// OpenAttachment returns the attachment and its content, the caller closes it.
+func (c *Calendar) OpenAttachment(ctx context.Context, id int64) (model.Attachment, io.ReadCloser, error) {
+	a, err := c.LookupAttachment(ctx, id)
+	if err != nil {
+		return a, nil, err
+	}
+	content, err := c.blobs.Open(ctx, a.Key)
+	if err != nil {
+		return a, nil, fmt.Errorf("can't open attachment %v: %w", a.ID, err)
+	}
+	return a, content, nil
+}
+
+func (c *Calendar) ListAttachments(ctx context.Context, eventID int64) ([]model.Attachment, error) {
+	if c.blobs == nil {
+		return []model.Attachment{}, ErrAttachmentsDisabled
+	}
+	if eventID == 0 {
+		return []model.Attachment{}, ErrID
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	return c.storage.ListAttachments(ctx, eventID)
+}
+
+func (c *Calendar) DeleteAttachment(ctx context.Context, id int64) error {
+	a, err := c.LookupAttachment(ctx, id)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	if err := c.storage.DeleteAttachment(ctx, id); err != nil {
+		return err
+	}
+	c.deleteBlob(a.Key)
+	return nil
+}
+
+// SweepAttachments deletes the blobs older than the orphan age that no
+// attachment refers to, like the ones of events purged by the scheduler.
+func (c *Calendar) SweepAttachments(ctx context.Context, date time.Time) (int64, error) {
+	if c.blobs == nil {
+		return 0, ErrAttachmentsDisabled
+	}
+	before := date.Add(-c.attachmentsConf().orphanAge())
+	var deleted int64
+	err := c.blobs.Walk(ctx, func(key string, modTime time.Time) error {
+		if !modTime.Before(before) {
+			return nil
+		}
+		found, err := c.storage.HasAttachmentKey(ctx, key)
+		if err != nil || found {
+			return err
+		}
+		if err := c.blobs.Delete(ctx, key); err != nil {
+			return err
+		}
+		deleted++
+		return nil
+	})
+	return deleted, err
+}
+
+func (c *Calendar) sweepAttachments(ctx context.Context, date time.Time) error {
+	deleted, err := c.SweepAttachments(ctx, date)
+	if deleted > 0 {
+		c.log.Infof("Orphaned blobs deleted:%v\n", deleted)
+	}
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// Jobs returns the runner of the sweep of orphaned blobs, nil while
+// attachments are disabled.
+func (c *Calendar) Jobs() (*jobs.Runner, error) {
+	if c.blobs == nil {
+		return nil, nil
+	}
+	runner := jobs.NewRunner(c.log)
+	job, err := jobs.NewJob(jobSweepAttachments, c.attachmentsConf().Sweep, defaultAttachmentSweep,
+		c.sweepAttachments)
+	if err != nil {
+		return nil, fmt.Errorf("job %v: %w", jobSweepAttachments, err)
+	}
+	if err := runner.Add(job); err != nil {
+		return nil, err
+	}
+	return runner, nil
+}
@@ -5,34 +5,78 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/mail"
+	"net/url"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/blob"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
 	logger "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/logger"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/notifier"
+	internalgrpc "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/server/grpcservice"
+	internalhttp "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/server/http"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/server/ratelimit"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/storage"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
 )
 
+const (
+	defaultTimeout = 2 * time.Second
+	maxReminders   = 10
+	maxQuery       = 256
+	maxResults     = 50
+	maxTags        = 20
+	maxTagLen      = 32
+	maxFilterTags  = 50
+)
+
 type CalendarConf struct {
-	Logger  logger.Conf  `toml:"logger"`
-	Storage storage.Conf `toml:"storage"`
-	HTTP    struct {
-		Host string `toml:"host"`
-		Port string `toml:"port"`
-	} `toml:"http-server"`
-	GRPC struct {
-		Host string `toml:"host"`
-		Port string `toml:"port"`
-	} `toml:"grpc-server"`
+	Logger      logger.Conf            `toml:"logger"`
+	Storage     storage.Conf           `toml:"storage"`
+	Timeout     time.Duration          `toml:"timeout"`
+	HealthAddr  string                 `toml:"health_addr"`
+	HTTP        internalhttp.Conf      `toml:"http-server"`
+	GRPC        internalgrpc.Conf      `toml:"grpc-server"`
+	RateLimit   ratelimit.Conf         `toml:"ratelimit"`
+	Templates   notifier.TemplatesConf `toml:"templates"`
+	Attachments AttachmentsConf        `toml:"attachments"`
+}
+
+func (c CalendarConf) Validate() error {
+	v := &config.Validator{}
+	c.Logger.Check(v.Section("logger"))
+	c.Storage.Check(v.Section("storage"))
+	if c.HealthAddr != "" {
+		v.Addr("health_addr", c.HealthAddr)
+	}
+	v.Section("http-server").Port("port", c.HTTP.Port)
+	c.HTTP.Check(v.Section("http-server"))
+	v.Section("grpc-server").Port("port", c.GRPC.Port)
+	c.GRPC.Check(v.Section("grpc-server"))
+	c.RateLimit.Check(v.Section("ratelimit"))
+	c.Templates.Check(v.Section("templates"))
+	c.Attachments.Check(v.Section("attachments"))
+	v.NotNegative("timeout", c.Timeout)
+	return v.Err()
 }
 
 type Calendar struct {
-	conf    CalendarConf
-	log     Logger
-	storage CalendarStorage
+	conf      CalendarConf
+	confMu    sync.RWMutex
+	loadConf  func() (CalendarConf, error)
+	onReload  []func(CalendarConf)
+	log       Logger
+	storage   CalendarStorage
+	templates *notifier.Templates
+	blobs     blob.Store
 }
 
 type CalendarStorage interface {
@@ -42,9 +86,23 @@ type CalendarStorage interface {
 	UpdateEvent(context.Context, *model.Event) error
 	DeleteEvent(context.Context, int64) error
 	LookupEvent(context.Context, int64) (model.Event, error)
-	ListEvents(context.Context, int64) ([]model.Event, error)
-	ListEventsRange(context.Context, int64, time.Time, time.Time) ([]model.Event, error)
+	ListEvents(context.Context, int64, model.TagFilter) ([]model.Event, error)
+	ListEventsRange(context.Context, int64, time.Time, time.Time, model.TagFilter) ([]model.Event, error)
 	IsBusyDateTimeRange(context.Context, int64, int64, time.Time, time.Time) error
+	SearchEvents(
+		context.Context, int64, string, time.Time, time.Time, model.TagFilter, int,
+	) ([]model.SearchResult, error)
+	SetUserChannels(context.Context, int64, []model.UserChannel) error
+	ListUserChannels(context.Context, int64) ([]model.UserChannel, error)
+	SetUserRetention(context.Context, int64, time.Duration) error
+	LookupUserRetention(context.Context, int64) (time.Duration, error)
+	ListUserTags(context.Context, int64) ([]model.TagCount, error)
+	InsertAttachment(context.Context, *model.Attachment, int64) error
+	LookupAttachment(context.Context, int64) (model.Attachment, error)
+	ListAttachments(context.Context, int64) ([]model.Attachment, error)
+	DeleteAttachment(context.Context, int64) error
+	UserAttachmentsSize(context.Context, int64) (int64, error)
+	HasAttachmentKey(context.Context, string) (bool, error)
 }
 
 type Server interface {
@@ -52,6 +110,53 @@ type Server interface {
 	Stop(context.Context) error
 }
 
+func (c *Calendar) timeout() time.Duration {
+	c.confMu.RLock()
+	defer c.confMu.RUnlock()
+	if c.conf.Timeout == 0 {
+		return defaultTimeout
+	}
+	return c.conf.Timeout
+}
+
+func (c *Calendar) SetConfLoader(load func() (CalendarConf, error)) {
+	c.loadConf = load
+}
+
+// OnReload registers fn to apply the reloaded config to the parts
+// of the service outside of Calendar, like the rate limiter.
+func (c *Calendar) OnReload(fn func(CalendarConf)) {
+	c.onReload = append(c.onReload, fn)
+}
+
+// Reload re-reads the config and applies the log level, the timeout and the rate limits.
+func (c *Calendar) Reload() error {
+	if c.loadConf == nil {
+		return ErrNoConfLoader
+	}
+
+	conf, err := c.loadConf()
+	if err != nil {
+		return err
+	}
+
+	c.confMu.Lock()
+	defer c.confMu.Unlock()
+
+	err = checkReload(c.log, c.conf, conf, "logger.level", "timeout", "ratelimit.rate", "ratelimit.burst")
+	if err != nil {
+		return err
+	}
+	if err := setLogLevel(c.log, conf.Logger.Level); err != nil {
+		return err
+	}
+	c.conf = conf
+	for _, fn := range c.onReload {
+		fn(conf)
+	}
+	return nil
+}
+
 func (c *Calendar) checkBasicRules(e *model.Event, checkID bool) error {
 	if checkID && e.ID == 0 {
 		return fmt.Errorf("%w: zero", ErrID)
@@ -78,19 +183,77 @@ func (c *Calendar) checkBasicRules(e *model.Event, checkID bool) error {
 		return fmt.Errorf("%w: equal OnTime", ErrOffTime)
 	}
 
-	if !e.NotifyTime.IsZero() {
-		if e.NotifyTime.After(e.OffTime) {
-			return fmt.Errorf("%w: after OffTime", ErrNotifyTime)
+	if err := c.checkTags(e.Tags); err != nil {
+		return err
+	}
+
+	return c.checkReminders(e.Reminders)
+}
+
+// checkTags expects normalized tags, commas are not allowed as SQL storage
+// joins tags by them.
+func (c *Calendar) checkTags(tags []string) error {
+	if len(tags) > maxTags {
+		return fmt.Errorf("%w: must be <=%v tags", ErrTag, maxTags)
+	}
+	for _, tag := range tags {
+		if utf8.RuneCountInString(tag) > maxTagLen {
+			return fmt.Errorf("%w: %q must be <=%v characters", ErrTag, tag, maxTagLen)
 		}
+		for _, r := range tag {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_./ ", r) {
+				return fmt.Errorf("%w: %q has %q", ErrTag, tag, r)
+			}
+		}
+	}
+	return nil
+}
+
+// tagFilter normalizes the filter of a listing, the number of its tags is
+// limited as every one is a query parameter of SQL storage.
+func (c *Calendar) tagFilter(filter model.TagFilter) (model.TagFilter, error) {
+	filter = filter.Normalize()
+	if len(filter.Any)+len(filter.All) > maxFilterTags {
+		return filter, fmt.Errorf("%w: filter must have <=%v tags", ErrTag, maxFilterTags)
+	}
+	return filter, nil
+}
+
+func (c *Calendar) checkReminders(reminders []model.Reminder) error {
+	if len(reminders) > maxReminders {
+		return fmt.Errorf("%w: must be <=%v", ErrReminder, maxReminders)
 	}
 
+	type key struct {
+		offset  time.Duration
+		channel string
+	}
+	seen := map[key]bool{}
+	for _, r := range reminders {
+		switch {
+		case r.Offset < 0:
+			return fmt.Errorf("%w: offset %v is negative", ErrReminder, r.Offset)
+		case r.Offset%time.Second != 0:
+			return fmt.Errorf("%w: offset %v is not whole seconds", ErrReminder, r.Offset)
+		case r.Channel != "" && !notifier.Known(r.Channel):
+			return fmt.Errorf("%w: unknown channel %q", ErrReminder, r.Channel)
+		case seen[key{r.Offset, r.Channel}]:
+			return fmt.Errorf("%w: duplicate %v %q", ErrReminder, r.Offset, r.Channel)
+		}
+		seen[key{r.Offset, r.Channel}] = true
+	}
 	return nil
 }
 
-func (c *Calendar) isBusyDateTimeRange(ctx context.Context, id, userID int64, onTime, offTime time.Time) error {
-	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+// isBusyDateTimeRange checks that the event does not overlap others, all-day
+// events overlap nothing.
+func (c *Calendar) isBusyDateTimeRange(ctx context.Context, event *model.Event) error {
+	if event.AllDay {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
 	defer cancel()
-	return c.storage.IsBusyDateTimeRange(ctx, id, userID, onTime, offTime)
+	return c.storage.IsBusyDateTimeRange(ctx, event.ID, event.UserID, event.OnTime, event.OffTime)
 }
 
 func (c *Calendar) firstDayOfWeek(t time.Time) time.Time {
@@ -114,87 +277,279 @@ func (c *Calendar) Close(ctx context.Context) error {
 }
 
 func (c *Calendar) InsertEvent(ctx context.Context, event *model.Event) error {
+	event.Tags = model.NormalizeTags(event.Tags)
+	event.NormalizeDates()
 	if err := c.checkBasicRules(event, false); err != nil {
 		return err
 	}
 
-	if err := c.isBusyDateTimeRange(ctx, event.ID, event.UserID, event.OnTime, event.OffTime); err != nil {
+	if err := c.isBusyDateTimeRange(ctx, event); err != nil {
 		return err
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	event.ScheduleReminders()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
 	defer cancel()
 
 	return c.storage.InsertEvent(ctx, event)
 }
 
 func (c *Calendar) UpdateEvent(ctx context.Context, event *model.Event) error {
+	event.Tags = model.NormalizeTags(event.Tags)
+	event.NormalizeDates()
 	if err := c.checkBasicRules(event, true); err != nil {
 		return err
 	}
 
-	if err := c.isBusyDateTimeRange(ctx, event.ID, event.UserID, event.OnTime, event.OffTime); err != nil {
+	if err := c.isBusyDateTimeRange(ctx, event); err != nil {
 		return err
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	event.ScheduleReminders()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
 	defer cancel()
 	return c.storage.UpdateEvent(ctx, event)
 }
 
+// DeleteEvent deletes the blobs of the event's attachments too.
 func (c *Calendar) DeleteEvent(ctx context.Context, id int64) error {
-	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
 	defer cancel()
-	return c.storage.DeleteEvent(ctx, id)
+
+	var attachments []model.Attachment
+	if c.blobs != nil {
+		var err error
+		if attachments, err = c.storage.ListAttachments(ctx, id); err != nil {
+			return err
+		}
+	}
+	if err := c.storage.DeleteEvent(ctx, id); err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		c.deleteBlob(a.Key)
+	}
+	return nil
 }
 
 func (c *Calendar) LookupEvent(ctx context.Context, id int64) (model.Event, error) {
 	if id == 0 {
 		return model.Event{}, ErrID
 	}
-	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
 	defer cancel()
 	return c.storage.LookupEvent(ctx, id)
 }
 
-func (c *Calendar) ListEvents(ctx context.Context, userID int64) ([]model.Event, error) {
+// ListEvents and the other listings return the events matching the tag
+// filter, all events if it is empty.
+func (c *Calendar) ListEvents(ctx context.Context, userID int64, filter model.TagFilter) ([]model.Event, error) {
 	if userID == 0 {
 		return []model.Event{}, ErrUserID
 	}
-	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	filter, err := c.tagFilter(filter)
+	if err != nil {
+		return []model.Event{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
 	defer cancel()
-	return c.storage.ListEvents(ctx, userID)
+	return c.storage.ListEvents(ctx, userID, filter)
 }
 
-func (c *Calendar) ListEventsDay(ctx context.Context, userID int64, date time.Time) ([]model.Event, error) {
+func (c *Calendar) ListEventsDay(ctx context.Context, userID int64, date time.Time,
+	filter model.TagFilter,
+) ([]model.Event, error) {
 	if userID == 0 {
 		return []model.Event{}, ErrUserID
 	}
-	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	filter, err := c.tagFilter(filter)
+	if err != nil {
+		return []model.Event{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
 	defer cancel()
-	return c.storage.ListEventsRange(ctx, userID, date, date)
+	return c.storage.ListEventsRange(ctx, userID, date, date, filter)
 }
 
-func (c *Calendar) ListEventsWeek(ctx context.Context, userID int64, date time.Time) ([]model.Event, error) {
+func (c *Calendar) ListEventsWeek(ctx context.Context, userID int64, date time.Time,
+	filter model.TagFilter,
+) ([]model.Event, error) {
 	if userID == 0 {
 		return []model.Event{}, ErrUserID
 	}
-	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	filter, err := c.tagFilter(filter)
+	if err != nil {
+		return []model.Event{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
 	defer cancel()
 	monday := c.firstDayOfWeek(date)
 	sunday := monday.AddDate(0, 0, 6)
-	return c.storage.ListEventsRange(ctx, userID, monday, sunday)
+	return c.storage.ListEventsRange(ctx, userID, monday, sunday, filter)
 }
 
-func (c *Calendar) ListEventsMonth(ctx context.Context, userID int64, date time.Time) ([]model.Event, error) {
+func (c *Calendar) ListEventsMonth(ctx context.Context, userID int64, date time.Time,
+	filter model.TagFilter,
+) ([]model.Event, error) {
 	if userID == 0 {
 		return []model.Event{}, ErrUserID
 	}
-	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	filter, err := c.tagFilter(filter)
+	if err != nil {
+		return []model.Event{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
 	defer cancel()
 	dayFirst := c.firstDayOfMonth(date)
 	dayLast := c.lastDayOfMonth(date)
-	return c.storage.ListEventsRange(ctx, userID, dayFirst, dayLast)
+	return c.storage.ListEventsRange(ctx, userID, dayFirst, dayLast, filter)
+}
+
+// SearchEvents finds the events of the user by the words of their title and
+// description, best matches first. The query follows the web search syntax:
+// all words must match, "-word" excludes. Zero bounds of the range are open,
+// an empty tag filter matches every event.
+func (c *Calendar) SearchEvents(ctx context.Context, userID int64, query string,
+	from, to time.Time, filter model.TagFilter,
+) ([]model.SearchResult, error) {
+	if userID == 0 {
+		return []model.SearchResult{}, ErrUserID
+	}
+	query = strings.TrimSpace(query)
+	switch {
+	case query == "":
+		return []model.SearchResult{}, fmt.Errorf("%w: empty", ErrQuery)
+	case len(query) > maxQuery:
+		return []model.SearchResult{}, fmt.Errorf("%w: must be <=%v", ErrQuery, maxQuery)
+	case !from.IsZero() && !to.IsZero() && to.Before(from):
+		return []model.SearchResult{}, fmt.Errorf("%w: to is before from", ErrRange)
+	}
+	filter, err := c.tagFilter(filter)
+	if err != nil {
+		return []model.SearchResult{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	return c.storage.SearchEvents(ctx, userID, query, from, to, filter, maxResults)
+}
+
+func (c *Calendar) ListUserTags(ctx context.Context, userID int64) ([]model.TagCount, error) {
+	if userID == 0 {
+		return []model.TagCount{}, ErrUserID
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	return c.storage.ListUserTags(ctx, userID)
+}
+
+func (c *Calendar) checkUserChannel(channel model.UserChannel) error {
+	switch channel.Channel {
+	case notifier.ChannelLog:
+		return nil
+	case notifier.ChannelEmail:
+		// the address is used as the SMTP recipient as is, so it must be
+		// a bare address without a display name or angle brackets
+		addr, err := mail.ParseAddress(channel.Address)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrChannelAddress, err)
+		}
+		if addr.Address != channel.Address {
+			return fmt.Errorf("%w: %q is not a bare address", ErrChannelAddress, channel.Address)
+		}
+		return nil
+	case notifier.ChannelWebhook:
+		u, err := url.Parse(channel.Address)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: %q is not an http(s) URL", ErrChannelAddress, channel.Address)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrChannel, channel.Channel)
+}
+
+func (c *Calendar) checkUserChannelLocale(channel model.UserChannel) error {
+	if len(channel.Locale) > 16 {
+		return fmt.Errorf("%w: must be <=16", ErrLocale)
+	}
+	if _, err := time.LoadLocation(channel.TimeZone); err != nil {
+		return fmt.Errorf("%w: %v", ErrTimeZone, err)
+	}
+	return nil
+}
+
+// SetUserChannels replaces the channels the user receives notifications by.
+// An empty list returns the user to the default channels.
+func (c *Calendar) SetUserChannels(ctx context.Context, userID int64, channels []model.UserChannel) error {
+	if userID == 0 {
+		return ErrUserID
+	}
+	for _, channel := range channels {
+		if err := c.checkUserChannel(channel); err != nil {
+			return err
+		}
+		if err := c.checkUserChannelLocale(channel); err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	return c.storage.SetUserChannels(ctx, userID, channels)
+}
+
+func (c *Calendar) ListUserChannels(ctx context.Context, userID int64) ([]model.UserChannel, error) {
+	if userID == 0 {
+		return []model.UserChannel{}, ErrUserID
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	return c.storage.ListUserChannels(ctx, userID)
+}
+
+// SetUserRetention keeps the user's events for retention after they end,
+// zero returns the user to the scheduler's retention.
+func (c *Calendar) SetUserRetention(ctx context.Context, userID int64, retention time.Duration) error {
+	if userID == 0 {
+		return ErrUserID
+	}
+	if retention < 0 || retention%time.Second != 0 {
+		return fmt.Errorf("%w: %v", ErrRetention, retention)
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	return c.storage.SetUserRetention(ctx, userID, retention)
+}
+
+func (c *Calendar) LookupUserRetention(ctx context.Context, userID int64) (time.Duration, error) {
+	if userID == 0 {
+		return 0, ErrUserID
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	return c.storage.LookupUserRetention(ctx, userID)
+}
+
+// PreviewNotification renders the notification of the event as the sender
+// would for the channel, locale and time zone.
+func (c *Calendar) PreviewNotification(ctx context.Context, id int64, channel model.UserChannel,
+) (notifier.Message, error) {
+	if !notifier.Known(channel.Channel) {
+		return notifier.Message{}, fmt.Errorf("%w: %q", ErrChannel, channel.Channel)
+	}
+	if err := c.checkUserChannelLocale(channel); err != nil {
+		return notifier.Message{}, err
+	}
+	event, err := c.LookupEvent(ctx, id)
+	if err != nil {
+		return notifier.Message{}, err
+	}
+	msg, err := c.templates.Render(channel.Channel, channel.Locale, channel.TimeZone, event)
+	if err != nil {
+		return notifier.Message{}, err
+	}
+	msg.Notification = model.NotificationMsg{ID: event.ID, Title: event.Title, Date: event.OnTime, UserID: event.UserID}
+	return msg, nil
 }
 
 func NewCalendar(log Logger, conf CalendarConf, storage CalendarStorage) *Calendar {
@@ -205,14 +560,42 @@ func NewCalendar(log Logger, conf CalendarConf, storage CalendarStorage) *Calend
 		exitfail(fmt.Sprintf("Can't connect to storage:%v", err))
 	}
 
-	return &Calendar{log: log, conf: conf, storage: storage}
+	templates, err := notifier.LoadTemplates(conf.Templates)
+	if err != nil {
+		exitfail(fmt.Sprintf("Can't load templates:%v", err))
+	}
+
+	var blobs blob.Store
+	if conf.Attachments.Enabled() {
+		if blobs, err = blob.New(conf.Attachments.Conf); err != nil {
+			exitfail(fmt.Sprintf("Can't create blob store:%v", err))
+		}
+	}
+
+	return &Calendar{log: log, conf: conf, storage: storage, templates: templates, blobs: blobs}
 }
 
-func (c Calendar) Run(httpsrv Server, grpcsrv Server) {
+func (c *Calendar) Run(httpsrv Server, grpcsrv Server) {
 	ctx, cancel := signal.NotifyContext(context.Background(),
-		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+		syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	hup, stopHup := notifyReload()
+	defer stopHup()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				if err := c.Reload(); err != nil {
+					c.log.Errorf("Can't reload config:%v\n", err)
+				}
+			}
+		}
+	}()
+
 	g, ctxEG := errgroup.WithContext(ctx)
 
 	func1 := func() error {
@@ -250,6 +633,14 @@ func (c Calendar) Run(httpsrv Server, grpcsrv Server) {
 	g.Go(func1)
 	g.Go(func2)
 
+	runner, err := c.Jobs()
+	if err != nil {
+		exitfail(fmt.Sprintf("Can't create jobs:%v", err))
+	}
+	if runner != nil {
+		g.Go(func() error { return runner.Run(ctxEG) })
+	}
+
 	if err := g.Wait(); err != nil {
 		if !errors.Is(err, http.ErrServerClosed) &&
 			!errors.Is(err, grpc.ErrServerStopped) &&
@@ -2,7 +2,9 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"sync"
@@ -10,13 +12,17 @@ import (
 	"time"
 
 	api "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/api/stub"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/blob"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/logger"
 	internalgrpc "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/server/grpcservice"
 	memorystorage "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/storage/memory"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -28,7 +34,7 @@ func helperAPIEvent(id int64, userid int64, onTime, offTime time.Time) *api.Even
 	aEvent.Description = func(s string) *string { return &s }(fmt.Sprintf("DescriptionN%v", userid))
 	aEvent.OnTime = timestamppb.New(onTime)
 	aEvent.OffTime = timestamppb.New(offTime)
-	aEvent.NotifyTime = timestamppb.New(time.Time{})
+	aEvent.Reminders = []*api.Reminder{{Offset: durationpb.New(15 * time.Minute)}}
 	return &aEvent
 }
 
@@ -175,6 +181,36 @@ func allTest(t *testing.T, conn *grpc.ClientConn, wg *sync.WaitGroup) { //nolint
 			require.Len(t, founds.GetEvent(), 1)
 		})
 
+		step += step
+		t.Run("case_listevents_day_timezone", func(t *testing.T) {
+			wg.Add(1)
+			defer wg.Done()
+			step := step
+			t.Parallel()
+			ctx := context.Background()
+			userID := int64(step)
+			client := api.NewCalendarClient(conn)
+
+			day := time.Date(2030, 1, 12, 0, 0, 0, 0, time.UTC)
+			event := helperAPIEvent(0, userID, day, day)
+			event.AllDay = func(b bool) *bool { return &b }(true)
+			_, err := client.InsertEvent(ctx, &api.ReqByEvent{Event: event})
+			require.NoError(t, err)
+
+			// 2030-01-11 15:30 UTC is already the 12th in Tokyo
+			date := timestamppb.New(day.Add(-510 * time.Minute))
+			tokyo, utc, wrong := "Asia/Tokyo", "UTC", "Mars/Olympus"
+			founds, err := client.ListEventsDay(ctx, &api.ReqByUserByDate{UserID: &userID, Date: date, TimeZone: &tokyo})
+			require.NoError(t, err)
+			require.Len(t, founds.GetEvent(), 1)
+			founds, err = client.ListEventsDay(ctx, &api.ReqByUserByDate{UserID: &userID, Date: date, TimeZone: &utc})
+			require.NoError(t, err)
+			require.Empty(t, founds.GetEvent())
+
+			_, err = client.ListEventsWeek(ctx, &api.ReqByUserByDate{UserID: &userID, Date: date, TimeZone: &wrong})
+			require.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+
 		step += step
 		t.Run("case_listevents_week", func(t *testing.T) {
 			wg.Add(1)
@@ -236,6 +272,101 @@ func allTest(t *testing.T, conn *grpc.ClientConn, wg *sync.WaitGroup) { //nolint
 			// Should be 31 events in January 2023
 			require.Len(t, founds.GetEvent(), 31)
 		})
+
+		step += step
+		t.Run("case_search", func(t *testing.T) {
+			wg.Add(1)
+			defer wg.Done()
+			step := step
+			t.Parallel()
+			ctx := context.Background()
+			userID := int64(step)
+			client := api.NewCalendarClient(conn)
+
+			for i := 0; i < attempt; i++ {
+				event := api.ReqByEvent{
+					Event: helperAPIEvent(0, userID, currTime.AddDate(0, 0, i), currTime.AddDate(0, 0, i).Add(time.Hour)),
+				}
+				_, err := client.InsertEvent(ctx, &event)
+				require.NoError(t, err)
+			}
+
+			query := fmt.Sprintf("TitleN%v", userID)
+			rep, err := client.SearchEvents(ctx, &api.ReqSearch{UserID: &userID, Query: &query})
+			require.NoError(t, err)
+			require.Len(t, rep.GetResult(), attempt)
+			require.Equal(t, "<b>"+query+"</b>", rep.GetResult()[0].GetTitle())
+
+			rep, err = client.SearchEvents(ctx, &api.ReqSearch{
+				UserID: &userID,
+				Query:  &query,
+				From:   timestamppb.New(currTime.AddDate(0, 0, attempt-2)),
+			})
+			require.NoError(t, err)
+			require.Len(t, rep.GetResult(), 2)
+
+			rep, err = client.SearchEvents(ctx, &api.ReqSearch{
+				UserID: &userID,
+				Query:  &query,
+				Tags:   &api.TagFilter{Any: []string{"urgent"}},
+			})
+			require.NoError(t, err)
+			require.Empty(t, rep.GetResult(), "untagged events")
+		})
+
+		step += step
+		t.Run("case_attachments", func(t *testing.T) {
+			wg.Add(1)
+			defer wg.Done()
+			step := step
+			t.Parallel()
+			ctx := context.Background()
+			userID := int64(step)
+			client := api.NewCalendarClient(conn)
+
+			event := api.ReqByEvent{Event: helperAPIEvent(0, userID, currTime, currTime.Add(time.Hour))}
+			repID, err := client.InsertEvent(ctx, &event)
+			require.NoError(t, err)
+
+			upload, err := client.UploadAttachment(ctx)
+			require.NoError(t, err)
+			name, contentType := "agenda.txt", "text/plain"
+			info := &api.AttachmentInfo{EventID: repID.ID, Name: &name, ContentType: &contentType}
+			require.NoError(t, upload.Send(&api.ReqUploadAttachment{Data: &api.ReqUploadAttachment_Info{Info: info}}))
+			for _, chunk := range []string{"age", "nda"} {
+				data := &api.ReqUploadAttachment_Chunk{Chunk: []byte(chunk)}
+				require.NoError(t, upload.Send(&api.ReqUploadAttachment{Data: data}))
+			}
+			attachment, err := upload.CloseAndRecv()
+			require.NoError(t, err)
+			require.EqualValues(t, 6, attachment.GetSize())
+			require.Equal(t, name, attachment.GetName())
+
+			download, err := client.DownloadAttachment(ctx, &api.ReqByID{ID: attachment.ID})
+			require.NoError(t, err)
+			chunk, err := download.Recv()
+			require.NoError(t, err)
+			require.Equal(t, attachment.GetChecksum(), chunk.GetInfo().GetChecksum())
+			var content []byte
+			for {
+				chunk, err := download.Recv()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				require.NoError(t, err)
+				content = append(content, chunk.GetChunk()...)
+			}
+			require.Equal(t, "agenda", string(content))
+
+			rep, err := client.ListAttachments(ctx, &api.ReqByID{ID: repID.ID})
+			require.NoError(t, err)
+			require.Len(t, rep.GetAttachment(), 1)
+			_, err = client.DeleteAttachment(ctx, &api.ReqByID{ID: attachment.ID})
+			require.NoError(t, err)
+			rep, err = client.ListAttachments(ctx, &api.ReqByID{ID: repID.ID})
+			require.NoError(t, err)
+			require.Empty(t, rep.GetAttachment())
+		})
 	})
 }
 
@@ -244,12 +375,12 @@ func TestGrpcService(t *testing.T) {
 	db := memorystorage.New()
 	log := logger.NewLogger("DEBUG", os.Stdout)
 
-	calendar := &Calendar{log: log, storage: db}
+	calendar := &Calendar{log: log, storage: db, blobs: blob.NewFS(t.TempDir())}
 
 	dialer := func() func(context.Context, string) (net.Conn, error) {
 		listener := bufconn.Listen(1024 * 1024)
 
-		_, server := internalgrpc.NewServer(log, calendar, "", "")
+		_, server := internalgrpc.NewServer(log, calendar, internalgrpc.Conf{})
 
 		go func() {
 			if err := server.Serve(listener); err != nil {
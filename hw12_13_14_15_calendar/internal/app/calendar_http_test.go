@@ -1,6 +1,7 @@
 package app
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -10,7 +11,9 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/blob"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/logger"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
 	internalhttp "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/server/http"
@@ -48,7 +51,7 @@ func TestCalendarHTTPServer(t *testing.T) {
 		"description" : "Description_N200",
 		"ontime" : "2015-09-18T00:00:00Z",
 		"offtime" : "2015-09-19T00:00:00Z",
-		"notifytime" : "0001-01-01T00:00:00Z"
+		"reminders" : [{"offset": "15m"}]
 	}`
 
 	body2 := fmt.Sprintf(`{
@@ -57,8 +60,7 @@ func TestCalendarHTTPServer(t *testing.T) {
 				"title" : "Title_N400",
 				"description" : "Description_N400",
 				"ontime" : "2015-09-18T00:00:00Z",
-				"offtime" : "2015-09-20T00:00:00Z",
-				"notifytime" : "0001-01-01T00:00:00Z"
+				"offtime" : "2015-09-20T00:00:00Z"
 			}`, userID400)
 
 	body3 := fmt.Sprintf(`{
@@ -67,14 +69,13 @@ func TestCalendarHTTPServer(t *testing.T) {
 				"title" : "Title_N402",
 				"description" : "Description_N402",
 				"ontime" : "2015-10-18T00:00:00Z",
-				"offtime" : "2015-10-20T00:00:00Z",
-				"notifytime" : "0001-01-01T00:00:00Z"
+				"offtime" : "2015-10-20T00:00:00Z"
 			}`, userID400)
 
 	db := memorystorage.New()
 	log := logger.NewLogger("DEBUG", os.Stdout)
 	calendar := &Calendar{log: log, storage: db}
-	httpsrv := internalhttp.NewServer(log, calendar, "", "")
+	httpsrv := internalhttp.NewServer(log, calendar, internalhttp.Conf{})
 	httpcli := &http.Client{}
 
 	t.Run("case_insert", func(t *testing.T) {
@@ -164,6 +165,115 @@ func TestCalendarHTTPServer(t *testing.T) {
 		require.EqualValues(t, userID400, rep[1].UserID)
 	})
 
+	t.Run("case_search", func(t *testing.T) {
+		var rep []model.SearchResult
+		ts := httptest.NewServer(httpsrv.Handler())
+		defer ts.Close()
+
+		body := fmt.Sprintf(`{"userid": %d, "query": "N402", "from": "2015-10-01T00:00:00Z"}`, userID400)
+		res, err := httpcli.Post(ts.URL+"/SearchEvents", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.NoError(t, helperDecode(res.Body, &rep))
+		require.Len(t, rep, 1)
+		require.Equal(t, "Title_<b>N402</b>", rep[0].Title)
+		require.Equal(t, "Description_<b>N402</b>", rep[0].Snippet)
+
+		res, err = httpcli.Post(ts.URL+"/SearchEvents", "application/json", strings.NewReader(bodyUserID))
+		require.NoError(t, err)
+		res.Body.Close()
+		require.Equal(t, http.StatusBadRequest, res.StatusCode, "empty query")
+	})
+
+	t.Run("case_tags", func(t *testing.T) {
+		ts := httptest.NewServer(httpsrv.Handler())
+		defer ts.Close()
+
+		body := fmt.Sprintf(`{
+			"userid": %d,
+			"title" : "Tagged",
+			"ontime" : "2015-11-18T00:00:00Z",
+			"offtime" : "2015-11-19T00:00:00Z",
+			"tags" : ["Project-X", "urgent"]
+		}`, userID400)
+		res, err := httpcli.Post(ts.URL+"/InsertEvent", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		var events []model.Event
+		body = fmt.Sprintf(`{"userid": %d, "tags": {"any": ["project-x"]}}`, userID400)
+		res, err = httpcli.Post(ts.URL+"/ListEvents", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.NoError(t, helperDecode(res.Body, &events))
+		require.Len(t, events, 1)
+		require.Equal(t, []string{"project-x", "urgent"}, events[0].Tags)
+
+		var results []model.SearchResult
+		body = fmt.Sprintf(`{"userid": %d, "query": "tagged", "tags": {"all": ["urgent"]}}`, userID400)
+		res, err = httpcli.Post(ts.URL+"/SearchEvents", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.NoError(t, helperDecode(res.Body, &results))
+		require.Len(t, results, 1)
+		require.Equal(t, "Tagged", results[0].Event.Title)
+
+		var tags []model.TagCount
+		res, err = httpcli.Post(ts.URL+"/ListUserTags", "application/json", strings.NewReader(bodyUserID))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.NoError(t, helperDecode(res.Body, &tags))
+		require.Equal(t, []model.TagCount{{Tag: "project-x", Count: 1}, {Tag: "urgent", Count: 1}}, tags)
+	})
+
+	t.Run("case_attachments", func(t *testing.T) {
+		calendar := &Calendar{log: log, storage: db, blobs: blob.NewFS(t.TempDir())}
+		ts := httptest.NewServer(internalhttp.NewServer(log, calendar, internalhttp.Conf{}).Handler())
+		defer ts.Close()
+
+		var attachment model.Attachment
+		res, err := httpcli.Post(ts.URL+"/UploadAttachment?eventid=2&name=agenda.txt", "text/plain",
+			strings.NewReader("agenda"))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.NoError(t, helperDecode(res.Body, &attachment))
+		require.EqualValues(t, 6, attachment.Size)
+
+		res, err = httpcli.Get(fmt.Sprintf("%v/DownloadAttachment?id=%v", ts.URL, attachment.ID))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		content, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, "agenda", string(content))
+		require.Equal(t, "text/plain", res.Header.Get("Content-Type"))
+		require.Equal(t, `attachment; filename=agenda.txt`, res.Header.Get("Content-Disposition"))
+		require.Equal(t, `"`+attachment.Checksum+`"`, res.Header.Get("ETag"))
+
+		var attachments []model.Attachment
+		res, err = httpcli.Post(ts.URL+"/ListAttachments", "application/json", strings.NewReader(`{"eventid": 2}`))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.NoError(t, helperDecode(res.Body, &attachments))
+		require.Len(t, attachments, 1)
+
+		var rep ReplayMsg
+		body := fmt.Sprintf(`{"id": %v}`, attachment.ID)
+		res, err = httpcli.Post(ts.URL+"/DeleteAttachment", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.NoError(t, helperDecode(res.Body, &rep))
+		require.Equal(t, msgDeleted, rep.Msg)
+
+		res, err = httpcli.Get(fmt.Sprintf("%v/DownloadAttachment?id=%v", ts.URL, attachment.ID))
+		require.NoError(t, err)
+		res.Body.Close()
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
 	t.Run("case_delete", func(t *testing.T) {
 		var rep ReplayMsg
 		ts := httptest.NewServer(http.HandlerFunc(httpsrv.DeleteEvent))
@@ -182,4 +292,189 @@ func TestCalendarHTTPServer(t *testing.T) {
 		require.Empty(t, rep.Err)
 		require.EqualValues(t, msgDeleted, rep.Msg)
 	})
+
+	t.Run("case_user_retention", func(t *testing.T) {
+		ts := httptest.NewServer(httpsrv.Handler())
+		defer ts.Close()
+
+		for body, expected := range map[string]int{
+			fmt.Sprintf(`{"userid": %d, "retention": "720h"}`, userID400):  http.StatusOK,
+			fmt.Sprintf(`{"userid": %d, "retention": "month"}`, userID400): http.StatusBadRequest,
+			fmt.Sprintf(`{"userid": %d, "retention": "-1h"}`, userID400):   http.StatusBadRequest,
+		} {
+			res, err := httpcli.Post(ts.URL+"/SetUserRetention", "application/json", strings.NewReader(body))
+			require.NoError(t, err)
+			res.Body.Close()
+			require.Equal(t, expected, res.StatusCode, body)
+		}
+
+		var rep struct {
+			UserID    int64  `json:"userid"`
+			Retention string `json:"retention"`
+		}
+		res, err := httpcli.Post(ts.URL+"/LookupUserRetention", "application/json", strings.NewReader(bodyUserID))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.NoError(t, helperDecode(res.Body, &rep))
+		require.Equal(t, userID400, rep.UserID)
+		require.Equal(t, "720h0m0s", rep.Retention)
+	})
+}
+
+func TestCalendarHTTPMiddlewares(t *testing.T) {
+	db := memorystorage.New()
+	log := logger.NewLogger("DEBUG", os.Stdout)
+	calendar := &Calendar{log: log, storage: db, blobs: blob.NewFS(t.TempDir())}
+	httpsrv := internalhttp.NewServer(log, calendar, internalhttp.Conf{
+		MaxBodySize: 64,
+		Compress:    true,
+		CORS:        internalhttp.CORSConf{AllowedOrigins: []string{"https://example.com"}},
+	})
+	ts := httptest.NewServer(httpsrv.Handler())
+	defer ts.Close()
+	httpcli := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+
+	t.Run("body_too_large", func(t *testing.T) {
+		var rep ReplayMsg
+		body := fmt.Sprintf(`{"userid": 1, "title": %q}`, strings.Repeat("a", 100))
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, ts.URL+"/InsertEvent",
+			strings.NewReader(body))
+		require.NoError(t, err)
+		res, err := httpcli.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+		require.NoError(t, helperDecode(res.Body, &rep))
+		require.NotEmpty(t, rep.Err)
+	})
+
+	t.Run("gzip", func(t *testing.T) {
+		var rep []model.Event
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, ts.URL+"/ListEvents",
+			strings.NewReader(`{"userid": 1}`))
+		require.NoError(t, err)
+		req.Header.Set("Accept-Encoding", "gzip")
+		res, err := httpcli.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, "gzip", res.Header.Get("Content-Encoding"))
+		zr, err := gzip.NewReader(res.Body)
+		require.NoError(t, err)
+		require.NoError(t, helperDecode(zr, &rep))
+		require.Empty(t, rep)
+	})
+
+	t.Run("gzip_attachments", func(t *testing.T) {
+		event := model.Event{UserID: 1, Title: "Standup", OnTime: time.Now(), OffTime: time.Now().Add(time.Hour)}
+		require.NoError(t, db.InsertEvent(context.Background(), &event))
+		upload := func(name, contentType, content string) model.Attachment {
+			t.Helper()
+			var attachment model.Attachment
+			res, err := httpcli.Post(fmt.Sprintf("%v/UploadAttachment?eventid=%v&name=%v", ts.URL, event.ID, name),
+				contentType, strings.NewReader(content))
+			require.NoError(t, err)
+			defer res.Body.Close()
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			require.NoError(t, helperDecode(res.Body, &attachment))
+			return attachment
+		}
+		download := func(id int64, ifNoneMatch string) *http.Response {
+			t.Helper()
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet,
+				fmt.Sprintf("%v/DownloadAttachment?id=%v", ts.URL, id), nil)
+			require.NoError(t, err)
+			req.Header.Set("Accept-Encoding", "gzip")
+			if ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", ifNoneMatch)
+			}
+			res, err := httpcli.Do(req)
+			require.NoError(t, err)
+			return res
+		}
+
+		text := upload("agenda.txt", "text/plain", "agenda")
+		res := download(text.ID, "")
+		defer res.Body.Close()
+		require.Equal(t, "gzip", res.Header.Get("Content-Encoding"))
+		etag := res.Header.Get("ETag")
+		require.Equal(t, `W/"`+text.Checksum+`"`, etag, "tag of compressed body is weak")
+		zr, err := gzip.NewReader(res.Body)
+		require.NoError(t, err)
+		content, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		require.Equal(t, "agenda", string(content))
+
+		res = download(text.ID, etag)
+		res.Body.Close()
+		require.Equal(t, http.StatusNotModified, res.StatusCode)
+		require.Equal(t, etag, res.Header.Get("ETag"))
+
+		image := upload("logo.png", "image/png", "\x89PNG\r\n")
+		res = download(image.ID, "")
+		defer res.Body.Close()
+		require.Empty(t, res.Header.Get("Content-Encoding"), "binary content is not compressed")
+		require.Equal(t, "6", res.Header.Get("Content-Length"))
+		require.Equal(t, `"`+image.Checksum+`"`, res.Header.Get("ETag"))
+		content, err = io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, "\x89PNG\r\n", string(content))
+	})
+
+	t.Run("cors_preflight", func(t *testing.T) {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodOptions, ts.URL+"/ListEvents", nil)
+		require.NoError(t, err)
+		req.Header.Set("Origin", "https://example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		res, err := httpcli.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+		require.Equal(t, "https://example.com", res.Header.Get("Access-Control-Allow-Origin"))
+		require.Equal(t, http.MethodPost, res.Header.Get("Access-Control-Allow-Methods"))
+	})
+
+	t.Run("cors_foreign_origin", func(t *testing.T) {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, ts.URL+"/ListEvents",
+			strings.NewReader(`{"userid": 1}`))
+		require.NoError(t, err)
+		req.Header.Set("Origin", "https://evil.example.com")
+		res, err := httpcli.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Empty(t, res.Header.Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("no_debug_vars", func(t *testing.T) {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, ts.URL+"/debug/vars", nil)
+		require.NoError(t, err)
+		res, err := httpcli.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, http.StatusNotFound, res.StatusCode, "metrics are served on health_addr only")
+	})
+}
+
+func TestCalendarHTTPRecover(t *testing.T) {
+	var rep ReplayMsg
+	log := logger.NewLogger("DEBUG", os.Stdout)
+	calendar := &Calendar{log: log} // no storage, so handlers panic
+	httpsrv := internalhttp.NewServer(log, calendar, internalhttp.Conf{})
+	ts := httptest.NewServer(httpsrv.Handler())
+	defer ts.Close()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, ts.URL+"/ListEvents",
+		strings.NewReader(`{"userid": 1}`))
+	require.NoError(t, err)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusInternalServerError, res.StatusCode)
+	require.NoError(t, helperDecode(res.Body, &rep))
+	require.NotEmpty(t, rep.Err)
 }
@@ -2,12 +2,19 @@ package app
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/blob"
 	logger "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/logger"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/notifier"
 	memorystorage "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/storage/memory"
 	"github.com/stretchr/testify/require"
 )
@@ -27,7 +34,6 @@ func TestCalendar(t *testing.T) {
 			Description: "DescriptionN1",
 			OnTime:      time.Time{},
 			OffTime:     time.Time{},
-			NotifyTime:  time.Time{},
 		}
 		err := calendar.checkBasicRules(&event, true)
 		require.ErrorIs(t, err, ErrID)
@@ -59,6 +65,16 @@ func TestCalendar(t *testing.T) {
 		err = calendar.checkBasicRules(&event, false)
 		require.NoError(t, err)
 
+		event.Reminders = []model.Reminder{{Offset: -time.Minute}}
+		require.ErrorIs(t, calendar.checkBasicRules(&event, false), ErrReminder)
+		event.Reminders = []model.Reminder{{Offset: time.Minute, Channel: "sms"}}
+		require.ErrorIs(t, calendar.checkBasicRules(&event, false), ErrReminder)
+		event.Reminders = []model.Reminder{{Offset: time.Minute}, {Offset: time.Minute}}
+		require.ErrorIs(t, calendar.checkBasicRules(&event, false), ErrReminder)
+		event.Reminders = []model.Reminder{{Offset: time.Minute}, {Offset: time.Minute, Channel: "email"}}
+		require.NoError(t, calendar.checkBasicRules(&event, false))
+		event.Reminders = nil
+
 		err = calendar.InsertEvent(ctx, &event)
 		require.NoError(t, err)
 
@@ -69,7 +85,6 @@ func TestCalendar(t *testing.T) {
 			Description: event.Description,
 			OnTime:      event.OnTime,
 			OffTime:     event.OffTime,
-			NotifyTime:  event.NotifyTime,
 		}
 		err = calendar.InsertEvent(ctx, &eventCopy)
 		require.ErrorIs(t, err, memorystorage.ErrDataRangeIsBusy)
@@ -84,7 +99,6 @@ func TestCalendar(t *testing.T) {
 			Description: "DescriptionN1",
 			OnTime:      currTime.AddDate(0, 0, 1),
 			OffTime:     currTime.AddDate(0, 0, 7),
-			NotifyTime:  time.Time{},
 		}
 		err := calendar.InsertEvent(ctx, &event)
 		require.NoError(t, err)
@@ -97,11 +111,368 @@ func TestCalendar(t *testing.T) {
 		require.NoError(t, err)
 		require.EqualValues(t, userID, eventFound.UserID)
 
-		events, err := calendar.ListEvents(ctx, event.UserID)
+		events, err := calendar.ListEvents(ctx, event.UserID, model.TagFilter{})
 		require.NoError(t, err)
 		require.EqualValues(t, int(1), len(events))
 
 		err = calendar.DeleteEvent(ctx, event.ID)
 		require.NoError(t, err)
 	})
+
+	t.Run("test_reminders", func(t *testing.T) {
+		currTime := time.Now()
+		event := model.Event{
+			UserID:    150,
+			Title:     "TitleN1",
+			OnTime:    currTime.AddDate(0, 0, 1),
+			OffTime:   currTime.AddDate(0, 0, 2),
+			Reminders: []model.Reminder{{Offset: 24 * time.Hour}, {Offset: 10 * time.Minute, Channel: "email"}},
+		}
+		require.NoError(t, calendar.InsertEvent(ctx, &event))
+		require.True(t, event.Reminders[0].NotifyTime.Equal(currTime))
+
+		due, err := db.ListEventsDayOfNotice(ctx, currTime)
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+		require.Len(t, due[0].Reminders, 1)
+		require.NoError(t, db.UpdateEventNotified(ctx, due[0].Reminders[0].ID))
+
+		event.OnTime = currTime.AddDate(0, 0, 3)
+		event.OffTime = currTime.AddDate(0, 0, 4)
+		require.NoError(t, calendar.UpdateEvent(ctx, &event))
+
+		found, err := calendar.LookupEvent(ctx, event.ID)
+		require.NoError(t, err)
+		require.Len(t, found.Reminders, 2)
+		require.True(t, found.Reminders[0].NotifyTime.Equal(currTime.AddDate(0, 0, 2)), "reminder follows the event")
+		require.False(t, found.Reminders[0].Notified, "moved reminder is due again")
+		require.True(t, found.Reminders[1].NotifyTime.Equal(event.OnTime.Add(-10*time.Minute)))
+
+		require.NoError(t, calendar.DeleteEvent(ctx, event.ID))
+	})
+
+	t.Run("test_reload", func(t *testing.T) {
+		conf := CalendarConf{}
+		conf.Logger.Level = "DEBUG"
+		conf.HTTP.Port = "8089"
+		calendar := Calendar{log: log, storage: db, conf: conf}
+
+		err := calendar.Reload()
+		require.ErrorIs(t, err, ErrNoConfLoader)
+
+		newConf := conf
+		newConf.Logger.Level = "ERROR"
+		newConf.Timeout = 5 * time.Second
+		calendar.SetConfLoader(func() (CalendarConf, error) { return newConf, nil })
+		require.EqualValues(t, defaultTimeout, calendar.timeout())
+		require.NoError(t, calendar.Reload())
+		require.EqualValues(t, 5*time.Second, calendar.timeout())
+
+		newConf.HTTP.Port = "8090"
+		newConf.Timeout = time.Second
+		err = calendar.Reload()
+		require.ErrorIs(t, err, ErrReloadRestart)
+		require.EqualValues(t, 5*time.Second, calendar.timeout())
+		require.EqualValues(t, "8089", calendar.conf.HTTP.Port)
+		require.NoError(t, log.SetLevel("DEBUG"))
+	})
+	t.Run("test_user_channels", func(t *testing.T) {
+		userID := int64(700)
+		require.ErrorIs(t, calendar.SetUserChannels(ctx, userID, []model.UserChannel{{Channel: "sms"}}), ErrChannel)
+		require.ErrorIs(t, calendar.SetUserChannels(ctx, userID, []model.UserChannel{
+			{Channel: "email", Address: "not an address"},
+		}), ErrChannelAddress)
+		for _, address := range []string{"Bob <bob@example.com>", "<bob@example.com>", " bob@example.com"} {
+			require.ErrorIs(t, calendar.SetUserChannels(ctx, userID, []model.UserChannel{
+				{Channel: "email", Address: address},
+			}), ErrChannelAddress, address)
+		}
+		require.ErrorIs(t, calendar.SetUserChannels(ctx, userID, []model.UserChannel{
+			{Channel: "webhook", Address: "ftp://example.com"},
+		}), ErrChannelAddress)
+		require.ErrorIs(t, calendar.SetUserChannels(ctx, userID, []model.UserChannel{
+			{Channel: "log", TimeZone: "Mars/Olympus"},
+		}), ErrTimeZone)
+
+		channels := []model.UserChannel{
+			{Channel: "email", Address: "user@example.com"},
+			{Channel: "webhook", Address: "https://example.com/hook"},
+		}
+		require.NoError(t, calendar.SetUserChannels(ctx, userID, channels))
+		found, err := calendar.ListUserChannels(ctx, userID)
+		require.NoError(t, err)
+		require.Len(t, found, 2)
+		require.Equal(t, userID, found[0].UserID)
+	})
+
+	t.Run("test_user_retention", func(t *testing.T) {
+		userID := int64(710)
+		require.ErrorIs(t, calendar.SetUserRetention(ctx, 0, time.Hour), ErrUserID)
+		require.ErrorIs(t, calendar.SetUserRetention(ctx, userID, -time.Hour), ErrRetention)
+		require.ErrorIs(t, calendar.SetUserRetention(ctx, userID, time.Millisecond), ErrRetention)
+
+		require.NoError(t, calendar.SetUserRetention(ctx, userID, 720*time.Hour))
+		retention, err := calendar.LookupUserRetention(ctx, userID)
+		require.NoError(t, err)
+		require.Equal(t, 720*time.Hour, retention)
+
+		require.NoError(t, calendar.SetUserRetention(ctx, userID, 0))
+		retention, err = calendar.LookupUserRetention(ctx, userID)
+		require.NoError(t, err)
+		require.Zero(t, retention, "global retention")
+	})
+
+	t.Run("test_search", func(t *testing.T) {
+		userID := int64(720)
+		currTime := time.Now()
+		for i, e := range []model.Event{
+			{Title: "Team standup", Description: "Daily sync of the backend team"},
+			{Title: "Lunch", Description: "With the backend team"},
+			{Title: "Backend review", Description: "Review of the quarter", OnTime: currTime.AddDate(0, 1, 0)},
+		} {
+			e := e
+			e.UserID = userID
+			if e.OnTime.IsZero() {
+				e.OnTime = currTime.Add(time.Duration(i) * time.Hour)
+			}
+			e.OffTime = e.OnTime.Add(30 * time.Minute)
+			require.NoError(t, calendar.InsertEvent(ctx, &e))
+		}
+
+		_, err := calendar.SearchEvents(ctx, 0, "team", time.Time{}, time.Time{}, model.TagFilter{})
+		require.ErrorIs(t, err, ErrUserID)
+		_, err = calendar.SearchEvents(ctx, userID, "  ", time.Time{}, time.Time{}, model.TagFilter{})
+		require.ErrorIs(t, err, ErrQuery)
+		_, err = calendar.SearchEvents(ctx, userID, "team", currTime, currTime.Add(-time.Hour), model.TagFilter{})
+		require.ErrorIs(t, err, ErrRange)
+
+		results, err := calendar.SearchEvents(ctx, userID, "Team", time.Time{}, time.Time{}, model.TagFilter{})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		require.Equal(t, "Team standup", results[0].Event.Title, "title matches rank higher")
+		require.Equal(t, "<b>Team</b> standup", results[0].Title)
+		require.Equal(t, "Daily sync of the backend <b>team</b>", results[0].Snippet)
+		require.Greater(t, results[0].Rank, results[1].Rank)
+
+		results, err = calendar.SearchEvents(ctx, userID, "backend -lunch", time.Time{}, currTime.AddDate(0, 0, 7),
+			model.TagFilter{})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.Equal(t, "Team standup", results[0].Event.Title)
+	})
+
+	t.Run("test_tags", func(t *testing.T) {
+		userID := int64(730)
+		currTime := time.Now()
+		event := model.Event{
+			UserID:  userID,
+			Title:   "Planning",
+			OnTime:  currTime,
+			OffTime: currTime.Add(time.Hour),
+			Tags:    []string{" Project-X", "urgent", "project-x", ""},
+		}
+		require.NoError(t, calendar.InsertEvent(ctx, &event))
+		require.Equal(t, []string{"project-x", "urgent"}, event.Tags)
+
+		events, err := calendar.ListEventsDay(ctx, userID, currTime, model.TagFilter{Any: []string{"URGENT"}})
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		events, err = calendar.ListEvents(ctx, userID, model.TagFilter{All: []string{"urgent", "project-y"}})
+		require.NoError(t, err)
+		require.Empty(t, events)
+
+		results, err := calendar.SearchEvents(ctx, userID, "planning", time.Time{}, time.Time{},
+			model.TagFilter{All: []string{"Urgent"}})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		results, err = calendar.SearchEvents(ctx, userID, "planning", time.Time{}, time.Time{},
+			model.TagFilter{Any: []string{"project-y"}})
+		require.NoError(t, err)
+		require.Empty(t, results)
+
+		many := model.TagFilter{Any: make([]string, maxFilterTags+1)}
+		for i := range many.Any {
+			many.Any[i] = fmt.Sprintf("tag%v", i)
+		}
+		_, err = calendar.ListEvents(ctx, userID, many)
+		require.ErrorIs(t, err, ErrTag)
+		_, err = calendar.ListEventsMonth(ctx, userID, currTime, many)
+		require.ErrorIs(t, err, ErrTag)
+		_, err = calendar.SearchEvents(ctx, userID, "planning", time.Time{}, time.Time{}, many)
+		require.ErrorIs(t, err, ErrTag)
+
+		tags, err := calendar.ListUserTags(ctx, userID)
+		require.NoError(t, err)
+		require.Len(t, tags, 2)
+		_, err = calendar.ListUserTags(ctx, 0)
+		require.ErrorIs(t, err, ErrUserID)
+
+		event.Tags = []string{"a,b"}
+		require.ErrorIs(t, calendar.UpdateEvent(ctx, &event), ErrTag)
+		event.Tags = []string{strings.Repeat("x", maxTagLen+1)}
+		require.ErrorIs(t, calendar.UpdateEvent(ctx, &event), ErrTag)
+		event.Tags = make([]string, maxTags+1)
+		for i := range event.Tags {
+			event.Tags[i] = fmt.Sprintf("tag%v", i)
+		}
+		require.ErrorIs(t, calendar.UpdateEvent(ctx, &event), ErrTag)
+	})
+
+	t.Run("test_allday", func(t *testing.T) {
+		userID := int64(740)
+		tokyo := time.FixedZone("JST", 9*3600)
+		newYork := time.FixedZone("EST", -5*3600)
+
+		meeting := model.Event{
+			UserID:  userID,
+			Title:   "Meeting",
+			OnTime:  time.Date(2030, 1, 11, 10, 0, 0, 0, time.UTC),
+			OffTime: time.Date(2030, 1, 11, 11, 0, 0, 0, time.UTC),
+		}
+		require.NoError(t, calendar.InsertEvent(ctx, &meeting))
+
+		birthday := model.Event{
+			UserID: userID,
+			Title:  "Birthday",
+			OnTime: time.Date(2030, 1, 11, 23, 30, 0, 0, newYork),
+			AllDay: true,
+		}
+		require.NoError(t, calendar.InsertEvent(ctx, &birthday), "all-day events overlap nothing")
+		require.Equal(t, time.Date(2030, 1, 11, 0, 0, 0, 0, time.UTC), birthday.OnTime)
+		require.Equal(t, time.Date(2030, 1, 12, 0, 0, 0, 0, time.UTC), birthday.OffTime)
+
+		for _, date := range []time.Time{
+			time.Date(2030, 1, 11, 0, 30, 0, 0, tokyo),
+			time.Date(2030, 1, 11, 23, 30, 0, 0, newYork),
+		} {
+			events, err := calendar.ListEventsDay(ctx, userID, date, model.TagFilter{})
+			require.NoError(t, err)
+			titles := []string{}
+			for _, e := range events {
+				titles = append(titles, e.Title)
+			}
+			require.Contains(t, titles, "Birthday", date)
+		}
+		events, err := calendar.ListEventsDay(ctx, userID, time.Date(2030, 1, 12, 0, 30, 0, 0, tokyo), model.TagFilter{})
+		require.NoError(t, err)
+		require.Empty(t, events)
+	})
+
+	t.Run("test_attachments", func(t *testing.T) {
+		dir := t.TempDir()
+		conf := CalendarConf{Attachments: AttachmentsConf{MaxSize: 10, Quota: 15}}
+		calendar := Calendar{log: log, storage: db, conf: conf, blobs: blob.NewFS(dir)}
+
+		currTime := time.Now()
+		event := model.Event{UserID: 750, Title: "Review", OnTime: currTime, OffTime: currTime.Add(time.Hour)}
+		require.NoError(t, calendar.InsertEvent(ctx, &event))
+
+		_, err := calendar.UploadAttachment(ctx, event.ID, "big", "", strings.NewReader("0123456789a"))
+		require.ErrorIs(t, err, ErrAttachmentSize)
+		a, err := calendar.UploadAttachment(ctx, event.ID, " agenda.txt ", "", strings.NewReader("agenda"))
+		require.NoError(t, err)
+		require.Equal(t, "agenda.txt", a.Name)
+		require.Equal(t, defaultContentType, a.ContentType)
+		require.EqualValues(t, 6, a.Size)
+		require.Equal(t, "df71fb0bd94cb72795bae4dce7bb6bd33efa6f7aa7ad11d78bfd6f734ca30e0b", a.Checksum)
+
+		found, content, err := calendar.OpenAttachment(ctx, a.ID)
+		require.NoError(t, err)
+		data, err := io.ReadAll(content)
+		require.NoError(t, err)
+		require.NoError(t, content.Close())
+		require.Equal(t, "agenda", string(data))
+		require.Equal(t, a, found)
+
+		_, err = calendar.UploadAttachment(ctx, event.ID, "over", "", strings.NewReader("0123456789"))
+		require.ErrorIs(t, err, model.ErrQuotaExceeded)
+		_, err = calendar.UploadAttachment(ctx, event.ID, "../x", "", strings.NewReader(""))
+		require.ErrorIs(t, err, ErrAttachmentName)
+		_, err = calendar.UploadAttachment(ctx, event.ID, "x", "text/", strings.NewReader(""))
+		require.ErrorIs(t, err, ErrContentType)
+		b, err := calendar.UploadAttachment(ctx, event.ID, "slides.pdf", "application/pdf", strings.NewReader("slides"))
+		require.NoError(t, err)
+
+		attachments, err := calendar.ListAttachments(ctx, event.ID)
+		require.NoError(t, err)
+		require.Equal(t, []model.Attachment{a, b}, attachments)
+		require.Equal(t, 2, countFiles(t, dir), "rejected uploads leave no blobs")
+
+		require.NoError(t, calendar.DeleteAttachment(ctx, a.ID))
+		_, _, err = calendar.OpenAttachment(ctx, a.ID)
+		require.ErrorIs(t, err, memorystorage.ErrAttachmentNotFound)
+		require.NoError(t, calendar.DeleteEvent(ctx, event.ID))
+		require.Zero(t, countFiles(t, dir), "deleted with the event")
+
+		_, err = (&Calendar{log: log, storage: db}).ListAttachments(ctx, event.ID)
+		require.ErrorIs(t, err, ErrAttachmentsDisabled)
+	})
+
+	t.Run("test_sweep_attachments", func(t *testing.T) {
+		dir := t.TempDir()
+		blobs := blob.NewFS(dir)
+		calendar := Calendar{log: log, storage: db, blobs: blobs}
+
+		currTime := time.Now()
+		event := model.Event{UserID: 760, Title: "Review", OnTime: currTime, OffTime: currTime.Add(time.Hour)}
+		require.NoError(t, calendar.InsertEvent(ctx, &event))
+		_, err := calendar.UploadAttachment(ctx, event.ID, "agenda.txt", "text/plain", strings.NewReader("agenda"))
+		require.NoError(t, err)
+		_, err = blobs.Put(ctx, blob.NewKey(), strings.NewReader("orphan"))
+		require.NoError(t, err)
+
+		deleted, err := calendar.SweepAttachments(ctx, time.Now())
+		require.NoError(t, err)
+		require.Zero(t, deleted, "younger than the orphan age")
+		deleted, err = calendar.SweepAttachments(ctx, time.Now().Add(defaultAttachmentOrphanAge+time.Minute))
+		require.NoError(t, err)
+		require.EqualValues(t, 1, deleted)
+		require.Equal(t, 1, countFiles(t, dir))
+	})
+
+	t.Run("test_preview", func(t *testing.T) {
+		templates, err := notifier.LoadTemplates(notifier.TemplatesConf{})
+		require.NoError(t, err)
+		calendar := Calendar{log: log, storage: db, templates: templates}
+
+		currTime := time.Now()
+		event := model.Event{
+			UserID:  800,
+			Title:   "Standup",
+			OnTime:  currTime.Add(2 * time.Hour),
+			OffTime: currTime.Add(3 * time.Hour),
+		}
+		require.NoError(t, calendar.InsertEvent(ctx, &event))
+
+		channel := model.UserChannel{Channel: notifier.ChannelEmail, Locale: "ru", TimeZone: "Asia/Tokyo"}
+		msg, err := calendar.PreviewNotification(ctx, event.ID, channel)
+		require.NoError(t, err)
+		require.Contains(t, msg.Subject, "Напоминание: Standup")
+		require.Contains(t, msg.Body, "начнётся через 2 часа")
+		require.Contains(t, msg.Body, event.OnTime.In(mustLoadLocation(t, "Asia/Tokyo")).Format("15:04"))
+
+		_, err = calendar.PreviewNotification(ctx, event.ID, model.UserChannel{Channel: "sms"})
+		require.ErrorIs(t, err, ErrChannel)
+		_, err = calendar.PreviewNotification(ctx, 0, model.UserChannel{Channel: notifier.ChannelLog})
+		require.ErrorIs(t, err, ErrID)
+	})
+}
+
+func countFiles(t *testing.T, dir string) (n int) {
+	t.Helper()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return err
+	})
+	require.NoError(t, err)
+	return n
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	require.NoError(t, err)
+	return loc
 }
@@ -4,6 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
 )
 
 var (
@@ -13,9 +18,27 @@ var (
 	ErrDescription    = errors.New("wrong Description")
 	ErrOnTime         = errors.New("wrong OnTime")
 	ErrOffTime        = errors.New("wrong OffTime")
-	ErrNotifyTime     = errors.New("wrong NotifyTime")
+	ErrReminder       = errors.New("wrong Reminder")
 	ErrEventNotFound  = errors.New("event not found")
 	ErrTooLongCloseDB = errors.New("too long close db")
+	ErrNoConfLoader   = errors.New("config loader is not set")
+	ErrReloadRestart  = errors.New("changes require restart")
+	ErrNotDelivered   = errors.New("notification is not delivered to any channel")
+	ErrChannel        = errors.New("wrong channel")
+	ErrChannelAddress = errors.New("wrong channel address")
+	ErrLocale         = errors.New("wrong locale")
+	ErrTimeZone       = errors.New("wrong time zone")
+	ErrRetention      = errors.New("wrong retention")
+	ErrDuplicate      = errors.New("duplicate notification")
+	ErrNotLeader      = errors.New("scheduler lease is held by another node")
+	ErrQuery          = errors.New("wrong Query")
+	ErrRange          = errors.New("wrong range")
+	ErrTag            = errors.New("wrong Tag")
+
+	ErrAttachmentsDisabled = errors.New("attachments are disabled")
+	ErrAttachmentName      = errors.New("wrong attachment Name")
+	ErrContentType         = errors.New("wrong ContentType")
+	ErrAttachmentSize      = errors.New("attachment is too large")
 )
 
 type Logger interface {
@@ -30,3 +53,48 @@ func exitfail(msg string) {
 	fmt.Fprintln(os.Stderr, msg)
 	os.Exit(1)
 }
+
+type levelSetter interface {
+	SetLevel(level string) error
+}
+
+func notifyReload() (<-chan os.Signal, func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	return hup, func() { signal.Stop(hup) }
+}
+
+// checkReload logs the difference between the running and the re-read config.
+// Changes of keys not listed in live are refused as a whole.
+func checkReload(log Logger, oldConf, newConf interface{}, live ...string) error {
+	changes := config.Diff(oldConf, newConf)
+	restart := []string{}
+	for _, ch := range changes {
+		isLive := false
+		for _, key := range live {
+			isLive = isLive || ch.Key == key
+		}
+		if !isLive {
+			restart = append(restart, ch.Key)
+		}
+	}
+
+	if len(restart) > 0 {
+		return fmt.Errorf("%w: %v", ErrReloadRestart, strings.Join(restart, ", "))
+	}
+
+	if len(changes) == 0 {
+		log.Infof("Config reloaded: nothing changed\n")
+	}
+	for _, ch := range changes {
+		log.Infof("Config reloaded: %v\n", ch)
+	}
+	return nil
+}
+
+func setLogLevel(log Logger, level string) error {
+	if l, ok := log.(levelSetter); ok {
+		return l.SetLevel(level)
+	}
+	return nil
+}
@@ -3,40 +3,112 @@ package app
 import (
 	"context"
 	"fmt"
+	"io"
+	"math"
+	"os"
 	"os/signal"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/archive"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/jobs"
 	logger "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/logger"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/storage"
 )
 
+const (
+	defaultInflightTimeout = time.Minute
+	defaultMaxAttempts     = 5
+	defaultLease           = 30 * time.Second
+	expireBatch            = 500
+	dryRunLimit            = 1000
+	schedulerLease         = "scheduler"
+)
+
 type SchedulerConf struct {
-	Logger  logger.Conf   `toml:"logger"`
-	Storage storage.Conf  `toml:"storage"`
-	URLRMQ  string        `toml:"url_rmq"`
-	Period  time.Duration `toml:"period"`
+	Logger          logger.Conf   `toml:"logger"`
+	Storage         storage.Conf  `toml:"storage"`
+	HealthAddr      string        `toml:"health_addr"`
+	Period          time.Duration `toml:"period"`
+	Retention       time.Duration `toml:"retention"`
+	InflightTimeout time.Duration `toml:"inflight_timeout"`
+	MaxAttempts     int           `toml:"max_attempts"`
+	Lease           time.Duration `toml:"lease"`
+	NodeID          string        `toml:"node_id"`
+	Archive         archive.Conf  `toml:"archive"`
+	Jobs            SchedulerJobs `toml:"jobs"`
+	TransportConf
+}
+
+func (c SchedulerConf) Validate() error {
+	v := &config.Validator{}
+	c.Logger.Check(v.Section("logger"))
+	c.Storage.Check(v.Section("storage"))
+	c.TransportConf.Check(v)
+	if c.HealthAddr != "" {
+		v.Addr("health_addr", c.HealthAddr)
+	}
+	v.Positive("period", c.Period)
+	v.NotNegative("retention", c.Retention)
+	v.NotNegative("inflight_timeout", c.InflightTimeout)
+	v.Range("max_attempts", int64(c.MaxAttempts), 0, math.MaxInt32)
+	if c.Lease != 0 && c.Lease < time.Second {
+		v.AddKeyf("lease", "must be at least 1s, got %v", c.Lease)
+	}
+	c.Archive.Check(v.Section("archive"))
+	c.Jobs.Notify.Check(v.Section("jobs.notify"))
+	c.Jobs.Cleanup.Check(v.Section("jobs.cleanup"))
+	return v.Err()
+}
+
+// SchedulerJobs configures the jobs, both run every period by default.
+type SchedulerJobs struct {
+	Notify  jobs.Conf `toml:"notify"`
+	Cleanup jobs.Conf `toml:"cleanup"`
 }
 
+const (
+	jobNotify  = "notify"
+	jobCleanup = "cleanup"
+)
+
 type Scheduler struct {
 	conf     SchedulerConf
+	confMu   sync.RWMutex
+	loadConf func() (SchedulerConf, error)
 	log      Logger
 	storage  SchedulerStorage
 	producer SchedulerProducer
+	nodeID   string
+	leader   atomic.Bool
 }
 
 type SchedulerStorage interface {
 	Connect(context.Context) error
 	Close(context.Context) error
+	ListExpiredEvents(context.Context, time.Time, time.Time, int64, int) ([]model.Event, error)
+	DeleteEvents(context.Context, []int64) (int64, error)
+	InsertEvent(context.Context, *model.Event) error
+	UpdateEventNotified(context.Context, int64) error
+	EnqueueNotifications(context.Context, time.Time) (int64, error)
+	ClaimNotifications(context.Context, time.Time, time.Duration, int) ([]model.Notification, error)
 	ListEventsDayOfNotice(context.Context, time.Time) ([]model.Event, error)
-	DeleteEventsOlderDate(context.Context, time.Time) (int64, error)
+	ListDueNotifications(context.Context, time.Time, time.Duration, int) ([]model.Notification, error)
+	ReleaseNotification(context.Context, int64, string) error
+	AcquireLease(context.Context, string, string, time.Duration) (bool, error)
+	ReleaseLease(context.Context, string, string) error
+	PurgeDedupKeys(context.Context) (int64, error)
 }
 
 type SchedulerProducer interface {
 	Connect(context.Context) error
 	Close(context.Context) error
-	SendNotification(context.Context, *model.Event) error
+	SendNotification(context.Context, *model.Notification) error
 }
 
 func NewScheduler(log Logger, conf SchedulerConf, storage SchedulerStorage, producer SchedulerProducer) *Scheduler {
@@ -45,6 +117,11 @@ func NewScheduler(log Logger, conf SchedulerConf, storage SchedulerStorage, prod
 		log:      log,
 		storage:  storage,
 		producer: producer,
+		nodeID:   conf.NodeID,
+	}
+	if scheduler.nodeID == "" {
+		hostname, _ := os.Hostname()
+		scheduler.nodeID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -56,75 +133,450 @@ func NewScheduler(log Logger, conf SchedulerConf, storage SchedulerStorage, prod
 	}
 
 	if err := producer.Connect(ctx); err != nil {
-		exitfail(fmt.Sprintf("Can't connect to RabbitMQ:%v", err))
+		exitfail(fmt.Sprintf("Can't connect to %v:%v", conf.Name(), err))
 	}
 
 	return scheduler
 }
 
-func (s Scheduler) Run() {
+func (s *Scheduler) SetConfLoader(load func() (SchedulerConf, error)) {
+	s.loadConf = load
+}
+
+func (s *Scheduler) config() SchedulerConf {
+	s.confMu.RLock()
+	defer s.confMu.RUnlock()
+	return s.conf
+}
+
+// Reload re-reads the config and applies the log level, jobs and retention.
+// Run must restart the jobs after a successful reload.
+func (s *Scheduler) Reload() error {
+	if s.loadConf == nil {
+		return ErrNoConfLoader
+	}
+
+	conf, err := s.loadConf()
+	if err != nil {
+		return err
+	}
+
+	if err := checkReload(s.log, s.config(), conf, "logger.level", "period", "retention",
+		"inflight_timeout", "max_attempts", "lease", "archive.dir", "archive.format",
+		"jobs.notify.schedule", "jobs.notify.jitter", "jobs.notify.timeout", "jobs.notify.on_error",
+		"jobs.cleanup.schedule", "jobs.cleanup.jitter", "jobs.cleanup.timeout", "jobs.cleanup.on_error",
+	); err != nil {
+		return err
+	}
+	if err := setLogLevel(s.log, conf.Logger.Level); err != nil {
+		return err
+	}
+	s.confMu.Lock()
+	s.conf = conf
+	s.confMu.Unlock()
+	return nil
+}
+
+// retentionDate returns the date before which events are deleted,
+// one year back unless retention is configured. Users may have own retention.
+func (s *Scheduler) retentionDate(date time.Time) time.Time {
+	if retention := s.config().Retention; retention != 0 {
+		return date.Add(-retention)
+	}
+	return date.AddDate(-1, 0, 0)
+}
+
+func (s *Scheduler) inflightTimeout() time.Duration {
+	if timeout := s.config().InflightTimeout; timeout != 0 {
+		return timeout
+	}
+	return defaultInflightTimeout
+}
+
+func (s *Scheduler) maxAttempts() int {
+	if attempts := s.config().MaxAttempts; attempts != 0 {
+		return attempts
+	}
+	return defaultMaxAttempts
+}
+
+func (s *Scheduler) lease() time.Duration {
+	if lease := s.config().Lease; lease != 0 {
+		return lease
+	}
+	return defaultLease
+}
+
+// elect takes or renews the scheduler lease. Only the leader runs the jobs,
+// the others take over when the leader stops renewing its lease.
+func (s *Scheduler) elect(ctx context.Context) bool {
+	leader, err := s.storage.AcquireLease(ctx, schedulerLease, s.nodeID, s.lease())
+	if err != nil {
+		s.log.Errorf("Can't acquire lease:%v\n", err)
+		leader = false
+	}
+	if s.leader.Swap(leader) != leader {
+		if leader {
+			s.log.Infof("Node %v became the leader\n", s.nodeID)
+		} else {
+			s.log.Infof("Node %v is not the leader anymore\n", s.nodeID)
+		}
+	}
+	return leader
+}
+
+// RunJobs sends notifications and removes old events if this node is the leader.
+func (s *Scheduler) RunJobs(ctx context.Context, date time.Time) error {
+	if !s.elect(ctx) {
+		s.log.Debugf("Node %v is not the leader, skipping jobs\n", s.nodeID)
+		return nil
+	}
+	if err := s.notify(ctx, date); err != nil {
+		return err
+	}
+	return s.cleanup(ctx, date)
+}
+
+func (s *Scheduler) notify(ctx context.Context, date time.Time) error {
+	s.log.Debugf("Starting notification process...\n")
+	sent, err := s.SendNotification(ctx, date)
+	if err != nil {
+		return err
+	}
+	s.log.Debugf("Notifications  sent:%v\n", sent)
+	return nil
+}
+
+func (s *Scheduler) cleanup(ctx context.Context, date time.Time) error {
+	s.log.Debugf("Starting to remove events that are older than %v\n", s.retentionDate(date))
+	deleted, err := s.ExpireEvents(ctx, date)
+	if err != nil {
+		return err
+	}
+	s.log.Debugf("Old events deleted:%v\n", deleted)
+
+	purged, err := s.storage.PurgeDedupKeys(ctx)
+	if err != nil {
+		return err
+	}
+	s.log.Debugf("Expired dedup keys purged:%v\n", purged)
+	return nil
+}
+
+// leaderOnly skips the job on nodes not holding the lease, which is renewed
+// by Run independently of the jobs.
+func (s *Scheduler) leaderOnly(name string, run jobs.Func) jobs.Func {
+	return func(ctx context.Context, date time.Time) error {
+		if !s.leader.Load() {
+			s.log.Debugf("Node %v is not the leader, skipping %v\n", s.nodeID, name)
+			return nil
+		}
+		return run(ctx, date)
+	}
+}
+
+// Jobs returns the runner of the notify and cleanup jobs. Errors of the jobs
+// are transient by default: notifications stay in the outbox and the
+// producer reconnects, so the next run tries again.
+func (s *Scheduler) Jobs() (*jobs.Runner, error) {
+	conf := s.config()
+	period := fmt.Sprintf("@every %v", conf.Period)
+	runner := jobs.NewRunner(s.log)
+	for _, j := range []struct {
+		name string
+		conf jobs.Conf
+		run  jobs.Func
+	}{
+		{jobNotify, conf.Jobs.Notify, s.notify},
+		{jobCleanup, conf.Jobs.Cleanup, s.cleanup},
+	} {
+		job, err := jobs.NewJob(j.name, j.conf, period, s.leaderOnly(j.name, j.run))
+		if err != nil {
+			return nil, fmt.Errorf("job %v: %w", j.name, err)
+		}
+		if err := runner.Add(job); err != nil {
+			return nil, err
+		}
+	}
+	return runner, nil
+}
+
+// startJobs runs the jobs until stop is called, done gets the error of
+// a job failed with the fail policy and is closed when the jobs stop.
+func (s *Scheduler) startJobs(ctx context.Context) (stop func(), done <-chan error) {
+	runner, err := s.Jobs()
+	if err != nil {
+		exitfail(fmt.Sprintf("Can't create jobs:%v", err))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- runner.Run(ctx)
+		close(errc)
+	}()
+	return func() {
+		cancel()
+		<-errc
+	}, errc
+}
+
+func (s *Scheduler) Run() {
 	ctx, cancel := signal.NotifyContext(context.Background(),
-		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+		syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	ticker := time.NewTicker(s.conf.Period)
-	defer ticker.Stop()
+	hup, stopHup := notifyReload()
+	defer stopHup()
+
+	// renew the lease a few times per lease period, so it does not expire between jobs
+	leaseTicker := time.NewTicker(s.lease() / 3)
+	defer leaseTicker.Stop()
+	s.elect(ctx)
+
+	stopJobs, jobsDone := s.startJobs(ctx)
+	stop := func() {
+		stopJobs()
+		ctxStop, cancelStop := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelStop()
+		s.Stop(ctxStop)
+	}
 
 	for {
 		select {
+		case <-hup:
+			old := s.config()
+			if err := s.Reload(); err != nil {
+				s.log.Errorf("Can't reload config:%v\n", err)
+				continue
+			}
+			leaseTicker.Reset(s.lease() / 3)
+			if conf := s.config(); conf.Period != old.Period || conf.Jobs != old.Jobs {
+				stopJobs()
+				stopJobs, jobsDone = s.startJobs(ctx)
+			}
+
 		case <-ctx.Done():
-			ctxStop, cancelStop := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancelStop()
-			s.Stop(ctxStop)
+			stop()
 			return
 
-		case <-ticker.C:
-			// TODO Должны ли мы завершить работу если процесс возвращает ошибку?
-			date := time.Now()
-			s.log.Debugf("Starting notification process...\n")
-			sent, err := s.SendNotification(ctx, date)
-			if err != nil {
-				s.log.Errorf("%v", err)
-				return
-			}
-			s.log.Debugf("Notifications  sent:%v\n", sent)
+		case <-leaseTicker.C:
+			s.elect(ctx)
 
-			s.log.Debugf("Starting to remove events that are older than a year\n")
-			deleted, err := s.DeleteEventsOlderDate(ctx, date.AddDate(-1, 0, 0))
-			if err != nil {
-				s.log.Errorf("%v", err)
-				return
+		case err := <-jobsDone:
+			jobsDone = nil
+			if err == nil {
+				continue
 			}
-			s.log.Debugf("Old events deleted:%v\n", deleted)
-			s.log.Debugf("Notification process has finished\n")
+			stop()
+			exitfail(fmt.Sprintf("Scheduler stopped:%v", err))
 		}
 	}
 }
 
-func (s Scheduler) Stop(ctx context.Context) {
+func (s *Scheduler) Stop(ctx context.Context) {
+	if s.leader.Load() {
+		if err := s.storage.ReleaseLease(ctx, schedulerLease, s.nodeID); err != nil {
+			s.log.Errorf("Can't release lease:%v\n", err)
+		}
+	}
 	s.producer.Close(ctx)
 	s.log.Debugf("Producer closed\n")
 	s.storage.Close(ctx)
 	s.log.Debugf("Storage closed\n")
 }
 
-func (s Scheduler) DeleteEventsOlderDate(ctx context.Context, date time.Time) (int64, error) {
-	return s.storage.DeleteEventsOlderDate(ctx, date)
+// ExpireEvents deletes events past their retention in batches. When archival
+// is enabled every batch is archived first. A batch that can't be archived is
+// kept for the next run and skipped, so it doesn't hold back the later ones,
+// the error is returned when all the batches are done.
+func (s *Scheduler) ExpireEvents(ctx context.Context, date time.Time) (int64, error) {
+	deleted := int64(0)
+	afterID := int64(0)
+	var errArchive error
+	for {
+		events, err := s.storage.ListExpiredEvents(ctx, date, s.retentionDate(date), afterID, expireBatch)
+		if err != nil {
+			return deleted, err
+		}
+		if len(events) == 0 {
+			return deleted, errArchive
+		}
+		afterID = events[len(events)-1].ID
+
+		if err := s.archive(date, events); err != nil {
+			s.log.Errorf("Can't archive events %v-%v, kept for the next run:%v\n", events[0].ID, afterID, err)
+			if errArchive == nil {
+				errArchive = fmt.Errorf("can't archive events:%w", err)
+			}
+		} else {
+			ids := make([]int64, len(events))
+			for i := range events {
+				ids[i] = events[i].ID
+			}
+			n, err := s.storage.DeleteEvents(ctx, ids)
+			deleted += n
+			if err != nil {
+				return deleted, err
+			}
+		}
+
+		if len(events) < expireBatch {
+			return deleted, errArchive
+		}
+	}
+}
+
+func (s *Scheduler) archive(date time.Time, events []model.Event) error {
+	conf := s.config().Archive
+	if !conf.Enabled() {
+		return nil
+	}
+	filename, err := archive.New(conf).Write(date, events)
+	if err != nil {
+		return err
+	}
+	s.log.Infof("Archived %v events to %v\n", len(events), filename)
+	return nil
 }
 
-func (s Scheduler) SendNotification(ctx context.Context, date time.Time) (int64, error) {
+// RestoreEvents inserts archived events again under new IDs. Reminders that
+// were notified or were due before the date are marked notified, so restored
+// events don't send stale reminders. The events expire again on the next
+// cleanup unless the retention was extended.
+func (s *Scheduler) RestoreEvents(ctx context.Context, date time.Time, events []model.Event) (int64, error) {
+	restored := int64(0)
+	for i := range events {
+		e := events[i]
+		e.ID = 0
+		notified := make([]bool, len(e.Reminders))
+		for i, r := range e.Reminders {
+			notified[i] = r.Notified || !r.NotifyTime.After(date)
+		}
+
+		if err := s.storage.InsertEvent(ctx, &e); err != nil {
+			return restored, fmt.Errorf("can't restore event %q:%w", e.Title, err)
+		}
+		restored++
+
+		for i, r := range e.Reminders {
+			if !notified[i] {
+				continue
+			}
+			if err := s.storage.UpdateEventNotified(ctx, r.ID); err != nil {
+				return restored, fmt.Errorf("can't restore reminder of event %v:%w", e.ID, err)
+			}
+		}
+	}
+	return restored, nil
+}
+
+// SendNotification moves due reminders to the outbox and publishes the ones
+// that are pending or were queued longer than the in-flight timeout ago.
+func (s *Scheduler) SendNotification(ctx context.Context, date time.Time) (int64, error) {
 	sent := int64(0)
-	events, err := s.storage.ListEventsDayOfNotice(ctx, date)
+	enqueued, err := s.storage.EnqueueNotifications(ctx, date)
 	if err != nil {
 		return sent, err
 	}
+	s.log.Debugf("Notifications enqueued:%v\n", enqueued)
 
-	for i := range events {
-		err := s.producer.SendNotification(ctx, &events[i])
+	notifications, err := s.storage.ClaimNotifications(ctx, date, s.inflightTimeout(), s.maxAttempts())
+	if err != nil {
+		return sent, err
+	}
+
+	for i := range notifications {
+		err := s.producer.SendNotification(ctx, &notifications[i])
 		if err != nil {
+			if errRelease := s.storage.ReleaseNotification(ctx, notifications[i].ID, err.Error()); errRelease != nil {
+				s.log.Errorf("Can't release notification:%v\n", errRelease)
+			}
 			return sent, fmt.Errorf("SendNotification:%w", err)
 		}
 		sent++
 	}
 	return sent, nil
 }
+
+// RunOnce runs the notify and cleanup jobs for date and prints their results
+// to w. The dry run prints the notifications that would be sent and the
+// events that would be deleted, nothing is published or changed. Otherwise
+// the scheduler lease is taken first, so the jobs don't run alongside a
+// running scheduler, ErrNotLeader is returned while another node holds it.
+func (s *Scheduler) RunOnce(ctx context.Context, date time.Time, dryRun bool, w io.Writer) error {
+	if dryRun {
+		return s.dryRun(ctx, date, w)
+	}
+	if !s.elect(ctx) {
+		return ErrNotLeader
+	}
+
+	sent, err := s.SendNotification(ctx, date)
+	fmt.Fprintf(w, "Notifications sent: %v\n", sent)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := s.ExpireEvents(ctx, date)
+	fmt.Fprintf(w, "Events deleted: %v\n", deleted)
+	if err != nil {
+		return err
+	}
+
+	purged, err := s.storage.PurgeDedupKeys(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Dedup keys purged: %v\n", purged)
+	return nil
+}
+
+func (s *Scheduler) dryRun(ctx context.Context, date time.Time, w io.Writer) error {
+	events, err := s.storage.ListEventsDayOfNotice(ctx, date)
+	if err != nil {
+		return err
+	}
+	notifications, err := s.storage.ListDueNotifications(ctx, date, s.inflightTimeout(), s.maxAttempts())
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Notifications to send at %v:\n", date.Format(time.RFC3339))
+	fmt.Fprintln(tw, "NOTIFICATION\tEVENT\tUSER\tCHANNEL\tNOTIFY TIME\tTITLE")
+	for _, n := range notifications {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\n", n.ID, n.EventID, n.UserID, channelName(n.Channel),
+			n.NotifyTime.Format(time.RFC3339), n.Title)
+	}
+	for _, e := range events {
+		for _, r := range e.Reminders {
+			fmt.Fprintf(tw, "new\t%v\t%v\t%v\t%v\t%v\n", e.ID, e.UserID, channelName(r.Channel),
+				r.NotifyTime.Format(time.RFC3339), e.Title)
+		}
+	}
+	tw.Flush()
+
+	before := s.retentionDate(date)
+	expired, err := s.storage.ListExpiredEvents(ctx, date, before, 0, dryRunLimit)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(tw, "\nEvents to delete, ended before %v or own retention:\n", before.Format(time.RFC3339))
+	fmt.Fprintln(tw, "EVENT\tUSER\tOFF TIME\tTITLE")
+	for _, e := range expired {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", e.ID, e.UserID, e.OffTime.Format(time.RFC3339), e.Title)
+	}
+	if len(expired) == dryRunLimit {
+		fmt.Fprintf(tw, "...\tonly the first %v are shown\n", dryRunLimit)
+	}
+	return tw.Flush()
+}
+
+func channelName(channel string) string {
+	if channel == "" {
+		return "all"
+	}
+	return channel
+}
@@ -1,11 +1,16 @@
 package app
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/archive"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/jobs"
 	logger "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/logger"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
 	memorystorage "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/storage/memory"
@@ -13,6 +18,32 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+type followerStorage struct {
+	*memorystorage.Storage
+	leader bool
+}
+
+func (s *followerStorage) AcquireLease(context.Context, string, string, time.Duration) (bool, error) {
+	return s.leader, nil
+}
+
+// recordingProducer keeps the IDs of the claimed notifications it sends.
+type recordingProducer struct {
+	*internalrmq.DummyProducer
+	sent []int64
+}
+
+func (p *recordingProducer) SendNotification(ctx context.Context, notification *model.Notification) error {
+	p.sent = append(p.sent, notification.ID)
+	return p.DummyProducer.SendNotification(ctx, notification)
+}
+
+func (p *recordingProducer) last(t *testing.T) int64 {
+	t.Helper()
+	require.NotEmpty(t, p.sent)
+	return p.sent[len(p.sent)-1]
+}
+
 func TestScheduler(t *testing.T) {
 	ctx := context.Background()
 
@@ -31,7 +62,7 @@ func TestScheduler(t *testing.T) {
 			Description: "DescriptionN1",
 			OnTime:      currTime.AddDate(0, 0, 1),
 			OffTime:     currTime.AddDate(0, 0, 7),
-			NotifyTime:  currTime.AddDate(0, 0, 0),
+			Reminders:   []model.Reminder{{Offset: 24 * time.Hour, NotifyTime: currTime}},
 		}
 
 		err := db.InsertEvent(ctx, &event)
@@ -54,7 +85,7 @@ func TestScheduler(t *testing.T) {
 			Description: "DescriptionN1",
 			OnTime:      currTime.AddDate(0, 0, 1),
 			OffTime:     currTime.AddDate(0, 0, 7),
-			NotifyTime:  currTime.AddDate(0, 0, 0),
+			Reminders:   []model.Reminder{{Offset: 24 * time.Hour, NotifyTime: currTime}},
 		}
 
 		err := db.InsertEvent(ctx, &event)
@@ -63,11 +94,296 @@ func TestScheduler(t *testing.T) {
 		eventID := event.ID
 		require.NotEmpty(t, eventID)
 
-		n, err := scheduler.DeleteEventsOlderDate(ctx, currTime.AddDate(1, 0, 1))
+		n, err := scheduler.ExpireEvents(ctx, currTime.AddDate(1, 0, 8))
 		require.NoError(t, err)
 		require.EqualValues(t, int64(1), n)
 
 		_, err = db.LookupEvent(ctx, eventID)
 		require.ErrorIs(t, err, memorystorage.ErrEventNotFound)
 	})
+
+	t.Run("test_retention_archive", func(t *testing.T) {
+		currTime := time.Now()
+		dir := t.TempDir()
+		scheduler := Scheduler{log: log, storage: db, producer: producer, conf: SchedulerConf{
+			Archive: archive.Conf{Dir: dir},
+		}}
+
+		events := []model.Event{
+			{
+				UserID: 600, Title: "Own retention", OnTime: currTime.AddDate(0, 0, -3), OffTime: currTime.AddDate(0, 0, -2),
+				Reminders: []model.Reminder{{Offset: time.Hour, NotifyTime: currTime.AddDate(0, 0, -3).Add(-time.Hour)}},
+			},
+			{UserID: 601, Title: "Global retention", OnTime: currTime.AddDate(0, 0, -3), OffTime: currTime.AddDate(0, 0, -2)},
+		}
+		for i := range events {
+			require.NoError(t, db.InsertEvent(ctx, &events[i]))
+		}
+		defer db.DeleteEvent(ctx, events[1].ID)
+		require.NoError(t, db.SetUserRetention(ctx, 600, 24*time.Hour))
+
+		n, err := scheduler.ExpireEvents(ctx, currTime)
+		require.NoError(t, err)
+		require.EqualValues(t, 1, n)
+		_, err = db.LookupEvent(ctx, events[0].ID)
+		require.ErrorIs(t, err, memorystorage.ErrEventNotFound)
+		_, err = db.LookupEvent(ctx, events[1].ID)
+		require.NoError(t, err, "kept for the global retention")
+
+		files, err := filepath.Glob(filepath.Join(dir, "events-*.jsonl.gz"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		f, err := os.Open(files[0])
+		require.NoError(t, err)
+		defer f.Close()
+		archived, err := archive.ReadJSONL(f)
+		require.NoError(t, err)
+		require.Len(t, archived, 1)
+		require.Equal(t, events[0].ID, archived[0].ID)
+		require.Equal(t, "Own retention", archived[0].Title)
+
+		n, err = scheduler.RestoreEvents(ctx, currTime, archived)
+		require.NoError(t, err)
+		require.EqualValues(t, 1, n)
+		restored, err := db.ListEvents(ctx, 600, model.TagFilter{})
+		require.NoError(t, err)
+		require.Len(t, restored, 1)
+		defer db.DeleteEvent(ctx, restored[0].ID)
+		require.NotEqual(t, events[0].ID, restored[0].ID, "restored under a new ID")
+		require.Equal(t, "Own retention", restored[0].Title)
+		require.Len(t, restored[0].Reminders, 1)
+		require.True(t, restored[0].Reminders[0].Notified, "past reminders are not sent again")
+
+		scheduler.conf.Archive.Dir = filepath.Join(dir, "missing")
+		require.NoError(t, db.SetUserRetention(ctx, 601, 24*time.Hour))
+		_, err = scheduler.ExpireEvents(ctx, currTime)
+		require.Error(t, err)
+		_, err = db.LookupEvent(ctx, events[1].ID)
+		require.NoError(t, err, "not deleted when it can't be archived")
+	})
+
+	t.Run("test_archive_failed_batch", func(t *testing.T) {
+		currTime := time.Now()
+		dir := t.TempDir()
+		storage := memorystorage.New()
+		scheduler := Scheduler{log: log, storage: storage, producer: producer, conf: SchedulerConf{
+			Retention: 24 * time.Hour,
+			Archive:   archive.Conf{Dir: dir},
+		}}
+
+		for i := 0; i < expireBatch+1; i++ {
+			event := model.Event{UserID: 610, Title: "Expired", OnTime: currTime.AddDate(0, 0, -3), OffTime: currTime.AddDate(0, 0, -2)}
+			require.NoError(t, storage.InsertEvent(ctx, &event))
+		}
+		first, err := storage.ListExpiredEvents(ctx, currTime, currTime, 0, 1)
+		require.NoError(t, err)
+		// a directory in place of the archive of the first batch fails its rename
+		name := fmt.Sprintf("events-%s-%d.jsonl.gz", currTime.UTC().Format("20060102T150405Z"), first[0].ID)
+		require.NoError(t, os.Mkdir(filepath.Join(dir, name), 0o755))
+
+		n, err := scheduler.ExpireEvents(ctx, currTime)
+		require.Error(t, err)
+		require.EqualValues(t, 1, n, "the next batch is deleted")
+		_, err = storage.LookupEvent(ctx, first[0].ID)
+		require.NoError(t, err, "the failed batch is kept for the next run")
+	})
+
+	t.Run("test_notification_outbox", func(t *testing.T) {
+		currTime := time.Now()
+		event := model.Event{
+			UserID:    300,
+			Title:     "TitleN1",
+			OnTime:    currTime.AddDate(0, 0, 1),
+			OffTime:   currTime.AddDate(0, 0, 7),
+			Reminders: []model.Reminder{{Offset: 24 * time.Hour, NotifyTime: currTime}},
+		}
+		require.NoError(t, db.InsertEvent(ctx, &event))
+		defer db.DeleteEvent(ctx, event.ID)
+
+		producer := &recordingProducer{DummyProducer: internalrmq.NewDummyProducer()}
+		scheduler := Scheduler{log: log, storage: db, producer: producer}
+		n, err := scheduler.SendNotification(ctx, currTime)
+		require.NoError(t, err)
+		require.EqualValues(t, int64(1), n)
+
+		n, err = scheduler.SendNotification(ctx, currTime.Add(time.Second))
+		require.NoError(t, err)
+		require.EqualValues(t, int64(0), n, "in-flight notification is not sent again")
+
+		currTime = currTime.Add(defaultInflightTimeout)
+		n, err = scheduler.SendNotification(ctx, currTime)
+		require.NoError(t, err)
+		require.EqualValues(t, int64(1), n, "timed out notification is retried")
+		require.Len(t, producer.sent, 2)
+		require.Equal(t, producer.sent[0], producer.sent[1])
+
+		id := producer.last(t)
+		require.NoError(t, db.DeliverNotification(ctx, id))
+		require.Error(t, db.DeliverNotification(ctx, id))
+		notification, err := db.LookupNotification(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, model.NotificationDelivered, notification.Status)
+		require.Equal(t, 2, notification.Attempts)
+
+		n, err = scheduler.SendNotification(ctx, currTime.Add(defaultInflightTimeout))
+		require.NoError(t, err)
+		require.EqualValues(t, int64(0), n)
+	})
+
+	t.Run("test_notification_attempts", func(t *testing.T) {
+		currTime := time.Now()
+		event := model.Event{
+			UserID:    400,
+			Title:     "TitleN1",
+			OnTime:    currTime.AddDate(0, 0, 1),
+			OffTime:   currTime.AddDate(0, 0, 7),
+			Reminders: []model.Reminder{{Offset: 24 * time.Hour, NotifyTime: currTime}},
+		}
+		require.NoError(t, db.InsertEvent(ctx, &event))
+		defer db.DeleteEvent(ctx, event.ID)
+
+		producer := &recordingProducer{DummyProducer: internalrmq.NewDummyProducer()}
+		scheduler := Scheduler{log: log, storage: db, producer: producer, conf: SchedulerConf{MaxAttempts: 2}}
+		for _, expected := range []int64{1, 1, 0} {
+			n, err := scheduler.SendNotification(ctx, currTime)
+			require.NoError(t, err)
+			require.EqualValues(t, expected, n)
+			currTime = currTime.Add(defaultInflightTimeout)
+		}
+
+		notification, err := db.LookupNotification(ctx, producer.last(t))
+		require.NoError(t, err)
+		require.Equal(t, model.NotificationFailed, notification.Status)
+	})
+
+	t.Run("test_leader_election", func(t *testing.T) {
+		currTime := time.Now()
+		storage := &followerStorage{Storage: memorystorage.New()}
+		event := model.Event{
+			UserID:    500,
+			Title:     "TitleN1",
+			OnTime:    currTime.AddDate(0, 0, 1),
+			OffTime:   currTime.AddDate(0, 0, 7),
+			Reminders: []model.Reminder{{Offset: 24 * time.Hour, NotifyTime: currTime}},
+		}
+		require.NoError(t, storage.InsertEvent(ctx, &event))
+
+		producer := &recordingProducer{DummyProducer: internalrmq.NewDummyProducer()}
+		scheduler := Scheduler{log: log, storage: storage, producer: producer, nodeID: "node-1"}
+		require.NoError(t, scheduler.RunJobs(ctx, currTime))
+		require.False(t, scheduler.leader.Load())
+		require.Empty(t, producer.sent, "follower does not run jobs")
+
+		storage.leader = true
+		require.NoError(t, scheduler.RunJobs(ctx, currTime))
+		require.True(t, scheduler.leader.Load())
+		notification, err := storage.LookupNotification(ctx, producer.last(t))
+		require.NoError(t, err)
+		require.Equal(t, model.NotificationQueued, notification.Status)
+	})
+
+	t.Run("test_reload", func(t *testing.T) {
+		currTime := time.Now()
+		conf := SchedulerConf{Period: time.Minute}
+		conf.Logger.Level = "DEBUG"
+		scheduler := Scheduler{log: log, storage: db, producer: producer, conf: conf}
+		require.True(t, currTime.AddDate(-1, 0, 0).Equal(scheduler.retentionDate(currTime)))
+
+		newConf := conf
+		newConf.Period = time.Second
+		newConf.Retention = 24 * time.Hour
+		scheduler.SetConfLoader(func() (SchedulerConf, error) { return newConf, nil })
+		require.NoError(t, scheduler.Reload())
+		require.EqualValues(t, time.Second, scheduler.conf.Period)
+		require.True(t, currTime.Add(-24*time.Hour).Equal(scheduler.retentionDate(currTime)))
+
+		newConf.URLRMQ = "amqp://localhost/"
+		require.ErrorIs(t, scheduler.Reload(), ErrReloadRestart)
+	})
+
+	t.Run("test_jobs", func(t *testing.T) {
+		currTime := time.Now()
+		storage := &followerStorage{Storage: memorystorage.New()}
+		conf := SchedulerConf{Period: time.Minute}
+		conf.Jobs.Cleanup = jobs.Conf{Schedule: "30 3 * * *", OnError: "fail"}
+		producer := &recordingProducer{DummyProducer: internalrmq.NewDummyProducer()}
+		scheduler := Scheduler{log: log, storage: storage, producer: producer, conf: conf}
+
+		runner, err := scheduler.Jobs()
+		require.NoError(t, err)
+		status := runner.Status()
+		require.Len(t, status, 2)
+		require.Equal(t, "cleanup", status[0].Name)
+		require.Equal(t, "30 3 * * *", status[0].Schedule)
+		require.Equal(t, "notify", status[1].Name)
+		require.Equal(t, "@every 1m0s", status[1].Schedule, "period by default")
+
+		event := model.Event{
+			UserID:    800,
+			Title:     "TitleN1",
+			OnTime:    currTime.AddDate(0, 0, 1),
+			OffTime:   currTime.AddDate(0, 0, 7),
+			Reminders: []model.Reminder{{Offset: 24 * time.Hour, NotifyTime: currTime}},
+		}
+		require.NoError(t, storage.InsertEvent(ctx, &event))
+
+		require.NoError(t, runner.RunNow(ctx, "notify", currTime))
+		require.Empty(t, producer.sent, "follower skips jobs")
+
+		storage.leader = true
+		scheduler.elect(ctx)
+		require.NoError(t, runner.RunNow(ctx, "notify", currTime))
+		notification, err := storage.LookupNotification(ctx, producer.last(t))
+		require.NoError(t, err)
+		require.Equal(t, model.NotificationQueued, notification.Status)
+
+		scheduler.conf.Jobs.Notify.Schedule = "sometimes"
+		_, err = scheduler.Jobs()
+		require.ErrorIs(t, err, jobs.ErrSchedule)
+	})
+}
+
+func TestSchedulerRunOnce(t *testing.T) {
+	ctx := context.Background()
+	db := memorystorage.New()
+	log := logger.NewLogger("DEBUG", os.Stdout)
+	scheduler := Scheduler{log: log, storage: db, producer: internalrmq.NewDummyProducer()}
+
+	date := time.Date(2023, 1, 2, 15, 0, 0, 0, time.UTC)
+	due := model.Event{
+		UserID:    100,
+		Title:     "Standup",
+		OnTime:    date.Add(10 * time.Minute),
+		OffTime:   date.Add(time.Hour),
+		Reminders: []model.Reminder{{Offset: 15 * time.Minute, Channel: "email"}},
+	}
+	due.ScheduleReminders()
+	old := model.Event{UserID: 100, Title: "Retro", OnTime: date.AddDate(-2, 0, 0), OffTime: date.AddDate(-2, 0, 0)}
+	require.NoError(t, db.InsertEvent(ctx, &due))
+	require.NoError(t, db.InsertEvent(ctx, &old))
+
+	var out bytes.Buffer
+	require.NoError(t, scheduler.RunOnce(ctx, date, true, &out))
+	require.Contains(t, out.String(), "Notifications to send at 2023-01-02T15:00:00Z")
+	require.Regexp(t, `new\s+1\s+100\s+email\s+2023-01-02T14:55:00Z\s+Standup`, out.String())
+	require.Regexp(t, `2\s+100\s+2021-01-02T15:00:00Z\s+Retro`, out.String())
+
+	events, err := db.ListEventsDayOfNotice(ctx, date)
+	require.NoError(t, err)
+	require.Len(t, events, 1, "dry run changes nothing")
+	_, err = db.LookupEvent(ctx, old.ID)
+	require.NoError(t, err)
+
+	out.Reset()
+	follower := Scheduler{log: log, storage: &followerStorage{Storage: db}, producer: internalrmq.NewDummyProducer()}
+	require.ErrorIs(t, follower.RunOnce(ctx, date, false, &out), ErrNotLeader, "a scheduler is running")
+	require.Empty(t, out.String())
+
+	require.NoError(t, scheduler.RunOnce(ctx, date, false, &out))
+	require.Equal(t, "Notifications sent: 1\nEvents deleted: 1\nDedup keys purged: 0\n", out.String())
+
+	out.Reset()
+	require.NoError(t, scheduler.RunOnce(ctx, date, true, &out))
+	require.NotContains(t, out.String(), "Standup", "nothing left to send")
+	require.NotContains(t, out.String(), "Retro")
 }
@@ -2,27 +2,116 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
 	logger "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/logger"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/notifier"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/storage"
+	memorystorage "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/storage/memory"
 )
 
+const (
+	DedupStorage = "storage"
+	DedupMemory  = "memory"
+
+	defaultDedupTTL   = 7 * 24 * time.Hour
+	defaultDedupLease = 5 * time.Minute
+	dedupPurgePeriod  = time.Hour
+
+	defaultWorkers      = 4
+	defaultDrainTimeout = 30 * time.Second
+)
+
+// SenderConf runs workers goroutines delivering notifications. On shutdown
+// the messages being delivered are given drain_timeout to finish.
 type SenderConf struct {
-	Logger  logger.Conf  `toml:"logger"`
-	Storage storage.Conf `toml:"storage"`
-	URLRMQ  string       `toml:"url_rmq"`
+	Logger       logger.Conf   `toml:"logger"`
+	Storage      storage.Conf  `toml:"storage"`
+	HealthAddr   string        `toml:"health_addr"`
+	Workers      int           `toml:"workers"`
+	DrainTimeout time.Duration `toml:"drain_timeout"`
+	Notifiers    notifier.Conf `toml:"notifiers"`
+	Dedup        DedupConf     `toml:"dedup"`
+	TransportConf
+}
+
+// DedupConf keeps the keys of processed notifications for ttl. Keys kept
+// by the storage survive restarts and are shared by the senders, a key being
+// processed is held for lease in case the sender crashes.
+type DedupConf struct {
+	Store string        `toml:"store"`
+	TTL   time.Duration `toml:"ttl"`
+	Lease time.Duration `toml:"lease"`
+}
+
+func (c DedupConf) Check(v *config.Validator) {
+	if c.Store != "" {
+		v.OneOf("store", c.Store, DedupStorage, DedupMemory)
+	}
+	v.NotNegative("ttl", c.TTL)
+	v.NotNegative("lease", c.Lease)
+}
+
+func (c DedupConf) ttl() time.Duration {
+	if c.TTL == 0 {
+		return defaultDedupTTL
+	}
+	return c.TTL
+}
+
+func (c DedupConf) lease() time.Duration {
+	if c.Lease == 0 {
+		return defaultDedupLease
+	}
+	return c.Lease
+}
+
+func (c SenderConf) Validate() error {
+	v := &config.Validator{}
+	c.Logger.Check(v.Section("logger"))
+	c.Storage.Check(v.Section("storage"))
+	c.TransportConf.Check(v)
+	if c.HealthAddr != "" {
+		v.Addr("health_addr", c.HealthAddr)
+	}
+	v.Range("workers", int64(c.Workers), 0, 1024)
+	v.NotNegative("drain_timeout", c.DrainTimeout)
+	c.Notifiers.Check(v.Section("notifiers"))
+	c.Dedup.Check(v.Section("dedup"))
+	return v.Err()
+}
+
+func (c SenderConf) workers() int {
+	if c.Workers == 0 {
+		return defaultWorkers
+	}
+	return c.Workers
+}
+
+func (c SenderConf) drainTimeout() time.Duration {
+	if c.DrainTimeout == 0 {
+		return defaultDrainTimeout
+	}
+	return c.DrainTimeout
 }
 
 type Sender struct {
-	conf     SenderConf
-	log      Logger
-	storage  SenderStorage
-	consumer SenderConsumer
+	conf      SenderConf
+	confMu    sync.RWMutex
+	loadConf  func() (SenderConf, error)
+	log       Logger
+	storage   SenderStorage
+	consumer  SenderConsumer
+	notifiers map[string]notifier.Notifier
+	templates *notifier.Templates
+	dedup     DedupStore
 }
 
 type SenderStorage interface {
@@ -30,64 +119,409 @@ type SenderStorage interface {
 	Close(context.Context) error
 
 	UpdateEventNotified(context.Context, int64) error
+	LookupEvent(context.Context, int64) (model.Event, error)
+	DeliverNotification(context.Context, int64) error
+	LookupNotification(context.Context, int64) (model.Notification, error)
+	ListUserChannels(context.Context, int64) ([]model.UserChannel, error)
+	InsertDeliveryFailure(context.Context, *model.DeliveryFailure) error
+	DedupStore
+}
+
+type DedupStore interface {
+	ClaimDedupKey(context.Context, string, time.Duration) (model.DedupState, error)
+	CompleteDedupKey(context.Context, string, time.Duration) error
+	ReleaseDedupKey(context.Context, string) error
+	PurgeDedupKeys(context.Context) (int64, error)
 }
 
 type SenderConsumer interface {
 	Connect(context.Context) error
 	Close(context.Context) error
 	NotifyChannel() <-chan model.NotificationMsg
+	Ack(context.Context, model.NotificationMsg) error
+	Retry(context.Context, model.NotificationMsg, error) error
 }
 
 func NewSender(log Logger, conf SenderConf, storage SenderStorage, consumer SenderConsumer) *Sender {
+	templates, err := notifier.LoadTemplates(conf.Notifiers.Templates)
+	if err != nil {
+		exitfail(fmt.Sprintf("Can't load templates:%v", err))
+	}
+
 	sender := &Sender{
-		conf:     conf,
-		log:      log,
-		storage:  storage,
-		consumer: consumer,
+		conf:      conf,
+		log:       log,
+		storage:   storage,
+		consumer:  consumer,
+		notifiers: notifier.New(log, conf.Notifiers),
+		templates: templates,
+		dedup:     storage,
+	}
+	if conf.Dedup.Store == DedupMemory {
+		sender.dedup = memorystorage.New()
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	err := storage.Connect(ctx)
-	if err != nil {
+	if err := storage.Connect(ctx); err != nil {
 		exitfail(fmt.Sprintf("Can't connect to storage:%v", err))
 	}
 
 	if err := consumer.Connect(ctx); err != nil {
-		exitfail(fmt.Sprintf("Can't connect to RabbitMQ:%v", err))
+		exitfail(fmt.Sprintf("Can't connect to %v:%v", conf.Name(), err))
 	}
 
 	return sender
 }
 
-func (s Sender) Run() {
+func (s *Sender) config() SenderConf {
+	s.confMu.RLock()
+	defer s.confMu.RUnlock()
+	return s.conf
+}
+
+func (s *Sender) SetConfLoader(load func() (SenderConf, error)) {
+	s.loadConf = load
+}
+
+// Reload re-reads the config and applies the log level and dedup timeouts.
+func (s *Sender) Reload() error {
+	if s.loadConf == nil {
+		return ErrNoConfLoader
+	}
+
+	conf, err := s.loadConf()
+	if err != nil {
+		return err
+	}
+
+	if err := checkReload(s.log, s.config(), conf, "logger.level", "dedup.ttl", "dedup.lease"); err != nil {
+		return err
+	}
+	if err := setLogLevel(s.log, conf.Logger.Level); err != nil {
+		return err
+	}
+	s.confMu.Lock()
+	s.conf = conf
+	s.confMu.Unlock()
+	return nil
+}
+
+func (s *Sender) Run() {
 	ctx, cancel := signal.NotifyContext(context.Background(),
-		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+		syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	hup, stopHup := notifyReload()
+	defer stopHup()
+
+	drain := s.startWorkers()
+
+	// keys of the storage are purged by the scheduler
+	var purge <-chan time.Time
+	if s.config().Dedup.Store == DedupMemory {
+		ticker := time.NewTicker(dedupPurgePeriod)
+		defer ticker.Stop()
+		purge = ticker.C
+	}
+
 	for {
 		select {
+		case <-hup:
+			if err := s.Reload(); err != nil {
+				s.log.Errorf("Can't reload config:%v\n", err)
+			}
+
+		case <-purge:
+			s.purgeDedupKeys(ctx)
+
 		case <-ctx.Done():
+			ctxDrain, cancelDrain := context.WithTimeout(context.Background(), s.config().drainTimeout())
+			defer cancelDrain()
+			if err := drain(ctxDrain); err != nil {
+				s.log.Warningf("Workers not drained:%v\n", err)
+			}
+
 			ctxStop, cancelStop := context.WithTimeout(context.Background(), 5*time.Second)
 			defer cancelStop()
 			s.Stop(ctxStop)
 			return
+		}
+	}
+}
+
+func (s *Sender) purgeDedupKeys(ctx context.Context) {
+	purged, err := s.dedup.PurgeDedupKeys(ctx)
+	if err != nil {
+		s.log.Errorf("Can't purge dedup keys:%v\n", err)
+		return
+	}
+	s.log.Debugf("Dedup keys purged:%v\n", purged)
+}
+
+// startWorkers runs the workers handling messages of the consumer. The
+// returned drain stops them taking new messages and waits for the ones being
+// handled. If ctx is done first, the deliveries in progress are canceled,
+// their messages are left unacked and are redelivered by the broker.
+func (s *Sender) startWorkers() func(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	quit := make(chan struct{})
+	done := make(chan struct{})
+
+	wg := sync.WaitGroup{}
+	workers := s.config().workers()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work(ctx, quit)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	s.log.Debugf("Started %v workers\n", workers)
+
+	return func(ctxDrain context.Context) error {
+		defer cancel()
+		close(quit)
+		select {
+		case <-done:
+			s.log.Debugf("Workers drained\n")
+			return nil
+		case <-ctxDrain.Done():
+			cancel()
+			<-done
+			return ctxDrain.Err()
+		}
+	}
+}
+
+func (s *Sender) work(ctx context.Context, quit <-chan struct{}) {
+	for {
+		select {
+		case <-quit:
+			return
+		default:
+		}
 
+		select {
+		case <-quit:
+			return
 		case msg, ok := <-s.consumer.NotifyChannel():
-			if ok {
-				err := s.storage.UpdateEventNotified(ctx, msg.ID)
-				if err != nil {
-					s.log.Errorf("Can't update notified:%v\n", err)
-					continue
-				}
-				s.log.Debugf("UpdateEventNotified: updated\n")
+			if !ok {
+				return
 			}
+			s.handle(ctx, msg)
 		}
 	}
 }
 
-func (s Sender) Stop(ctx context.Context) {
+// handle acks delivered and duplicate messages, others are retried.
+func (s *Sender) handle(ctx context.Context, msg model.NotificationMsg) {
+	err := s.Deliver(ctx, msg)
+	switch {
+	case err == nil, errors.Is(err, model.ErrNotificationNotQueued), errors.Is(err, ErrDuplicate):
+		err = s.consumer.Ack(ctx, msg)
+	default:
+		err = s.consumer.Retry(ctx, msg, err)
+	}
+	if err != nil {
+		s.log.Errorf("Can't settle message:%v\n", err)
+	}
+}
+
+// Deliver sends the notification through the user's channels and marks it
+// as delivered. A notification that is not queued any more was already
+// delivered, ErrNotificationNotQueued is returned and nothing is sent.
+// Messages queued before the outbox have no notification ID, their reminders
+// are only marked notified.
+func (s *Sender) Deliver(ctx context.Context, msg model.NotificationMsg) error {
+	if msg.NotificationID == 0 {
+		return s.deliverLegacy(ctx, msg)
+	}
+
+	notification, err := s.storage.LookupNotification(ctx, msg.NotificationID)
+	if err != nil {
+		return err
+	}
+	if notification.Status != model.NotificationQueued {
+		s.log.Warningf("Notification %v of event %v is %v, skipped\n", msg.NotificationID, msg.ID, notification.Status)
+		return model.ErrNotificationNotQueued
+	}
+
+	if err := s.notifyOnce(ctx, msg); err != nil {
+		return err
+	}
+
+	if err := s.storage.DeliverNotification(ctx, msg.NotificationID); err != nil {
+		s.log.Warningf("Notification %v of event %v not delivered:%v\n", msg.NotificationID, msg.ID, err)
+		return err
+	}
+	s.log.Debugf("Notification %v of event %v delivered\n", msg.NotificationID, msg.ID)
+	return nil
+}
+
+// deliverLegacy marks the reminders of a message without a notification ID
+// notified. Messages of the first version name neither, only the event ID
+// and time, so the reminders of the event due by now are marked, unless the
+// event was deleted or moved since. Those are acked and logged, their
+// reminders are left to the outbox.
+func (s *Sender) deliverLegacy(ctx context.Context, msg model.NotificationMsg) error {
+	if msg.ReminderID != 0 {
+		if err := s.storage.UpdateEventNotified(ctx, msg.ReminderID); err != nil {
+			s.log.Errorf("Can't update notified:%v\n", err)
+			return err
+		}
+		s.log.Debugf("UpdateEventNotified: updated\n")
+		return nil
+	}
+
+	event, err := s.storage.LookupEvent(ctx, msg.ID)
+	if err != nil {
+		s.log.Warningf("Legacy message %+v skipped, can't lookup event:%v\n", msg, err)
+		return nil
+	}
+	if !event.OnTime.Equal(msg.Date) {
+		s.log.Warningf("Legacy message %+v skipped, event is moved to %v\n", msg, event.OnTime)
+		return nil
+	}
+	now := time.Now()
+	for _, r := range event.Reminders {
+		if r.Notified || r.NotifyTime.After(now) {
+			continue
+		}
+		if err := s.storage.UpdateEventNotified(ctx, r.ID); err != nil {
+			s.log.Errorf("Can't update notified:%v\n", err)
+			return err
+		}
+		s.log.Debugf("Reminder %v of event %v notified by legacy message\n", r.ID, event.ID)
+	}
+	return nil
+}
+
+// notifyOnce sends msg unless a copy with the same dedup key was sent or is
+// being sent. A copy that was sent counts as the delivery of msg, so the
+// notification is marked delivered; while a copy is being sent ErrDuplicate
+// is returned. The key is released when nothing was sent, so the retry of
+// the message goes through.
+func (s *Sender) notifyOnce(ctx context.Context, msg model.NotificationMsg) error {
+	if s.dedup == nil || msg.DedupKey == "" {
+		return s.notify(ctx, msg)
+	}
+
+	conf := s.config()
+	state, err := s.dedup.ClaimDedupKey(ctx, msg.DedupKey, conf.Dedup.lease())
+	if err != nil {
+		return err
+	}
+	switch state {
+	case model.DedupDone:
+		s.log.Warningf("Notification %v of event %v was sent as %v, not sent again\n",
+			msg.NotificationID, msg.ID, msg.DedupKey)
+		return nil
+	case model.DedupPending:
+		s.log.Warningf("Notification %v of event %v is a duplicate of %v, skipped\n",
+			msg.NotificationID, msg.ID, msg.DedupKey)
+		return fmt.Errorf("%w: %v", ErrDuplicate, msg.DedupKey)
+	case model.DedupClaimed:
+	}
+
+	if err := s.notify(ctx, msg); err != nil {
+		if errRelease := s.dedup.ReleaseDedupKey(ctx, msg.DedupKey); errRelease != nil {
+			s.log.Errorf("Can't release dedup key:%v\n", errRelease)
+		}
+		return err
+	}
+	if err := s.dedup.CompleteDedupKey(ctx, msg.DedupKey, conf.Dedup.ttl()); err != nil {
+		s.log.Errorf("Can't complete dedup key:%v\n", err)
+	}
+	return nil
+}
+
+// notify sends msg to every channel of the user, or to the default channels
+// if the user has none. A reminder tied to a channel is sent only to the
+// user's addresses of that channel. Failures are recorded per channel; the
+// notification is retried only if no channel succeeded.
+func (s *Sender) notify(ctx context.Context, msg model.NotificationMsg) error {
+	channels, err := s.storage.ListUserChannels(ctx, msg.UserID)
+	if err != nil {
+		return err
+	}
+	if len(channels) == 0 {
+		channels = s.config().Notifiers.Defaults()
+	}
+	if msg.Channel != "" {
+		channels = filterChannels(channels, msg.Channel)
+	}
+
+	event := s.event(ctx, msg)
+	delivered := 0
+	var lastErr error
+	for _, channel := range channels {
+		err := notifier.ErrNotConfigured
+		if n, ok := s.notifiers[channel.Channel]; ok {
+			var text notifier.Message
+			text, err = s.templates.Render(channel.Channel, channel.Locale, channel.TimeZone, event)
+			if err == nil {
+				text.Notification = msg
+				err = n.Notify(ctx, channel.Address, text)
+			}
+		}
+		if err == nil {
+			delivered++
+			continue
+		}
+
+		lastErr = err
+		s.log.Errorf("Can't notify %v %v:%v\n", channel.Channel, channel.Address, err)
+		failure := model.DeliveryFailure{
+			NotificationID: msg.NotificationID,
+			Channel:        channel.Channel,
+			Address:        channel.Address,
+			Error:          err.Error(),
+			FailedAt:       time.Now(),
+		}
+		if err := s.storage.InsertDeliveryFailure(ctx, &failure); err != nil {
+			s.log.Errorf("Can't record delivery failure:%v\n", err)
+		}
+	}
+
+	if delivered == 0 && lastErr != nil {
+		return fmt.Errorf("%w: %v", ErrNotDelivered, lastErr)
+	}
+	return nil
+}
+
+// filterChannels returns the user's channels named name. If the user has
+// none, the channel is used without an address, that is enough for "log".
+func filterChannels(channels []model.UserChannel, name string) []model.UserChannel {
+	filtered := []model.UserChannel{}
+	for _, c := range channels {
+		if c.Channel == name {
+			filtered = append(filtered, c)
+		}
+	}
+	if len(filtered) == 0 {
+		filtered = append(filtered, model.UserChannel{Channel: name})
+	}
+	return filtered
+}
+
+// event returns the event of the notification to render templates with.
+// If it can't be found, e.g. it was deleted, the fields of msg are used.
+func (s *Sender) event(ctx context.Context, msg model.NotificationMsg) model.Event {
+	event, err := s.storage.LookupEvent(ctx, msg.ID)
+	if err != nil {
+		s.log.Warningf("Can't lookup event %v:%v\n", msg.ID, err)
+		return model.Event{ID: msg.ID, UserID: msg.UserID, Title: msg.Title, OnTime: msg.Date}
+	}
+	return event
+}
+
+func (s *Sender) Stop(ctx context.Context) {
 	s.consumer.Close(ctx)
 	s.log.Debugf("Consumer closed\n")
 	s.storage.Close(ctx)
@@ -2,24 +2,43 @@ package app
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
 
 	logger "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/logger"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/notifier"
 	memorystorage "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/storage/memory"
 	internalrmq "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/rabbitmq"
 	"github.com/stretchr/testify/require"
 )
 
+type ackConsumer struct {
+	*internalrmq.DummyConsumer
+	acked chan model.NotificationMsg
+}
+
+func (c *ackConsumer) Ack(ctx context.Context, msg model.NotificationMsg) error {
+	c.acked <- msg
+	return nil
+}
+
 func TestSender(t *testing.T) {
 	db := memorystorage.New()
 	require.NotNil(t, db)
 	log := logger.NewLogger("DEBUG", os.Stdout)
 	notifyChannel := make(chan model.NotificationMsg, 1)
 	consumer := internalrmq.NewDummyConsumer(notifyChannel)
-	sender := Sender{log: log, storage: db, consumer: consumer}
+	templates, err := notifier.LoadTemplates(notifier.TemplatesConf{})
+	require.NoError(t, err)
+	sender := Sender{
+		log: log, storage: db, consumer: consumer,
+		notifiers: notifier.New(log, notifier.Conf{}), templates: templates, dedup: db,
+	}
 
 	t.Run("test_receive_notification", func(t *testing.T) {
 		currTime := time.Now()
@@ -49,20 +68,234 @@ func TestSender(t *testing.T) {
 			Description: "DescriptionN1",
 			OnTime:      currTime.AddDate(0, 0, 1),
 			OffTime:     currTime.AddDate(0, 0, 7),
-			NotifyTime:  currTime.AddDate(0, 0, 0),
+			Reminders:   []model.Reminder{{Offset: 24 * time.Hour, NotifyTime: currTime}},
 		}
 
 		err := db.InsertEvent(ctx, &event)
 		require.NoError(t, err)
 		eventID := event.ID
 		require.NotEmpty(t, eventID)
-		require.EqualValues(t, false, event.Notified)
+		require.EqualValues(t, false, event.Reminders[0].Notified)
 
-		err = sender.storage.UpdateEventNotified(ctx, event.ID)
+		err = sender.storage.UpdateEventNotified(ctx, event.Reminders[0].ID)
 		require.NoError(t, err)
 
 		event, err = db.LookupEvent(ctx, eventID)
 		require.NoError(t, err)
-		require.EqualValues(t, true, event.Notified)
+		require.EqualValues(t, true, event.Reminders[0].Notified)
+	})
+	t.Run("test_deliver_once", func(t *testing.T) {
+		ctx := context.Background()
+		currTime := time.Now()
+		event := model.Event{
+			UserID:    300,
+			Title:     "TitleN1",
+			OnTime:    currTime.AddDate(0, 0, 1),
+			OffTime:   currTime.AddDate(0, 0, 7),
+			Reminders: []model.Reminder{{Offset: 24 * time.Hour, NotifyTime: currTime}},
+		}
+		require.NoError(t, db.InsertEvent(ctx, &event))
+
+		_, err := db.EnqueueNotifications(ctx, currTime)
+		require.NoError(t, err)
+		notifications, err := db.ClaimNotifications(ctx, currTime, time.Minute, 1)
+		require.NoError(t, err)
+		require.Len(t, notifications, 1)
+
+		msg := model.NotificationMsg{ID: event.ID, NotificationID: notifications[0].ID}
+		require.NoError(t, sender.Deliver(ctx, msg))
+		require.ErrorIs(t, sender.Deliver(ctx, msg), model.ErrNotificationNotQueued, "redelivered message is dropped")
+	})
+	t.Run("test_deliver_legacy", func(t *testing.T) {
+		ctx := context.Background()
+		currTime := time.Now()
+		event := model.Event{
+			UserID:  350,
+			Title:   "TitleN1",
+			OnTime:  currTime.AddDate(0, 0, 1),
+			OffTime: currTime.AddDate(0, 0, 7),
+			Reminders: []model.Reminder{
+				{Offset: 24 * time.Hour, NotifyTime: currTime},
+				{Offset: time.Hour, NotifyTime: currTime.Add(23 * time.Hour)},
+			},
+		}
+		require.NoError(t, db.InsertEvent(ctx, &event))
+
+		moved := model.NotificationMsg{ID: event.ID, UserID: event.UserID, Date: event.OnTime.Add(time.Hour)}
+		require.NoError(t, sender.Deliver(ctx, moved), "message of a moved event is acked")
+		require.NoError(t, sender.Deliver(ctx, model.NotificationMsg{ID: -1}), "message of a deleted event is acked")
+		stored, err := db.LookupEvent(ctx, event.ID)
+		require.NoError(t, err)
+		require.False(t, stored.Reminders[0].Notified)
+
+		msg := model.NotificationMsg{ID: event.ID, UserID: event.UserID, Title: event.Title, Date: event.OnTime}
+		require.NoError(t, sender.Deliver(ctx, msg))
+		stored, err = db.LookupEvent(ctx, event.ID)
+		require.NoError(t, err)
+		require.True(t, stored.Reminders[0].Notified, "due reminder is notified")
+		require.False(t, stored.Reminders[1].Notified, "later reminder is kept")
+	})
+	t.Run("test_deduplicate", func(t *testing.T) {
+		ctx := context.Background()
+		currTime := time.Now()
+		var msgs []model.NotificationMsg
+		for i := 0; i < 3; i++ {
+			event := model.Event{
+				UserID:    400,
+				Title:     "TitleN1",
+				OnTime:    currTime.AddDate(0, 0, 1),
+				OffTime:   currTime.AddDate(0, 0, 7),
+				Reminders: []model.Reminder{{Offset: 24 * time.Hour, NotifyTime: currTime}},
+			}
+			require.NoError(t, db.InsertEvent(ctx, &event))
+			_, err := db.EnqueueNotifications(ctx, currTime)
+			require.NoError(t, err)
+			notifications, err := db.ClaimNotifications(ctx, currTime, time.Minute, 1)
+			require.NoError(t, err)
+			require.Len(t, notifications, 1)
+			msgs = append(msgs, model.NewNotificationMsg(&notifications[0]))
+		}
+		// a copy of the reminder queued again, e.g. after a restore of the database
+		msgs[1].DedupKey = msgs[0].DedupKey
+
+		require.NoError(t, sender.Deliver(ctx, msgs[0]))
+		require.NoError(t, sender.Deliver(ctx, msgs[1]), "second copy is not sent")
+		for _, msg := range msgs[:2] {
+			notification, err := db.LookupNotification(ctx, msg.NotificationID)
+			require.NoError(t, err)
+			require.Equal(t, model.NotificationDelivered, notification.Status)
+		}
+
+		// the copy of a reminder still being sent is left for the broker
+		state, err := db.ClaimDedupKey(ctx, "400:pending", time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, model.DedupClaimed, state)
+		msgs[2].DedupKey = "400:pending"
+		require.ErrorIs(t, sender.Deliver(ctx, msgs[2]), ErrDuplicate)
+	})
+	t.Run("test_purge_dedup_keys", func(t *testing.T) {
+		ctx := context.Background()
+		require.NoError(t, db.CompleteDedupKey(ctx, "600:1", -time.Minute))
+		require.NoError(t, db.CompleteDedupKey(ctx, "600:2", time.Minute))
+		sender.purgeDedupKeys(ctx)
+		purged, err := db.PurgeDedupKeys(ctx)
+		require.NoError(t, err)
+		require.Zero(t, purged, "expired keys are purged")
+		state, err := db.ClaimDedupKey(ctx, "600:2", time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, model.DedupDone, state, "live key is kept")
+	})
+	t.Run("test_user_channels", func(t *testing.T) {
+		ctx := context.Background()
+		currTime := time.Now()
+		hooks := make(chan notifier.WebhookPayload, 1)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var payload notifier.WebhookPayload
+			json.NewDecoder(r.Body).Decode(&payload)
+			hooks <- payload
+		}))
+		defer ts.Close()
+
+		sender := Sender{log: log, storage: db, consumer: consumer, notifiers: notifier.New(log, notifier.Conf{
+			Webhook: notifier.WebhookConf{Secret: "secret", Allow: []string{"127.0.0.0/8"}},
+		}), templates: templates}
+		event := model.Event{
+			UserID:    500,
+			Title:     "TitleN1",
+			OnTime:    currTime.AddDate(0, 0, 1),
+			OffTime:   currTime.AddDate(0, 0, 7),
+			Reminders: []model.Reminder{{Offset: 24 * time.Hour, NotifyTime: currTime}},
+		}
+		require.NoError(t, db.InsertEvent(ctx, &event))
+		require.NoError(t, db.SetUserChannels(ctx, event.UserID, []model.UserChannel{
+			{Channel: notifier.ChannelWebhook, Address: ts.URL, Locale: "ru", TimeZone: "Europe/Moscow"},
+			{Channel: notifier.ChannelEmail, Address: "user@example.com"},
+		}))
+
+		_, err := db.EnqueueNotifications(ctx, currTime)
+		require.NoError(t, err)
+		notifications, err := db.ClaimNotifications(ctx, currTime, time.Minute, 1)
+		require.NoError(t, err)
+		require.Len(t, notifications, 1)
+
+		msg := model.NotificationMsg{ID: event.ID, NotificationID: notifications[0].ID, UserID: event.UserID}
+		require.NoError(t, sender.Deliver(ctx, msg), "delivered by webhook")
+		payload := <-hooks
+		require.Equal(t, msg.NotificationID, payload.NotificationID)
+		require.Contains(t, payload.Text, "Ваша встреча 'TitleN1' начнётся через 1 день")
+		require.Contains(t, payload.Text, "(Europe/Moscow)")
+
+		failures, err := db.ListDeliveryFailures(ctx, msg.NotificationID)
+		require.NoError(t, err)
+		require.Len(t, failures, 1)
+		require.Equal(t, notifier.ChannelEmail, failures[0].Channel)
+		require.Equal(t, notifier.ErrNotConfigured.Error(), failures[0].Error)
+	})
+	t.Run("test_reminder_channel", func(t *testing.T) {
+		channels := []model.UserChannel{
+			{Channel: notifier.ChannelWebhook, Address: "https://example.com/hook"},
+			{Channel: notifier.ChannelEmail, Address: "user@example.com"},
+		}
+		require.Equal(t, channels[1:], filterChannels(channels, notifier.ChannelEmail))
+		require.Equal(t, []model.UserChannel{{Channel: notifier.ChannelLog}},
+			filterChannels(channels, notifier.ChannelLog), "channel without address is used as is")
+	})
+	t.Run("test_workers_drain", func(t *testing.T) {
+		ctx := context.Background()
+		currTime := time.Now()
+		started := make(chan struct{}, 2)
+		release := make(chan struct{})
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started <- struct{}{}
+			<-release
+		}))
+		defer ts.Close()
+
+		msgs := make(chan model.NotificationMsg, 2)
+		consumer := &ackConsumer{DummyConsumer: internalrmq.NewDummyConsumer(msgs), acked: make(chan model.NotificationMsg, 2)}
+		sender := Sender{
+			conf: SenderConf{Workers: 2}, log: log, storage: db, consumer: consumer,
+			notifiers: notifier.New(log, notifier.Conf{Webhook: notifier.WebhookConf{Secret: "secret", Allow: []string{"127.0.0.0/8"}}}),
+			templates: templates,
+		}
+		require.NoError(t, db.SetUserChannels(ctx, 600, []model.UserChannel{
+			{Channel: notifier.ChannelWebhook, Address: ts.URL},
+		}))
+		for i := 0; i < 2; i++ {
+			event := model.Event{
+				UserID:    600,
+				Title:     "TitleN1",
+				OnTime:    currTime.AddDate(0, 0, 1),
+				OffTime:   currTime.AddDate(0, 0, 7),
+				Reminders: []model.Reminder{{Offset: 24 * time.Hour, NotifyTime: currTime}},
+			}
+			require.NoError(t, db.InsertEvent(ctx, &event))
+			_, err := db.EnqueueNotifications(ctx, currTime)
+			require.NoError(t, err)
+			notifications, err := db.ClaimNotifications(ctx, currTime, time.Minute, 1)
+			require.NoError(t, err)
+			require.Len(t, notifications, 1)
+			msgs <- model.NewNotificationMsg(&notifications[0])
+		}
+
+		drain := sender.startWorkers()
+		<-started
+		<-started
+
+		drained := make(chan error, 1)
+		go func() {
+			ctxDrain, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			drained <- drain(ctxDrain)
+		}()
+		select {
+		case err := <-drained:
+			t.Fatalf("drained while messages are delivered: %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		close(release)
+		require.NoError(t, <-drained)
+		require.Len(t, consumer.acked, 2, "in-flight messages are acked before drain returns")
 	})
 }
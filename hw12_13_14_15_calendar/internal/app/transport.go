@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/codec"
+	internalnats "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/nats"
+	internalrmq "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/rabbitmq"
+)
+
+const (
+	TransportRabbitMQ = "rabbitmq"
+	TransportNATS     = "nats"
+)
+
+// TransportConf selects the broker notifications go through, RabbitMQ
+// by default.
+type TransportConf struct {
+	Transport string            `toml:"transport"`
+	URLRMQ    string            `toml:"url_rmq"`
+	RabbitMQ  internalrmq.Conf  `toml:"rabbitmq"`
+	URLNATS   string            `toml:"url_nats"`
+	NATS      internalnats.Conf `toml:"nats"`
+}
+
+func (c TransportConf) Check(v *config.Validator) {
+	switch c.Name() {
+	case TransportRabbitMQ:
+		v.URL("url_rmq", c.URLRMQ, "amqp", "amqps")
+		c.RabbitMQ.Check(v.Section("rabbitmq"))
+	case TransportNATS:
+		v.URL("url_nats", c.URLNATS, "nats", "tls")
+		c.NATS.Check(v.Section("nats"))
+	default:
+		v.OneOf("transport", c.Transport, TransportRabbitMQ, TransportNATS)
+	}
+}
+
+func (c TransportConf) Name() string {
+	if c.Transport == "" {
+		return TransportRabbitMQ
+	}
+	return c.Transport
+}
+
+type healthChecker interface {
+	Health() error
+}
+
+// NewProducer creates the producer of the configured transport, its Health
+// reports the connection state.
+func (c TransportConf) NewProducer(log Logger) (SchedulerProducer, func() error) {
+	var producer interface {
+		SchedulerProducer
+		healthChecker
+	}
+	if c.Name() == TransportNATS {
+		producer = internalnats.NewProducer(log, c.URLNATS, c.NATS)
+	} else {
+		producer = internalrmq.NewProducer(log, c.URLRMQ, c.RabbitMQ)
+	}
+	return producer, producer.Health
+}
+
+// NewConsumer creates the consumer of the configured transport, its Health
+// reports the connection state.
+func (c TransportConf) NewConsumer(log Logger) (SenderConsumer, func() error) {
+	var consumer interface {
+		SenderConsumer
+		healthChecker
+	}
+	if c.Name() == TransportNATS {
+		consumer = internalnats.NewConsumer(log, c.URLNATS, c.NATS)
+	} else {
+		consumer = internalrmq.NewConsumer(log, c.URLRMQ, c.RabbitMQ)
+	}
+	return consumer, consumer.Health
+}
+
+// DeadLetterQueue lists and requeues the messages the consumer gave up on.
+type DeadLetterQueue interface {
+	Connect(ctx context.Context) error
+	Close(ctx context.Context) error
+	List(ctx context.Context, limit int) ([]codec.DeadLetter, error)
+	Requeue(ctx context.Context, limit int) (int, error)
+}
+
+// NewDeadLetters creates the dead-letter queue of the configured transport.
+func (c TransportConf) NewDeadLetters(log Logger) DeadLetterQueue {
+	if c.Name() == TransportNATS {
+		return internalnats.NewDeadLetters(log, c.URLNATS, c.NATS)
+	}
+	return internalrmq.NewDeadLetters(log, c.URLRMQ, c.RabbitMQ)
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+	logger "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/logger"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/notifier"
+	memorystorage "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/storage/memory"
+	internalnats "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/nats"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransportConf(t *testing.T) {
+	for _, tc := range []struct {
+		conf TransportConf
+		err  string
+	}{
+		{conf: TransportConf{URLRMQ: "amqp://localhost/"}},
+		{conf: TransportConf{Transport: TransportNATS, URLNATS: "nats://localhost:4222"}},
+		{conf: TransportConf{Transport: TransportNATS, URLRMQ: "amqp://localhost/"}, err: "url_nats"},
+		{conf: TransportConf{Transport: "kafka"}, err: "transport"},
+	} {
+		v := &config.Validator{}
+		tc.conf.Check(v)
+		if tc.err == "" {
+			require.NoError(t, v.Err())
+		} else {
+			require.ErrorContains(t, v.Err(), tc.err)
+		}
+	}
+}
+
+func TestNATSTransport(t *testing.T) {
+	srv, err := server.NewServer(&server.Options{
+		Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: t.TempDir(), NoLog: true, NoSigs: true,
+	})
+	require.NoError(t, err)
+	go srv.Start()
+	require.True(t, srv.ReadyForConnections(5*time.Second))
+	defer srv.Shutdown()
+
+	ctx := context.Background()
+	log := logger.NewLogger("DEBUG", os.Stdout)
+	db := memorystorage.New()
+	conf := TransportConf{Transport: TransportNATS, URLNATS: srv.ClientURL(), NATS: internalnats.DefaultConf()}
+
+	producer, producerHealth := conf.NewProducer(log)
+	require.NoError(t, producer.Connect(ctx))
+	defer producer.Close(ctx)
+	require.NoError(t, producerHealth())
+
+	consumer, consumerHealth := conf.NewConsumer(log)
+	require.NoError(t, consumer.Connect(ctx))
+	defer consumer.Close(ctx)
+	require.NoError(t, consumerHealth())
+
+	templates, err := notifier.LoadTemplates(notifier.TemplatesConf{})
+	require.NoError(t, err)
+	scheduler := Scheduler{log: log, storage: db, producer: producer}
+	sender := Sender{
+		log: log, storage: db, consumer: consumer,
+		notifiers: notifier.New(log, notifier.Conf{}), templates: templates,
+	}
+
+	currTime := time.Now()
+	event := model.Event{
+		UserID:    100,
+		Title:     "TitleN1",
+		OnTime:    currTime.AddDate(0, 0, 1),
+		OffTime:   currTime.AddDate(0, 0, 7),
+		Reminders: []model.Reminder{{Offset: 24 * time.Hour, NotifyTime: currTime}},
+	}
+	require.NoError(t, db.InsertEvent(ctx, &event))
+
+	n, err := scheduler.SendNotification(ctx, currTime)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, n)
+
+	select {
+	case msg := <-consumer.NotifyChannel():
+		require.Equal(t, event.ID, msg.ID)
+		sender.handle(ctx, msg)
+		notification, err := db.LookupNotification(ctx, msg.NotificationID)
+		require.NoError(t, err)
+		require.Equal(t, model.NotificationDelivered, notification.Status)
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification is not received")
+	}
+}
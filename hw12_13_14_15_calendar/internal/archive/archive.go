@@ -0,0 +1,140 @@
+package archive
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+)
+
+const (
+	FormatJSONL = "jsonl"
+	FormatICS   = "ics"
+)
+
+// Conf enables archival of expired events to gzip compressed files of dir.
+type Conf struct {
+	Dir    string `toml:"dir"`
+	Format string `toml:"format"`
+}
+
+func (c Conf) Check(v *config.Validator) {
+	if c.Dir == "" {
+		return
+	}
+	if info, err := os.Stat(c.Dir); err != nil {
+		v.AddKeyf("dir", "%v", err)
+	} else if !info.IsDir() {
+		v.AddKeyf("dir", "%v is not a directory", c.Dir)
+	}
+	if c.Format != "" {
+		v.OneOf("format", c.Format, FormatJSONL, FormatICS)
+	}
+}
+
+func (c Conf) Enabled() bool {
+	return c.Dir != ""
+}
+
+func (c Conf) format() string {
+	if c.Format == "" {
+		return FormatJSONL
+	}
+	return c.Format
+}
+
+type Archiver struct {
+	conf Conf
+}
+
+func New(conf Conf) *Archiver {
+	return &Archiver{conf: conf}
+}
+
+// Write stores the events in a new file named by the date and the first
+// event ID, e.g. events-20230102T150405Z-42.jsonl.gz. The file is written
+// under a temporary name and renamed when complete, so a partly written
+// archive is never taken for a complete one.
+func (a *Archiver) Write(date time.Time, events []model.Event) (filename string, err error) {
+	if len(events) == 0 {
+		return "", nil
+	}
+
+	name := fmt.Sprintf("events-%s-%d.%s.gz", date.UTC().Format("20060102T150405Z"), events[0].ID, a.conf.format())
+	filename = filepath.Join(a.conf.Dir, name)
+
+	f, err := os.CreateTemp(a.conf.Dir, "."+name+".*")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	zw := gzip.NewWriter(f)
+	zw.Name = name[:len(name)-len(".gz")]
+	zw.ModTime = date
+
+	if a.conf.format() == FormatICS {
+		err = WriteICS(zw, date, events)
+	} else {
+		err = WriteJSONL(zw, events)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err = zw.Close(); err != nil {
+		return "", err
+	}
+	if err = f.Sync(); err != nil {
+		return "", err
+	}
+	if err = f.Close(); err != nil {
+		return "", err
+	}
+	if err = os.Rename(f.Name(), filename); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// WriteJSONL writes an event per line in the JSON of the API, so the events
+// can be inserted again as they are.
+func WriteJSONL(w io.Writer, events []model.Event) error {
+	enc := json.NewEncoder(w)
+	for i := range events {
+		if err := enc.Encode(events[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadJSONL reads events written by WriteJSONL from a gzip compressed archive.
+func ReadJSONL(r io.Reader) ([]model.Event, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var events []model.Event
+	dec := json.NewDecoder(zr)
+	for dec.More() {
+		var e model.Event
+		if err := dec.Decode(&e); err != nil {
+			return events, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
@@ -0,0 +1,133 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func testEvents() []model.Event {
+	onTime := time.Date(2023, 1, 2, 15, 0, 0, 0, time.UTC)
+	return []model.Event{
+		{
+			ID: 42, UserID: 7, Title: "Meeting; room 1, floor 2", Description: "line 1\nline 2",
+			OnTime: onTime, OffTime: onTime.Add(time.Hour),
+			Reminders: []model.Reminder{{ID: 1, Offset: 90 * time.Minute, Channel: "email"}},
+			Tags:      []string{"project-x", "urgent"},
+		},
+		{ID: 43, UserID: 8, Title: "Lunch", OnTime: onTime.Add(2 * time.Hour), OffTime: onTime.Add(3 * time.Hour)},
+		{
+			ID: 44, UserID: 8, Title: "Vacation",
+			OnTime: model.Date(onTime), OffTime: model.Date(onTime).AddDate(0, 0, 5), AllDay: true,
+		},
+	}
+}
+
+func TestArchiver(t *testing.T) {
+	date := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	t.Run("jsonl", func(t *testing.T) {
+		dir := t.TempDir()
+		filename, err := New(Conf{Dir: dir}).Write(date, testEvents())
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(dir, "events-20240102T150405Z-42.jsonl.gz"), filename)
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1, "temporary file is renamed")
+
+		f, err := os.Open(filename)
+		require.NoError(t, err)
+		defer f.Close()
+		events, err := ReadJSONL(f)
+		require.NoError(t, err)
+		require.Equal(t, testEvents(), events)
+	})
+
+	t.Run("ics", func(t *testing.T) {
+		dir := t.TempDir()
+		filename, err := New(Conf{Dir: dir, Format: FormatICS}).Write(date, testEvents())
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(dir, "events-20240102T150405Z-42.ics.gz"), filename)
+
+		f, err := os.Open(filename)
+		require.NoError(t, err)
+		defer f.Close()
+		zr, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		data, err := io.ReadAll(zr)
+		require.NoError(t, err)
+
+		ics := string(data)
+		require.True(t, strings.HasPrefix(ics, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+		require.Contains(t, ics, "UID:42@calendar\r\nDTSTAMP:20240102T150405Z\r\n"+
+			"DTSTART:20230102T150000Z\r\nDTEND:20230102T160000Z\r\n")
+		require.Contains(t, ics, `SUMMARY:Meeting\; room 1\, floor 2`+"\r\n")
+		require.Contains(t, ics, `DESCRIPTION:line 1\nline 2`+"\r\n")
+		require.Contains(t, ics, "CATEGORIES:project-x,urgent\r\n")
+		require.Contains(t, ics, "TRIGGER:-PT1H30M\r\nX-CALENDAR-CHANNEL:email\r\n")
+		require.Contains(t, ics, "DTSTART;VALUE=DATE:20230102\r\nDTEND;VALUE=DATE:20230107\r\n")
+		require.Equal(t, 3, strings.Count(ics, "BEGIN:VEVENT"))
+		require.True(t, strings.HasSuffix(ics, "END:VEVENT\r\nEND:VCALENDAR\r\n"))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		dir := t.TempDir()
+		filename, err := New(Conf{Dir: dir}).Write(date, nil)
+		require.NoError(t, err)
+		require.Empty(t, filename)
+	})
+}
+
+func TestICSLine(t *testing.T) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	long := "SUMMARY:" + strings.Repeat("я", 100)
+	writeICSLine(w, long)
+	w.Flush()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+	require.Greater(t, len(lines), 1)
+	unfolded := lines[0]
+	for _, l := range lines {
+		require.LessOrEqual(t, len(l), icsLineLimit)
+	}
+	for _, l := range lines[1:] {
+		require.True(t, strings.HasPrefix(l, " "))
+		unfolded += l[1:]
+	}
+	require.Equal(t, long, unfolded)
+}
+
+func TestICSDuration(t *testing.T) {
+	for d, expected := range map[time.Duration]string{
+		0:                          "PT0S",
+		15 * time.Minute:           "PT15M",
+		24 * time.Hour:             "P1D",
+		26*time.Hour + time.Second: "P1DT2H1S",
+	} {
+		require.Equal(t, expected, icsDuration(d))
+	}
+}
+
+func TestConf(t *testing.T) {
+	v := &config.Validator{}
+	Conf{}.Check(v)
+	Conf{Dir: t.TempDir(), Format: FormatICS}.Check(v)
+	require.NoError(t, v.Err())
+
+	Conf{Dir: filepath.Join(t.TempDir(), "missing")}.Check(v)
+	Conf{Dir: t.TempDir(), Format: "xml"}.Check(v)
+	require.ErrorContains(t, v.Err(), "dir")
+	require.ErrorContains(t, v.Err(), "format")
+}
@@ -0,0 +1,117 @@
+package archive
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+)
+
+const (
+	icsTime      = "20060102T150405Z"
+	icsDate      = "20060102"
+	icsLineLimit = 75
+)
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// WriteICS writes the events as an iCalendar (RFC 5545) calendar, reminders
+// become alarms. All-day events get floating dates, so every viewer sees
+// them on the same days.
+func WriteICS(w io.Writer, date time.Time, events []model.Event) error {
+	bw := bufio.NewWriter(w)
+	line := func(format string, a ...interface{}) {
+		writeICSLine(bw, fmt.Sprintf(format, a...))
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//otus-go-hw//calendar//EN")
+	for _, e := range events {
+		line("BEGIN:VEVENT")
+		line("UID:%d@calendar", e.ID)
+		line("DTSTAMP:%s", date.UTC().Format(icsTime))
+		if e.AllDay {
+			line("DTSTART;VALUE=DATE:%s", e.OnTime.UTC().Format(icsDate))
+			line("DTEND;VALUE=DATE:%s", e.OffTime.UTC().Format(icsDate))
+		} else {
+			line("DTSTART:%s", e.OnTime.UTC().Format(icsTime))
+			line("DTEND:%s", e.OffTime.UTC().Format(icsTime))
+		}
+		line("SUMMARY:%s", icsEscaper.Replace(e.Title))
+		if e.Description != "" {
+			line("DESCRIPTION:%s", icsEscaper.Replace(e.Description))
+		}
+		if len(e.Tags) > 0 {
+			categories := make([]string, len(e.Tags))
+			for i, tag := range e.Tags {
+				categories[i] = icsEscaper.Replace(tag)
+			}
+			line("CATEGORIES:%s", strings.Join(categories, ","))
+		}
+		line("X-CALENDAR-USERID:%d", e.UserID)
+		for _, r := range e.Reminders {
+			line("BEGIN:VALARM")
+			line("ACTION:DISPLAY")
+			line("DESCRIPTION:%s", icsEscaper.Replace(e.Title))
+			line("TRIGGER:-%s", icsDuration(r.Offset))
+			if r.Channel != "" {
+				line("X-CALENDAR-CHANNEL:%s", icsEscaper.Replace(r.Channel))
+			}
+			line("END:VALARM")
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return bw.Flush()
+}
+
+// writeICSLine folds lines longer than 75 octets without splitting
+// UTF-8 sequences.
+func writeICSLine(w *bufio.Writer, s string) {
+	limit := icsLineLimit
+	for len(s) > limit {
+		i := limit
+		for i > 0 && s[i]&0xC0 == 0x80 {
+			i--
+		}
+		w.WriteString(s[:i])
+		w.WriteString("\r\n ")
+		s = s[i:]
+		// the leading space of a continuation counts
+		limit = icsLineLimit - 1
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+// icsDuration formats a duration as dur-value of RFC 5545, e.g. PT1H30M.
+func icsDuration(d time.Duration) string {
+	if d == 0 {
+		return "PT0S"
+	}
+	var b strings.Builder
+	b.WriteString("P")
+	if days := d / (24 * time.Hour); days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+		d -= days * 24 * time.Hour
+	}
+	if d > 0 {
+		b.WriteString("T")
+		if h := d / time.Hour; h > 0 {
+			fmt.Fprintf(&b, "%dH", h)
+			d -= h * time.Hour
+		}
+		if m := d / time.Minute; m > 0 {
+			fmt.Fprintf(&b, "%dM", m)
+			d -= m * time.Minute
+		}
+		if s := d / time.Second; s > 0 {
+			fmt.Fprintf(&b, "%dS", s)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package blob
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+)
+
+const StoreFS = "fs"
+
+var (
+	ErrNotFound = errors.New("blob not found")
+	ErrKey      = errors.New("wrong blob key")
+	ErrStore    = errors.New("unknown blob store")
+)
+
+// Conf selects the store of blobs, an empty store disables it.
+type Conf struct {
+	Store string `toml:"store"`
+	Dir   string `toml:"dir"`
+}
+
+func (c Conf) Check(v *config.Validator) {
+	if c.Store == "" {
+		return
+	}
+	v.OneOf("store", c.Store, StoreFS)
+	if c.Store == StoreFS && v.Required("dir", c.Dir) {
+		if info, err := os.Stat(c.Dir); err != nil {
+			v.AddKeyf("dir", "%v", err)
+		} else if !info.IsDir() {
+			v.AddKeyf("dir", "%v is not a directory", c.Dir)
+		}
+	}
+}
+
+func (c Conf) Enabled() bool {
+	return c.Store != ""
+}
+
+// Store keeps blobs by keys of NewKey. Put never leaves a partial blob
+// under the key.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// Walk calls fn for every blob with the time it was stored.
+	Walk(ctx context.Context, fn func(key string, modTime time.Time) error) error
+}
+
+func New(conf Conf) (Store, error) {
+	switch conf.Store {
+	case StoreFS:
+		return NewFS(conf.Dir), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrStore, conf.Store)
+}
+
+// NewKey returns a random key of 32 hex digits.
+func NewKey() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+func checkKey(key string) error {
+	if len(key) != 32 {
+		return fmt.Errorf("%w: %q", ErrKey, key)
+	}
+	if _, err := hex.DecodeString(key); err != nil {
+		return fmt.Errorf("%w: %q", ErrKey, key)
+	}
+	return nil
+}
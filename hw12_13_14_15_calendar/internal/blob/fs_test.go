@@ -0,0 +1,96 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestFS(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := New(Conf{Store: StoreFS, Dir: dir})
+	require.NoError(t, err)
+
+	t.Run("put_open_delete", func(t *testing.T) {
+		key := NewKey()
+		size, err := store.Put(ctx, key, strings.NewReader("agenda"))
+		require.NoError(t, err)
+		require.EqualValues(t, 6, size)
+		require.FileExists(t, filepath.Join(dir, key[:2], key))
+
+		r, err := store.Open(ctx, key)
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		require.Equal(t, "agenda", string(data))
+
+		require.NoError(t, store.Delete(ctx, key))
+		require.NoError(t, store.Delete(ctx, key), "missing blob")
+		_, err = store.Open(ctx, key)
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("failed_put", func(t *testing.T) {
+		key := NewKey()
+		_, err := store.Put(ctx, key, failingReader{})
+		require.Error(t, err)
+		entries, err := os.ReadDir(filepath.Join(dir, key[:2]))
+		require.NoError(t, err)
+		require.Empty(t, entries, "temporary file is removed")
+	})
+
+	t.Run("wrong_key", func(t *testing.T) {
+		_, err := store.Put(ctx, "../../etc/passwd", strings.NewReader(""))
+		require.ErrorIs(t, err, ErrKey)
+		_, err = store.Open(ctx, "ab")
+		require.ErrorIs(t, err, ErrKey)
+	})
+
+	t.Run("walk", func(t *testing.T) {
+		keys := map[string]bool{NewKey(): true, NewKey(): true}
+		for key := range keys {
+			_, err := store.Put(ctx, key, strings.NewReader(key))
+			require.NoError(t, err)
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".tmp"), nil, 0o600))
+
+		found := map[string]bool{}
+		err := store.Walk(ctx, func(key string, modTime time.Time) error {
+			require.WithinDuration(t, time.Now(), modTime, time.Minute)
+			found[key] = true
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, keys, found)
+	})
+}
+
+func TestConf(t *testing.T) {
+	v := &config.Validator{}
+	Conf{}.Check(v)
+	require.NoError(t, v.Err(), "disabled")
+
+	v = &config.Validator{}
+	Conf{Store: "s3"}.Check(v)
+	require.Error(t, v.Err())
+
+	v = &config.Validator{}
+	Conf{Store: StoreFS, Dir: filepath.Join(t.TempDir(), "missing")}.Check(v)
+	require.Error(t, v.Err())
+}
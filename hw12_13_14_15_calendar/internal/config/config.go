@@ -0,0 +1,327 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrFormat = errors.New("unsupported config format")
+
+type validator interface {
+	Validate() error
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Load reads filename (TOML, YAML or JSON, chosen by extension) into conf,
+// applies <envPrefix>_* environment overrides and validates the result.
+// Keys are the `toml` tags of conf for every format.
+func Load(filename, envPrefix string, conf interface{}) error {
+	data, err := readFile(filename)
+	if err != nil {
+		return err
+	}
+
+	v := &Validator{}
+	rv := reflect.ValueOf(conf).Elem()
+	assignMap(rv, data, "", v)
+	applyEnv(rv, envPrefix, "", v)
+
+	if c, ok := conf.(validator); ok {
+		var verr *ValidationError
+		err := c.Validate()
+		switch {
+		case errors.As(err, &verr):
+			for _, p := range verr.Problems {
+				v.Addf("%v", p)
+			}
+		case err != nil:
+			v.Addf("%v", err)
+		}
+	}
+	return v.Err()
+}
+
+func readFile(filename string) (map[string]interface{}, error) {
+	filedata, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".toml":
+		err = toml.Unmarshal(filedata, &data)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(filedata, &data)
+	case ".json":
+		err = json.Unmarshal(filedata, &data)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrFormat, filepath.Ext(filename))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't parse %v: %w", filename, err)
+	}
+	return data, nil
+}
+
+type field struct {
+	name  string
+	value reflect.Value
+}
+
+func fields(rv reflect.Value) []field {
+	ret := []field{}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		tag := strings.Split(sf.Tag.Get("toml"), ",")[0]
+		switch {
+		case tag == "-":
+			continue
+		case sf.Anonymous && tag == "" && sf.Type.Kind() == reflect.Struct:
+			ret = append(ret, fields(rv.Field(i))...)
+			continue
+		case !sf.IsExported():
+			continue
+		case tag == "":
+			tag = sf.Name
+		}
+		ret = append(ret, field{name: tag, value: rv.Field(i)})
+	}
+	return ret
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func isSection(rv reflect.Value) bool {
+	return rv.Kind() == reflect.Struct && rv.Type() != reflect.TypeOf(time.Time{})
+}
+
+func assignMap(rv reflect.Value, data map[string]interface{}, path string, v *Validator) {
+	known := map[string]reflect.Value{}
+	for _, f := range fields(rv) {
+		known[f.name] = f.value
+	}
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		name := join(path, k)
+		fv, ok := known[k]
+		if !ok {
+			v.Addf("%v: unknown key", name)
+			continue
+		}
+
+		if isSection(fv) {
+			section, ok := data[k].(map[string]interface{})
+			if !ok {
+				v.Addf("%v: must be a section", name)
+				continue
+			}
+			assignMap(fv, section, name, v)
+			continue
+		}
+
+		if err := assignValue(fv, data[k]); err != nil {
+			v.Addf("%v: %v", name, err)
+		}
+	}
+}
+
+func assignValue(fv reflect.Value, raw interface{}) error {
+	if s, ok := raw.(string); ok {
+		return assignString(fv, s)
+	}
+
+	switch fv.Kind() { //nolint:exhaustive
+	case reflect.Bool:
+		b, ok := raw.(bool)
+		if !ok {
+			return fmt.Errorf("must be a boolean, got %v", raw)
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if fv.Type() == durationType {
+			return fmt.Errorf("must be a duration string like \"10s\", got %v", raw)
+		}
+		n, ok := toInt(raw)
+		if !ok {
+			return fmt.Errorf("must be an integer, got %v", raw)
+		}
+		fv.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		n, ok := toFloat(raw)
+		if !ok {
+			return fmt.Errorf("must be a number, got %v", raw)
+		}
+		fv.SetFloat(n)
+	case reflect.Slice:
+		items, ok := raw.([]interface{})
+		if !ok {
+			return fmt.Errorf("must be a list, got %v", raw)
+		}
+		slice := reflect.MakeSlice(fv.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := assignValue(slice.Index(i), item); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		fv.Set(slice)
+	default:
+		return fmt.Errorf("unsupported value %v", raw)
+	}
+	return nil
+}
+
+func assignString(fv reflect.Value, s string) error {
+	switch fv.Kind() { //nolint:exhaustive
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("must be a boolean, got %q", s)
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if fv.Type() == durationType {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return fmt.Errorf("must be a duration like \"10s\", got %q", s)
+			}
+			fv.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("must be an integer, got %q", s)
+		}
+		fv.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number, got %q", s)
+		}
+		fv.SetFloat(n)
+	case reflect.Slice:
+		items := []interface{}{}
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return assignValue(fv, items)
+	default:
+		return fmt.Errorf("unsupported value %q", s)
+	}
+	return nil
+}
+
+func toInt(raw interface{}) (int64, bool) {
+	switch n := raw.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case float64:
+		if n == float64(int64(n)) {
+			return int64(n), true
+		}
+	}
+	return 0, false
+}
+
+func toFloat(raw interface{}) (float64, bool) {
+	switch n := raw.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// EnvName returns the environment variable overriding the key at path,
+// e.g. CALENDAR + http-server.port -> CALENDAR_HTTP_SERVER_PORT.
+func EnvName(prefix, path string) string {
+	name := strings.NewReplacer(".", "_", "-", "_").Replace(path)
+	return strings.ToUpper(prefix + "_" + name)
+}
+
+func applyEnv(rv reflect.Value, prefix, path string, v *Validator) {
+	for _, f := range fields(rv) {
+		name := join(path, f.name)
+		if isSection(f.value) {
+			applyEnv(f.value, prefix, name, v)
+			continue
+		}
+
+		env := EnvName(prefix, name)
+		s, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		if err := assignString(f.value, s); err != nil {
+			v.Addf("%v: %v", env, err)
+		}
+	}
+}
+
+type Change struct {
+	Key string
+	Old string
+	New string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%v: %v -> %v", c.Key, c.Old, c.New)
+}
+
+// Diff lists the keys whose values differ between two configs of the same type.
+func Diff(oldConf, newConf interface{}) []Change {
+	changes := []Change{}
+	diff(reflect.ValueOf(oldConf), reflect.ValueOf(newConf), "", &changes)
+	return changes
+}
+
+func diff(oldV, newV reflect.Value, path string, changes *[]Change) {
+	newFields := fields(newV)
+	for i, f := range fields(oldV) {
+		name := join(path, f.name)
+		if isSection(f.value) {
+			diff(f.value, newFields[i].value, name, changes)
+			continue
+		}
+		if !reflect.DeepEqual(f.value.Interface(), newFields[i].value.Interface()) {
+			*changes = append(*changes, Change{
+				Key: name,
+				Old: fmt.Sprint(f.value.Interface()),
+				New: fmt.Sprint(newFields[i].value.Interface()),
+			})
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testLogger struct {
+	Level string `toml:"level"`
+}
+
+type testBase struct {
+	Logger testLogger `toml:"logger"`
+	HTTP   struct {
+		Host string `toml:"host"`
+		Port string `toml:"port"`
+	} `toml:"http-server"`
+	Origins []string `toml:"origins"`
+}
+
+type testConf struct {
+	testBase
+	URL     string        `toml:"url"`
+	Period  time.Duration `toml:"period"`
+	Retries int           `toml:"retries"`
+	Enabled bool          `toml:"enabled"`
+}
+
+func (c testConf) Validate() error {
+	v := &Validator{}
+	v.Section("logger").OneOf("level", c.Logger.Level, "INFO", "DEBUG")
+	v.Section("http-server").Port("port", c.HTTP.Port)
+	v.URL("url", c.URL, "amqp")
+	v.Positive("period", c.Period)
+	v.Range("retries", int64(c.Retries), 0, 10)
+	return v.Err()
+}
+
+func helperWrite(t *testing.T, name, data string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(filename, []byte(data), 0o600))
+	return filename
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+	}{
+		{
+			name: "toml",
+			file: "conf.toml",
+			data: `url = "amqp://localhost:5672/"
+period = "10s"
+retries = 3
+enabled = true
+origins = ["a", "b"]
+
+[logger]
+level = "DEBUG"
+
+[http-server]
+host = "localhost"
+port = "8089"
+`,
+		},
+		{
+			name: "yaml",
+			file: "conf.yaml",
+			data: `url: amqp://localhost:5672/
+period: 10s
+retries: 3
+enabled: true
+origins: [a, b]
+logger:
+  level: DEBUG
+http-server:
+  host: localhost
+  port: "8089"
+`,
+		},
+		{
+			name: "json",
+			file: "conf.json",
+			data: `{"url": "amqp://localhost:5672/", "period": "10s", "retries": 3, "enabled": true,
+"origins": ["a", "b"], "logger": {"level": "DEBUG"}, "http-server": {"host": "localhost", "port": "8089"}}`,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var conf testConf
+			err := Load(helperWrite(t, tc.file, tc.data), "TEST", &conf)
+			require.NoError(t, err)
+			require.Equal(t, "DEBUG", conf.Logger.Level)
+			require.Equal(t, "8089", conf.HTTP.Port)
+			require.Equal(t, 10*time.Second, conf.Period)
+			require.Equal(t, 3, conf.Retries)
+			require.True(t, conf.Enabled)
+			require.Equal(t, []string{"a", "b"}, conf.Origins)
+		})
+	}
+
+	t.Run("env_override", func(t *testing.T) {
+		t.Setenv("TEST_HTTP_SERVER_PORT", "9000")
+		t.Setenv("TEST_PERIOD", "1m")
+		t.Setenv("TEST_ORIGINS", "x, y")
+		filename := helperWrite(t, "conf.toml", `url = "amqp://localhost/"
+period = "10s"
+[logger]
+level = "INFO"
+[http-server]
+port = "8089"
+`)
+		var conf testConf
+		require.NoError(t, Load(filename, "TEST", &conf))
+		require.Equal(t, "9000", conf.HTTP.Port)
+		require.Equal(t, time.Minute, conf.Period)
+		require.Equal(t, []string{"x", "y"}, conf.Origins)
+	})
+
+	t.Run("all_problems", func(t *testing.T) {
+		t.Setenv("TEST_RETRIES", "many")
+		filename := helperWrite(t, "conf.toml", `url = "http://"
+unknown = 1
+[logger]
+level = "TRACE"
+[http-server]
+port = "70000"
+`)
+		var conf testConf
+		err := Load(filename, "TEST", &conf)
+		var verr *ValidationError
+		require.True(t, errors.As(err, &verr))
+		require.Equal(t, []string{
+			"unknown: unknown key",
+			`TEST_RETRIES: must be an integer, got "many"`,
+			`logger.level: "TRACE" is not one of INFO, DEBUG`,
+			`http-server.port: "70000" is not a port in range 1-65535`,
+			`url: URL "http://" has no host`,
+			`url: URL scheme "http" is not one of amqp`,
+			"period: must be positive, got 0s",
+		}, verr.Problems)
+
+		t.Setenv("TEST_RETRIES", "11")
+		err = Load(helperWrite(t, "conf.toml", `url = "amqp://localhost"
+period = "1s"
+[logger]
+level = "INFO"
+[http-server]
+port = "70000"
+`), "TEST", &conf)
+		require.True(t, errors.As(err, &verr))
+		require.Equal(t, []string{
+			`http-server.port: "70000" is not a port in range 1-65535`,
+			"retries: 11 is out of range 0-10",
+		}, verr.Problems)
+	})
+
+	t.Run("wrong_format", func(t *testing.T) {
+		var conf testConf
+		err := Load(helperWrite(t, "conf.ini", ""), "TEST", &conf)
+		require.ErrorIs(t, err, ErrFormat)
+	})
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n\t" + strings.Join(e.Problems, "\n\t")
+}
+
+// Validator collects every problem of a config so they can be reported at once.
+type Validator struct {
+	prefix   string
+	problems *[]string
+}
+
+func (v *Validator) Section(name string) *Validator {
+	if v.problems == nil {
+		v.problems = &[]string{}
+	}
+	return &Validator{prefix: join(v.prefix, name), problems: v.problems}
+}
+
+func (v *Validator) Addf(format string, a ...interface{}) {
+	if v.problems == nil {
+		v.problems = &[]string{}
+	}
+	*v.problems = append(*v.problems, fmt.Sprintf(format, a...))
+}
+
+func (v *Validator) AddKeyf(key, format string, a ...interface{}) {
+	v.Addf("%v: "+format, append([]interface{}{join(v.prefix, key)}, a...)...)
+}
+
+func (v *Validator) Err() error {
+	if v.problems == nil || len(*v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: *v.problems}
+}
+
+func (v *Validator) Required(key, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.AddKeyf(key, "required")
+		return false
+	}
+	return true
+}
+
+func (v *Validator) File(key, filename string) {
+	if filename == "" {
+		return
+	}
+	if _, err := os.Stat(filename); err != nil {
+		v.AddKeyf(key, "%v", err)
+	}
+}
+
+func (v *Validator) OneOf(key, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.AddKeyf(key, "%q is not one of %v", value, strings.Join(allowed, ", "))
+}
+
+func (v *Validator) Port(key, value string) {
+	if !v.Required(key, value) {
+		return
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		v.AddKeyf(key, "%q is not a port in range 1-65535", value)
+	}
+}
+
+// Addr checks a host:port address, an empty host means all interfaces.
+func (v *Validator) Addr(key, value string) {
+	if !v.Required(key, value) {
+		return
+	}
+	_, port, err := net.SplitHostPort(value)
+	if err != nil {
+		v.AddKeyf(key, "%v", err)
+		return
+	}
+	v.Port(key, port)
+}
+
+func (v *Validator) URL(key, value string, schemes ...string) {
+	if !v.Required(key, value) {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		v.AddKeyf(key, "malformed URL: %v", err)
+		return
+	}
+	if u.Host == "" {
+		v.AddKeyf(key, "URL %q has no host", value)
+	}
+	for _, s := range schemes {
+		if u.Scheme == s {
+			return
+		}
+	}
+	v.AddKeyf(key, "URL scheme %q is not one of %v", u.Scheme, strings.Join(schemes, ", "))
+}
+
+func (v *Validator) Positive(key string, d time.Duration) {
+	if d <= 0 {
+		v.AddKeyf(key, "must be positive, got %v", d)
+	}
+}
+
+func (v *Validator) NotNegative(key string, d time.Duration) {
+	if d < 0 {
+		v.AddKeyf(key, "must not be negative, got %v", d)
+	}
+}
+
+func (v *Validator) Range(key string, value, min, max int64) {
+	if value < min || value > max {
+		v.AddKeyf(key, "%v is out of range %v-%v", value, min, max)
+	}
+}
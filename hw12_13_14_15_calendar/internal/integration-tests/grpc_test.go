@@ -14,6 +14,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -25,7 +26,7 @@ func helperAPIEvent(id int64, userid int64, onTime, offTime time.Time) *api.Even
 	aEvent.Description = func(s string) *string { return &s }(fmt.Sprintf("DescriptionN%v", userid))
 	aEvent.OnTime = timestamppb.New(onTime)
 	aEvent.OffTime = timestamppb.New(offTime)
-	aEvent.NotifyTime = timestamppb.New(time.Time{})
+	aEvent.Reminders = []*api.Reminder{{Offset: durationpb.New(15 * time.Minute)}}
 	return &aEvent
 }
 
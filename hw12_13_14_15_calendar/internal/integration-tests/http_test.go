@@ -57,7 +57,7 @@ func TestIntegrationHTTPAPi(t *testing.T) { //nolint:nolintlint
 		"description" : "Description_N200",
 		"ontime" : "2015-09-18T00:00:00Z",
 		"offtime" : "2015-09-19T00:00:00Z",
-		"notifytime" : "0001-01-01T00:00:00Z"
+		"reminders" : [{"offset": "15m"}]
 	}`
 
 	body2 := fmt.Sprintf(`{
@@ -66,8 +66,7 @@ func TestIntegrationHTTPAPi(t *testing.T) { //nolint:nolintlint
 	 	"title" : "Title_N400",
 	 	"description" : "Description_N400",
 	 	"ontime" : "2015-09-18T00:00:00Z",
-	 	"offtime" : "2015-09-20T00:00:00Z",
-	 	"notifytime" : "0001-01-01T00:00:00Z"
+	 	"offtime" : "2015-09-20T00:00:00Z"
 	 	}`, userID400)
 
 	body3 := fmt.Sprintf(`{
@@ -76,8 +75,7 @@ func TestIntegrationHTTPAPi(t *testing.T) { //nolint:nolintlint
 					"title" : "Title_N402",
 					"description" : "Description_N402",
 					"ontime" : "2015-10-18T00:00:00Z",
-					"offtime" : "2015-10-20T00:00:00Z",
-					"notifytime" : "0001-01-01T00:00:00Z"
+					"offtime" : "2015-10-20T00:00:00Z"
 				}`, userID400)
 
 	t.Run("case_insert", func(t *testing.T) {
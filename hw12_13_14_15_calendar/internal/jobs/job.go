@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+)
+
+// ErrorPolicy tells the runner what to do when a job fails.
+type ErrorPolicy string
+
+const (
+	// Continue logs the error, the job runs again as scheduled.
+	Continue ErrorPolicy = "continue"
+	// Fail stops the runner, Run returns the error.
+	Fail ErrorPolicy = "fail"
+)
+
+type Conf struct {
+	Schedule string        `toml:"schedule"`
+	Jitter   time.Duration `toml:"jitter"`
+	Timeout  time.Duration `toml:"timeout"`
+	OnError  string        `toml:"on_error"`
+}
+
+func (c Conf) Check(v *config.Validator) {
+	if c.Schedule != "" {
+		if _, err := Parse(c.Schedule); err != nil {
+			v.AddKeyf("schedule", "%v", err)
+		}
+	}
+	v.NotNegative("jitter", c.Jitter)
+	v.NotNegative("timeout", c.Timeout)
+	if c.OnError != "" {
+		v.OneOf("on_error", c.OnError, string(Continue), string(Fail))
+	}
+}
+
+// Func runs a job for the date of the run.
+type Func func(ctx context.Context, date time.Time) error
+
+type Job struct {
+	Name     string
+	Schedule Schedule
+	// Jitter delays every run by a random duration up to Jitter, so
+	// replicas do not hit the storage at the same moment.
+	Jitter  time.Duration
+	Timeout time.Duration
+	OnError ErrorPolicy
+	Run     Func
+}
+
+// NewJob creates a job of the config, defaultSchedule is used when the
+// config has none.
+func NewJob(name string, conf Conf, defaultSchedule string, run Func) (Job, error) {
+	spec := conf.Schedule
+	if spec == "" {
+		spec = defaultSchedule
+	}
+	schedule, err := Parse(spec)
+	if err != nil {
+		return Job{}, err
+	}
+
+	onError := ErrorPolicy(conf.OnError)
+	if onError == "" {
+		onError = Continue
+	}
+	return Job{
+		Name:     name,
+		Schedule: schedule,
+		Jitter:   conf.Jitter,
+		Timeout:  conf.Timeout,
+		OnError:  onError,
+		Run:      run,
+	}, nil
+}
@@ -0,0 +1,141 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedule(t *testing.T) {
+	start := time.Date(2023, 1, 31, 10, 17, 30, 0, time.UTC) // Tuesday
+	for _, tc := range []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"@every 1m30s", start.Add(90 * time.Second)},
+		{"* * * * *", time.Date(2023, 1, 31, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2023, 1, 31, 10, 30, 0, 0, time.UTC)},
+		{"5,50 9-11 * * *", time.Date(2023, 1, 31, 10, 50, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2023, 1, 31, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 3 * * sun", time.Date(2023, 2, 5, 3, 30, 0, 0, time.UTC)},
+		{"30 3 * * 7", time.Date(2023, 2, 5, 3, 30, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * fri", time.Date(2023, 2, 3, 0, 0, 0, 0, time.UTC)}, // either day field matches
+		{"0 12 1 */3 *", time.Date(2023, 4, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	} {
+		schedule, err := Parse(tc.spec)
+		require.NoError(t, err, tc.spec)
+		require.Equal(t, tc.expected, schedule.Next(start), tc.spec)
+		require.Equal(t, tc.spec, schedule.String())
+	}
+
+	india := time.FixedZone("IST", 5*3600+1800)
+	schedule, err := Parse("0 * * * *")
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2023, 1, 31, 11, 0, 0, 0, india), schedule.Next(time.Date(2023, 1, 31, 10, 17, 0, 0, india)))
+
+	for _, spec := range []string{
+		"", "@every", "@every -1s", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *",
+		"* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@sometimes",
+	} {
+		_, err := Parse(spec)
+		require.ErrorIs(t, err, ErrSchedule, spec)
+	}
+}
+
+func TestConf(t *testing.T) {
+	v := &config.Validator{}
+	Conf{}.Check(v)
+	Conf{Schedule: "@every 10s", Jitter: time.Second, Timeout: time.Minute, OnError: "fail"}.Check(v)
+	require.NoError(t, v.Err())
+
+	Conf{Schedule: "often", Jitter: -time.Second, OnError: "panic"}.Check(v.Section("jobs"))
+	require.ErrorContains(t, v.Err(), "jobs.schedule")
+	require.ErrorContains(t, v.Err(), "jobs.jitter")
+	require.ErrorContains(t, v.Err(), "jobs.on_error")
+
+	job, err := NewJob("notify", Conf{}, "@every 10s", nil)
+	require.NoError(t, err)
+	require.Equal(t, Every(10*time.Second), job.Schedule)
+	require.Equal(t, Continue, job.OnError)
+}
+
+func TestRunner(t *testing.T) {
+	log := logger.NewLogger("DEBUG", os.Stdout)
+	ctx := context.Background()
+
+	t.Run("schedule", func(t *testing.T) {
+		var runs int32
+		r := NewRunner(log)
+		require.NoError(t, r.Add(Job{Name: "tick", Schedule: Every(10 * time.Millisecond), Jitter: time.Millisecond,
+			Run: func(context.Context, time.Time) error {
+				atomic.AddInt32(&runs, 1)
+				return errors.New("failed")
+			}}))
+		require.ErrorIs(t, r.Add(Job{Name: "tick", Schedule: Every(time.Second)}), ErrDuplicate)
+
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		require.NoError(t, r.Run(ctx), "errors are logged by default")
+
+		status := r.Status()
+		require.Len(t, status, 1)
+		require.GreaterOrEqual(t, atomic.LoadInt32(&runs), int32(3))
+		require.EqualValues(t, atomic.LoadInt32(&runs), status[0].Runs)
+		require.Equal(t, status[0].Runs, status[0].Failures)
+		require.Equal(t, "failed", status[0].LastError)
+		require.Equal(t, "@every 10ms", status[0].Schedule)
+		require.False(t, status[0].LastRun.IsZero())
+		require.True(t, status[0].NextRun.After(status[0].LastRun))
+		require.Contains(t, statuses.Get("tick").String(), `"name":"tick"`)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		r := NewRunner(log)
+		blocked := make(chan struct{})
+		require.NoError(t, r.Add(Job{Name: "fail", Schedule: Every(time.Millisecond), OnError: Fail,
+			Run: func(context.Context, time.Time) error { return errors.New("broken") }}))
+		require.NoError(t, r.Add(Job{Name: "other", Schedule: Every(time.Hour),
+			Run: func(context.Context, time.Time) error { close(blocked); return nil }}))
+
+		err := r.Run(ctx)
+		require.ErrorContains(t, err, "job fail: broken")
+		select {
+		case <-blocked:
+			t.Fatal("other job is not stopped")
+		default:
+		}
+	})
+
+	t.Run("overlap_and_timeout", func(t *testing.T) {
+		r := NewRunner(log)
+		started := make(chan struct{})
+		require.NoError(t, r.Add(Job{Name: "slow", Schedule: Every(time.Hour), Timeout: 50 * time.Millisecond,
+			Run: func(ctx context.Context, _ time.Time) error {
+				close(started)
+				<-ctx.Done()
+				return ctx.Err()
+			}}))
+
+		done := make(chan error)
+		go func() { done <- r.RunNow(ctx, "slow", time.Now()) }()
+		<-started
+		require.ErrorIs(t, r.RunNow(ctx, "slow", time.Now()), ErrRunning)
+		require.ErrorIs(t, <-done, context.DeadlineExceeded)
+		require.ErrorIs(t, r.RunNow(ctx, "missing", time.Now()), ErrUnknown)
+
+		status := r.Status()[0]
+		require.EqualValues(t, 1, status.Skipped)
+		require.EqualValues(t, 1, status.Runs)
+		require.False(t, status.Running)
+	})
+}
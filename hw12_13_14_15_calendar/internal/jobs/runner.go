@@ -0,0 +1,183 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	ErrDuplicate = errors.New("duplicate job")
+	ErrUnknown   = errors.New("unknown job")
+	ErrRunning   = errors.New("job is running")
+)
+
+var statuses = expvar.NewMap("jobs")
+
+type Logger interface {
+	Errorf(format string, a ...interface{})
+	Warningf(format string, a ...interface{})
+	Debugf(format string, a ...interface{})
+}
+
+// Status of a job, shown in /debug/vars as "jobs".
+type Status struct {
+	Name         string        `json:"name"`
+	Schedule     string        `json:"schedule"`
+	Running      bool          `json:"running"`
+	LastRun      time.Time     `json:"last_run"`
+	LastDuration time.Duration `json:"last_duration"`
+	LastError    string        `json:"last_error,omitempty"`
+	NextRun      time.Time     `json:"next_run"`
+	Runs         int64         `json:"runs"`
+	Failures     int64         `json:"failures"`
+	Skipped      int64         `json:"skipped"`
+}
+
+type job struct {
+	Job
+	mu     sync.Mutex
+	status Status
+}
+
+func (j *job) Status() Status {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status
+}
+
+// Runner runs every job in its own goroutine. A job never overlaps with
+// itself: runs missed while it is busy are skipped.
+type Runner struct {
+	log  Logger
+	jobs map[string]*job
+	now  func() time.Time
+}
+
+func NewRunner(log Logger) *Runner {
+	return &Runner{log: log, jobs: map[string]*job{}, now: time.Now}
+}
+
+func (r *Runner) Add(j Job) error {
+	if _, ok := r.jobs[j.Name]; ok {
+		return fmt.Errorf("%w: %v", ErrDuplicate, j.Name)
+	}
+	entry := &job{Job: j, status: Status{Name: j.Name, Schedule: j.Schedule.String()}}
+	r.jobs[j.Name] = entry
+	statuses.Set(j.Name, expvar.Func(func() interface{} { return entry.Status() }))
+	return nil
+}
+
+// Status returns the status of every job ordered by name.
+func (r *Runner) Status() []Status {
+	ret := make([]Status, 0, len(r.jobs))
+	for _, j := range r.jobs {
+		ret = append(ret, j.Status())
+	}
+	sort.Slice(ret, func(i, k int) bool { return ret[i].Name < ret[k].Name })
+	return ret
+}
+
+// Run runs the jobs as scheduled until ctx is done. It returns the error of
+// a job with the Fail policy, the other jobs are stopped then.
+func (r *Runner) Run(ctx context.Context) error {
+	g, ctx := errgroup.WithContext(ctx)
+	for _, j := range r.jobs {
+		j := j
+		g.Go(func() error { return r.loop(ctx, j) })
+	}
+	return g.Wait()
+}
+
+// RunNow runs the job once at once, unless it is running already.
+func (r *Runner) RunNow(ctx context.Context, name string, date time.Time) error {
+	j, ok := r.jobs[name]
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrUnknown, name)
+	}
+	return r.run(ctx, j, date)
+}
+
+func (r *Runner) loop(ctx context.Context, j *job) error {
+	timer := time.NewTimer(0)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+
+	for {
+		now := r.now()
+		next := j.Schedule.Next(now)
+		if next.IsZero() {
+			r.log.Warningf("Job %v has no next run\n", j.Name)
+			return nil
+		}
+		if j.Jitter > 0 {
+			next = next.Add(time.Duration(rand.Int63n(int64(j.Jitter)))) //nolint:gosec
+		}
+		j.mu.Lock()
+		j.status.NextRun = next
+		j.mu.Unlock()
+
+		timer.Reset(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+		}
+
+		err := r.run(ctx, j, r.now())
+		if err != nil && !errors.Is(err, ErrRunning) && j.OnError == Fail {
+			return fmt.Errorf("job %v: %w", j.Name, err)
+		}
+	}
+}
+
+func (r *Runner) run(ctx context.Context, j *job, date time.Time) error {
+	j.mu.Lock()
+	if j.status.Running {
+		j.status.Skipped++
+		j.mu.Unlock()
+		r.log.Warningf("Job %v is still running, run skipped\n", j.Name)
+		return fmt.Errorf("%w: %v", ErrRunning, j.Name)
+	}
+	j.status.Running = true
+	j.mu.Unlock()
+
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+
+	r.log.Debugf("Job %v started\n", j.Name)
+	start := r.now()
+	err := j.Run(ctx, date)
+	duration := r.now().Sub(start)
+
+	j.mu.Lock()
+	j.status.Running = false
+	j.status.LastRun = start
+	j.status.LastDuration = duration
+	j.status.Runs++
+	j.status.LastError = ""
+	if err != nil {
+		j.status.Failures++
+		j.status.LastError = err.Error()
+	}
+	j.mu.Unlock()
+
+	if err != nil {
+		r.log.Errorf("Job %v failed after %v:%v\n", j.Name, duration, err)
+		return err
+	}
+	r.log.Debugf("Job %v finished in %v\n", j.Name, duration)
+	return nil
+}
@@ -7,6 +7,9 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
 )
 
 const (
@@ -22,29 +25,52 @@ type Conf struct {
 	Level string `toml:"level"`
 }
 
+func (c Conf) Check(v *config.Validator) {
+	v.OneOf("level", strings.ToUpper(c.Level), "ERROR", "WARN", "INFO", "DEBUG")
+}
+
 type Logger struct {
-	logLevel int
+	logLevel int32
 	out      io.Writer
 	mu       *sync.Mutex
 }
 
-func NewLogger(level string, out io.Writer) *Logger {
+func parseLevel(level string) (int32, error) {
 	switch strings.ToUpper(level) {
 	case "ERROR":
-		return &Logger{logLevel: LevelError, mu: &sync.Mutex{}, out: out}
+		return LevelError, nil
 	case "WARN":
-		return &Logger{logLevel: LevelWarn, mu: &sync.Mutex{}, out: out}
+		return LevelWarn, nil
 	case "INFO":
-		return &Logger{logLevel: LevelInfo, mu: &sync.Mutex{}, out: out}
+		return LevelInfo, nil
 	case "DEBUG":
-		return &Logger{logLevel: LevelDebug, mu: &sync.Mutex{}, out: out}
-	default:
+		return LevelDebug, nil
+	}
+	return 0, fmt.Errorf("%w: %q", ErrLogLevel, level)
+}
+
+func NewLogger(level string, out io.Writer) *Logger {
+	logLevel, err := parseLevel(level)
+	if err != nil {
 		fmt.Fprintln(os.Stderr, "unrecognized log_level")
 		os.Exit(1)
 	}
+	return &Logger{logLevel: logLevel, mu: &sync.Mutex{}, out: out}
+}
+
+func (l *Logger) SetLevel(level string) error {
+	logLevel, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt32(&l.logLevel, logLevel)
 	return nil
 }
 
+func (l *Logger) level() int32 {
+	return atomic.LoadInt32(&l.logLevel)
+}
+
 func (l *Logger) printf(format string, a ...interface{}) {
 	l.mu.Lock()
 	_, err := fmt.Fprintf(l.out, format, a...)
@@ -61,25 +87,25 @@ func (l *Logger) Fatalf(format string, a ...interface{}) {
 }
 
 func (l *Logger) Errorf(format string, a ...interface{}) {
-	if l.logLevel >= LevelError {
+	if l.level() >= LevelError {
 		l.printf("ERROR:"+format, a...)
 	}
 }
 
 func (l *Logger) Warningf(format string, a ...interface{}) {
-	if l.logLevel >= LevelWarn {
+	if l.level() >= LevelWarn {
 		l.printf("WARN:"+format, a...)
 	}
 }
 
 func (l *Logger) Infof(format string, a ...interface{}) {
-	if l.logLevel >= LevelInfo {
+	if l.level() >= LevelInfo {
 		l.printf("INFO:"+format, a...)
 	}
 }
 
 func (l *Logger) Debugf(format string, a ...interface{}) {
-	if l.logLevel >= LevelDebug {
+	if l.level() >= LevelDebug {
 		l.printf("DEBUG:"+format, a...)
 	}
 }
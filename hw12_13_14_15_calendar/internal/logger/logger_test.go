@@ -100,3 +100,17 @@ func TestFatalf(t *testing.T) {
 	}
 	t.Fatalf("process ran with err %v, want exit status 1", err)
 }
+
+func TestSetLevel(t *testing.T) {
+	var b bytes.Buffer
+	l := NewLogger("ERROR", &b)
+
+	l.Debugf("skipped")
+	require.Empty(t, b.String())
+
+	require.NoError(t, l.SetLevel("debug"))
+	l.Debugf("message")
+	require.Equal(t, "DEBUG:message", b.String())
+
+	require.ErrorIs(t, l.SetLevel("TRACE"), ErrLogLevel)
+}
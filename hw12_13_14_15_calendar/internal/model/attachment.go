@@ -0,0 +1,24 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrQuotaExceeded is returned by storages when an attachment does not fit
+// the quota of the user.
+var ErrQuotaExceeded = errors.New("attachment quota exceeded")
+
+// Attachment is a file of an event, the content is kept in the blob store
+// under Key. Checksum is the hex SHA-256 of the content.
+type Attachment struct {
+	ID          int64     `json:"id"`
+	EventID     int64     `json:"eventid"`
+	UserID      int64     `json:"userid"`
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"contenttype"`
+	Checksum    string    `json:"checksum"`
+	Key         string    `json:"-"`
+	CreatedAt   time.Time `json:"createdat"`
+}
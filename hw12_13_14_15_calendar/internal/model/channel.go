@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// UserChannel is a delivery channel chosen by the user, e.g. an email
+// address or a webhook URL. Channels without an address, like "log",
+// leave it empty. Locale and TimeZone choose how notifications are
+// rendered, empty ones mean the configured defaults.
+type UserChannel struct {
+	UserID   int64  `json:"userid"`
+	Channel  string `json:"channel"`
+	Address  string `json:"address,omitempty"`
+	Locale   string `json:"locale,omitempty"`
+	TimeZone string `json:"timezone,omitempty"`
+}
+
+// DeliveryFailure records a failed attempt to notify through one channel.
+type DeliveryFailure struct {
+	NotificationID int64     `json:"notificationid"`
+	Channel        string    `json:"channel"`
+	Address        string    `json:"address,omitempty"`
+	Error          string    `json:"error"`
+	FailedAt       time.Time `json:"failedat"`
+}
@@ -1,14 +1,141 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
+// Event takes the time from OnTime to OffTime. All-day events take whole
+// dates instead: OnTime is the first date and OffTime the date after the
+// last one, both at midnight UTC, see NormalizeDates.
 type Event struct {
-	ID          int64     `json:"id"`
-	UserID      int64     `json:"userid"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	OnTime      time.Time `json:"ontime"`
-	OffTime     time.Time `json:"offtime"`
-	NotifyTime  time.Time `json:"notifytime,omitempty"`
-	Notified    bool      `json:"-"`
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"userid"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	OnTime      time.Time  `json:"ontime"`
+	OffTime     time.Time  `json:"offtime"`
+	AllDay      bool       `json:"allday,omitempty"`
+	Reminders   []Reminder `json:"reminders,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+}
+
+// Date returns the date of t in its own location as midnight UTC.
+func Date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// NormalizeDates turns the times of an all-day event into dates. A zero
+// OffTime or one of the same date as OnTime makes a single-day event.
+func (e *Event) NormalizeDates() {
+	if !e.AllDay || e.OnTime.IsZero() {
+		return
+	}
+	e.OnTime = Date(e.OnTime)
+	if !e.OffTime.IsZero() {
+		e.OffTime = Date(e.OffTime)
+	}
+	if e.OffTime.IsZero() || e.OffTime.Equal(e.OnTime) {
+		e.OffTime = e.OnTime.AddDate(0, 0, 1)
+	}
+}
+
+// Span returns the time the event takes for a viewer in loc, all-day events
+// take their dates from the local midnight.
+func (e *Event) Span(loc *time.Location) (onTime, offTime time.Time) {
+	if !e.AllDay {
+		return e.OnTime, e.OffTime
+	}
+	on, off := e.OnTime.UTC(), e.OffTime.UTC()
+	return time.Date(on.Year(), on.Month(), on.Day(), 0, 0, 0, 0, loc),
+		time.Date(off.Year(), off.Month(), off.Day(), 0, 0, 0, 0, loc)
+}
+
+// ScheduleReminders recomputes the notify times of the reminders from OnTime.
+// Reminders whose time changed are due again. The reminders of all-day events
+// count from midnight UTC of the first date, not the recipient's midnight:
+// a reminder may go to channels of several time zones.
+func (e *Event) ScheduleReminders() {
+	for i := range e.Reminders {
+		r := &e.Reminders[i]
+		notifyTime := e.OnTime.Add(-r.Offset)
+		if !r.NotifyTime.Equal(notifyTime) {
+			r.NotifyTime = notifyTime
+			r.Notified = false
+		}
+	}
+}
+
+// Reminder notifies the user Offset before the event starts through Channel,
+// or through all the user's channels if Channel is empty.
+type Reminder struct {
+	ID         int64         `json:"id,omitempty"`
+	Offset     time.Duration `json:"offset"`
+	Channel    string        `json:"channel,omitempty"`
+	NotifyTime time.Time     `json:"notifytime"`
+	Notified   bool          `json:"notified"`
+}
+
+type reminderJSON struct {
+	ID         int64     `json:"id,omitempty"`
+	Offset     string    `json:"offset"`
+	Channel    string    `json:"channel,omitempty"`
+	NotifyTime time.Time `json:"notifytime"`
+	Notified   bool      `json:"notified"`
+}
+
+// MarshalJSON writes Offset as a duration string like "15m0s".
+func (r Reminder) MarshalJSON() ([]byte, error) {
+	return json.Marshal(reminderJSON{
+		ID:         r.ID,
+		Offset:     r.Offset.String(),
+		Channel:    r.Channel,
+		NotifyTime: r.NotifyTime,
+		Notified:   r.Notified,
+	})
+}
+
+// UnmarshalJSON reads Offset as a duration string like "15m".
+func (r *Reminder) UnmarshalJSON(data []byte) error {
+	var rj reminderJSON
+	if err := json.Unmarshal(data, &rj); err != nil {
+		return err
+	}
+	offset, err := time.ParseDuration(rj.Offset)
+	if err != nil {
+		return fmt.Errorf("reminder offset: %w", err)
+	}
+	*r = Reminder{ID: rj.ID, Offset: offset, Channel: rj.Channel, NotifyTime: rj.NotifyTime, Notified: rj.Notified}
+	return nil
+}
+
+// MergeReminders matches reminders with the stored ones by offset and
+// channel. Matched reminders keep their ID and, if their notify time did
+// not change, the notified state; others get zero IDs to be inserted.
+func MergeReminders(stored, reminders []Reminder) []Reminder {
+	merged := make([]Reminder, len(reminders))
+	for i, r := range reminders {
+		r.ID = 0
+		r.Notified = false
+		for _, old := range stored {
+			if old.Offset == r.Offset && old.Channel == r.Channel {
+				r.ID = old.ID
+				r.Notified = old.Notified && old.NotifyTime.Equal(r.NotifyTime)
+				break
+			}
+		}
+		merged[i] = r
+	}
+	return merged
+}
+
+// SearchResult is an event found by a full-text query. Title and Snippet are
+// the HTML escaped title and a part of the description with the matched
+// words wrapped in <b></b>.
+type SearchResult struct {
+	Event   Event   `json:"event"`
+	Rank    float64 `json:"rank"`
+	Title   string  `json:"title"`
+	Snippet string  `json:"snippet"`
 }
@@ -1,10 +1,88 @@
 package model
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
+// ErrNotificationNotQueued is returned by storages when a notification can't
+// change its state because it is not queued, e.g. it was already delivered.
+var ErrNotificationNotQueued = errors.New("notification is not queued")
+
+// NotificationStatus is a state of the notification outbox entry:
+// pending -> queued -> delivered or failed. Queued entries that are not
+// delivered in time go back to the queue.
+type NotificationStatus string
+
+const (
+	NotificationPending   NotificationStatus = "pending"
+	NotificationQueued    NotificationStatus = "queued"
+	NotificationDelivered NotificationStatus = "delivered"
+	NotificationFailed    NotificationStatus = "failed"
+)
+
+type Notification struct {
+	ID         int64
+	EventID    int64
+	ReminderID int64
+	Channel    string
+	UserID     int64
+	Title      string
+	Date       time.Time
+	NotifyTime time.Time
+	Status     NotificationStatus
+	Attempts   int
+	QueuedAt   time.Time
+	LastError  string
+}
+
+// NotificationMsg is the queued notification. ID is the event ID. Channel
+// limits delivery to one of the user's channels, empty means all of them.
+// DedupKey is the same for every copy of the notification of a reminder.
 type NotificationMsg struct {
-	ID     int64
-	Title  string
-	Date   time.Time
-	UserID int64
+	ID             int64
+	NotificationID int64
+	ReminderID     int64  `json:",omitempty"`
+	Channel        string `json:",omitempty"`
+	DedupKey       string `json:",omitempty"`
+	Title          string
+	Date           time.Time
+	UserID         int64
+	DeliveryTag    uint64 `json:"-"`
 }
+
+func NewNotificationMsg(n *Notification) NotificationMsg {
+	return NotificationMsg{
+		ID:             n.EventID,
+		NotificationID: n.ID,
+		ReminderID:     n.ReminderID,
+		Channel:        n.Channel,
+		DedupKey:       DedupKey(n.EventID, n.NotifyTime, n.Channel),
+		Title:          n.Title,
+		Date:           n.Date,
+		UserID:         n.UserID,
+	}
+}
+
+// DedupKey identifies the reminder of the event due at notifyTime, e.g.
+// "42:1672671600" or "42:1672671600:email" for a reminder of one channel.
+func DedupKey(eventID int64, notifyTime time.Time, channel string) string {
+	key := fmt.Sprintf("%d:%d", eventID, notifyTime.Unix())
+	if channel != "" {
+		key += ":" + channel
+	}
+	return key
+}
+
+// DedupState is what ClaimDedupKey found for a key.
+type DedupState int
+
+const (
+	// DedupClaimed means the key was free and is leased to the caller.
+	DedupClaimed DedupState = iota
+	// DedupPending means another copy is being sent.
+	DedupPending
+	// DedupDone means a copy was sent.
+	DedupDone
+)
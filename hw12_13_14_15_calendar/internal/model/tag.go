@@ -0,0 +1,70 @@
+package model
+
+import (
+	"sort"
+	"strings"
+)
+
+// TagFilter selects the events having any of Any and all of All, an empty
+// filter selects every event.
+type TagFilter struct {
+	Any []string `json:"any,omitempty"`
+	All []string `json:"all,omitempty"`
+}
+
+func (f TagFilter) IsEmpty() bool {
+	return len(f.Any) == 0 && len(f.All) == 0
+}
+
+// Match expects normalized tags on both sides, see NormalizeTags.
+func (f TagFilter) Match(tags []string) bool {
+	has := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		has[tag] = true
+	}
+	for _, tag := range f.All {
+		if !has[tag] {
+			return false
+		}
+	}
+	if len(f.Any) == 0 {
+		return true
+	}
+	for _, tag := range f.Any {
+		if has[tag] {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize returns the filter with normalized tags.
+func (f TagFilter) Normalize() TagFilter {
+	return TagFilter{Any: NormalizeTags(f.Any), All: NormalizeTags(f.All)}
+}
+
+// TagCount is the number of events of a user having the tag.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int64  `json:"count"`
+}
+
+// NormalizeTags trims and lowercases the tags, drops empty and duplicate
+// ones and sorts the rest.
+func NormalizeTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
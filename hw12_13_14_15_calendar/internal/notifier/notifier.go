@@ -0,0 +1,111 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+)
+
+const (
+	ChannelLog     = "log"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// Channels lists the names of all known delivery channels.
+var Channels = []string{ChannelLog, ChannelEmail, ChannelWebhook}
+
+// redacted hides a secret in config dumps, keeping whether it is set.
+func redacted(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "***"
+}
+
+// Known tells if name is one of Channels.
+func Known(name string) bool {
+	for _, c := range Channels {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	ErrNoAddress     = errors.New("channel address is not set")
+	ErrNotConfigured = errors.New("channel is not configured")
+)
+
+type Logger interface {
+	Fatalf(format string, a ...interface{})
+	Errorf(format string, a ...interface{})
+	Warningf(format string, a ...interface{})
+	Infof(format string, a ...interface{})
+	Debugf(format string, a ...interface{})
+}
+
+// Notifier delivers a rendered notification to the address of one channel.
+type Notifier interface {
+	Notify(ctx context.Context, address string, msg Message) error
+}
+
+type Conf struct {
+	Default   []string      `toml:"default"`
+	SMTP      SMTPConf      `toml:"smtp"`
+	Webhook   WebhookConf   `toml:"webhook"`
+	Templates TemplatesConf `toml:"templates"`
+}
+
+func (c Conf) Check(v *config.Validator) {
+	for _, name := range c.Default {
+		v.OneOf("default", name, Channels...)
+	}
+	c.SMTP.Check(v.Section("smtp"))
+	c.Webhook.Check(v.Section("webhook"))
+	c.Templates.Check(v.Section("templates"))
+}
+
+// Defaults returns the channels of users without own preferences.
+func (c Conf) Defaults() []model.UserChannel {
+	names := c.Default
+	if len(names) == 0 {
+		names = []string{ChannelLog}
+	}
+	channels := make([]model.UserChannel, len(names))
+	for i, name := range names {
+		channels[i] = model.UserChannel{Channel: name}
+	}
+	return channels
+}
+
+// New returns the notifiers of the configured channels by channel name.
+func New(log Logger, conf Conf) map[string]Notifier {
+	notifiers := map[string]Notifier{
+		ChannelLog: NewLog(log),
+	}
+	if conf.SMTP.Enabled() {
+		notifiers[ChannelEmail] = NewSMTP(conf.SMTP)
+	}
+	if conf.Webhook.Enabled() {
+		notifiers[ChannelWebhook] = NewWebhook(conf.Webhook)
+	}
+	return notifiers
+}
+
+type Log struct {
+	log Logger
+}
+
+// NewLog returns a notifier that only writes notifications to the log.
+func NewLog(log Logger) *Log {
+	return &Log{log: log}
+}
+
+func (n *Log) Notify(ctx context.Context, address string, msg Message) error {
+	n.log.Infof("Notification for user %v: %v\n", msg.Notification.UserID, msg.Body)
+	return nil
+}
@@ -0,0 +1,134 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+)
+
+const defaultSMTPTimeout = 10 * time.Second
+
+type SMTPConf struct {
+	Host     string        `toml:"host"`
+	Port     string        `toml:"port"`
+	Username string        `toml:"username"`
+	Password string        `toml:"password"`
+	From     string        `toml:"from"`
+	Timeout  time.Duration `toml:"timeout"`
+}
+
+func (c SMTPConf) Enabled() bool {
+	return c.Host != ""
+}
+
+// String hides the password when the config is printed.
+func (c SMTPConf) String() string {
+	type conf SMTPConf
+	c.Password = redacted(c.Password)
+	return fmt.Sprint(conf(c))
+}
+
+func (c SMTPConf) Check(v *config.Validator) {
+	if !c.Enabled() {
+		return
+	}
+	v.Port("port", c.Port)
+	v.Required("from", c.From)
+	v.NotNegative("timeout", c.Timeout)
+}
+
+func (c SMTPConf) timeout() time.Duration {
+	if c.Timeout == 0 {
+		return defaultSMTPTimeout
+	}
+	return c.Timeout
+}
+
+type SMTP struct {
+	conf SMTPConf
+}
+
+func NewSMTP(conf SMTPConf) *SMTP {
+	return &SMTP{conf: conf}
+}
+
+// Notify sends an email to address. STARTTLS is used when the server
+// offers it, the credentials are only sent over TLS.
+func (n *SMTP) Notify(ctx context.Context, address string, msg Message) error {
+	if address == "" {
+		return ErrNoAddress
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, n.conf.timeout())
+	defer cancel()
+
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(n.conf.Host, n.conf.Port))
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, n.conf.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: n.conf.Host, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	}
+	if n.conf.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.conf.Username, n.conf.Password, n.conf.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(n.conf.From); err != nil {
+		return err
+	}
+	if err := c.Rcpt(address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(n.message(address, msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (n *SMTP) message(address string, msg Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", n.conf.From)
+	fmt.Fprintf(&b, "To: %s\r\n", address)
+	subject := msg.Subject
+	if subject == "" {
+		subject = "Reminder: " + msg.Notification.Title
+	}
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
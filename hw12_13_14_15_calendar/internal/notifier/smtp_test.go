@@ -0,0 +1,114 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMail struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTP accepts a single session and sends the received mail to mails.
+func fakeSMTP(t *testing.T, mails chan<- fakeMail) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var mail fakeMail
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 fake")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				mail.from = strings.Trim(strings.TrimSpace(line)[len("MAIL FROM:"):], "<>")
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				mail.to = append(mail.to, strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>"))
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				mail.data = data.String()
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 bye")
+				mails <- mail
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func TestSMTP(t *testing.T) {
+	mails := make(chan fakeMail, 1)
+	host, port, err := net.SplitHostPort(fakeSMTP(t, mails))
+	require.NoError(t, err)
+
+	n := NewSMTP(SMTPConf{Host: host, Port: port, From: "calendar@example.com", Timeout: time.Second})
+	msg := Message{
+		Notification: model.NotificationMsg{ID: 1, UserID: 2, Title: "Standup", Date: time.Now()},
+		Body:         "Your meeting 'Standup' starts in 15 minutes\nat 10:00",
+	}
+
+	require.ErrorIs(t, n.Notify(context.Background(), "", msg), ErrNoAddress)
+	require.NoError(t, n.Notify(context.Background(), "user@example.com", msg))
+
+	mail := <-mails
+	require.Equal(t, "calendar@example.com", mail.from)
+	require.Equal(t, []string{"user@example.com"}, mail.to)
+	require.Contains(t, mail.data, "Subject: Reminder: Standup")
+	require.Contains(t, mail.data, "Your meeting 'Standup' starts in 15 minutes\r\nat 10:00")
+}
+
+func TestConfRedacted(t *testing.T) {
+	conf := struct {
+		SMTP    SMTPConf
+		Webhook WebhookConf
+	}{
+		SMTP:    SMTPConf{Host: "smtp.example.com", Username: "calendar", Password: "hunter2"},
+		Webhook: WebhookConf{Secret: "whsec"},
+	}
+	dump := fmt.Sprint(conf)
+	require.Contains(t, dump, "{smtp.example.com  calendar ***  0s}")
+	require.NotContains(t, dump, "hunter2")
+	require.NotContains(t, dump, "whsec")
+	require.NotContains(t, SMTPConf{}.String(), "***", "unset password stays empty")
+}
@@ -0,0 +1,250 @@
+package notifier
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+)
+
+const (
+	defaultLocale   = "en"
+	defaultTemplate = "default"
+	subjectTemplate = "subject"
+	templateExt     = ".tmpl"
+)
+
+var ErrNoTemplate = errors.New("no template")
+
+//go:embed templates
+var builtinTemplates embed.FS
+
+// TemplatesConf points to a directory of <locale>/<channel>.tmpl files.
+// They override the built-in templates, <locale>/default.tmpl is used for
+// channels without own template.
+type TemplatesConf struct {
+	Dir      string `toml:"dir"`
+	Locale   string `toml:"locale"`
+	TimeZone string `toml:"timezone"`
+}
+
+func (c TemplatesConf) Check(v *config.Validator) {
+	v.File("dir", c.Dir)
+	if _, err := time.LoadLocation(c.TimeZone); err != nil {
+		v.AddKeyf("timezone", "%v", err)
+	}
+}
+
+func (c TemplatesConf) locale() string {
+	if c.Locale == "" {
+		return defaultLocale
+	}
+	return c.Locale
+}
+
+// Message is a notification rendered for one channel.
+type Message struct {
+	Notification model.NotificationMsg
+	Subject      string
+	Body         string
+}
+
+type Templates struct {
+	conf      TemplatesConf
+	templates map[string]*template.Template
+	now       func() time.Time
+}
+
+// LoadTemplates parses the built-in templates and the ones of conf.Dir.
+func LoadTemplates(conf TemplatesConf) (*Templates, error) {
+	t := &Templates{conf: conf, templates: map[string]*template.Template{}, now: time.Now}
+
+	builtin, err := fs.Sub(builtinTemplates, "templates")
+	if err != nil {
+		return nil, err
+	}
+	if err := t.load(builtin); err != nil {
+		return nil, err
+	}
+	if conf.Dir != "" {
+		if err := t.load(os.DirFS(conf.Dir)); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (t *Templates) load(fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		locale, file := path.Split(name)
+		if d.IsDir() || path.Ext(file) != templateExt || strings.Count(name, "/") != 1 {
+			return nil
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(string(data))
+		if err != nil {
+			return fmt.Errorf("can't parse template %v: %w", name, err)
+		}
+		t.templates[path.Join(locale, strings.TrimSuffix(file, templateExt))] = tmpl
+		return nil
+	})
+}
+
+// lookup finds the template of the channel for the locale, falling back to
+// the language of the locale ("ru-RU" -> "ru") and then to the default locale.
+func (t *Templates) lookup(channel, locale string) (*template.Template, string, error) {
+	locales := []string{locale, language(locale), t.conf.locale(), defaultLocale}
+	for _, l := range locales {
+		for _, name := range []string{channel, defaultTemplate} {
+			if tmpl, ok := t.templates[path.Join(l, name)]; ok {
+				return tmpl, l, nil
+			}
+		}
+	}
+	return nil, "", fmt.Errorf("%w for %v/%v", ErrNoTemplate, locale, channel)
+}
+
+// Render renders the event for the channel in the user's locale and time
+// zone, empty ones mean the configured defaults.
+func (t *Templates) Render(channel, locale, timezone string, event model.Event) (Message, error) {
+	if timezone == "" {
+		timezone = t.conf.TimeZone
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return Message{}, err
+	}
+
+	tmpl, locale, err := t.lookup(channel, locale)
+	if err != nil {
+		return Message{}, err
+	}
+
+	// all-day events start at the midnight of the recipient
+	event.OnTime, event.OffTime = event.Span(loc)
+	data := TemplateData{Event: event, Channel: channel, Locale: locale, Now: t.now(), Location: loc}
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, data); err != nil {
+		return Message{}, err
+	}
+	msg := Message{Body: strings.TrimSpace(body.String())}
+
+	if tmpl.Lookup(subjectTemplate) != nil {
+		var subject bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&subject, subjectTemplate, data); err != nil {
+			return Message{}, err
+		}
+		msg.Subject = strings.TrimSpace(subject.String())
+	}
+	return msg, nil
+}
+
+// TemplateData is the value templates are executed with. Times of the event
+// are shown in Location by the helper methods.
+type TemplateData struct {
+	Event    model.Event
+	Channel  string
+	Locale   string
+	Now      time.Time
+	Location *time.Location
+}
+
+func (d TemplateData) In(t time.Time) time.Time {
+	return t.In(d.Location)
+}
+
+func (d TemplateData) Format(t time.Time, layout string) string {
+	return d.In(t).Format(layout)
+}
+
+func (d TemplateData) Zone() string {
+	return d.Location.String()
+}
+
+// Relative tells the distance from now to t in words, e.g. "in 15 minutes".
+func (d TemplateData) Relative(t time.Time) string {
+	words, ok := relativeWords[language(d.Locale)]
+	if !ok {
+		words = relativeWords[defaultLocale]
+	}
+
+	diff := t.Sub(d.Now).Round(time.Minute)
+	format := words.in
+	if diff < 0 {
+		diff = -diff
+		format = words.ago
+	}
+
+	var n int64
+	var forms [3]string
+	switch {
+	case diff < time.Minute:
+		return words.now
+	case diff < time.Hour:
+		n, forms = int64(diff/time.Minute), words.minute
+	case diff < 24*time.Hour:
+		n, forms = int64(diff/time.Hour), words.hour
+	default:
+		n, forms = int64(diff/(24*time.Hour)), words.day
+	}
+	return fmt.Sprintf(format, fmt.Sprintf("%d %s", n, forms[words.plural(n)]))
+}
+
+type relative struct {
+	now, in, ago      string
+	minute, hour, day [3]string
+	plural            func(n int64) int
+}
+
+var relativeWords = map[string]relative{
+	"en": {
+		now: "now", in: "in %s", ago: "%s ago",
+		minute: [3]string{"minute", "minutes"},
+		hour:   [3]string{"hour", "hours"},
+		day:    [3]string{"day", "days"},
+		plural: func(n int64) int {
+			if n == 1 {
+				return 0
+			}
+			return 1
+		},
+	},
+	"ru": {
+		now: "сейчас", in: "через %s", ago: "%s назад",
+		minute: [3]string{"минуту", "минуты", "минут"},
+		hour:   [3]string{"час", "часа", "часов"},
+		day:    [3]string{"день", "дня", "дней"},
+		plural: func(n int64) int {
+			switch {
+			case n%10 == 1 && n%100 != 11:
+				return 0
+			case n%10 >= 2 && n%10 <= 4 && (n%100 < 10 || n%100 >= 20):
+				return 1
+			}
+			return 2
+		},
+	},
+}
+
+func language(locale string) string {
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+	return strings.ToLower(locale)
+}
@@ -0,0 +1,105 @@
+package notifier
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplates(t *testing.T) {
+	now := time.Date(2023, 1, 10, 6, 45, 0, 0, time.UTC)
+	event := model.Event{ID: 1, UserID: 2, Title: "Standup", OnTime: now.Add(15 * time.Minute)}
+
+	templates, err := LoadTemplates(TemplatesConf{})
+	require.NoError(t, err)
+	templates.now = func() time.Time { return now }
+
+	t.Run("default", func(t *testing.T) {
+		msg, err := templates.Render(ChannelLog, "", "Europe/Moscow", event)
+		require.NoError(t, err)
+		require.Equal(t, "Your meeting 'Standup' starts in 15 minutes at 10:00 (Europe/Moscow)", msg.Body)
+		require.Equal(t, "Reminder: Standup", msg.Subject)
+	})
+
+	t.Run("locale", func(t *testing.T) {
+		msg, err := templates.Render(ChannelWebhook, "ru-RU", "Europe/Moscow", event)
+		require.NoError(t, err)
+		require.Equal(t, "Ваша встреча 'Standup' начнётся через 15 минут в 10:00 (Europe/Moscow)", msg.Body)
+
+		msg, err = templates.Render(ChannelLog, "de", "", event)
+		require.NoError(t, err, "unknown locale falls back to the default one")
+		require.Equal(t, "Your meeting 'Standup' starts in 15 minutes at 07:00 (UTC)", msg.Body)
+	})
+
+	t.Run("channel", func(t *testing.T) {
+		msg, err := templates.Render(ChannelEmail, "en", "Europe/Moscow", event)
+		require.NoError(t, err)
+		require.Equal(t, "Reminder: Standup at 10:00", msg.Subject)
+		require.Contains(t, msg.Body, "starts in 15 minutes at Tue, 10 Jan 2023 10:00 (Europe/Moscow)")
+	})
+
+	t.Run("all_day", func(t *testing.T) {
+		event := model.Event{Title: "Vacation", OnTime: model.Date(now).AddDate(0, 0, 1), AllDay: true}
+		event.NormalizeDates()
+
+		msg, err := templates.Render(ChannelLog, "en", "Asia/Tokyo", event)
+		require.NoError(t, err)
+		require.Equal(t, "Your meeting 'Vacation' starts in 8 hours on Wed, 11 Jan", msg.Body)
+		msg, err = templates.Render(ChannelEmail, "en", "America/New_York", event)
+		require.NoError(t, err)
+		require.Equal(t, "Reminder: Vacation", msg.Subject)
+		require.Contains(t, msg.Body, "starts in 22 hours on Wed, 11 Jan 2023.")
+		msg, err = templates.Render(ChannelEmail, "ru", "Europe/Moscow", event)
+		require.NoError(t, err)
+		require.Contains(t, msg.Body, "начнётся через 14 часов, 11.01.2023.")
+	})
+
+	t.Run("dir", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "en"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "en", "log.tmpl"),
+			[]byte(`{{.Event.Title}} {{.Relative .Event.OnTime}}`), 0o600))
+
+		templates, err := LoadTemplates(TemplatesConf{Dir: dir})
+		require.NoError(t, err)
+		templates.now = func() time.Time { return now }
+
+		msg, err := templates.Render(ChannelLog, "en", "", event)
+		require.NoError(t, err)
+		require.Equal(t, "Standup in 15 minutes", msg.Body)
+
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "en", "log.tmpl"), []byte(`{{.Event.Title`), 0o600))
+		_, err = LoadTemplates(TemplatesConf{Dir: dir})
+		require.Error(t, err)
+	})
+
+	t.Run("bad_timezone", func(t *testing.T) {
+		_, err := templates.Render(ChannelLog, "en", "Mars/Olympus", event)
+		require.Error(t, err)
+	})
+}
+
+func TestRelative(t *testing.T) {
+	now := time.Date(2023, 1, 10, 6, 45, 0, 0, time.UTC)
+	tests := []struct {
+		locale string
+		diff   time.Duration
+		want   string
+	}{
+		{locale: "en", diff: 20 * time.Second, want: "now"},
+		{locale: "en", diff: time.Minute, want: "in 1 minute"},
+		{locale: "en", diff: -2 * time.Hour, want: "2 hours ago"},
+		{locale: "en", diff: 49 * time.Hour, want: "in 2 days"},
+		{locale: "ru", diff: 21 * time.Minute, want: "через 21 минуту"},
+		{locale: "ru", diff: 3 * time.Hour, want: "через 3 часа"},
+		{locale: "ru", diff: -11 * 24 * time.Hour, want: "11 дней назад"},
+	}
+	for _, tc := range tests {
+		d := TemplateData{Locale: tc.locale, Now: now, Location: time.UTC}
+		require.Equal(t, tc.want, d.Relative(now.Add(tc.diff)), "%v %v", tc.locale, tc.diff)
+	}
+}
@@ -0,0 +1,208 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+)
+
+const (
+	defaultWebhookTimeout = 10 * time.Second
+
+	HeaderSignature = "X-Calendar-Signature"
+	HeaderTimestamp = "X-Calendar-Timestamp"
+)
+
+// ErrForbiddenAddress is returned for webhooks resolving to an address the
+// sender must not call.
+var ErrForbiddenAddress = errors.New("webhook address is forbidden")
+
+// WebhookConf configures webhooks. Allow and Deny are CIDR networks checked
+// against the address the webhook host resolves to: denied networks are
+// never called, allowed ones are called even when they are loopback, private
+// or link-local, which are denied otherwise.
+type WebhookConf struct {
+	Secret  string        `toml:"secret"`
+	Timeout time.Duration `toml:"timeout"`
+	Allow   []string      `toml:"allow"`
+	Deny    []string      `toml:"deny"`
+}
+
+func (c WebhookConf) Enabled() bool {
+	return c.Secret != ""
+}
+
+// String hides the secret when the config is printed.
+func (c WebhookConf) String() string {
+	type conf WebhookConf
+	c.Secret = redacted(c.Secret)
+	return fmt.Sprint(conf(c))
+}
+
+func (c WebhookConf) Check(v *config.Validator) {
+	v.NotNegative("timeout", c.Timeout)
+	for _, cidr := range c.Allow {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			v.AddKeyf("allow", "%v", err)
+		}
+	}
+	for _, cidr := range c.Deny {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			v.AddKeyf("deny", "%v", err)
+		}
+	}
+}
+
+func (c WebhookConf) timeout() time.Duration {
+	if c.Timeout == 0 {
+		return defaultWebhookTimeout
+	}
+	return c.Timeout
+}
+
+func parseNets(cidrs []string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if _, n, err := net.ParseCIDR(cidr); err == nil {
+			nets = append(nets, n)
+		}
+	}
+	return nets
+}
+
+func contains(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// addressGuard checks the addresses webhooks connect to. The check runs at
+// dial time, after the host is resolved, so a public name resolving to an
+// internal address is refused as well.
+type addressGuard struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+func (g addressGuard) check(ip net.IP) error {
+	switch {
+	case contains(g.deny, ip):
+	case contains(g.allow, ip):
+		return nil
+	case ip.IsLoopback(), ip.IsPrivate(), ip.IsUnspecified(), ip.IsMulticast(),
+		ip.IsLinkLocalUnicast(), ip.IsLinkLocalMulticast(), ip.IsInterfaceLocalMulticast():
+	default:
+		return nil
+	}
+	return fmt.Errorf("%w: %v", ErrForbiddenAddress, ip)
+}
+
+func (g addressGuard) control(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%w: %q", ErrForbiddenAddress, host)
+	}
+	return g.check(ip)
+}
+
+type Webhook struct {
+	conf   WebhookConf
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewWebhook returns the webhook notifier. Its client skips proxies, so the
+// address guard sees the webhook addresses, and does not follow redirects,
+// which are answered as failed deliveries.
+func NewWebhook(conf WebhookConf) *Webhook {
+	guard := addressGuard{allow: parseNets(conf.Allow), deny: parseNets(conf.Deny)}
+	dialer := &net.Dialer{Timeout: conf.timeout(), Control: guard.control}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   conf.timeout(),
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return &Webhook{conf: conf, client: client, now: time.Now}
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>". Receivers check
+// it with the shared secret and reject old timestamps to prevent replays.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a webhook request body.
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// WebhookPayload is the JSON body of webhook requests: the notification
+// fields and its rendered text.
+type WebhookPayload struct {
+	model.NotificationMsg
+	Subject string `json:"subject,omitempty"`
+	Text    string `json:"text"`
+}
+
+// Notify posts the notification as JSON to the address URL.
+func (n *Webhook) Notify(ctx context.Context, address string, msg Message) error {
+	if address == "" {
+		return ErrNoAddress
+	}
+
+	body, err := json.Marshal(WebhookPayload{NotificationMsg: msg.Notification, Subject: msg.Subject, Text: msg.Body})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, address, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(n.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(n.conf.Secret, timestamp, body))
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %v", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhook(t *testing.T) {
+	const secret = "secret"
+	received := make(chan WebhookPayload, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !Verify(secret, r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var payload WebhookPayload
+		json.Unmarshal(body, &payload)
+		received <- payload
+	}))
+	defer ts.Close()
+
+	msg := Message{
+		Notification: model.NotificationMsg{ID: 1, NotificationID: 3, UserID: 2, Title: "Standup", Date: time.Now()},
+		Body:         "Your meeting 'Standup' starts in 15 minutes",
+	}
+
+	local := []string{"127.0.0.0/8", "::1/128"}
+
+	t.Run("signed", func(t *testing.T) {
+		n := NewWebhook(WebhookConf{Secret: secret, Allow: local})
+		require.NoError(t, n.Notify(context.Background(), ts.URL, msg))
+		payload := <-received
+		require.Equal(t, msg.Notification.NotificationID, payload.NotificationID)
+		require.Equal(t, msg.Body, payload.Text)
+	})
+
+	t.Run("wrong_secret", func(t *testing.T) {
+		n := NewWebhook(WebhookConf{Secret: "other", Allow: local})
+		require.Error(t, n.Notify(context.Background(), ts.URL, msg))
+	})
+
+	t.Run("forbidden_address", func(t *testing.T) {
+		n := NewWebhook(WebhookConf{Secret: secret})
+		require.ErrorIs(t, n.Notify(context.Background(), ts.URL, msg), ErrForbiddenAddress, "loopback")
+
+		n = NewWebhook(WebhookConf{Secret: secret, Allow: local, Deny: []string{"127.0.0.1/32"}})
+		require.ErrorIs(t, n.Notify(context.Background(), ts.URL, msg), ErrForbiddenAddress, "deny wins")
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		redirect := httptest.NewServer(http.RedirectHandler(ts.URL, http.StatusTemporaryRedirect))
+		defer redirect.Close()
+		n := NewWebhook(WebhookConf{Secret: secret, Allow: local})
+		require.Error(t, n.Notify(context.Background(), redirect.URL, msg))
+		require.Empty(t, received, "redirect is not followed")
+	})
+
+	t.Run("no_address", func(t *testing.T) {
+		n := NewWebhook(WebhookConf{Secret: secret})
+		require.ErrorIs(t, n.Notify(context.Background(), "", msg), ErrNoAddress)
+	})
+}
+
+func TestAddressGuard(t *testing.T) {
+	guard := addressGuard{allow: parseNets([]string{"10.1.0.0/16"}), deny: parseNets([]string{"203.0.113.0/24"})}
+	for _, ip := range []string{"93.184.216.34", "2606:2800:220:1::1", "10.1.2.3"} {
+		require.NoError(t, guard.check(net.ParseIP(ip)), ip)
+	}
+	for _, ip := range []string{
+		"127.0.0.1", "::1", "10.2.0.1", "172.16.0.1", "192.168.1.1", "169.254.169.254", "fe80::1", "fd00::1",
+		"0.0.0.0", "::", "::ffff:127.0.0.1", "203.0.113.1",
+	} {
+		require.ErrorIs(t, guard.check(net.ParseIP(ip)), ErrForbiddenAddress, ip)
+	}
+}
+
+func TestWebhookConfCheck(t *testing.T) {
+	v := &config.Validator{}
+	WebhookConf{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.0.0.1"}}.Check(v)
+	require.ErrorContains(t, v.Err(), "deny")
+}
@@ -2,15 +2,25 @@ package internalgrpc
 
 import (
 	context "context"
+	"errors"
+	"io"
+	"math"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 
 	api "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/api/stub"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/server/ratelimit"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/server/tlsconfig"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
@@ -21,6 +31,8 @@ const (
 	KeyMethodID ctxKeyID = iota
 )
 
+const downloadChunkSize = 64 << 10
+
 type Logger interface {
 	Fatalf(format string, a ...interface{})
 	Errorf(format string, a ...interface{})
@@ -34,18 +46,31 @@ type Application interface {
 	UpdateEvent(context.Context, *model.Event) error
 	DeleteEvent(context.Context, int64) error
 	LookupEvent(context.Context, int64) (model.Event, error)
-	ListEvents(context.Context, int64) ([]model.Event, error)
-	ListEventsDay(context.Context, int64, time.Time) ([]model.Event, error)
-	ListEventsWeek(context.Context, int64, time.Time) ([]model.Event, error)
-	ListEventsMonth(context.Context, int64, time.Time) ([]model.Event, error)
+	ListEvents(context.Context, int64, model.TagFilter) ([]model.Event, error)
+	ListEventsDay(context.Context, int64, time.Time, model.TagFilter) ([]model.Event, error)
+	ListEventsWeek(context.Context, int64, time.Time, model.TagFilter) ([]model.Event, error)
+	ListEventsMonth(context.Context, int64, time.Time, model.TagFilter) ([]model.Event, error)
+	SearchEvents(context.Context, int64, string, time.Time, time.Time, model.TagFilter) ([]model.SearchResult, error)
+	ListUserTags(context.Context, int64) ([]model.TagCount, error)
+	UploadAttachment(context.Context, int64, string, string, io.Reader) (model.Attachment, error)
+	OpenAttachment(context.Context, int64) (model.Attachment, io.ReadCloser, error)
+	DeleteAttachment(context.Context, int64) error
+	ListAttachments(context.Context, int64) ([]model.Attachment, error)
+}
+
+type Conf struct {
+	Host string `toml:"host"`
+	Port string `toml:"port"`
+	tlsconfig.Conf
 }
 
 type Service struct {
 	log     Logger
 	app     Application
 	basesrv *grpc.Server
-	host    string
-	port    string
+	conf    Conf
+	tls     *tlsconfig.Reloader
+	limiter *ratelimit.Limiter
 	api.UnimplementedCalendarServer
 }
 
@@ -57,8 +82,40 @@ func (Service) APIEventFromEvent(event *model.Event) *api.Event {
 		Description: &event.Description,
 		OnTime:      timestamppb.New(event.OnTime),
 		OffTime:     timestamppb.New(event.OffTime),
-		NotifyTime:  timestamppb.New(event.NotifyTime),
+		Reminders:   apiReminders(event.Reminders),
+		Tags:        event.Tags,
+		AllDay:      &event.AllDay,
+	}
+}
+
+func apiReminders(reminders []model.Reminder) []*api.Reminder {
+	apiReminders := make([]*api.Reminder, len(reminders))
+	for i := range reminders {
+		r := &reminders[i]
+		apiReminders[i] = &api.Reminder{
+			ID:         &r.ID,
+			Offset:     durationpb.New(r.Offset),
+			Channel:    &r.Channel,
+			NotifyTime: timestamppb.New(r.NotifyTime),
+			Notified:   &r.Notified,
+		}
+	}
+	return apiReminders
+}
+
+func remindersFromAPI(apiReminders []*api.Reminder) []model.Reminder {
+	reminders := make([]model.Reminder, 0, len(apiReminders))
+	for _, apiReminder := range apiReminders {
+		reminder := model.Reminder{
+			ID:      apiReminder.GetID(),
+			Channel: apiReminder.GetChannel(),
+		}
+		if err := apiReminder.Offset.CheckValid(); err == nil {
+			reminder.Offset = apiReminder.Offset.AsDuration()
+		}
+		reminders = append(reminders, reminder)
 	}
+	return reminders
 }
 
 func (Service) EventFromAPIEvent(apiEvent *api.Event) *model.Event {
@@ -68,19 +125,40 @@ func (Service) EventFromAPIEvent(apiEvent *api.Event) *model.Event {
 	event.UserID = *apiEvent.UserID
 	event.Title = *apiEvent.Title
 	event.Description = *apiEvent.Description
+	event.AllDay = apiEvent.GetAllDay()
+	// the dates of all-day events are UTC ones
 	if err := apiEvent.OnTime.CheckValid(); err == nil {
-		event.OnTime = apiEvent.OnTime.AsTime().Local()
+		event.OnTime = apiEvent.OnTime.AsTime()
 	}
 	if err := apiEvent.OffTime.CheckValid(); err == nil {
-		event.OffTime = apiEvent.OffTime.AsTime().Local()
+		event.OffTime = apiEvent.OffTime.AsTime()
 	}
-	if err := apiEvent.NotifyTime.CheckValid(); err == nil {
-		event.NotifyTime = apiEvent.NotifyTime.AsTime().Local()
+	if !event.AllDay {
+		event.OnTime, event.OffTime = event.OnTime.Local(), event.OffTime.Local()
 	}
+	event.Reminders = remindersFromAPI(apiEvent.Reminders)
+	event.Tags = apiEvent.GetTags()
 
 	return &event
 }
 
+// viewerDate returns Date of the request in its time zone, the days of
+// all-day events are the ones of the viewer.
+func viewerDate(req *api.ReqByUserByDate) (time.Time, error) {
+	loc := time.Local
+	if tz := req.GetTimeZone(); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return time.Time{}, status.Errorf(codes.InvalidArgument, "wrong TimeZone %q", tz)
+		}
+	}
+	return req.Date.AsTime().In(loc), nil
+}
+
+func tagFilterFromAPI(filter *api.TagFilter) model.TagFilter {
+	return model.TagFilter{Any: filter.GetAny(), All: filter.GetAll()}
+}
+
 func (s Service) InsertEvent(ctx context.Context, req *api.ReqByEvent) (*api.RepID, error) {
 	event := s.EventFromAPIEvent(req.Event)
 	if err := s.app.InsertEvent(ctx, event); err != nil {
@@ -118,7 +196,7 @@ func (s Service) LookupEvent(ctx context.Context, req *api.ReqByID) (*api.RepEve
 }
 
 func (s Service) ListEvents(ctx context.Context, req *api.ReqByUser) (*api.RepEvents, error) {
-	events, err := s.app.ListEvents(ctx, *req.UserID)
+	events, err := s.app.ListEvents(ctx, *req.UserID, tagFilterFromAPI(req.Tags))
 	if err != nil {
 		return nil, err
 	}
@@ -133,7 +211,11 @@ func (s Service) ListEvents(ctx context.Context, req *api.ReqByUser) (*api.RepEv
 }
 
 func (s Service) ListEventsDay(ctx context.Context, req *api.ReqByUserByDate) (*api.RepEvents, error) {
-	events, err := s.app.ListEventsDay(ctx, *req.UserID, req.Date.AsTime().Local())
+	date, err := viewerDate(req)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.app.ListEventsDay(ctx, *req.UserID, date, tagFilterFromAPI(req.Tags))
 	if err != nil {
 		return nil, err
 	}
@@ -148,7 +230,11 @@ func (s Service) ListEventsDay(ctx context.Context, req *api.ReqByUserByDate) (*
 }
 
 func (s Service) ListEventsWeek(ctx context.Context, req *api.ReqByUserByDate) (*api.RepEvents, error) {
-	events, err := s.app.ListEventsWeek(ctx, *req.UserID, req.Date.AsTime().Local())
+	date, err := viewerDate(req)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.app.ListEventsWeek(ctx, *req.UserID, date, tagFilterFromAPI(req.Tags))
 	if err != nil {
 		return nil, err
 	}
@@ -163,7 +249,11 @@ func (s Service) ListEventsWeek(ctx context.Context, req *api.ReqByUserByDate) (
 }
 
 func (s Service) ListEventsMonth(ctx context.Context, req *api.ReqByUserByDate) (*api.RepEvents, error) {
-	events, err := s.app.ListEventsMonth(ctx, *req.UserID, req.Date.AsTime().Local())
+	date, err := viewerDate(req)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.app.ListEventsMonth(ctx, *req.UserID, date, tagFilterFromAPI(req.Tags))
 	if err != nil {
 		return nil, err
 	}
@@ -177,7 +267,225 @@ func (s Service) ListEventsMonth(ctx context.Context, req *api.ReqByUserByDate)
 	return &rep, nil
 }
 
-func NewServer(log Logger, app Application, host, port string) (*Service, *grpc.Server) {
+func (s Service) SearchEvents(ctx context.Context, req *api.ReqSearch) (*api.RepSearch, error) {
+	var from, to time.Time
+	if req.From.CheckValid() == nil {
+		from = req.From.AsTime().Local()
+	}
+	if req.To.CheckValid() == nil {
+		to = req.To.AsTime().Local()
+	}
+	results, err := s.app.SearchEvents(ctx, req.GetUserID(), req.GetQuery(), from, to, tagFilterFromAPI(req.GetTags()))
+	if err != nil {
+		return nil, err
+	}
+
+	rep := api.RepSearch{}
+	rep.Result = make([]*api.SearchResult, len(results))
+	for i := range results {
+		r := &results[i]
+		rep.Result[i] = &api.SearchResult{
+			Event:   s.APIEventFromEvent(&r.Event),
+			Rank:    &r.Rank,
+			Title:   &r.Title,
+			Snippet: &r.Snippet,
+		}
+	}
+	return &rep, nil
+}
+
+func (s Service) ListUserTags(ctx context.Context, req *api.ReqByUser) (*api.RepTags, error) {
+	tags, err := s.app.ListUserTags(ctx, req.GetUserID())
+	if err != nil {
+		return nil, err
+	}
+
+	rep := api.RepTags{}
+	rep.Tag = make([]*api.TagCount, len(tags))
+	for i := range tags {
+		rep.Tag[i] = &api.TagCount{Tag: &tags[i].Tag, Count: &tags[i].Count}
+	}
+	return &rep, nil
+}
+
+func apiAttachment(a *model.Attachment) *api.Attachment {
+	return &api.Attachment{
+		ID:          &a.ID,
+		EventID:     &a.EventID,
+		UserID:      &a.UserID,
+		Name:        &a.Name,
+		Size:        &a.Size,
+		ContentType: &a.ContentType,
+		Checksum:    &a.Checksum,
+		CreatedAt:   timestamppb.New(a.CreatedAt),
+	}
+}
+
+// uploadReader reads the chunks of the upload stream following the info.
+type uploadReader struct {
+	stream api.Calendar_UploadAttachmentServer
+	chunk  []byte
+}
+
+func (r *uploadReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		if req.GetInfo() != nil {
+			return 0, status.Error(codes.InvalidArgument, "info after content")
+		}
+		r.chunk = req.GetChunk()
+	}
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+func (s Service) UploadAttachment(stream api.Calendar_UploadAttachmentServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	info := req.GetInfo()
+	if info == nil {
+		return status.Error(codes.InvalidArgument, "info must come first")
+	}
+
+	attachment, err := s.app.UploadAttachment(stream.Context(), info.GetEventID(), info.GetName(),
+		info.GetContentType(), &uploadReader{stream: stream})
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(apiAttachment(&attachment))
+}
+
+func (s Service) DownloadAttachment(req *api.ReqByID, stream api.Calendar_DownloadAttachmentServer) error {
+	attachment, content, err := s.app.OpenAttachment(stream.Context(), req.GetID())
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	info := &api.RepAttachmentChunk_Info{Info: apiAttachment(&attachment)}
+	if err := stream.Send(&api.RepAttachmentChunk{Data: info}); err != nil {
+		return err
+	}
+	buf := make([]byte, downloadChunkSize)
+	for {
+		n, err := content.Read(buf)
+		if n > 0 {
+			chunk := &api.RepAttachmentChunk_Chunk{Chunk: buf[:n]}
+			if err := stream.Send(&api.RepAttachmentChunk{Data: chunk}); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s Service) DeleteAttachment(ctx context.Context, req *api.ReqByID) (*emptypb.Empty, error) {
+	if err := s.app.DeleteAttachment(ctx, req.GetID()); err != nil {
+		return nil, err
+	}
+	return new(emptypb.Empty), nil
+}
+
+func (s Service) ListAttachments(ctx context.Context, req *api.ReqByID) (*api.RepAttachments, error) {
+	attachments, err := s.app.ListAttachments(ctx, req.GetID())
+	if err != nil {
+		return nil, err
+	}
+
+	rep := api.RepAttachments{}
+	rep.Attachment = make([]*api.Attachment, len(attachments))
+	for i := range attachments {
+		rep.Attachment[i] = apiAttachment(&attachments[i])
+	}
+	return &rep, nil
+}
+
+func clientIDFromPeer(ctx context.Context) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ctx
+	}
+	if id := tlsconfig.Identity(&tlsInfo.State); id != "" {
+		return tlsconfig.WithClientID(ctx, id)
+	}
+	return ctx
+}
+
+// rateLimitKey is the client certificate identity or the remote IP.
+func rateLimitKey(ctx context.Context) string {
+	if id := tlsconfig.ClientID(ctx); id != "" {
+		return "user:" + id
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if ip, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return "ip:" + ip
+		}
+		return "ip:" + p.Addr.String()
+	}
+	return "ip:unknown"
+}
+
+func (s *Service) SetRateLimiter(limiter *ratelimit.Limiter) {
+	s.limiter = limiter
+}
+
+func (s *Service) rateLimitInterceptor(ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) { //nolint:gofumpt
+	if s.limiter == nil {
+		return handler(ctx, req)
+	}
+	if ok, wait := s.limiter.Allow(rateLimitKey(ctx)); !ok {
+		retryAfter := strconv.Itoa(int(math.Ceil(wait.Seconds())))
+		grpc.SetHeader(ctx, metadata.Pairs("retry-after", retryAfter))
+		return nil, status.Errorf(codes.ResourceExhausted, "too many requests, retry after %vs", retryAfter)
+	}
+	return handler(ctx, req)
+}
+
+// streamContext replaces the context of a server stream.
+type streamContext struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s streamContext) Context() context.Context {
+	return s.ctx
+}
+
+// rateLimitStreamInterceptor counts a stream as one request.
+func (s *Service) rateLimitStreamInterceptor(srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error { //nolint:gofumpt
+	ctx := clientIDFromPeer(ss.Context())
+	if s.limiter != nil {
+		if ok, wait := s.limiter.Allow(rateLimitKey(ctx)); !ok {
+			retryAfter := strconv.Itoa(int(math.Ceil(wait.Seconds())))
+			ss.SetHeader(metadata.Pairs("retry-after", retryAfter))
+			return status.Errorf(codes.ResourceExhausted, "too many requests, retry after %vs", retryAfter)
+		}
+	}
+	return handler(srv, streamContext{ServerStream: ss, ctx: ctx})
+}
+
+func NewServer(log Logger, app Application, conf Conf) (*Service, *grpc.Server) {
 	unarayLoggerEnricherIntercepter := func(ctx context.Context,
 		req interface{},
 		info *grpc.UnaryServerInfo,
@@ -200,27 +508,38 @@ func NewServer(log Logger, app Application, host, port string) (*Service, *grpc.
 		b.WriteString(userAgent)
 		b.WriteString("\"\n")
 		log.Infof(b.String())
-		return handler(ctx, req)
+		return handler(clientIDFromPeer(ctx), req)
 	}
 
-	basesrv := grpc.NewServer(grpc.UnaryInterceptor(unarayLoggerEnricherIntercepter))
-
 	server := &Service{
 		log:                         log,
 		app:                         app,
-		basesrv:                     basesrv,
-		host:                        host,
-		port:                        port,
+		conf:                        conf,
 		UnimplementedCalendarServer: api.UnimplementedCalendarServer{},
 	}
 
+	opts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(unarayLoggerEnricherIntercepter,
+		server.rateLimitInterceptor), grpc.ChainStreamInterceptor(server.rateLimitStreamInterceptor)}
+	if conf.Enabled() {
+		server.tls = tlsconfig.NewReloader(log, conf.Conf)
+		opts = append(opts, grpc.Creds(credentials.NewTLS(server.tls.Config())))
+	}
+
+	server.basesrv = grpc.NewServer(opts...)
+
 	api.RegisterCalendarServer(server.basesrv, server)
 
-	return server, basesrv
+	return server, server.basesrv
 }
 
 func (s *Service) Start(context.Context) error {
-	addr := net.JoinHostPort(s.host, s.port)
+	if s.tls != nil {
+		if err := s.tls.Load(); err != nil {
+			return err
+		}
+	}
+
+	addr := net.JoinHostPort(s.conf.Host, s.conf.Port)
 	dial, err := net.Listen("tcp", addr)
 	if err != nil {
 		return err
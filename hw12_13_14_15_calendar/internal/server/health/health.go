@@ -0,0 +1,84 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http"
+	"sort"
+	"time"
+)
+
+type Logger interface {
+	Errorf(format string, a ...interface{})
+	Infof(format string, a ...interface{})
+}
+
+// Check returns nil while the component is healthy.
+type Check func() error
+
+type report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Handler serves /health, which answers 503 if any check fails, and /debug/vars.
+func Handler(checks map[string]Check) http.Handler {
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		rep := report{Status: "ok", Checks: map[string]string{}}
+		for _, name := range names {
+			if err := checks[name](); err != nil {
+				rep.Status = "fail"
+				rep.Checks[name] = err.Error()
+				continue
+			}
+			rep.Checks[name] = "ok"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if rep.Status != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(rep)
+	})
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+type Server struct {
+	log Logger
+	srv http.Server
+}
+
+func NewServer(log Logger, addr string, checks map[string]Check) *Server {
+	return &Server{
+		log: log,
+		srv: http.Server{
+			Addr:              addr,
+			Handler:           Handler(checks),
+			ReadHeaderTimeout: 2 * time.Second,
+		},
+	}
+}
+
+// Start serves health checks in the background.
+func (s *Server) Start() {
+	go func() {
+		s.log.Infof("Health server started on:%v\n", s.srv.Addr)
+		if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.Errorf("Health server:%v\n", err)
+		}
+	}()
+}
+
+func (s *Server) Stop(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
@@ -0,0 +1,38 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	var rabbitErr error
+	h := Handler(map[string]Check{
+		"storage":  func() error { return nil },
+		"rabbitmq": func() error { return rabbitErr },
+	})
+
+	get := func() (int, report) {
+		var rep report
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&rep))
+		return w.Code, rep
+	}
+
+	code, rep := get()
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "ok", rep.Status)
+
+	rabbitErr = errors.New("disconnected")
+	code, rep = get()
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, "fail", rep.Status)
+	require.Equal(t, "disconnected", rep.Checks["rabbitmq"])
+	require.Equal(t, "ok", rep.Checks["storage"])
+}
@@ -0,0 +1,137 @@
+package internalhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type reqByEvent struct {
+	EventID int64 `json:"eventid"`
+}
+
+// UploadAttachment takes the content as the raw body, the event and the
+// name of the attachment as the eventid and name query parameters.
+func (s *Server) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	eventID, err := strconv.ParseInt(query.Get("eventid"), 10, 64)
+	if err != nil {
+		s.log.Errorf("UploadAttachment:%v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't UploadAttachment:wrong eventid %q\"}\n", query.Get("eventid"))))
+		return
+	}
+
+	attachment, err := s.app.UploadAttachment(r.Context(), eventID, query.Get("name"),
+		r.Header.Get("Content-Type"), r.Body)
+	if err != nil {
+		s.log.Errorf("UploadAttachment:%v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't UploadAttachment:%v\"}\n", err)))
+		return
+	}
+
+	jattachment, err := json.Marshal(attachment)
+	if err != nil {
+		s.log.Errorf("UploadAttachment:%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't UploadAttachment:%v\"}\n", err)))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(jattachment)
+	w.Write([]byte("\n"))
+}
+
+// DownloadAttachment streams the content of the attachment of the id query
+// parameter, the checksum is its ETag.
+func (s *Server) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		s.log.Errorf("DownloadAttachment:%v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't DownloadAttachment:wrong id %q\"}\n", r.URL.Query().Get("id"))))
+		return
+	}
+
+	attachment, content, err := s.app.OpenAttachment(r.Context(), id)
+	if err != nil {
+		s.log.Errorf("DownloadAttachment:%v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't DownloadAttachment:%v\"}\n", err)))
+		return
+	}
+	defer content.Close()
+
+	etag := strconv.Quote(attachment.Checksum)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", attachment.ContentType)
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition",
+		mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, content); err != nil {
+		s.log.Errorf("DownloadAttachment:%v\n", err)
+	}
+}
+
+func (s *Server) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	var req reqByID
+	if err := s.helperDecode(r.Body, w, &req); err != nil {
+		return
+	}
+	err := s.app.DeleteAttachment(r.Context(), req.ID)
+	if err != nil {
+		s.log.Errorf("DeleteAttachment:%v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't DeleteAttachment:%v\"}\n", err)))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"msg\": \"Deleted\"}\n"))
+}
+
+func (s *Server) ListAttachments(w http.ResponseWriter, r *http.Request) {
+	var req reqByEvent
+	if err := s.helperDecode(r.Body, w, &req); err != nil {
+		return
+	}
+	attachments, err := s.app.ListAttachments(r.Context(), req.EventID)
+	if err != nil {
+		s.log.Errorf("ListAttachments:%v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't ListAttachments:%v\"}\n", err)))
+		return
+	}
+
+	jattachments, err := json.Marshal(attachments)
+	if err != nil {
+		s.log.Errorf("ListAttachments:%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't ListAttachments:%v\"}\n", err)))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(jattachments)
+	w.Write([]byte("\n"))
+}
+
+// etagMatch compares the If-None-Match header with etag weakly, as the tags
+// of compressed responses are weak.
+func etagMatch(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
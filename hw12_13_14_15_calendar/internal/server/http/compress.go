@@ -0,0 +1,155 @@
+package internalhttp
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// maxCompressLength is the largest declared Content-Length compressed,
+// larger bodies, like attachments, are sent as they are.
+const maxCompressLength = 1 << 20
+
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// compressWriter starts compressing with the first write, so nothing is sent
+// and no encoding header is set for handlers that fail before responding.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	w           compressor
+	wroteHeader bool
+}
+
+// compressible tells if a response with header is worth compressing: it is
+// not encoded yet, not too large and of a textual content type.
+func compressible(header http.Header) bool {
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	if length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil && length > maxCompressLength {
+		return false
+	}
+	contentType, _, _ := strings.Cut(header.Get("Content-Type"), ";")
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	switch {
+	case contentType == "", strings.HasPrefix(contentType, "text/"),
+		strings.HasSuffix(contentType, "+json"), strings.HasSuffix(contentType, "+xml"):
+		return true
+	}
+	switch contentType {
+	case "application/json", "application/javascript", "application/xml", "application/x-ndjson":
+		return true
+	}
+	return false
+}
+
+func (cw *compressWriter) WriteHeader(statusCode int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		switch {
+		case !compressible(cw.Header()):
+			cw.encoding = ""
+		case statusCode == http.StatusNoContent || statusCode == http.StatusNotModified:
+			// no body, but the tag must be the one of the compressed body
+			cw.encoding = ""
+			weakETag(cw.Header())
+		default:
+			cw.Header().Del("Content-Length")
+			cw.Header().Set("Content-Encoding", cw.encoding)
+			weakETag(cw.Header())
+		}
+	}
+	cw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// weakETag marks the entity tag weak: the compressed body differs byte by
+// byte from the one the tag was computed for.
+func weakETag(header http.Header) {
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+}
+
+func (cw *compressWriter) Write(buf []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.encoding == "" {
+		return cw.ResponseWriter.Write(buf)
+	}
+	if cw.w == nil {
+		if cw.encoding == "br" {
+			cw.w = brotli.NewWriter(cw.ResponseWriter)
+		} else {
+			cw.w = gzip.NewWriter(cw.ResponseWriter)
+		}
+	}
+	return cw.w.Write(buf)
+}
+
+// Flush sends the data compressed so far to the client.
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.w != nil {
+		cw.w.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) Close() error {
+	if cw.w == nil {
+		return nil
+	}
+	return cw.w.Close()
+}
+
+// acceptEncoding picks br or gzip from the Accept-Encoding header, br first.
+func acceptEncoding(header string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		params = strings.TrimSpace(params)
+		if strings.HasPrefix(params, "q=") {
+			if q, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64); err == nil && q == 0 {
+				continue
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+
+	switch {
+	case accepted["br"]:
+		return "br"
+	case accepted["gzip"]:
+		return "gzip"
+	}
+	return ""
+}
+
+func compressMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := acceptEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
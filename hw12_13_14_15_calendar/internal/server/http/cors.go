@@ -0,0 +1,83 @@
+package internalhttp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+)
+
+type CORSConf struct {
+	AllowedOrigins   []string      `toml:"allowed_origins"`
+	AllowedMethods   []string      `toml:"allowed_methods"`
+	AllowedHeaders   []string      `toml:"allowed_headers"`
+	AllowCredentials bool          `toml:"allow_credentials"`
+	MaxAge           time.Duration `toml:"max_age"`
+}
+
+func (c CORSConf) Check(v *config.Validator) {
+	for _, origin := range c.AllowedOrigins {
+		if origin != "*" {
+			v.URL("allowed_origins", origin, "http", "https")
+		}
+	}
+	if c.AllowCredentials && c.allowOrigin("*") {
+		v.AddKeyf("allow_credentials", "can't be used with the \"*\" origin")
+	}
+	v.NotNegative("max_age", c.MaxAge)
+}
+
+func (c CORSConf) allowOrigin(origin string) bool {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func orDefault(values []string, def ...string) string {
+	if len(values) == 0 {
+		values = def
+	}
+	return strings.Join(values, ", ")
+}
+
+// corsMiddleware answers preflight requests and marks responses for browsers
+// of the allowed origins. It does nothing unless allowed origins are configured.
+func corsMiddleware(conf CORSConf) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" || len(conf.AllowedOrigins) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			if !conf.allowOrigin(origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if conf.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Methods", orDefault(conf.AllowedMethods, http.MethodPost))
+			w.Header().Set("Access-Control-Allow-Headers", orDefault(conf.AllowedHeaders, "Content-Type"))
+			if conf.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(conf.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
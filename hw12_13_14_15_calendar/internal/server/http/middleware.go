@@ -1,15 +1,67 @@
 package internalhttp
 
 import (
+	"context"
+	"errors"
+	"math"
+	"net"
 	"net/http"
+	"runtime/debug"
+	"strconv"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/server/ratelimit"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/server/tlsconfig"
 )
 
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h so that a request passes the middlewares in the given order.
+func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// loggerMiddleware provides the logger to the other middlewares when
+// the handler is served outside of Start, e.g. by httptest.
+func loggerMiddleware(log Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Value(KeyLoggerID).(Logger); !ok {
+				r = r.WithContext(context.WithValue(r.Context(), KeyLoggerID, log))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 type MiddlewareLogger struct{}
 
 func NewMiddlewareLogger() *MiddlewareLogger {
 	return &MiddlewareLogger{}
 }
 
+func (m *MiddlewareLogger) recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if err, ok := rec.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(rec)
+			}
+			l := r.Context().Value(KeyLoggerID).(Logger)
+			l.Errorf("panic serving %v: %v\n%s", r.URL.Path, rec, debug.Stack())
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("{\"error\": \"Internal server error\"}\n"))
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (m *MiddlewareLogger) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		l := r.Context().Value(KeyLoggerID).(Logger)
@@ -25,3 +77,43 @@ func (m *MiddlewareLogger) setCommonHeadersMiddleware(next http.Handler) http.Ha
 		next.ServeHTTP(w, r)
 	})
 }
+
+func (m *MiddlewareLogger) clientIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := tlsconfig.Identity(r.TLS); id != "" {
+			r = r.WithContext(tlsconfig.WithClientID(r.Context(), id))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitKey is the client certificate identity or the remote IP.
+func rateLimitKey(r *http.Request) string {
+	if id := tlsconfig.ClientID(r.Context()); id != "" {
+		return "user:" + id
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return "ip:" + ip
+}
+
+func rateLimitMiddleware(limiter *ratelimit.Limiter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if limiter == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if ok, wait := limiter.Allow(rateLimitKey(r)); !ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte("{\"error\": \"Too many requests\"}\n"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -47,6 +47,12 @@ func (rwl *ResponseWriterCounter) Hijack() (net.Conn, *bufio.ReadWriter, error)
 	return rwl.ResponseWriter.(http.Hijacker).Hijack()
 }
 
+func (rwl *ResponseWriterCounter) Flush() {
+	if f, ok := rwl.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func (rwl *ResponseWriterCounter) Count() uint64 {
 	return atomic.LoadUint64(&rwl.count)
 }
@@ -3,13 +3,18 @@ package internalhttp
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"time"
 
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/notifier"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/server/ratelimit"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/server/tlsconfig"
 )
 
 type ctxKeyID int
@@ -18,12 +23,39 @@ const (
 	KeyLoggerID ctxKeyID = iota
 )
 
+const defaultMaxBodySize = 1 << 20
+
+type Conf struct {
+	Host string `toml:"host"`
+	Port string `toml:"port"`
+	tlsconfig.Conf
+	MaxBodySize int64    `toml:"max_body_size"`
+	Compress    bool     `toml:"compress"`
+	CORS        CORSConf `toml:"cors"`
+}
+
+func (c Conf) Check(v *config.Validator) {
+	c.Conf.Check(v)
+	if c.MaxBodySize < 0 {
+		v.AddKeyf("max_body_size", "must not be negative, got %v", c.MaxBodySize)
+	}
+	c.CORS.Check(v.Section("cors"))
+}
+
+func (c Conf) maxBodySize() int64 {
+	if c.MaxBodySize == 0 {
+		return defaultMaxBodySize
+	}
+	return c.MaxBodySize
+}
+
 type Server struct {
-	log  Logger
-	srv  http.Server
-	app  Application
-	host string
-	port string
+	log     Logger
+	srv     http.Server
+	app     Application
+	conf    Conf
+	tls     *tlsconfig.Reloader
+	limiter *ratelimit.Limiter
 }
 
 type Logger interface {
@@ -39,10 +71,21 @@ type Application interface {
 	UpdateEvent(context.Context, *model.Event) error
 	DeleteEvent(context.Context, int64) error
 	LookupEvent(context.Context, int64) (model.Event, error)
-	ListEvents(context.Context, int64) ([]model.Event, error)
-	ListEventsDay(context.Context, int64, time.Time) ([]model.Event, error)
-	ListEventsWeek(context.Context, int64, time.Time) ([]model.Event, error)
-	ListEventsMonth(context.Context, int64, time.Time) ([]model.Event, error)
+	ListEvents(context.Context, int64, model.TagFilter) ([]model.Event, error)
+	ListEventsDay(context.Context, int64, time.Time, model.TagFilter) ([]model.Event, error)
+	ListEventsWeek(context.Context, int64, time.Time, model.TagFilter) ([]model.Event, error)
+	ListEventsMonth(context.Context, int64, time.Time, model.TagFilter) ([]model.Event, error)
+	SearchEvents(context.Context, int64, string, time.Time, time.Time, model.TagFilter) ([]model.SearchResult, error)
+	SetUserChannels(context.Context, int64, []model.UserChannel) error
+	ListUserChannels(context.Context, int64) ([]model.UserChannel, error)
+	SetUserRetention(context.Context, int64, time.Duration) error
+	LookupUserRetention(context.Context, int64) (time.Duration, error)
+	ListUserTags(context.Context, int64) ([]model.TagCount, error)
+	PreviewNotification(context.Context, int64, model.UserChannel) (notifier.Message, error)
+	UploadAttachment(context.Context, int64, string, string, io.Reader) (model.Attachment, error)
+	OpenAttachment(context.Context, int64) (model.Attachment, io.ReadCloser, error)
+	DeleteAttachment(context.Context, int64) error
+	ListAttachments(context.Context, int64) ([]model.Attachment, error)
 }
 
 type reqByID struct {
@@ -50,16 +93,59 @@ type reqByID struct {
 }
 
 type reqByUser struct {
-	UserID int64 `json:"userid"`
+	UserID int64           `json:"userid"`
+	Tags   model.TagFilter `json:"tags"`
+}
+
+// reqSearch limits the events to the ones overlapping from and to and
+// matching the tags if they are set.
+type reqSearch struct {
+	UserID int64           `json:"userid"`
+	Query  string          `json:"query"`
+	From   time.Time       `json:"from"`
+	To     time.Time       `json:"to"`
+	Tags   model.TagFilter `json:"tags"`
+}
+
+type reqUserChannels struct {
+	UserID   int64               `json:"userid"`
+	Channels []model.UserChannel `json:"channels"`
+}
+
+// reqUserRetention carries the retention as a duration string, e.g. "720h".
+type reqUserRetention struct {
+	UserID    int64  `json:"userid"`
+	Retention string `json:"retention"`
+}
+
+type reqPreview struct {
+	ID       int64  `json:"id"`
+	Channel  string `json:"channel"`
+	Locale   string `json:"locale"`
+	TimeZone string `json:"timezone"`
+}
+
+type respPreview struct {
+	Subject string `json:"subject,omitempty"`
+	Body    string `json:"body"`
 }
 
 type reqByUserByDate struct {
-	UserID int64     `json:"userid"`
-	Date   time.Time `json:"date"`
+	UserID int64           `json:"userid"`
+	Date   time.Time       `json:"date"`
+	Tags   model.TagFilter `json:"tags"`
 }
 
-func NewServer(log Logger, app Application, host, port string) *Server {
-	return &Server{log: log, app: app, host: host, port: port}
+func NewServer(log Logger, app Application, conf Conf) *Server {
+	server := &Server{log: log, app: app, conf: conf}
+	if conf.Enabled() {
+		server.tls = tlsconfig.NewReloader(log, conf.Conf)
+	}
+	return server
+}
+
+func (s *Server) SetRateLimiter(limiter *ratelimit.Limiter) {
+	s.limiter = limiter
 }
 
 func (s *Server) doNothing(w http.ResponseWriter, r *http.Request) {
@@ -67,9 +153,15 @@ func (s *Server) doNothing(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) helperDecode(stream io.ReadCloser, w http.ResponseWriter, data interface{}) error {
-	decoder := json.NewDecoder(stream)
+	decoder := json.NewDecoder(http.MaxBytesReader(w, stream, s.conf.maxBodySize()))
 	if err := decoder.Decode(&data); err != nil {
+		var maxBytesErr *http.MaxBytesError
 		s.log.Errorf("Can't decode json:%v\n", err)
+		if errors.As(err, &maxBytesErr) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			w.Write([]byte(fmt.Sprintf("{\"error\": \"Request body is larger than %v bytes\"}\n", maxBytesErr.Limit)))
+			return err
+		}
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't decode json:%v\"}\n", err)))
 		return err
@@ -155,7 +247,7 @@ func (s *Server) ListEvents(w http.ResponseWriter, r *http.Request) { //nolint:d
 	if err := s.helperDecode(r.Body, w, &req); err != nil {
 		return
 	}
-	eventsFound, err := s.app.ListEvents(r.Context(), req.UserID)
+	eventsFound, err := s.app.ListEvents(r.Context(), req.UserID, req.Tags)
 	if err != nil {
 		s.log.Errorf("ListEvents:%v\n", err)
 		w.WriteHeader(http.StatusBadRequest)
@@ -181,7 +273,7 @@ func (s *Server) ListEventsDay(w http.ResponseWriter, r *http.Request) { //nolin
 		return
 	}
 
-	eventsFound, err := s.app.ListEventsDay(r.Context(), req.UserID, req.Date)
+	eventsFound, err := s.app.ListEventsDay(r.Context(), req.UserID, req.Date, req.Tags)
 	if err != nil {
 		s.log.Errorf("ListEventsDay:%v\n", err)
 		w.WriteHeader(http.StatusBadRequest)
@@ -206,7 +298,7 @@ func (s *Server) ListEventsWeek(w http.ResponseWriter, r *http.Request) { //noli
 	if err := s.helperDecode(r.Body, w, &req); err != nil {
 		return
 	}
-	eventsFound, err := s.app.ListEventsWeek(r.Context(), req.UserID, req.Date)
+	eventsFound, err := s.app.ListEventsWeek(r.Context(), req.UserID, req.Date, req.Tags)
 	if err != nil {
 		s.log.Errorf("ListEventsWeek:%v\n", err)
 		w.WriteHeader(http.StatusBadRequest)
@@ -231,7 +323,7 @@ func (s *Server) ListEventsMonth(w http.ResponseWriter, r *http.Request) { //nol
 	if err := s.helperDecode(r.Body, w, &req); err != nil {
 		return
 	}
-	eventsFound, err := s.app.ListEventsMonth(r.Context(), req.UserID, req.Date)
+	eventsFound, err := s.app.ListEventsMonth(r.Context(), req.UserID, req.Date, req.Tags)
 	if err != nil {
 		s.log.Errorf("ListEventsMonth:%v\n", err)
 		w.WriteHeader(http.StatusBadRequest)
@@ -251,34 +343,216 @@ func (s *Server) ListEventsMonth(w http.ResponseWriter, r *http.Request) { //nol
 	w.Write([]byte("\n"))
 }
 
-func (s *Server) Start(ctx context.Context) error {
-	addr := net.JoinHostPort(s.host, s.port)
+func (s *Server) SearchEvents(w http.ResponseWriter, r *http.Request) {
+	var req reqSearch
+	if err := s.helperDecode(r.Body, w, &req); err != nil {
+		return
+	}
+	results, err := s.app.SearchEvents(r.Context(), req.UserID, req.Query, req.From, req.To, req.Tags)
+	if err != nil {
+		s.log.Errorf("SearchEvents:%v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't SearchEvents:%v\"}\n", err)))
+		return
+	}
+
+	jresults, err := json.Marshal(results)
+	if err != nil {
+		s.log.Errorf("SearchEvents:%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't SearchEvents:%v\"}\n", err)))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(jresults)
+	w.Write([]byte("\n"))
+}
+
+func (s *Server) SetUserChannels(w http.ResponseWriter, r *http.Request) {
+	var req reqUserChannels
+	if err := s.helperDecode(r.Body, w, &req); err != nil {
+		return
+	}
+	err := s.app.SetUserChannels(r.Context(), req.UserID, req.Channels)
+	if err != nil {
+		s.log.Errorf("SetUserChannels:%v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't SetUserChannels:%v\"}\n", err)))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"msg\": \"Updated\"}\n"))
+}
+
+func (s *Server) ListUserChannels(w http.ResponseWriter, r *http.Request) { //nolint:dupl
+	var req reqByUser
+	if err := s.helperDecode(r.Body, w, &req); err != nil {
+		return
+	}
+	channels, err := s.app.ListUserChannels(r.Context(), req.UserID)
+	if err != nil {
+		s.log.Errorf("ListUserChannels:%v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't ListUserChannels:%v\"}\n", err)))
+		return
+	}
+
+	jchannels, err := json.Marshal(channels)
+	if err != nil {
+		s.log.Errorf("ListUserChannels:%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't ListUserChannels:%v\"}\n", err)))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(jchannels)
+	w.Write([]byte("\n"))
+}
+
+func (s *Server) SetUserRetention(w http.ResponseWriter, r *http.Request) {
+	var req reqUserRetention
+	if err := s.helperDecode(r.Body, w, &req); err != nil {
+		return
+	}
+	retention, err := time.ParseDuration(req.Retention)
+	if err == nil {
+		err = s.app.SetUserRetention(r.Context(), req.UserID, retention)
+	}
+	if err != nil {
+		s.log.Errorf("SetUserRetention:%v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't SetUserRetention:%v\"}\n", err)))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"msg\": \"Updated\"}\n"))
+}
+
+func (s *Server) LookupUserRetention(w http.ResponseWriter, r *http.Request) {
+	var req reqByUser
+	if err := s.helperDecode(r.Body, w, &req); err != nil {
+		return
+	}
+	retention, err := s.app.LookupUserRetention(r.Context(), req.UserID)
+	if err != nil {
+		s.log.Errorf("LookupUserRetention:%v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't LookupUserRetention:%v\"}\n", err)))
+		return
+	}
+
+	jretention, err := json.Marshal(reqUserRetention{UserID: req.UserID, Retention: retention.String()})
+	if err != nil {
+		s.log.Errorf("LookupUserRetention:%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't LookupUserRetention:%v\"}\n", err)))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(jretention)
+	w.Write([]byte("\n"))
+}
+
+func (s *Server) ListUserTags(w http.ResponseWriter, r *http.Request) {
+	var req reqByUser
+	if err := s.helperDecode(r.Body, w, &req); err != nil {
+		return
+	}
+	tags, err := s.app.ListUserTags(r.Context(), req.UserID)
+	if err != nil {
+		s.log.Errorf("ListUserTags:%v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't ListUserTags:%v\"}\n", err)))
+		return
+	}
+
+	jtags, err := json.Marshal(tags)
+	if err != nil {
+		s.log.Errorf("ListUserTags:%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't ListUserTags:%v\"}\n", err)))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(jtags)
+	w.Write([]byte("\n"))
+}
+
+func (s *Server) PreviewNotification(w http.ResponseWriter, r *http.Request) {
+	var req reqPreview
+	if err := s.helperDecode(r.Body, w, &req); err != nil {
+		return
+	}
+	channel := model.UserChannel{Channel: req.Channel, Locale: req.Locale, TimeZone: req.TimeZone}
+	msg, err := s.app.PreviewNotification(r.Context(), req.ID, channel)
+	if err != nil {
+		s.log.Errorf("PreviewNotification:%v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't PreviewNotification:%v\"}\n", err)))
+		return
+	}
+
+	jmsg, err := json.Marshal(respPreview{Subject: msg.Subject, Body: msg.Body})
+	if err != nil {
+		s.log.Errorf("PreviewNotification:%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(fmt.Sprintf("{\"error\": \"Can't PreviewNotification:%v\"}\n", err)))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(jmsg)
+	w.Write([]byte("\n"))
+}
+
+// Handler routes the API and passes every request through the same middlewares.
+func (s *Server) Handler() http.Handler {
 	midLogger := NewMiddlewareLogger()
 	mux := http.NewServeMux()
 
-	mux.Handle("/InsertEvent", midLogger.setCommonHeadersMiddleware(
-		midLogger.loggingMiddleware(http.HandlerFunc(s.InsertEvent))))
-	mux.Handle("/UpdateEvent", midLogger.setCommonHeadersMiddleware(
-		midLogger.loggingMiddleware(http.HandlerFunc(s.UpdateEvent))))
-	mux.Handle("/DeleteEvent", midLogger.setCommonHeadersMiddleware(
-		midLogger.loggingMiddleware(http.HandlerFunc(s.DeleteEvent))))
-	mux.Handle("/LookupEvent", midLogger.setCommonHeadersMiddleware(
-		midLogger.loggingMiddleware(http.HandlerFunc(s.LookupEvent))))
-	mux.Handle("/ListEvents", midLogger.setCommonHeadersMiddleware(
-		midLogger.loggingMiddleware(http.HandlerFunc(s.ListEvents))))
-	mux.Handle("/ListEventsDay", midLogger.setCommonHeadersMiddleware(
-		midLogger.loggingMiddleware(http.HandlerFunc(s.ListEventsDay))))
-	mux.Handle("/ListEventsWeek", midLogger.setCommonHeadersMiddleware(
-		midLogger.loggingMiddleware(http.HandlerFunc(s.ListEventsWeek))))
-	mux.Handle("/ListEventsMonth", midLogger.setCommonHeadersMiddleware(
-		midLogger.loggingMiddleware(http.HandlerFunc(s.ListEventsMonth))))
+	mux.HandleFunc("/InsertEvent", s.InsertEvent)
+	mux.HandleFunc("/UpdateEvent", s.UpdateEvent)
+	mux.HandleFunc("/DeleteEvent", s.DeleteEvent)
+	mux.HandleFunc("/LookupEvent", s.LookupEvent)
+	mux.HandleFunc("/ListEvents", s.ListEvents)
+	mux.HandleFunc("/ListEventsDay", s.ListEventsDay)
+	mux.HandleFunc("/ListEventsWeek", s.ListEventsWeek)
+	mux.HandleFunc("/ListEventsMonth", s.ListEventsMonth)
+	mux.HandleFunc("/SearchEvents", s.SearchEvents)
+	mux.HandleFunc("/SetUserChannels", s.SetUserChannels)
+	mux.HandleFunc("/SetUserRetention", s.SetUserRetention)
+	mux.HandleFunc("/LookupUserRetention", s.LookupUserRetention)
+	mux.HandleFunc("/ListUserChannels", s.ListUserChannels)
+	mux.HandleFunc("/ListUserTags", s.ListUserTags)
+	mux.HandleFunc("/PreviewNotification", s.PreviewNotification)
+	mux.HandleFunc("/UploadAttachment", s.UploadAttachment)
+	mux.HandleFunc("/DownloadAttachment", s.DownloadAttachment)
+	mux.HandleFunc("/DeleteAttachment", s.DeleteAttachment)
+	mux.HandleFunc("/ListAttachments", s.ListAttachments)
 
 	// to avoid twice handling
 	mux.HandleFunc("/favicon.ico", s.doNothing)
 
+	middlewares := []Middleware{
+		loggerMiddleware(s.log),
+		midLogger.recoverMiddleware,
+		midLogger.clientIDMiddleware,
+		midLogger.loggingMiddleware,
+		corsMiddleware(s.conf.CORS),
+		rateLimitMiddleware(s.limiter),
+	}
+	if s.conf.Compress {
+		middlewares = append(middlewares, compressMiddleware)
+	}
+	middlewares = append(middlewares, midLogger.setCommonHeadersMiddleware)
+
+	return Chain(mux, middlewares...)
+}
+
+func (s *Server) Start(ctx context.Context) error {
+	addr := net.JoinHostPort(s.conf.Host, s.conf.Port)
 	s.srv = http.Server{
 		Addr:              addr,
-		Handler:           mux,
+		Handler:           s.Handler(),
 		ReadHeaderTimeout: 2 * time.Second,
 		BaseContext: func(l net.Listener) context.Context {
 			bCtx := context.WithValue(ctx, KeyLoggerID, s.log)
@@ -286,6 +560,15 @@ func (s *Server) Start(ctx context.Context) error {
 		},
 	}
 
+	if s.tls != nil {
+		if err := s.tls.Load(); err != nil {
+			return err
+		}
+		s.srv.TLSConfig = s.tls.Config()
+		s.log.Infof("HTTPS-server started on:%v\n", addr)
+		return s.srv.ListenAndServeTLS("", "")
+	}
+
 	s.log.Infof("HTTP-server started on:%v\n", addr)
 
 	return s.srv.ListenAndServe()
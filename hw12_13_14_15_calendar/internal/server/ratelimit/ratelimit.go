@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"expvar"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+)
+
+const pruneInterval = time.Minute
+
+var (
+	metrics = expvar.NewMap("ratelimit")
+
+	limitersMu sync.Mutex
+	limiters   = map[*Limiter]struct{}{}
+)
+
+func init() {
+	metrics.Set("keys", expvar.Func(func() interface{} {
+		limitersMu.Lock()
+		defer limitersMu.Unlock()
+		keys := 0
+		for l := range limiters {
+			keys += l.Len()
+		}
+		return keys
+	}))
+}
+
+type Conf struct {
+	Rate  float64 `toml:"rate"`
+	Burst int     `toml:"burst"`
+}
+
+func (c Conf) Enabled() bool {
+	return c.Rate > 0
+}
+
+func (c Conf) Check(v *config.Validator) {
+	if c.Rate < 0 {
+		v.AddKeyf("rate", "must not be negative, got %v", c.Rate)
+	}
+	if c.Enabled() {
+		v.Range("burst", int64(c.Burst), 1, math.MaxInt32)
+	}
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a token bucket per key: every key may spend up to Burst
+// requests at once, and its bucket refills at Rate requests per second.
+type Limiter struct {
+	mu        sync.Mutex
+	conf      Conf
+	buckets   map[string]*bucket
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+func New(conf Conf) *Limiter {
+	l := &Limiter{conf: conf, buckets: map[string]*bucket{}, now: time.Now}
+	limitersMu.Lock()
+	limiters[l] = struct{}{}
+	limitersMu.Unlock()
+	return l
+}
+
+// SetConf changes the limits, the existing buckets keep their tokens.
+func (l *Limiter) SetConf(conf Conf) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.conf = conf
+}
+
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// Allow takes a token from the bucket of key. If there is none, it returns
+// false and the time after which the next token will be available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.conf.Enabled() {
+		return true, 0
+	}
+
+	now := l.now()
+	l.pruneUnsafe(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.conf.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.conf.Burst), b.tokens+now.Sub(b.last).Seconds()*l.conf.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		metrics.Add("allowed", 1)
+		return true, 0
+	}
+
+	metrics.Add("rejected", 1)
+	wait := time.Duration((1 - b.tokens) / l.conf.Rate * float64(time.Second))
+	return false, wait
+}
+
+// pruneUnsafe forgets the keys whose buckets have refilled completely.
+func (l *Limiter) pruneUnsafe(now time.Time) {
+	if now.Sub(l.lastPrune) < pruneInterval {
+		return
+	}
+	l.lastPrune = now
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.conf.Rate >= float64(l.conf.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
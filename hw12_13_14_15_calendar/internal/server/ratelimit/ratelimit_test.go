@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Now()
+	l := New(Conf{Rate: 2, Burst: 3})
+	l.now = func() time.Time { return now }
+
+	t.Run("burst_then_reject", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			ok, _ := l.Allow("user:1")
+			require.True(t, ok)
+		}
+		ok, wait := l.Allow("user:1")
+		require.False(t, ok)
+		require.Equal(t, 500*time.Millisecond, wait)
+
+		ok, _ = l.Allow("ip:127.0.0.1")
+		require.True(t, ok, "other keys have own buckets")
+	})
+
+	t.Run("refill", func(t *testing.T) {
+		now = now.Add(500 * time.Millisecond)
+		ok, _ := l.Allow("user:1")
+		require.True(t, ok)
+		ok, _ = l.Allow("user:1")
+		require.False(t, ok)
+	})
+
+	t.Run("prune_full_buckets", func(t *testing.T) {
+		now = now.Add(pruneInterval)
+		ok, _ := l.Allow("user:2")
+		require.True(t, ok)
+		require.Equal(t, 1, l.Len())
+	})
+
+	t.Run("set_conf", func(t *testing.T) {
+		l.SetConf(Conf{})
+		for i := 0; i < 10; i++ {
+			ok, _ := l.Allow("user:2")
+			require.True(t, ok)
+		}
+	})
+}
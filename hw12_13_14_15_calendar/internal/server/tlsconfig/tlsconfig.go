@@ -0,0 +1,247 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+)
+
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+var (
+	ErrNoCA         = errors.New("no CA certificates found")
+	ErrNoClientCert = errors.New("client certificate required")
+)
+
+type ctxKeyID int
+
+const (
+	KeyClientID ctxKeyID = iota
+)
+
+type Logger interface {
+	Fatalf(format string, a ...interface{})
+	Errorf(format string, a ...interface{})
+	Warningf(format string, a ...interface{})
+	Infof(format string, a ...interface{})
+	Debugf(format string, a ...interface{})
+}
+
+type Conf struct {
+	Cert       string `toml:"cert"`
+	Key        string `toml:"key"`
+	CA         string `toml:"ca"`
+	ClientAuth string `toml:"client_auth"`
+}
+
+func (c Conf) Enabled() bool {
+	return c.Cert != "" || c.Key != ""
+}
+
+func (c Conf) clientAuth() string {
+	if c.ClientAuth == "" {
+		return ClientAuthNone
+	}
+	return c.ClientAuth
+}
+
+func (c Conf) Check(v *config.Validator) {
+	v.OneOf("client_auth", c.clientAuth(), ClientAuthNone, ClientAuthOptional, ClientAuthRequire)
+	if !c.Enabled() {
+		if c.clientAuth() != ClientAuthNone {
+			v.Required("cert", c.Cert)
+		}
+		return
+	}
+
+	v.File("cert", c.Cert)
+	v.File("key", c.Key)
+	v.File("ca", c.CA)
+	v.Required("cert", c.Cert)
+	v.Required("key", c.Key)
+	if c.clientAuth() != ClientAuthNone {
+		v.Required("ca", c.CA)
+	}
+}
+
+// Reloader serves the certificate and the client CA pool from files
+// and re-reads them when their modification time changes.
+type Reloader struct {
+	log      Logger
+	conf     Conf
+	mu       sync.RWMutex
+	modTimes map[string]time.Time
+	cert     *tls.Certificate
+	pool     *x509.CertPool
+}
+
+func NewReloader(log Logger, conf Conf) *Reloader {
+	return &Reloader{log: log, conf: conf, modTimes: map[string]time.Time{}}
+}
+
+func (r *Reloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, filename := range []string{r.conf.Cert, r.conf.Key, r.conf.CA} {
+		if filename == "" {
+			continue
+		}
+		fi, err := os.Stat(filename)
+		if err != nil || !fi.ModTime().Equal(r.modTimes[filename]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Load reads the files if they have changed since the previous call.
+// On error the previously loaded certificates stay in use.
+func (r *Reloader) Load() error {
+	if !r.changed() {
+		return nil
+	}
+
+	modTimes := map[string]time.Time{}
+	for _, filename := range []string{r.conf.Cert, r.conf.Key, r.conf.CA} {
+		if filename == "" {
+			continue
+		}
+		fi, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		modTimes[filename] = fi.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.conf.Cert, r.conf.Key)
+	if err != nil {
+		return fmt.Errorf("can't load key pair: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if r.conf.CA != "" {
+		pem, err := os.ReadFile(r.conf.CA)
+		if err != nil {
+			return fmt.Errorf("can't load CA: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%w: %v", ErrNoCA, r.conf.CA)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reloaded := r.cert != nil
+	r.cert, r.pool, r.modTimes = &cert, pool, modTimes
+	if reloaded {
+		r.log.Infof("TLS certificates reloaded from:%v\n", r.conf.Cert)
+	}
+	return nil
+}
+
+func (r *Reloader) reload() {
+	if err := r.Load(); err != nil {
+		r.log.Errorf("Can't reload TLS certificates:%v\n", err)
+	}
+}
+
+func (r *Reloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.reload()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+func (r *Reloader) verifyClient(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		if r.conf.clientAuth() == ClientAuthRequire {
+			return ErrNoClientCert
+		}
+		return nil
+	}
+
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs[i] = cert
+	}
+
+	r.mu.RLock()
+	pool := r.pool
+	r.mu.RUnlock()
+
+	opts := x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
+
+// Config returns a server config picking up certificate changes on every handshake.
+// Client certificates are verified against the current CA pool by the reloader
+// itself, so the CA file can be replaced without restarting the server.
+func (r *Reloader) Config() *tls.Config {
+	conf := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.getCertificate,
+	}
+
+	switch r.conf.clientAuth() {
+	case ClientAuthOptional:
+		conf.ClientAuth = tls.RequestClientCert
+		conf.VerifyPeerCertificate = r.verifyClient
+	case ClientAuthRequire:
+		conf.ClientAuth = tls.RequireAnyClientCert
+		conf.VerifyPeerCertificate = r.verifyClient
+	}
+	return conf
+}
+
+// Identity returns the subject of the client certificate, if any.
+// It is only meaningful for connections made with a Reloader config,
+// which rejects unverified client certificates during the handshake.
+func Identity(state *tls.ConnectionState) string {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return ""
+	}
+	cert := state.PeerCertificates[0]
+	switch {
+	case cert.Subject.CommonName != "":
+		return cert.Subject.CommonName
+	case len(cert.EmailAddresses) > 0:
+		return cert.EmailAddresses[0]
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	}
+	return ""
+}
+
+func WithClientID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, KeyClientID, id)
+}
+
+// ClientID returns the identity of the client certificate of the request.
+func ClientID(ctx context.Context) string {
+	id, _ := ctx.Value(KeyClientID).(string)
+	return id
+}
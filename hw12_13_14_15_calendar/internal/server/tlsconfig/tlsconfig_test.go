@@ -0,0 +1,169 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/logger"
+	"github.com/stretchr/testify/require"
+)
+
+type helperCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func helperIssue(t *testing.T, cn string, parent *helperCert, usage x509.ExtKeyUsage) *helperCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &helperCert{cert: cert, key: key}
+}
+
+func (c *helperCert) write(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+
+	if keyFile == "" {
+		return
+	}
+	der, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+}
+
+func (c *helperCert) tlsCert() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	conf := Conf{
+		Cert:       filepath.Join(dir, "server.crt"),
+		Key:        filepath.Join(dir, "server.key"),
+		CA:         filepath.Join(dir, "ca.crt"),
+		ClientAuth: ClientAuthRequire,
+	}
+
+	ca := helperIssue(t, "ca", nil, x509.ExtKeyUsageAny)
+	ca.write(t, conf.CA, "")
+	server := helperIssue(t, "server-1", ca, x509.ExtKeyUsageServerAuth)
+	server.write(t, conf.Cert, conf.Key)
+	client := helperIssue(t, "client-1", ca, x509.ExtKeyUsageClientAuth)
+	stranger := helperIssue(t, "stranger", helperIssue(t, "other-ca", nil, x509.ExtKeyUsageAny),
+		x509.ExtKeyUsageClientAuth)
+
+	log := logger.NewLogger("DEBUG", os.Stdout)
+	reloader := NewReloader(log, conf)
+	require.NoError(t, reloader.Load())
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", reloader.Config())
+	require.NoError(t, err)
+	srv := &http.Server{
+		ReadHeaderTimeout: time.Second,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(Identity(r.TLS)))
+		}),
+	}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(certs ...tls.Certificate) (string, string, error) {
+		transport := &http.Transport{TLSClientConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			RootCAs:      roots,
+			Certificates: certs,
+		}}
+		defer transport.CloseIdleConnections()
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet,
+			"https://"+listener.Addr().String(), nil)
+		require.NoError(t, err)
+		res, err := (&http.Client{Transport: transport}).Do(req)
+		if err != nil {
+			return "", "", err
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		return string(body), res.TLS.PeerCertificates[0].Subject.CommonName, err
+	}
+
+	t.Run("client_identity", func(t *testing.T) {
+		id, serverCN, err := get(client.tlsCert())
+		require.NoError(t, err)
+		require.Equal(t, "client-1", id)
+		require.Equal(t, "server-1", serverCN)
+	})
+
+	t.Run("client_rejected", func(t *testing.T) {
+		_, _, err := get()
+		require.Error(t, err)
+
+		_, _, err = get(stranger.tlsCert())
+		require.Error(t, err)
+	})
+
+	t.Run("reload_on_change", func(t *testing.T) {
+		helperIssue(t, "server-2", ca, x509.ExtKeyUsageServerAuth).write(t, conf.Cert, conf.Key)
+		future := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(conf.Cert, future, future))
+		require.NoError(t, os.Chtimes(conf.Key, future, future))
+
+		_, serverCN, err := get(client.tlsCert())
+		require.NoError(t, err)
+		require.Equal(t, "server-2", serverCN)
+	})
+
+	t.Run("broken_files_keep_previous", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(conf.Cert, []byte("broken"), 0o600))
+		future := time.Now().Add(2 * time.Minute)
+		require.NoError(t, os.Chtimes(conf.Cert, future, future))
+
+		_, serverCN, err := get(client.tlsCert())
+		require.NoError(t, err)
+		require.Equal(t, "server-2", serverCN)
+	})
+}
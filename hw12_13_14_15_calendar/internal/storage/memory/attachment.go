@@ -0,0 +1,93 @@
+package memorystorage
+
+import (
+	"context"
+	"sort"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+)
+
+// InsertAttachment refuses the attachment if the attachments of the user
+// would take more than quota bytes.
+func (s *Storage) InsertAttachment(ctx context.Context, a *model.Attachment, quota int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data[a.EventID]; !ok {
+		return ErrEventNotFound
+	}
+	if s.userAttachmentsSizeUnsafe(a.UserID)+a.Size > quota {
+		return ErrQuotaExceeded
+	}
+	a.ID = s.genAttachID
+	s.genAttachID++
+	attachment := *a
+	s.attachments[a.ID] = &attachment
+	return nil
+}
+
+func (s *Storage) LookupAttachment(ctx context.Context, id int64) (model.Attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if a, ok := s.attachments[id]; ok {
+		return *a, nil
+	}
+	return model.Attachment{}, ErrAttachmentNotFound
+}
+
+func (s *Storage) ListAttachments(ctx context.Context, eventID int64) ([]model.Attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	attachments := []model.Attachment{}
+	for _, a := range s.attachments {
+		if a.EventID == eventID {
+			attachments = append(attachments, *a)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].ID < attachments[j].ID })
+	return attachments, nil
+}
+
+func (s *Storage) DeleteAttachment(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.attachments[id]; !ok {
+		return ErrAttachmentNotFound
+	}
+	delete(s.attachments, id)
+	return nil
+}
+
+func (s *Storage) UserAttachmentsSize(ctx context.Context, userID int64) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.userAttachmentsSizeUnsafe(userID), nil
+}
+
+// HasAttachmentKey tells whether a blob is still referenced.
+func (s *Storage) HasAttachmentKey(ctx context.Context, key string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, a := range s.attachments {
+		if a.Key == key {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Storage) userAttachmentsSizeUnsafe(userID int64) (size int64) {
+	for _, a := range s.attachments {
+		if a.UserID == userID {
+			size += a.Size
+		}
+	}
+	return size
+}
+
+func (s *Storage) deleteAttachmentsUnsafe(eventID int64) {
+	for id, a := range s.attachments {
+		if a.EventID == eventID {
+			delete(s.attachments, id)
+		}
+	}
+}
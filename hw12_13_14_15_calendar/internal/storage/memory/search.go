@@ -0,0 +1,212 @@
+package memorystorage
+
+import (
+	"context"
+	"html"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+)
+
+const (
+	titleWeight       = 1.0
+	descriptionWeight = 0.4
+	snippetWords      = 35
+	highlightStart    = "<b>"
+	highlightStop     = "</b>"
+)
+
+// searchIndex maps a lowercased word to the events having it in the title
+// or the description.
+type searchIndex map[string]map[int64]struct{}
+
+func (idx searchIndex) add(e *model.Event) {
+	for _, w := range eventWords(e) {
+		ids, ok := idx[w]
+		if !ok {
+			ids = make(map[int64]struct{})
+			idx[w] = ids
+		}
+		ids[e.ID] = struct{}{}
+	}
+}
+
+func (idx searchIndex) remove(e *model.Event) {
+	for _, w := range eventWords(e) {
+		delete(idx[w], e.ID)
+		if len(idx[w]) == 0 {
+			delete(idx, w)
+		}
+	}
+}
+
+func eventWords(e *model.Event) []string {
+	return append(splitWords(e.Title), splitWords(e.Description)...)
+}
+
+// wordSpans returns the byte offsets of the words of text, a word is a run
+// of letters and digits.
+func wordSpans(text string) [][2]int {
+	spans := [][2]int{}
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+func splitWords(text string) []string {
+	spans := wordSpans(text)
+	words := make([]string, len(spans))
+	for i, span := range spans {
+		words[i] = strings.ToLower(text[span[0]:span[1]])
+	}
+	return words
+}
+
+// parseQuery splits the query into the words every result must have and the
+// ones it must not, the latter are prefixed with "-". Quotes are ignored.
+func parseQuery(query string) (include, exclude []string) {
+	for _, term := range strings.Fields(query) {
+		if strings.HasPrefix(term, "-") {
+			exclude = append(exclude, splitWords(term)...)
+			continue
+		}
+		include = append(include, splitWords(term)...)
+	}
+	return include, exclude
+}
+
+func countWords(words []string, word string) (n int) {
+	for _, w := range words {
+		if w == word {
+			n++
+		}
+	}
+	return n
+}
+
+// highlight wraps the words of terms in text, limited to maxWords words
+// around the first match if maxWords > 0. The text is HTML escaped, so the
+// tags are the only markup of the result.
+func highlight(text string, terms map[string]bool, maxWords int) string {
+	spans := wordSpans(text)
+	if len(spans) == 0 {
+		return html.EscapeString(text)
+	}
+
+	first, last := 0, len(spans)
+	if maxWords > 0 && len(spans) > maxWords {
+		for i, span := range spans {
+			if terms[strings.ToLower(text[span[0]:span[1]])] {
+				first = i
+				break
+			}
+		}
+		if first+maxWords > len(spans) {
+			first = len(spans) - maxWords
+		}
+		last = first + maxWords
+	}
+
+	var b strings.Builder
+	pos := spans[first][0]
+	if first == 0 {
+		pos = 0
+	}
+	for _, span := range spans[first:last] {
+		b.WriteString(html.EscapeString(text[pos:span[0]]))
+		word := text[span[0]:span[1]]
+		if terms[strings.ToLower(word)] {
+			b.WriteString(highlightStart + word + highlightStop)
+		} else {
+			b.WriteString(word)
+		}
+		pos = span[1]
+	}
+	if last == len(spans) {
+		b.WriteString(html.EscapeString(text[pos:]))
+	}
+	return b.String()
+}
+
+// SearchEvents finds the events of the user having all the words of the
+// query, overlapping the range and matching the filter, zero bounds are
+// open. Title words rank higher than description ones.
+func (s *Storage) SearchEvents(ctx context.Context, userID int64, query string, begin, end time.Time,
+	filter model.TagFilter, limit int,
+) ([]model.SearchResult, error) {
+	include, exclude := parseQuery(query)
+	results := []model.SearchResult{}
+	if len(include) == 0 {
+		return results, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	terms := make(map[string]bool, len(include))
+	for _, w := range include {
+		terms[w] = true
+	}
+
+	for id := range s.index[include[0]] {
+		e := s.data[id]
+		if e.UserID != userID ||
+			(!begin.IsZero() && e.OffTime.Before(begin)) ||
+			(!end.IsZero() && e.OnTime.After(end)) ||
+			!filter.Match(e.Tags) {
+			continue
+		}
+
+		title, description := splitWords(e.Title), splitWords(e.Description)
+		rank, matched := 0.0, true
+		for w := range terms {
+			n := titleWeight*float64(countWords(title, w)) + descriptionWeight*float64(countWords(description, w))
+			if n == 0 {
+				matched = false
+				break
+			}
+			rank += n
+		}
+		for _, w := range exclude {
+			if countWords(title, w)+countWords(description, w) > 0 {
+				matched = false
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		results = append(results, model.SearchResult{
+			Event:   *copyEvent(e),
+			Rank:    rank,
+			Title:   highlight(e.Title, terms, 0),
+			Snippet: highlight(e.Description, terms, snippetWords),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Event.ID < results[j].Event.ID
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
@@ -3,6 +3,7 @@ package memorystorage
 import (
 	"context"
 	"errors"
+	"sort"
 	"sync"
 	"time"
 
@@ -11,17 +12,41 @@ import (
 
 type mapEvent map[int64]*model.Event
 
+type mapNotification map[int64]*model.Notification
+
+type dedupKey struct {
+	done      bool
+	expiresAt time.Time
+}
+
 type Storage struct {
-	data  mapEvent
-	mu    sync.RWMutex
-	genID int64
+	data          mapEvent
+	index         searchIndex
+	notifications mapNotification
+	attachments   map[int64]*model.Attachment
+	channels      map[int64][]model.UserChannel
+	retention     map[int64]time.Duration
+	dedup         map[string]dedupKey
+	failures      []model.DeliveryFailure
+	mu            sync.RWMutex
+	genID         int64
+	genNotifyID   int64
+	genReminderID int64
+	genAttachID   int64
 }
 
 var (
-	ErrEventNotFound   = errors.New("event not found")
-	ErrDataRangeIsBusy = errors.New("data is busy")
+	ErrEventNotFound         = errors.New("event not found")
+	ErrDataRangeIsBusy       = errors.New("data is busy")
+	ErrReminderNotFound      = errors.New("reminder not found")
+	ErrNotificationNotFound  = errors.New("notification not found")
+	ErrNotificationNotQueued = model.ErrNotificationNotQueued
+	ErrAttachmentNotFound    = errors.New("attachment not found")
+	ErrQuotaExceeded         = model.ErrQuotaExceeded
 )
 
+const errTooManyAttempts = "too many attempts"
+
 func (s *Storage) getNewIDUnsafe() int64 {
 	ret := s.genID
 	s.genID++
@@ -29,7 +54,20 @@ func (s *Storage) getNewIDUnsafe() int64 {
 }
 
 func New() *Storage {
-	return &Storage{data: make(mapEvent), mu: sync.RWMutex{}, genID: 1}
+	return &Storage{
+		data:          make(mapEvent),
+		index:         make(searchIndex),
+		notifications: make(mapNotification),
+		attachments:   make(map[int64]*model.Attachment),
+		channels:      make(map[int64][]model.UserChannel),
+		retention:     make(map[int64]time.Duration),
+		dedup:         make(map[string]dedupKey),
+		mu:            sync.RWMutex{},
+		genID:         1,
+		genNotifyID:   1,
+		genReminderID: 1,
+		genAttachID:   1,
+	}
 }
 
 func (s *Storage) Connect(ctx context.Context) error {
@@ -52,11 +90,12 @@ func (s *Storage) inTimeSpan(start, end, check time.Time) bool {
 	return false
 }
 
+// IsBusyDateTimeRange ignores all-day events, they take dates rather than time.
 func (s *Storage) IsBusyDateTimeRange(ctx context.Context, id, userID int64, onTime, offTime time.Time) error {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	for _, v := range s.data {
-		if v.UserID == userID && v.ID != id &&
+		if v.UserID == userID && v.ID != id && !v.AllDay &&
 			(s.inTimeSpan(v.OnTime, v.OffTime, onTime) ||
 				s.inTimeSpan(v.OnTime, v.OffTime, offTime)) {
 			return ErrDataRangeIsBusy
@@ -69,100 +108,514 @@ func (s *Storage) InsertEvent(ctx context.Context, e *model.Event) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	e.ID = s.getNewIDUnsafe()
-	s.data[e.ID] = e
+	e.Reminders = model.MergeReminders(nil, e.Reminders)
+	s.setRemindersUnsafe(e)
+	s.data[e.ID] = copyEvent(e)
+	s.index.add(e)
 	return nil
 }
 
+// UpdateEvent keeps the state of reminders with the same offset and channel,
+// reminders that are gone are deleted with their notifications.
 func (s *Storage) UpdateEvent(ctx context.Context, e *model.Event) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if _, ok := s.data[e.ID]; !ok {
+	stored, ok := s.data[e.ID]
+	if !ok {
 		return ErrEventNotFound
 	}
-	s.data[e.ID] = e
+
+	e.Reminders = model.MergeReminders(stored.Reminders, e.Reminders)
+	s.setRemindersUnsafe(e)
+	for _, old := range stored.Reminders {
+		if !hasReminder(e.Reminders, old.ID) {
+			s.deleteNotificationsUnsafe(func(n *model.Notification) bool { return n.ReminderID == old.ID })
+		}
+	}
+	s.index.remove(stored)
+	s.data[e.ID] = copyEvent(e)
+	s.index.add(e)
 
 	return nil
 }
 
+func (s *Storage) setRemindersUnsafe(e *model.Event) {
+	for i := range e.Reminders {
+		if e.Reminders[i].ID == 0 {
+			e.Reminders[i].ID = s.genReminderID
+			s.genReminderID++
+		}
+	}
+}
+
+func hasReminder(reminders []model.Reminder, id int64) bool {
+	for _, r := range reminders {
+		if r.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func copyEvent(e *model.Event) *model.Event {
+	event := *e
+	event.Reminders = append([]model.Reminder(nil), e.Reminders...)
+	event.Tags = append([]string(nil), e.Tags...)
+	return &event
+}
+
 func (s *Storage) DeleteEvent(ctx context.Context, id int64) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if e, ok := s.data[id]; ok {
+		s.index.remove(e)
+	}
 	delete(s.data, id)
+	s.deleteNotificationsUnsafe(func(n *model.Notification) bool { return n.EventID == id })
+	s.deleteAttachmentsUnsafe(id)
 	return nil
 }
 
-func (s *Storage) ListEvents(ctx context.Context, userID int64) ([]model.Event, error) {
+func (s *Storage) ListEvents(ctx context.Context, userID int64, filter model.TagFilter) ([]model.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	sliceE := []model.Event{}
 	for _, v := range s.data {
-		if v.UserID == userID {
-			sliceE = append(sliceE, *v)
+		if v.UserID == userID && filter.Match(v.Tags) {
+			sliceE = append(sliceE, *copyEvent(v))
 		}
 	}
 
 	return sliceE, nil
 }
 
-func (s *Storage) ListEventsRange(ctx context.Context, userID int64, begin, end time.Time) ([]model.Event, error) {
+// ListEventsRange matches all-day events by the dates of begin and end in
+// their own locations, so the days are the ones of the viewer.
+func (s *Storage) ListEventsRange(ctx context.Context, userID int64, begin, end time.Time,
+	filter model.TagFilter,
+) ([]model.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	sliceE := []model.Event{}
 
+	first, last := model.Date(begin), model.Date(end)
 	for _, v := range s.data {
-		if v.UserID == userID &&
-			(s.inTimeSpan(begin, end, v.OnTime) ||
-				s.inTimeSpan(begin, end, v.OffTime)) {
-			sliceE = append(sliceE, *v)
+		if v.UserID != userID || !filter.Match(v.Tags) {
+			continue
+		}
+		if v.AllDay && !v.OnTime.After(last) && v.OffTime.After(first) ||
+			!v.AllDay && (s.inTimeSpan(begin, end, v.OnTime) || s.inTimeSpan(begin, end, v.OffTime)) {
+			sliceE = append(sliceE, *copyEvent(v))
 		}
 	}
 	return sliceE, nil
 }
 
+// ListUserTags counts the events of the user by tag, the most used first.
+func (s *Storage) ListUserTags(ctx context.Context, userID int64) ([]model.TagCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	counts := map[string]int64{}
+	for _, v := range s.data {
+		if v.UserID == userID {
+			for _, tag := range v.Tags {
+				counts[tag]++
+			}
+		}
+	}
+
+	tags := make([]model.TagCount, 0, len(counts))
+	for tag, count := range counts {
+		tags = append(tags, model.TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	return tags, nil
+}
+
 func (s *Storage) LookupEvent(ctx context.Context, eID int64) (model.Event, error) {
 	var event model.Event
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	if e, ok := s.data[eID]; ok {
-		event = *e
+		event = *copyEvent(e)
 		return event, nil
 	}
 
 	return event, ErrEventNotFound
 }
 
+func isDue(r model.Reminder, date time.Time) bool {
+	return !r.Notified && !r.NotifyTime.After(date)
+}
+
+// ListEventsDayOfNotice returns the events with reminders due by date, every
+// event has only its due reminders.
 func (s *Storage) ListEventsDayOfNotice(ctx context.Context, date time.Time) ([]model.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	sliceE := []model.Event{}
 
 	for _, v := range s.data {
-		if !v.Notified && (v.NotifyTime.Before(date) || v.NotifyTime.Equal(date)) {
-			sliceE = append(sliceE, *v)
+		event := *v
+		event.Reminders = nil
+		for _, r := range v.Reminders {
+			if isDue(r, date) {
+				event.Reminders = append(event.Reminders, r)
+			}
+		}
+		if len(event.Reminders) > 0 {
+			sliceE = append(sliceE, event)
 		}
 	}
 	return sliceE, nil
 }
 
-func (s *Storage) UpdateEventNotified(ctx context.Context, eventid int64) error {
+func (s *Storage) UpdateEventNotified(ctx context.Context, reminderID int64) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if _, ok := s.data[eventid]; !ok {
-		return ErrEventNotFound
+	for _, v := range s.data {
+		for i := range v.Reminders {
+			if v.Reminders[i].ID == reminderID {
+				v.Reminders[i].Notified = true
+				return nil
+			}
+		}
 	}
-	s.data[eventid].Notified = true
-	return nil
+	return ErrReminderNotFound
+}
+
+// ListExpiredEvents returns up to limit events after afterID ended before
+// the date, or before date minus own retention for users who have one.
+func (s *Storage) ListExpiredEvents(ctx context.Context, date, before time.Time, afterID int64,
+	limit int,
+) ([]model.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sliceE := []model.Event{}
+	for _, v := range s.data {
+		if v.ID <= afterID {
+			continue
+		}
+		expired := before
+		if retention, ok := s.retention[v.UserID]; ok {
+			expired = date.Add(-retention)
+		}
+		if v.OffTime.Before(expired) {
+			sliceE = append(sliceE, *copyEvent(v))
+		}
+	}
+
+	sort.Slice(sliceE, func(i, j int) bool { return sliceE[i].ID < sliceE[j].ID })
+	if len(sliceE) > limit {
+		sliceE = sliceE[:limit]
+	}
+	return sliceE, nil
 }
 
-func (s *Storage) DeleteEventsOlderDate(ctx context.Context, date time.Time) (int64, error) {
+func (s *Storage) DeleteEvents(ctx context.Context, ids []int64) (int64, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	deleted := int64(0)
-	for id, v := range s.data {
-		if v.OffTime.Before(date) {
+	for _, id := range ids {
+		if e, ok := s.data[id]; ok {
+			s.index.remove(e)
 			delete(s.data, id)
+			s.deleteNotificationsUnsafe(func(n *model.Notification) bool { return n.EventID == id })
+			s.deleteAttachmentsUnsafe(id)
 			deleted++
 		}
 	}
 	return deleted, nil
 }
+
+func (s *Storage) deleteNotificationsUnsafe(match func(*model.Notification) bool) {
+	for id, n := range s.notifications {
+		if match(n) {
+			delete(s.notifications, id)
+			s.deleteFailuresUnsafe(id)
+		}
+	}
+}
+
+func (s *Storage) deleteFailuresUnsafe(notificationID int64) {
+	failures := s.failures[:0]
+	for _, f := range s.failures {
+		if f.NotificationID != notificationID {
+			failures = append(failures, f)
+		}
+	}
+	s.failures = failures
+}
+
+func (s *Storage) hasNotificationUnsafe(reminderID int64, notifyTime time.Time) bool {
+	for _, n := range s.notifications {
+		if n.ReminderID == reminderID && n.NotifyTime.Equal(notifyTime) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Storage) EnqueueNotifications(ctx context.Context, date time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	enqueued := int64(0)
+
+	for _, v := range s.data {
+		for i := range v.Reminders {
+			r := &v.Reminders[i]
+			if !isDue(*r, date) {
+				continue
+			}
+			r.Notified = true
+			if s.hasNotificationUnsafe(r.ID, r.NotifyTime) {
+				continue
+			}
+			n := &model.Notification{
+				ID:         s.genNotifyID,
+				EventID:    v.ID,
+				ReminderID: r.ID,
+				Channel:    r.Channel,
+				UserID:     v.UserID,
+				Title:      v.Title,
+				Date:       v.OnTime,
+				NotifyTime: r.NotifyTime,
+				Status:     model.NotificationPending,
+			}
+			s.genNotifyID++
+			s.notifications[n.ID] = n
+			enqueued++
+		}
+	}
+	return enqueued, nil
+}
+
+func (s *Storage) ClaimNotifications(ctx context.Context, date time.Time, timeout time.Duration,
+	maxAttempts int,
+) ([]model.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sliceN := []model.Notification{}
+	expired := date.Add(-timeout)
+
+	for _, n := range s.notifications {
+		due := n.Status == model.NotificationPending ||
+			(n.Status == model.NotificationQueued && !n.QueuedAt.After(expired))
+		if !due {
+			continue
+		}
+		if n.Attempts >= maxAttempts {
+			n.Status = model.NotificationFailed
+			n.LastError = errTooManyAttempts
+			continue
+		}
+		n.Status = model.NotificationQueued
+		n.QueuedAt = date
+		n.Attempts++
+		sliceN = append(sliceN, *n)
+	}
+	return sliceN, nil
+}
+
+// ListDueNotifications returns the notifications ClaimNotifications would
+// claim, changing nothing.
+func (s *Storage) ListDueNotifications(ctx context.Context, date time.Time, timeout time.Duration,
+	maxAttempts int,
+) ([]model.Notification, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sliceN := []model.Notification{}
+	expired := date.Add(-timeout)
+
+	for _, n := range s.notifications {
+		due := n.Status == model.NotificationPending ||
+			(n.Status == model.NotificationQueued && !n.QueuedAt.After(expired))
+		if due && n.Attempts < maxAttempts {
+			sliceN = append(sliceN, *n)
+		}
+	}
+	sort.Slice(sliceN, func(i, j int) bool { return sliceN[i].ID < sliceN[j].ID })
+	return sliceN, nil
+}
+
+func (s *Storage) ReleaseNotification(ctx context.Context, id int64, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.notifications[id]
+	if !ok {
+		return ErrNotificationNotFound
+	}
+	if n.Status != model.NotificationQueued {
+		return ErrNotificationNotQueued
+	}
+	n.Status = model.NotificationPending
+	n.LastError = reason
+	return nil
+}
+
+func (s *Storage) DeliverNotification(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.notifications[id]
+	if !ok {
+		return ErrNotificationNotFound
+	}
+	if n.Status != model.NotificationQueued {
+		return ErrNotificationNotQueued
+	}
+	n.Status = model.NotificationDelivered
+	n.LastError = ""
+	return nil
+}
+
+func (s *Storage) FailNotification(ctx context.Context, id int64, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.notifications[id]
+	if !ok {
+		return ErrNotificationNotFound
+	}
+	if n.Status != model.NotificationQueued {
+		return ErrNotificationNotQueued
+	}
+	n.Status = model.NotificationFailed
+	n.LastError = reason
+	return nil
+}
+
+func (s *Storage) LookupNotification(ctx context.Context, id int64) (model.Notification, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if n, ok := s.notifications[id]; ok {
+		return *n, nil
+	}
+	return model.Notification{}, ErrNotificationNotFound
+}
+
+// AcquireLease always succeeds: in-memory storage is not shared between
+// processes, so every process is the leader of its own data.
+func (s *Storage) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (s *Storage) ReleaseLease(ctx context.Context, name, holder string) error {
+	return nil
+}
+
+func (s *Storage) SetUserChannels(ctx context.Context, userID int64, channels []model.UserChannel) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(channels) == 0 {
+		delete(s.channels, userID)
+		return nil
+	}
+	sliceC := make([]model.UserChannel, len(channels))
+	for i, c := range channels {
+		c.UserID = userID
+		sliceC[i] = c
+	}
+	s.channels[userID] = sliceC
+	return nil
+}
+
+func (s *Storage) ListUserChannels(ctx context.Context, userID int64) ([]model.UserChannel, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]model.UserChannel{}, s.channels[userID]...), nil
+}
+
+// ClaimDedupKey takes the key for lease, so the notification is processed by
+// one sender at a time. A key that is taken or already processed is not
+// claimed until the lease or the TTL of CompleteDedupKey expires.
+func (s *Storage) ClaimDedupKey(ctx context.Context, key string, lease time.Duration) (model.DedupState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if k, ok := s.dedup[key]; ok && k.expiresAt.After(now) {
+		if k.done {
+			return model.DedupDone, nil
+		}
+		return model.DedupPending, nil
+	}
+	s.dedup[key] = dedupKey{expiresAt: now.Add(lease)}
+	return model.DedupClaimed, nil
+}
+
+func (s *Storage) CompleteDedupKey(ctx context.Context, key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dedup[key] = dedupKey{done: true, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// ReleaseDedupKey lets a failed notification be processed again.
+func (s *Storage) ReleaseDedupKey(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if k, ok := s.dedup[key]; ok && !k.done {
+		delete(s.dedup, key)
+	}
+	return nil
+}
+
+// PurgeDedupKeys deletes the expired keys. The expiry is set by the clock of
+// the storage, so it is compared with that clock rather than a job date.
+func (s *Storage) PurgeDedupKeys(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	purged := int64(0)
+	for key, k := range s.dedup {
+		if k.expiresAt.Before(now) {
+			delete(s.dedup, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// SetUserRetention keeps events of the user for retention after they end,
+// zero restores the global retention.
+func (s *Storage) SetUserRetention(ctx context.Context, userID int64, retention time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if retention == 0 {
+		delete(s.retention, userID)
+		return nil
+	}
+	s.retention[userID] = retention
+	return nil
+}
+
+func (s *Storage) LookupUserRetention(ctx context.Context, userID int64) (time.Duration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.retention[userID], nil
+}
+
+func (s *Storage) InsertDeliveryFailure(ctx context.Context, f *model.DeliveryFailure) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.notifications[f.NotificationID]; !ok {
+		return ErrNotificationNotFound
+	}
+	s.failures = append(s.failures, *f)
+	return nil
+}
+
+func (s *Storage) ListDeliveryFailures(ctx context.Context, notificationID int64) ([]model.DeliveryFailure, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sliceF := []model.DeliveryFailure{}
+	for _, f := range s.failures {
+		if f.NotificationID == notificationID {
+			sliceF = append(sliceF, f)
+		}
+	}
+	return sliceF, nil
+}
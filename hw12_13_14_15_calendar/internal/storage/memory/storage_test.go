@@ -3,6 +3,7 @@ package memorystorage
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -16,7 +17,8 @@ func helperEvent(ev *model.Event, i int) {
 	ev.Description = fmt.Sprintf("Description_N%v", i+1)
 	ev.OnTime = time.Now()
 	ev.OffTime = time.Now().AddDate(0, 0, 7)
-	ev.NotifyTime = time.Now().AddDate(0, 0, 6)
+	ev.Reminders = []model.Reminder{{Offset: 24 * time.Hour}}
+	ev.ScheduleReminders()
 }
 
 func TestStorage(t *testing.T) {
@@ -54,4 +56,257 @@ func TestStorage(t *testing.T) {
 		err := db.UpdateEvent(context.Background(), &ev)
 		require.ErrorIs(t, err, ErrEventNotFound)
 	})
+	t.Run("reminders", func(t *testing.T) {
+		ctx := context.Background()
+		onTime := time.Now().Add(time.Hour)
+		ev := model.Event{
+			UserID:  1,
+			Title:   "Standup",
+			OnTime:  onTime,
+			OffTime: onTime.Add(time.Hour),
+			Reminders: []model.Reminder{
+				{Offset: 24 * time.Hour},
+				{Offset: 10 * time.Minute, Channel: "email"},
+			},
+		}
+		ev.ScheduleReminders()
+		require.NoError(t, db.InsertEvent(ctx, &ev))
+		require.NotZero(t, ev.Reminders[0].ID)
+		require.NotEqual(t, ev.Reminders[0].ID, ev.Reminders[1].ID)
+
+		due, err := db.ListEventsDayOfNotice(ctx, time.Now())
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+		require.Len(t, due[0].Reminders, 1, "only the day before reminder is due")
+		require.Equal(t, ev.Reminders[0].ID, due[0].Reminders[0].ID)
+
+		require.NoError(t, db.UpdateEventNotified(ctx, due[0].Reminders[0].ID))
+		require.ErrorIs(t, db.UpdateEventNotified(ctx, -1), ErrReminderNotFound)
+		due, err = db.ListEventsDayOfNotice(ctx, time.Now())
+		require.NoError(t, err)
+		require.Empty(t, due)
+
+		n, err := db.EnqueueNotifications(ctx, onTime)
+		require.NoError(t, err)
+		require.EqualValues(t, 1, n, "the ten minutes reminder is enqueued")
+		notifications, err := db.ClaimNotifications(ctx, onTime, time.Minute, 1)
+		require.NoError(t, err)
+		require.Len(t, notifications, 1)
+		require.Equal(t, ev.Reminders[1].ID, notifications[0].ReminderID)
+		require.Equal(t, "email", notifications[0].Channel)
+
+		ev.OnTime = onTime.Add(48 * time.Hour)
+		ev.OffTime = ev.OnTime.Add(time.Hour)
+		ev.Reminders = append(ev.Reminders[:1], model.Reminder{Offset: time.Hour})
+		ev.ScheduleReminders()
+		require.NoError(t, db.UpdateEvent(ctx, &ev))
+
+		stored, err := db.LookupEvent(ctx, ev.ID)
+		require.NoError(t, err)
+		require.Len(t, stored.Reminders, 2)
+		require.Equal(t, ev.Reminders[0].ID, stored.Reminders[0].ID, "moved reminder keeps its ID")
+		require.False(t, stored.Reminders[0].Notified, "moved reminder is due again")
+		require.True(t, stored.Reminders[0].NotifyTime.Equal(ev.OnTime.Add(-24*time.Hour)))
+		_, err = db.LookupNotification(ctx, notifications[0].ID)
+		require.ErrorIs(t, err, ErrNotificationNotFound, "notifications of removed reminders are deleted")
+	})
+	t.Run("dedup_keys", func(t *testing.T) {
+		ctx := context.Background()
+		state, err := db.ClaimDedupKey(ctx, "1:100", time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, model.DedupClaimed, state)
+		state, err = db.ClaimDedupKey(ctx, "1:100", time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, model.DedupPending, state, "the key is being processed")
+
+		require.NoError(t, db.ReleaseDedupKey(ctx, "1:100"))
+		state, err = db.ClaimDedupKey(ctx, "1:100", time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, model.DedupClaimed, state, "released key is claimed again")
+
+		require.NoError(t, db.CompleteDedupKey(ctx, "1:100", time.Hour))
+		require.NoError(t, db.ReleaseDedupKey(ctx, "1:100"))
+		state, err = db.ClaimDedupKey(ctx, "1:100", time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, model.DedupDone, state, "processed key is kept")
+
+		purged, err := db.PurgeDedupKeys(ctx)
+		require.NoError(t, err)
+		require.Zero(t, purged)
+		require.NoError(t, db.CompleteDedupKey(ctx, "1:100", -time.Minute))
+		purged, err = db.PurgeDedupKeys(ctx)
+		require.NoError(t, err)
+		require.EqualValues(t, 1, purged)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		db := New()
+		ctx := context.Background()
+		onTime := time.Now()
+		event := model.Event{
+			UserID:      1,
+			Title:       "Planning",
+			Description: "Sprint planning, bring the planning board",
+			OnTime:      onTime,
+			OffTime:     onTime.Add(time.Hour),
+		}
+		require.NoError(t, db.InsertEvent(ctx, &event))
+
+		results, err := db.SearchEvents(ctx, 1, "PLANNING", time.Time{}, time.Time{}, model.TagFilter{}, 10)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.InDelta(t, titleWeight+2*descriptionWeight, results[0].Rank, 1e-9)
+		require.Equal(t, "<b>Planning</b>", results[0].Title)
+		require.Equal(t, "Sprint <b>planning</b>, bring the <b>planning</b> board", results[0].Snippet)
+
+		results, err = db.SearchEvents(ctx, 2, "planning", time.Time{}, time.Time{}, model.TagFilter{}, 10)
+		require.NoError(t, err)
+		require.Empty(t, results, "other user")
+		results, err = db.SearchEvents(ctx, 1, "planning", onTime.Add(2*time.Hour), time.Time{}, model.TagFilter{}, 10)
+		require.NoError(t, err)
+		require.Empty(t, results, "out of range")
+		results, err = db.SearchEvents(ctx, 1, "planning", time.Time{}, time.Time{},
+			model.TagFilter{Any: []string{"work"}}, 10)
+		require.NoError(t, err)
+		require.Empty(t, results, "no tags")
+
+		event.Title = "Retro"
+		event.Description = "Sprint retrospective"
+		require.NoError(t, db.UpdateEvent(ctx, &event))
+		results, err = db.SearchEvents(ctx, 1, "planning", time.Time{}, time.Time{}, model.TagFilter{}, 10)
+		require.NoError(t, err)
+		require.Empty(t, results, "updated words are unindexed")
+		results, err = db.SearchEvents(ctx, 1, "sprint retro", time.Time{}, time.Time{}, model.TagFilter{}, 10)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+
+		require.NoError(t, db.DeleteEvent(ctx, event.ID))
+		require.Empty(t, db.index)
+	})
+
+	t.Run("search_snippet", func(t *testing.T) {
+		words := make([]string, 100)
+		for i := range words {
+			words[i] = fmt.Sprintf("w%v", i)
+		}
+		snippet := highlight(strings.Join(words, " "), map[string]bool{"w50": true}, snippetWords)
+		require.True(t, strings.HasPrefix(snippet, "<b>w50</b> w51"), snippet)
+		require.Len(t, strings.Fields(snippet), snippetWords)
+	})
+
+	t.Run("search_escape", func(t *testing.T) {
+		terms := map[string]bool{"script": true}
+		require.Equal(t, `&lt;<b>script</b>&gt;alert(&#39;x&#39;)&lt;/<b>script</b>&gt;`,
+			highlight(`<script>alert('x')</script>`, terms, 0))
+		require.Equal(t, "&lt;&gt;", highlight("<>", terms, 0), "no words")
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		db := New()
+		ctx := context.Background()
+		onTime := time.Now()
+		for _, tags := range [][]string{{"project-x", "urgent"}, {"project-x"}, {"project-y"}, nil} {
+			event := model.Event{UserID: 1, Title: "Title", OnTime: onTime, OffTime: onTime.Add(time.Hour), Tags: tags}
+			require.NoError(t, db.InsertEvent(ctx, &event))
+		}
+
+		for _, c := range []struct {
+			filter   model.TagFilter
+			expected int
+		}{
+			{model.TagFilter{}, 4},
+			{model.TagFilter{Any: []string{"project-x", "project-y"}}, 3},
+			{model.TagFilter{All: []string{"project-x", "urgent"}}, 1},
+			{model.TagFilter{Any: []string{"project-y"}, All: []string{"urgent"}}, 0},
+		} {
+			events, err := db.ListEvents(ctx, 1, c.filter)
+			require.NoError(t, err)
+			require.Len(t, events, c.expected, c.filter)
+			events, err = db.ListEventsRange(ctx, 1, onTime, onTime, c.filter)
+			require.NoError(t, err)
+			require.Len(t, events, c.expected, c.filter)
+		}
+
+		tags, err := db.ListUserTags(ctx, 1)
+		require.NoError(t, err)
+		require.Equal(t, []model.TagCount{{Tag: "project-x", Count: 2}, {Tag: "project-y", Count: 1}, {Tag: "urgent", Count: 1}}, tags)
+		tags, err = db.ListUserTags(ctx, 2)
+		require.NoError(t, err)
+		require.Empty(t, tags)
+	})
+
+	t.Run("all_day", func(t *testing.T) {
+		db := New()
+		ctx := context.Background()
+		tokyo := time.FixedZone("JST", 9*3600)
+		losAngeles := time.FixedZone("PDT", -7*3600)
+		event := model.Event{
+			UserID:  1,
+			Title:   "Vacation",
+			OnTime:  time.Date(2023, 5, 10, 0, 0, 0, 0, time.UTC),
+			OffTime: time.Date(2023, 5, 13, 0, 0, 0, 0, time.UTC),
+			AllDay:  true,
+		}
+		require.NoError(t, db.InsertEvent(ctx, &event))
+
+		for _, c := range []struct {
+			date     time.Time
+			expected int
+		}{
+			{time.Date(2023, 5, 10, 1, 0, 0, 0, tokyo), 1},
+			{time.Date(2023, 5, 12, 23, 0, 0, 0, losAngeles), 1},
+			{time.Date(2023, 5, 9, 23, 0, 0, 0, tokyo), 0},
+			{time.Date(2023, 5, 13, 1, 0, 0, 0, losAngeles), 0},
+		} {
+			events, err := db.ListEventsRange(ctx, 1, c.date, c.date, model.TagFilter{})
+			require.NoError(t, err)
+			require.Len(t, events, c.expected, c.date)
+		}
+		events, err := db.ListEventsRange(ctx, 1, time.Date(2023, 5, 1, 0, 0, 0, 0, tokyo),
+			time.Date(2023, 5, 31, 0, 0, 0, 0, tokyo), model.TagFilter{})
+		require.NoError(t, err)
+		require.Len(t, events, 1, "the month covers the event")
+
+		onTime := time.Date(2023, 5, 11, 10, 0, 0, 0, time.UTC)
+		require.NoError(t, db.IsBusyDateTimeRange(ctx, 0, 1, onTime, onTime.Add(time.Hour)))
+	})
+
+	t.Run("attachments", func(t *testing.T) {
+		db := New()
+		ctx := context.Background()
+		onTime := time.Now()
+		event := model.Event{UserID: 1, Title: "Title", OnTime: onTime, OffTime: onTime.Add(time.Hour)}
+		require.NoError(t, db.InsertEvent(ctx, &event))
+
+		a := model.Attachment{EventID: event.ID, UserID: 1, Name: "agenda.txt", Size: 60, Key: "k1"}
+		require.NoError(t, db.InsertAttachment(ctx, &a, 100))
+		require.EqualValues(t, 1, a.ID)
+		b := model.Attachment{EventID: event.ID, UserID: 1, Name: "slides.pdf", Size: 50, Key: "k2"}
+		require.ErrorIs(t, db.InsertAttachment(ctx, &b, 100), ErrQuotaExceeded)
+		b.Size = 40
+		require.NoError(t, db.InsertAttachment(ctx, &b, 100))
+		b.EventID = 100
+		require.ErrorIs(t, db.InsertAttachment(ctx, &b, 1000), ErrEventNotFound)
+
+		size, err := db.UserAttachmentsSize(ctx, 1)
+		require.NoError(t, err)
+		require.EqualValues(t, 100, size)
+		attachments, err := db.ListAttachments(ctx, event.ID)
+		require.NoError(t, err)
+		require.Equal(t, []model.Attachment{a, {ID: 2, EventID: event.ID, UserID: 1, Name: "slides.pdf", Size: 40, Key: "k2"}},
+			attachments)
+
+		require.NoError(t, db.DeleteAttachment(ctx, a.ID))
+		require.ErrorIs(t, db.DeleteAttachment(ctx, a.ID), ErrAttachmentNotFound)
+		_, err = db.LookupAttachment(ctx, a.ID)
+		require.ErrorIs(t, err, ErrAttachmentNotFound)
+		found, err := db.HasAttachmentKey(ctx, "k2")
+		require.NoError(t, err)
+		require.True(t, found)
+
+		require.NoError(t, db.DeleteEvent(ctx, event.ID))
+		found, err = db.HasAttachmentKey(ctx, "k2")
+		require.NoError(t, err)
+		require.False(t, found, "deleted with the event")
+	})
 }
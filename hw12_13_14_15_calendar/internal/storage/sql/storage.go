@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"html"
+	"strings"
 	"time"
 
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
@@ -17,10 +19,17 @@ type Storage struct {
 }
 
 var (
-	ErrEventNotFound   = errors.New("event not found")
-	ErrDataRangeIsBusy = errors.New("data is busy")
+	ErrEventNotFound         = errors.New("event not found")
+	ErrDataRangeIsBusy       = errors.New("data is busy")
+	ErrReminderNotFound      = errors.New("reminder not found")
+	ErrNotificationNotFound  = errors.New("notification not found")
+	ErrNotificationNotQueued = model.ErrNotificationNotQueued
+	ErrAttachmentNotFound    = errors.New("attachment not found")
+	ErrQuotaExceeded         = model.ErrQuotaExceeded
 )
 
+const errTooManyAttempts = "too many attempts"
+
 type EventDTO struct {
 	ID          sql.NullInt64
 	UserID      sql.NullInt64
@@ -28,7 +37,8 @@ type EventDTO struct {
 	Description sql.NullString
 	OnTime      sql.NullTime
 	OffTime     sql.NullTime
-	NotifyTime  sql.NullTime
+	AllDay      sql.NullBool
+	Tags        sql.NullString
 }
 
 func GetEvent(e EventDTO) (event model.Event) {
@@ -56,12 +66,71 @@ func GetEvent(e EventDTO) (event model.Event) {
 		event.OffTime = e.OffTime.Time
 	}
 
-	if e.NotifyTime.Valid {
-		event.NotifyTime = e.NotifyTime.Time
+	event.AllDay = e.AllDay.Bool
+
+	if e.Tags.Valid && e.Tags.String != "" {
+		event.Tags = strings.Split(e.Tags.String, ",")
 	}
 	return event
 }
 
+// ReminderDTO is nullable as reminders are left joined to events.
+type ReminderDTO struct {
+	ID         sql.NullInt64
+	OffsetSec  sql.NullInt64
+	Channel    sql.NullString
+	NotifyTime sql.NullTime
+	Notified   sql.NullBool
+}
+
+func GetReminder(r ReminderDTO) (reminder model.Reminder, ok bool) {
+	if !r.ID.Valid {
+		return reminder, false
+	}
+	return model.Reminder{
+		ID:         r.ID.Int64,
+		Offset:     time.Duration(r.OffsetSec.Int64) * time.Second,
+		Channel:    r.Channel.String,
+		NotifyTime: r.NotifyTime.Time,
+		Notified:   r.Notified.Bool,
+	}, true
+}
+
+// selectTags is the comma separated tags of the event e, tags have no commas.
+const selectTags = `(SELECT string_agg(t.tag, ',' ORDER BY t.tag) FROM event_tags t WHERE t.eventid = e.id)`
+
+const selectEvents = `SELECT e.id, e.userid, e.title, e.description, e.ontime, e.offtime, e.allday,
+						r.id, r.offsetsec, r.channel, r.notifytime, r.notified, ` + selectTags + `
+					  FROM events e LEFT JOIN reminders r ON r.eventid = e.id`
+
+// scanEvents reads rows of selectEvents ordered by event ID, every event
+// comes in as many rows as it has reminders.
+func scanEvents(rows *sql.Rows) (events []model.Event, err error) {
+	var eSQL EventDTO
+	var rSQL ReminderDTO
+
+	for rows.Next() {
+		if err := rows.Scan(&eSQL.ID, &eSQL.UserID, &eSQL.Title, &eSQL.Description,
+			&eSQL.OnTime, &eSQL.OffTime, &eSQL.AllDay,
+			&rSQL.ID, &rSQL.OffsetSec, &rSQL.Channel, &rSQL.NotifyTime, &rSQL.Notified, &eSQL.Tags); err != nil {
+			return events, fmt.Errorf("failed rows.Scan: %w", err)
+		}
+		if len(events) == 0 || events[len(events)-1].ID != eSQL.ID.Int64 {
+			events = append(events, GetEvent(eSQL))
+		}
+		if r, ok := GetReminder(rSQL); ok {
+			e := &events[len(events)-1]
+			e.Reminders = append(e.Reminders, r)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return events, fmt.Errorf("failed lookup event: %w", err)
+	}
+
+	return events, nil
+}
+
 func New(dsn string) *Storage {
 	return &Storage{dsn: dsn}
 }
@@ -104,38 +173,68 @@ func stringValue(s string) sql.NullString {
 	return sql.NullString{String: s, Valid: true}
 }
 
-func (s *Storage) InsertEvent(ctx context.Context, e *model.Event) error {
-	query := `INSERT INTO events (userid, title, description, ontime, offtime, notifytime)
+func (s *Storage) InsertEvent(ctx context.Context, e *model.Event) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `INSERT INTO events (userid, title, description, ontime, offtime, allday)
 						  values ($1, $2, $3, $4, $5, $6) RETURNING id`
 
-	row := s.db.QueryRowContext(ctx, query, e.UserID, stringValue(e.Title),
-		stringValue(e.Description), timeValue(e.OnTime), timeValue(e.OffTime),
-		timeValue(e.NotifyTime))
+	row := tx.QueryRowContext(ctx, query, e.UserID, stringValue(e.Title),
+		stringValue(e.Description), timeValue(e.OnTime), timeValue(e.OffTime), e.AllDay)
 
-	if err := row.Scan(&e.ID); err != nil {
+	if err = row.Scan(&e.ID); err != nil {
 		return fmt.Errorf("failed rows.Scan11: %w", err)
 	}
 
-	if err := row.Err(); err != nil {
-		return fmt.Errorf("failed rows.Next: %w", err)
+	reminders := model.MergeReminders(nil, e.Reminders)
+	if err = s.saveReminders(ctx, tx, e.ID, nil, reminders); err != nil {
+		return err
+	}
+	if err = s.saveTags(ctx, tx, e.ID, e.Tags, false); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed commit tx: %w", err)
 	}
+	e.Reminders = reminders
 
 	return nil
 }
 
-func (s *Storage) UpdateEvent(ctx context.Context, e *model.Event) error {
+// UpdateEvent keeps the state of reminders with the same offset and channel,
+// reminders that are gone are deleted with their notifications.
+func (s *Storage) UpdateEvent(ctx context.Context, e *model.Event) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
 	query := `UPDATE events SET userid = $2,
 								title = $3,
 								description = $4,
 								ontime = $5,
 								offtime = $6,
-								notifytime = $7
+								allday = $7
 	          WHERE id = $1`
 
-	res, err := s.db.ExecContext(ctx, query, e.ID, e.UserID, e.Title, e.Description,
+	res, err := tx.ExecContext(ctx, query, e.ID, e.UserID, e.Title, e.Description,
 		timeValue(e.OnTime),
 		timeValue(e.OffTime),
-		timeValue(e.NotifyTime))
+		e.AllDay)
 	if err != nil {
 		return fmt.Errorf("failed update event: %w", err)
 	}
@@ -149,9 +248,132 @@ func (s *Storage) UpdateEvent(ctx context.Context, e *model.Event) error {
 		return fmt.Errorf("failed rowsAffected: %v", rowsAffected)
 	}
 
+	stored, err := s.lockReminders(ctx, tx, e.ID)
+	if err != nil {
+		return err
+	}
+	reminders := model.MergeReminders(stored, e.Reminders)
+	if err = s.saveReminders(ctx, tx, e.ID, stored, reminders); err != nil {
+		return err
+	}
+	if err = s.saveTags(ctx, tx, e.ID, e.Tags, true); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed commit tx: %w", err)
+	}
+	e.Reminders = reminders
+
+	return nil
+}
+
+func (s *Storage) lockReminders(ctx context.Context, tx *sql.Tx, eventID int64) (reminders []model.Reminder, err error) {
+	query := `SELECT id, offsetsec, channel, notifytime, notified
+			  FROM reminders
+			  WHERE eventid = $1
+			  FOR UPDATE`
+
+	rows, err := tx.QueryContext(ctx, query, eventID)
+	if err != nil {
+		return reminders, fmt.Errorf("failed lookup reminders: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rSQL ReminderDTO
+		if err := rows.Scan(&rSQL.ID, &rSQL.OffsetSec, &rSQL.Channel, &rSQL.NotifyTime, &rSQL.Notified); err != nil {
+			return reminders, fmt.Errorf("failed rows.Scan: %w", err)
+		}
+		if r, ok := GetReminder(rSQL); ok {
+			reminders = append(reminders, r)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return reminders, fmt.Errorf("failed lookup reminders: %w", err)
+	}
+
+	return reminders, nil
+}
+
+// saveReminders inserts reminders with zero IDs, updates the others and
+// deletes the stored ones that are not in reminders.
+func (s *Storage) saveReminders(ctx context.Context, tx *sql.Tx, eventID int64,
+	stored, reminders []model.Reminder,
+) error {
+	kept := map[int64]bool{}
+	for i := range reminders {
+		r := &reminders[i]
+		offset := int64(r.Offset / time.Second)
+		if r.ID != 0 {
+			kept[r.ID] = true
+			query := `UPDATE reminders SET notifytime = $2, notified = $3
+					  WHERE id = $1`
+			if _, err := tx.ExecContext(ctx, query, r.ID, r.NotifyTime, r.Notified); err != nil {
+				return fmt.Errorf("failed update reminder: %w", err)
+			}
+			continue
+		}
+
+		query := `INSERT INTO reminders (eventid, offsetsec, channel, notifytime)
+				  VALUES ($1, $2, $3, $4) RETURNING id`
+		if err := tx.QueryRowContext(ctx, query, eventID, offset, r.Channel, r.NotifyTime).Scan(&r.ID); err != nil {
+			return fmt.Errorf("failed insert reminder: %w", err)
+		}
+	}
+
+	for _, r := range stored {
+		if kept[r.ID] {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM reminders WHERE id = $1`, r.ID); err != nil {
+			return fmt.Errorf("failed delete reminder: %w", err)
+		}
+	}
 	return nil
 }
 
+// saveTags inserts the tags of the event, replacing the stored ones if replace is set.
+func (s *Storage) saveTags(ctx context.Context, tx *sql.Tx, eventID int64, tags []string, replace bool) error {
+	if replace {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM event_tags WHERE eventid = $1`, eventID); err != nil {
+			return fmt.Errorf("failed delete tags: %w", err)
+		}
+	}
+	for _, tag := range tags {
+		query := `INSERT INTO event_tags (eventid, tag) VALUES ($1, $2)`
+		if _, err := tx.ExecContext(ctx, query, eventID, tag); err != nil {
+			return fmt.Errorf("failed insert tag: %w", err)
+		}
+	}
+	return nil
+}
+
+// tagFilterSQL returns the conditions of the filter on the event e, their
+// arguments are appended to args.
+func tagFilterSQL(filter model.TagFilter, args []interface{}) (string, []interface{}) {
+	params := func(tags []string) string {
+		p := make([]string, len(tags))
+		for i, tag := range tags {
+			args = append(args, tag)
+			p[i] = fmt.Sprintf("$%d", len(args))
+		}
+		return strings.Join(p, ", ")
+	}
+
+	var b strings.Builder
+	if len(filter.Any) > 0 {
+		fmt.Fprintf(&b, ` AND EXISTS (SELECT 1 FROM event_tags t WHERE t.eventid = e.id AND t.tag IN (%s))`,
+			params(filter.Any))
+	}
+	if len(filter.All) > 0 {
+		fmt.Fprintf(&b, ` AND (SELECT count(*) FROM event_tags t WHERE t.eventid = e.id AND t.tag IN (%s)) = %d`,
+			params(filter.All), len(filter.All))
+	}
+	return b.String(), args
+}
+
 func (s *Storage) DeleteEvent(ctx context.Context, id int64) error {
 	query := `DELETE FROM events
 	          WHERE id = $1`
@@ -163,98 +385,168 @@ func (s *Storage) DeleteEvent(ctx context.Context, id int64) error {
 	return nil
 }
 
-func (s *Storage) ListEvents(ctx context.Context, userID int64) (events []model.Event, err error) {
-	var e model.Event
-	var eSQL EventDTO
+func (s *Storage) ListEvents(ctx context.Context, userID int64, filter model.TagFilter) (events []model.Event, err error) {
+	where, args := tagFilterSQL(filter, []interface{}{userID})
+	query := selectEvents + `
+			  WHERE e.userid = $1` + where + `
+			  ORDER BY e.id, r.id`
 
-	query := `SELECT id, userid, title, description, ontime, offtime, notifytime
-	          FROM events
-			  WHERE userid = $1`
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return events, fmt.Errorf("failed lookup event: %w", err)
+	}
+	defer rows.Close()
 
-	rows, err := s.db.QueryContext(ctx, query, userID)
+	return scanEvents(rows)
+}
+
+// ListEventsRange matches all-day events by the dates of begin and end in
+// their own locations, so the days are the ones of the viewer.
+func (s *Storage) ListEventsRange(ctx context.Context, userID int64, begin, end time.Time,
+	filter model.TagFilter,
+) ([]model.Event, error) {
+	var events []model.Event
+
+	where, args := tagFilterSQL(filter, []interface{}{userID, begin, end, model.Date(begin), model.Date(end)})
+	query := selectEvents + `
+			  WHERE e.userid = $1 AND
+			  (NOT e.allday AND (e.ontime BETWEEN $2 AND $3 OR e.offtime BETWEEN $2 AND $3) OR
+			   e.allday AND e.ontime <= $5 AND e.offtime > $4)` + where + `
+			  ORDER BY e.id, r.id`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return events, fmt.Errorf("failed lookup event: %w", err)
 	}
 	defer rows.Close()
 
+	return scanEvents(rows)
+}
+
+// ListUserTags counts the events of the user by tag, the most used first.
+func (s *Storage) ListUserTags(ctx context.Context, userID int64) (tags []model.TagCount, err error) {
+	query := `SELECT t.tag, count(*)
+			  FROM event_tags t JOIN events e ON e.id = t.eventid
+			  WHERE e.userid = $1
+			  GROUP BY t.tag
+			  ORDER BY count(*) DESC, t.tag`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return tags, fmt.Errorf("failed list tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags = []model.TagCount{}
 	for rows.Next() {
-		if err := rows.Scan(&eSQL.ID, &eSQL.UserID, &eSQL.Title, &eSQL.Description,
-			&eSQL.OnTime, &eSQL.OffTime, &eSQL.NotifyTime); err != nil {
-			return events, fmt.Errorf("failed rows.Scan: %w", err)
+		var tag model.TagCount
+		if err := rows.Scan(&tag.Tag, &tag.Count); err != nil {
+			return tags, fmt.Errorf("failed rows.Scan: %w", err)
 		}
-		e = GetEvent(eSQL)
-		events = append(events, e)
+		tags = append(tags, tag)
 	}
 
 	if err := rows.Err(); err != nil {
-		return events, fmt.Errorf("failed lookup event: %w", err)
+		return tags, fmt.Errorf("failed list tags: %w", err)
 	}
-
-	return events, err
+	return tags, nil
 }
 
-func (s *Storage) ListEventsRange(ctx context.Context, userID int64, begin, end time.Time) ([]model.Event, error) {
-	var e model.Event
-	var events []model.Event
-	var eSQL EventDTO
+// headlineTags turns the selectors of ts_headline into tags. The selectors
+// are control characters stripped from the text, so the headline is HTML
+// escaped first and the tags are its only markup.
+var headlineTags = strings.NewReplacer("\x02", "<b>", "\x03", "</b>")
 
-	query := `SELECT id, userid, title, description, ontime, offtime, notifytime
-	          FROM events
-			  WHERE userid = $1 AND 
-			  (ontime BETWEEN $2 AND $3 OR offtime BETWEEN $2 AND $3)`
+func headline(text string) string {
+	return headlineTags.Replace(html.EscapeString(text))
+}
 
-	rows, err := s.db.QueryContext(ctx, query, userID, begin, end)
+// SearchEvents ranks the events of the user matching the websearch query by
+// the search column, title words weigh more than description ones. Zero
+// bounds of the range are open.
+func (s *Storage) SearchEvents(ctx context.Context, userID int64, query string, begin, end time.Time,
+	filter model.TagFilter, limit int,
+) (results []model.SearchResult, err error) {
+	where, args := tagFilterSQL(filter, []interface{}{userID, query, timeValue(begin), timeValue(end), limit})
+	q := `SELECT e.id, e.userid, e.title, e.description, e.ontime, e.offtime, e.allday,
+				r.id, r.offsetsec, r.channel, r.notifytime, r.notified, ` + selectTags + `,
+				f.rank, f.title, f.snippet
+			  FROM (SELECT id, ts_rank(search, q) AS rank,
+						ts_headline('simple', translate(title, E'\x02\x03', ''), q,
+							E'HighlightAll=true, StartSel=\x02, StopSel=\x03') AS title,
+						ts_headline('simple', translate(coalesce(description, ''), E'\x02\x03', ''), q,
+							E'StartSel=\x02, StopSel=\x03') AS snippet
+					FROM events e, websearch_to_tsquery('simple', $2) q
+					WHERE userid = $1 AND search @@ q AND
+					($3::timestamp IS NULL OR offtime >= $3) AND
+					($4::timestamp IS NULL OR ontime <= $4)` + where + `
+					ORDER BY rank DESC, id
+					LIMIT $5) f
+			  JOIN events e ON e.id = f.id
+			  LEFT JOIN reminders r ON r.eventid = e.id
+			  ORDER BY f.rank DESC, e.id, r.id`
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
 	if err != nil {
-		return events, fmt.Errorf("failed lookup event: %w", err)
+		return results, fmt.Errorf("failed search events: %w", err)
 	}
 	defer rows.Close()
 
+	var eSQL EventDTO
+	var rSQL ReminderDTO
+	var found model.SearchResult
 	for rows.Next() {
 		if err := rows.Scan(&eSQL.ID, &eSQL.UserID, &eSQL.Title, &eSQL.Description,
-			&eSQL.OnTime, &eSQL.OffTime, &eSQL.NotifyTime); err != nil {
-			return events, fmt.Errorf("failed rows.Scan: %w", err)
+			&eSQL.OnTime, &eSQL.OffTime, &eSQL.AllDay,
+			&rSQL.ID, &rSQL.OffsetSec, &rSQL.Channel, &rSQL.NotifyTime, &rSQL.Notified, &eSQL.Tags,
+			&found.Rank, &found.Title, &found.Snippet); err != nil {
+			return results, fmt.Errorf("failed rows.Scan: %w", err)
+		}
+		if len(results) == 0 || results[len(results)-1].Event.ID != eSQL.ID.Int64 {
+			found.Event = GetEvent(eSQL)
+			found.Title, found.Snippet = headline(found.Title), headline(found.Snippet)
+			results = append(results, found)
+		}
+		if r, ok := GetReminder(rSQL); ok {
+			e := &results[len(results)-1].Event
+			e.Reminders = append(e.Reminders, r)
 		}
-		e = GetEvent(eSQL)
-		events = append(events, e)
 	}
 
 	if err := rows.Err(); err != nil {
-		return events, fmt.Errorf("failed lookup event: %w", err)
+		return results, fmt.Errorf("failed search events: %w", err)
 	}
-
-	return events, nil
+	return results, nil
 }
 
 func (s *Storage) LookupEvent(ctx context.Context, eID int64) (e model.Event, err error) {
-	var eSQL EventDTO
-	query := `SELECT id, userid, title, description, ontime, offtime, notifytime
-	          FROM events
-			  WHERE id = $1`
+	query := selectEvents + `
+			  WHERE e.id = $1
+			  ORDER BY e.id, r.id`
 
-	rows := s.db.QueryRowContext(ctx, query, eID)
-
-	if err := rows.Scan(&eSQL.ID, &eSQL.UserID, &eSQL.Title, &eSQL.Description,
-		&eSQL.OnTime, &eSQL.OffTime, &eSQL.NotifyTime); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return e, ErrEventNotFound
-		}
-		return e, fmt.Errorf("failed rows.Scan: %w", err)
+	rows, err := s.db.QueryContext(ctx, query, eID)
+	if err != nil {
+		return e, fmt.Errorf("failed lookup event: %w", err)
 	}
+	defer rows.Close()
 
-	if err := rows.Err(); err != nil {
-		return e, fmt.Errorf("failed rows.Next: %w", err)
+	events, err := scanEvents(rows)
+	if err != nil {
+		return e, err
+	}
+	if len(events) == 0 {
+		return e, ErrEventNotFound
 	}
 
-	e = GetEvent(eSQL)
-
-	return e, err
+	return events[0], nil
 }
 
+// IsBusyDateTimeRange ignores all-day events, they take dates rather than time.
 func (s *Storage) IsBusyDateTimeRange(ctx context.Context, id, userID int64, onTime, offTime time.Time) error {
 	var eSQL EventDTO
 	query := `SELECT id
 	          FROM events
-			  WHERE id != $1 AND userid = $2 AND
+			  WHERE id != $1 AND userid = $2 AND NOT allday AND
 			  (($3 BETWEEN ontime and offtime) OR
 			   ($4 BETWEEN ontime and offtime))`
 
@@ -274,14 +566,16 @@ func (s *Storage) IsBusyDateTimeRange(ctx context.Context, id, userID int64, onT
 	return ErrDataRangeIsBusy
 }
 
+// ListEventsDayOfNotice returns the events with reminders due by date, every
+// event has only its due reminders.
 func (s *Storage) ListEventsDayOfNotice(ctx context.Context, date time.Time) ([]model.Event, error) {
-	var e model.Event
 	var events []model.Event
-	var eSQL EventDTO
 
-	query := `SELECT id, userid, title, description, ontime, offtime, notifytime
-	          FROM events
-			  WHERE notified = false AND notifytime <= $1`
+	query := `SELECT e.id, e.userid, e.title, e.description, e.ontime, e.offtime, e.allday,
+				r.id, r.offsetsec, r.channel, r.notifytime, r.notified, ` + selectTags + `
+			  FROM events e JOIN reminders r ON r.eventid = e.id
+			  WHERE r.notified = false AND r.notifytime <= $1
+			  ORDER BY e.id, r.id`
 
 	rows, err := s.db.QueryContext(ctx, query, date)
 	if err != nil {
@@ -289,28 +583,212 @@ func (s *Storage) ListEventsDayOfNotice(ctx context.Context, date time.Time) ([]
 	}
 	defer rows.Close()
 
+	return scanEvents(rows)
+}
+
+func (s *Storage) UpdateEventNotified(ctx context.Context, reminderID int64) error {
+	query := `UPDATE reminders SET notified = true WHERE id = $1`
+
+	res, err := s.db.ExecContext(ctx, query, reminderID)
+	if err != nil {
+		return fmt.Errorf("failed update reminder: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed get RowsAffected: %w", err)
+	}
+
+	if rowsAffected != 1 {
+		return ErrReminderNotFound
+	}
+
+	return nil
+}
+
+// ListExpiredEvents returns up to limit events after afterID ended before
+// the date, or before date minus own retention for users who have one.
+func (s *Storage) ListExpiredEvents(ctx context.Context, date, before time.Time, afterID int64,
+	limit int,
+) ([]model.Event, error) {
+	query := selectEvents + `
+			  WHERE e.id IN (
+			      SELECT ev.id FROM events ev
+			      LEFT JOIN user_retention ur ON ur.userid = ev.userid
+			      WHERE ev.id > $3 AND
+			            ev.offtime < COALESCE($1::timestamp - ur.retentionsec * INTERVAL '1 second', $2::timestamp)
+			      ORDER BY ev.id
+			      LIMIT $4)
+			  ORDER BY e.id, r.id`
+
+	rows, err := s.db.QueryContext(ctx, query, date, before, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed list expired events: %w", err)
+	}
+	defer rows.Close()
+
+	return scanEvents(rows)
+}
+
+func (s *Storage) DeleteEvents(ctx context.Context, ids []int64) (deleted int64, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, id := range ids {
+		res, err := tx.ExecContext(ctx, `DELETE FROM events WHERE id = $1`, id)
+		if err != nil {
+			return 0, fmt.Errorf("failed delete event: %w", err)
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed get RowsAffected: %w", err)
+		}
+		deleted += rowsAffected
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed commit tx: %w", err)
+	}
+	return deleted, nil
+}
+
+// EnqueueNotifications moves due reminders to the notifications outbox. Marking
+// the reminder and inserting the outbox entry is a single statement, so a
+// reminder is never lost or enqueued twice.
+func (s *Storage) EnqueueNotifications(ctx context.Context, date time.Time) (int64, error) {
+	query := `WITH due AS (
+				UPDATE reminders SET notified = true
+				WHERE notified = false AND notifytime <= $1
+				RETURNING id, eventid, channel, notifytime)
+			  INSERT INTO notifications (eventid, reminderid, channel, userid, title, ontime, notifytime)
+			  SELECT e.id, due.id, due.channel, e.userid, e.title, e.ontime, due.notifytime
+			  FROM due JOIN events e ON e.id = due.eventid
+			  ON CONFLICT (reminderid, notifytime) DO NOTHING`
+
+	res, err := s.db.ExecContext(ctx, query, date)
+	if err != nil {
+		return 0, fmt.Errorf("failed enqueue notifications: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed get RowsAffected: %w", err)
+	}
+
+	return rowsAffected, nil
+}
+
+// ClaimNotifications marks pending and timed out queued notifications as
+// queued and returns them. Notifications out of attempts become failed.
+func (s *Storage) ClaimNotifications(ctx context.Context, date time.Time, timeout time.Duration,
+	maxAttempts int,
+) (notifications []model.Notification, err error) {
+	expired := date.Add(-timeout)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return notifications, fmt.Errorf("failed begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	queryFail := `UPDATE notifications SET status = 'failed', lasterror = $3
+				  WHERE attempts >= $2 AND
+				  (status = 'pending' OR (status = 'queued' AND queuedat <= $1))`
+
+	if _, err = tx.ExecContext(ctx, queryFail, expired, maxAttempts, errTooManyAttempts); err != nil {
+		return notifications, fmt.Errorf("failed fail notifications: %w", err)
+	}
+
+	queryClaim := `UPDATE notifications SET status = 'queued', queuedat = $1, attempts = attempts + 1
+				   WHERE id IN (
+					SELECT id FROM notifications
+					WHERE status = 'pending' OR (status = 'queued' AND queuedat <= $2)
+					FOR UPDATE SKIP LOCKED)
+				   RETURNING id, eventid, reminderid, channel, userid, title, ontime, notifytime, attempts`
+
+	rows, err := tx.QueryContext(ctx, queryClaim, date, expired)
+	if err != nil {
+		return notifications, fmt.Errorf("failed claim notifications: %w", err)
+	}
+	defer rows.Close()
+
 	for rows.Next() {
-		if err := rows.Scan(&eSQL.ID, &eSQL.UserID, &eSQL.Title, &eSQL.Description,
-			&eSQL.OnTime, &eSQL.OffTime, &eSQL.NotifyTime); err != nil {
-			return events, fmt.Errorf("failed rows.Scan: %w", err)
+		var reminderID sql.NullInt64
+		n := model.Notification{Status: model.NotificationQueued, QueuedAt: date}
+		if err = rows.Scan(&n.ID, &n.EventID, &reminderID, &n.Channel, &n.UserID, &n.Title, &n.Date,
+			&n.NotifyTime, &n.Attempts); err != nil {
+			return notifications, fmt.Errorf("failed rows.Scan: %w", err)
 		}
-		e = GetEvent(eSQL)
-		events = append(events, e)
+		n.ReminderID = reminderID.Int64
+		notifications = append(notifications, n)
 	}
 
-	if err := rows.Err(); err != nil {
-		return events, fmt.Errorf("failed lookup event: %w", err)
+	if err = rows.Err(); err != nil {
+		return notifications, fmt.Errorf("failed claim notifications: %w", err)
 	}
 
-	return events, nil
+	if err = tx.Commit(); err != nil {
+		return notifications, fmt.Errorf("failed commit tx: %w", err)
+	}
+
+	return notifications, nil
 }
 
-func (s *Storage) UpdateEventNotified(ctx context.Context, eventid int64) error {
-	query := `UPDATE events SET notified = true WHERE id = $1`
+// ListDueNotifications returns the notifications ClaimNotifications would
+// claim, changing nothing.
+func (s *Storage) ListDueNotifications(ctx context.Context, date time.Time, timeout time.Duration,
+	maxAttempts int,
+) ([]model.Notification, error) {
+	var notifications []model.Notification
+
+	query := `SELECT id, eventid, reminderid, channel, userid, title, ontime, notifytime, status, attempts
+			  FROM notifications
+			  WHERE attempts < $2 AND (status = 'pending' OR (status = 'queued' AND queuedat <= $1))
+			  ORDER BY id`
 
-	res, err := s.db.ExecContext(ctx, query, eventid)
+	rows, err := s.db.QueryContext(ctx, query, date.Add(-timeout), maxAttempts)
 	if err != nil {
-		return fmt.Errorf("failed update event: %w", err)
+		return notifications, fmt.Errorf("failed list notifications: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reminderID sql.NullInt64
+		var n model.Notification
+		if err := rows.Scan(&n.ID, &n.EventID, &reminderID, &n.Channel, &n.UserID, &n.Title, &n.Date,
+			&n.NotifyTime, &n.Status, &n.Attempts); err != nil {
+			return notifications, fmt.Errorf("failed rows.Scan: %w", err)
+		}
+		n.ReminderID = reminderID.Int64
+		notifications = append(notifications, n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return notifications, fmt.Errorf("failed list notifications: %w", err)
+	}
+	return notifications, nil
+}
+
+func (s *Storage) setQueuedNotificationStatus(ctx context.Context, id int64, status model.NotificationStatus,
+	reason string,
+) error {
+	query := `UPDATE notifications SET status = $2, lasterror = $3
+			  WHERE id = $1 AND status = 'queued'`
+
+	res, err := s.db.ExecContext(ctx, query, id, status, stringValue(reason))
+	if err != nil {
+		return fmt.Errorf("failed update notification: %w", err)
 	}
 
 	rowsAffected, err := res.RowsAffected()
@@ -319,25 +797,404 @@ func (s *Storage) UpdateEventNotified(ctx context.Context, eventid int64) error
 	}
 
 	if rowsAffected != 1 {
-		return fmt.Errorf("failed rowsAffected: %v", rowsAffected)
+		return ErrNotificationNotQueued
 	}
 
 	return nil
 }
 
-func (s *Storage) DeleteEventsOlderDate(ctx context.Context, date time.Time) (int64, error) {
-	query := `DELETE FROM events
-	          WHERE offtime < $1`
+func (s *Storage) ReleaseNotification(ctx context.Context, id int64, reason string) error {
+	return s.setQueuedNotificationStatus(ctx, id, model.NotificationPending, reason)
+}
 
-	res, err := s.db.ExecContext(ctx, query, date)
+func (s *Storage) DeliverNotification(ctx context.Context, id int64) error {
+	return s.setQueuedNotificationStatus(ctx, id, model.NotificationDelivered, "")
+}
+
+func (s *Storage) FailNotification(ctx context.Context, id int64, reason string) error {
+	return s.setQueuedNotificationStatus(ctx, id, model.NotificationFailed, reason)
+}
+
+func (s *Storage) LookupNotification(ctx context.Context, id int64) (n model.Notification, err error) {
+	var reminderID sql.NullInt64
+	var queuedAt sql.NullTime
+	var lastError sql.NullString
+
+	query := `SELECT id, eventid, reminderid, channel, userid, title, ontime, notifytime, status, attempts,
+				queuedat, lasterror
+			  FROM notifications
+			  WHERE id = $1`
+
+	row := s.db.QueryRowContext(ctx, query, id)
+
+	if err := row.Scan(&n.ID, &n.EventID, &reminderID, &n.Channel, &n.UserID, &n.Title, &n.Date,
+		&n.NotifyTime, &n.Status, &n.Attempts, &queuedAt, &lastError); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return n, ErrNotificationNotFound
+		}
+		return n, fmt.Errorf("failed rows.Scan: %w", err)
+	}
+
+	n.ReminderID = reminderID.Int64
+	n.QueuedAt = queuedAt.Time
+	n.LastError = lastError.String
+
+	return n, nil
+}
+
+// AcquireLease takes or renews the named lease for holder until ttl passes.
+// It returns false while another holder has an unexpired lease. Expiration
+// is counted by the database clock, so replicas' clocks may differ.
+func (s *Storage) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	var leaseHolder string
+
+	query := `INSERT INTO leases (name, holder, expiresat)
+			  VALUES ($1, $2, now() + $3 * interval '1 millisecond')
+			  ON CONFLICT (name) DO UPDATE SET holder = $2, expiresat = now() + $3 * interval '1 millisecond'
+			  WHERE leases.holder = $2 OR leases.expiresat < now()
+			  RETURNING holder`
+
+	row := s.db.QueryRowContext(ctx, query, name, holder, ttl.Milliseconds())
+	if err := row.Scan(&leaseHolder); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed acquire lease: %w", err)
+	}
+
+	return leaseHolder == holder, nil
+}
+
+func (s *Storage) ReleaseLease(ctx context.Context, name, holder string) error {
+	query := `DELETE FROM leases
+			  WHERE name = $1 AND holder = $2`
+
+	if _, err := s.db.ExecContext(ctx, query, name, holder); err != nil {
+		return fmt.Errorf("failed release lease: %w", err)
+	}
+
+	return nil
+}
+
+// SetUserChannels replaces the delivery channels of the user.
+func (s *Storage) SetUserChannels(ctx context.Context, userID int64, channels []model.UserChannel) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, fmt.Errorf("failed delete event: %w", err)
+		return fmt.Errorf("failed begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM user_channels WHERE userid = $1`, userID); err != nil {
+		return fmt.Errorf("failed delete user channels: %w", err)
+	}
+
+	query := `INSERT INTO user_channels (userid, channel, address, locale, timezone)
+			  VALUES ($1, $2, $3, $4, $5)
+			  ON CONFLICT DO NOTHING`
+
+	for _, c := range channels {
+		if _, err = tx.ExecContext(ctx, query, userID, c.Channel, c.Address, c.Locale, c.TimeZone); err != nil {
+			return fmt.Errorf("failed insert user channel: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed commit tx: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) ListUserChannels(ctx context.Context, userID int64) (channels []model.UserChannel, err error) {
+	query := `SELECT userid, channel, address, locale, timezone
+			  FROM user_channels
+			  WHERE userid = $1
+			  ORDER BY channel, address`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return channels, fmt.Errorf("failed list user channels: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c model.UserChannel
+		if err := rows.Scan(&c.UserID, &c.Channel, &c.Address, &c.Locale, &c.TimeZone); err != nil {
+			return channels, fmt.Errorf("failed rows.Scan: %w", err)
+		}
+		channels = append(channels, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return channels, fmt.Errorf("failed list user channels: %w", err)
+	}
+
+	return channels, nil
+}
+
+// ClaimDedupKey takes the key for lease, so the notification is processed by
+// one sender at a time. A key that is taken or already processed is not
+// claimed until the lease or the TTL of CompleteDedupKey expires.
+func (s *Storage) ClaimDedupKey(ctx context.Context, key string, lease time.Duration) (model.DedupState, error) {
+	query := `INSERT INTO dedup_keys (key, done, expiresat)
+			  VALUES ($1, false, now() + $2 * interval '1 millisecond')
+			  ON CONFLICT (key) DO UPDATE SET done = false, expiresat = now() + $2 * interval '1 millisecond'
+			  WHERE dedup_keys.expiresat < now()
+			  RETURNING key`
+
+	var claimed string
+	err := s.db.QueryRowContext(ctx, query, key, lease.Milliseconds()).Scan(&claimed)
+	switch {
+	case err == nil:
+		return model.DedupClaimed, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return model.DedupPending, fmt.Errorf("failed claim dedup key: %w", err)
+	}
+
+	// a key released since is pending, the sender that held it retries
+	var done bool
+	err = s.db.QueryRowContext(ctx, `SELECT done FROM dedup_keys WHERE key = $1`, key).Scan(&done)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return model.DedupPending, nil
+	case err != nil:
+		return model.DedupPending, fmt.Errorf("failed lookup dedup key: %w", err)
+	case done:
+		return model.DedupDone, nil
+	}
+	return model.DedupPending, nil
+}
+
+func (s *Storage) CompleteDedupKey(ctx context.Context, key string, ttl time.Duration) error {
+	query := `UPDATE dedup_keys SET done = true, expiresat = now() + $2 * interval '1 millisecond'
+			  WHERE key = $1`
+
+	if _, err := s.db.ExecContext(ctx, query, key, ttl.Milliseconds()); err != nil {
+		return fmt.Errorf("failed complete dedup key: %w", err)
+	}
+	return nil
+}
+
+// ReleaseDedupKey lets a failed notification be processed again.
+func (s *Storage) ReleaseDedupKey(ctx context.Context, key string) error {
+	query := `DELETE FROM dedup_keys WHERE key = $1 AND NOT done`
+
+	if _, err := s.db.ExecContext(ctx, query, key); err != nil {
+		return fmt.Errorf("failed release dedup key: %w", err)
+	}
+	return nil
+}
+
+// PurgeDedupKeys deletes the expired keys. The expiry is set by now() of the
+// database, so it is compared with now() rather than a job date: a run-once
+// for a future date must not drop the keys of messages still in flight.
+func (s *Storage) PurgeDedupKeys(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM dedup_keys WHERE expiresat < now()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed purge dedup keys: %w", err)
 	}
 
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
 		return 0, fmt.Errorf("failed get RowsAffected: %w", err)
 	}
-
 	return rowsAffected, nil
 }
+
+// SetUserRetention keeps events of the user for retention after they end,
+// zero restores the global retention.
+func (s *Storage) SetUserRetention(ctx context.Context, userID int64, retention time.Duration) error {
+	query := `INSERT INTO user_retention (userid, retentionsec)
+			  VALUES ($1, $2)
+			  ON CONFLICT (userid) DO UPDATE SET retentionsec = EXCLUDED.retentionsec`
+	args := []interface{}{userID, int64(retention / time.Second)}
+	if retention == 0 {
+		query, args = `DELETE FROM user_retention WHERE userid = $1`, args[:1]
+	}
+
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed set user retention: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) LookupUserRetention(ctx context.Context, userID int64) (time.Duration, error) {
+	query := `SELECT retentionsec FROM user_retention WHERE userid = $1`
+
+	var sec int64
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&sec)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return 0, nil
+	case err != nil:
+		return 0, fmt.Errorf("failed lookup user retention: %w", err)
+	}
+	return time.Duration(sec) * time.Second, nil
+}
+
+func (s *Storage) InsertDeliveryFailure(ctx context.Context, f *model.DeliveryFailure) error {
+	query := `INSERT INTO delivery_failures (notificationid, channel, address, error, failedat)
+			  VALUES ($1, $2, $3, $4, $5)`
+
+	if _, err := s.db.ExecContext(ctx, query, f.NotificationID, f.Channel, f.Address, f.Error,
+		f.FailedAt); err != nil {
+		return fmt.Errorf("failed insert delivery failure: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ListDeliveryFailures(ctx context.Context, notificationID int64,
+) (failures []model.DeliveryFailure, err error) {
+	query := `SELECT notificationid, channel, address, error, failedat
+			  FROM delivery_failures
+			  WHERE notificationid = $1
+			  ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, query, notificationID)
+	if err != nil {
+		return failures, fmt.Errorf("failed list delivery failures: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var f model.DeliveryFailure
+		if err := rows.Scan(&f.NotificationID, &f.Channel, &f.Address, &f.Error, &f.FailedAt); err != nil {
+			return failures, fmt.Errorf("failed rows.Scan: %w", err)
+		}
+		failures = append(failures, f)
+	}
+
+	if err := rows.Err(); err != nil {
+		return failures, fmt.Errorf("failed list delivery failures: %w", err)
+	}
+
+	return failures, nil
+}
+
+// InsertAttachment inserts the attachment only if the attachments of the user
+// still fit quota bytes with it. The usage is summed under a transaction lock
+// of the user, so concurrent uploads can't both fit the quota.
+func (s *Storage) InsertAttachment(ctx context.Context, a *model.Attachment, quota int64) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, a.UserID); err != nil {
+		return fmt.Errorf("failed lock user: %w", err)
+	}
+
+	query := `INSERT INTO attachments (eventid, userid, name, size, contenttype, checksum, blobkey, createdat)
+			  SELECT $1, $2, $3, $4, $5, $6, $7, $8
+			  WHERE coalesce((SELECT sum(size) FROM attachments WHERE userid = $2), 0) + $4 <= $9
+			  RETURNING id`
+
+	err = tx.QueryRowContext(ctx, query, a.EventID, a.UserID, a.Name, a.Size, a.ContentType, a.Checksum,
+		a.Key, a.CreatedAt, quota).Scan(&a.ID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = ErrQuotaExceeded
+		return err
+	case err != nil:
+		return fmt.Errorf("failed insert attachment: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed commit tx: %w", err)
+	}
+	return nil
+}
+
+const selectAttachments = `SELECT id, eventid, userid, name, size, contenttype, checksum, blobkey, createdat
+			  FROM attachments`
+
+func scanAttachment(row interface{ Scan(...interface{}) error }) (a model.Attachment, err error) {
+	err = row.Scan(&a.ID, &a.EventID, &a.UserID, &a.Name, &a.Size, &a.ContentType, &a.Checksum, &a.Key,
+		&a.CreatedAt)
+	return a, err
+}
+
+func (s *Storage) LookupAttachment(ctx context.Context, id int64) (model.Attachment, error) {
+	query := selectAttachments + `
+			  WHERE id = $1`
+
+	a, err := scanAttachment(s.db.QueryRowContext(ctx, query, id))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return a, ErrAttachmentNotFound
+	case err != nil:
+		return a, fmt.Errorf("failed rows.Scan: %w", err)
+	}
+	return a, nil
+}
+
+func (s *Storage) ListAttachments(ctx context.Context, eventID int64) (attachments []model.Attachment, err error) {
+	query := selectAttachments + `
+			  WHERE eventid = $1
+			  ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, query, eventID)
+	if err != nil {
+		return attachments, fmt.Errorf("failed list attachments: %w", err)
+	}
+	defer rows.Close()
+
+	attachments = []model.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return attachments, fmt.Errorf("failed rows.Scan: %w", err)
+		}
+		attachments = append(attachments, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return attachments, fmt.Errorf("failed list attachments: %w", err)
+	}
+	return attachments, nil
+}
+
+func (s *Storage) DeleteAttachment(ctx context.Context, id int64) error {
+	query := `DELETE FROM attachments WHERE id = $1`
+
+	result, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed delete attachment: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed delete attachment: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrAttachmentNotFound
+	}
+	return nil
+}
+
+func (s *Storage) UserAttachmentsSize(ctx context.Context, userID int64) (size int64, err error) {
+	query := `SELECT coalesce(sum(size), 0) FROM attachments WHERE userid = $1`
+
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&size); err != nil {
+		return 0, fmt.Errorf("failed sum attachments: %w", err)
+	}
+	return size, nil
+}
+
+// HasAttachmentKey tells whether a blob is still referenced.
+func (s *Storage) HasAttachmentKey(ctx context.Context, key string) (found bool, err error) {
+	query := `SELECT EXISTS (SELECT 1 FROM attachments WHERE blobkey = $1)`
+
+	if err := s.db.QueryRowContext(ctx, query, key).Scan(&found); err != nil {
+		return false, fmt.Errorf("failed lookup attachment key: %w", err)
+	}
+	return found, nil
+}
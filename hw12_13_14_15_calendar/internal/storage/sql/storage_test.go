@@ -10,15 +10,21 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+var eventColumns = []string{
+	"id", "userid", "title", "description", "ontime", "offtime", "allday",
+	"reminderid", "offsetsec", "channel", "notifytime", "notified", "tags",
+}
+
 func TestSqlStorage(t *testing.T) {
+	onTime := time.Now()
 	event := model.Event{
 		ID:          0,
 		UserID:      1,
 		Title:       "TitleN1",
 		Description: "DescriptionN1",
-		OnTime:      time.Now(),
-		OffTime:     time.Now().AddDate(0, 0, 7),
-		NotifyTime:  time.Time{},
+		OnTime:      onTime,
+		OffTime:     onTime.AddDate(0, 0, 7),
+		Reminders:   []model.Reminder{{Offset: time.Hour, NotifyTime: onTime.Add(-time.Hour)}},
 	}
 
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
@@ -30,15 +36,22 @@ func TestSqlStorage(t *testing.T) {
 	storage := Storage{dsn: "", db: db}
 
 	t.Run("case_insert", func(t *testing.T) {
-		mock.ExpectQuery(`INSERT INTO events (userid, title, description, ontime, offtime, notifytime)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO events (userid, title, description, ontime, offtime, allday)
 		                              values ($1, $2, $3, $4, $5, $6) RETURNING id`).
 			WithArgs(event.UserID, event.Title, event.Description,
-				timeValue(event.OnTime), timeValue(event.OffTime), timeValue(event.NotifyTime)).
+				timeValue(event.OnTime), timeValue(event.OffTime), false).
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1"))
+		mock.ExpectQuery(`INSERT INTO reminders (eventid, offsetsec, channel, notifytime)
+						  VALUES ($1, $2, $3, $4) RETURNING id`).
+			WithArgs(1, 3600, "", event.Reminders[0].NotifyTime).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("7"))
+		mock.ExpectCommit()
 
 		err = storage.InsertEvent(context.Background(), &event)
 		require.NoError(t, err)
 		require.EqualValues(t, event.ID, int64(1))
+		require.EqualValues(t, 7, event.Reminders[0].ID)
 
 		if err := mock.ExpectationsWereMet(); err != nil {
 			t.Errorf("there were unfulfilled expectations: %s", err)
@@ -47,20 +60,55 @@ func TestSqlStorage(t *testing.T) {
 
 	t.Run("case_update", func(t *testing.T) {
 		event.UserID = 400
+		event.OnTime = onTime.Add(time.Hour)
+		event.Reminders = []model.Reminder{
+			{Offset: time.Hour, NotifyTime: onTime},
+			{Offset: 10 * time.Minute, Channel: "email", NotifyTime: onTime.Add(50 * time.Minute)},
+		}
+		event.Tags = []string{"project-x", "urgent"}
+		mock.ExpectBegin()
 		mock.ExpectExec(`UPDATE events
 						 SET userid = $2,
 						 	 title = $3,
 							 description = $4,
 							 ontime = $5,
 							 offtime = $6,
-							 notifytime = $7
+							 allday = $7
 						WHERE id = $1`).
 			WithArgs(event.ID, event.UserID, event.Title, event.Description,
-				timeValue(event.OnTime), timeValue(event.OffTime), timeValue(event.NotifyTime)).
+				timeValue(event.OnTime), timeValue(event.OffTime), false).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`SELECT id, offsetsec, channel, notifytime, notified
+						  FROM reminders WHERE eventid = $1 FOR UPDATE`).
+			WithArgs(event.ID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "offsetsec", "channel", "notifytime", "notified"}).
+				AddRow(7, 3600, "", onTime.Add(-time.Hour), true).
+				AddRow(8, 86400, "", onTime.Add(-24*time.Hour), true))
+		mock.ExpectExec(`UPDATE reminders SET notifytime = $2, notified = $3 WHERE id = $1`).
+			WithArgs(7, onTime, false).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`INSERT INTO reminders (eventid, offsetsec, channel, notifytime)
+						  VALUES ($1, $2, $3, $4) RETURNING id`).
+			WithArgs(event.ID, 600, "email", onTime.Add(50*time.Minute)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("9"))
+		mock.ExpectExec(`DELETE FROM reminders WHERE id = $1`).
+			WithArgs(8).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`DELETE FROM event_tags WHERE eventid = $1`).
+			WithArgs(event.ID).
 			WillReturnResult(sqlmock.NewResult(0, 1))
+		for _, tag := range event.Tags {
+			mock.ExpectExec(`INSERT INTO event_tags (eventid, tag) VALUES ($1, $2)`).
+				WithArgs(event.ID, tag).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectCommit()
 
 		err = storage.UpdateEvent(context.Background(), &event)
 		require.NoError(t, err)
+		require.EqualValues(t, 7, event.Reminders[0].ID, "moved reminder keeps its ID")
+		require.False(t, event.Reminders[0].Notified, "moved reminder is due again")
+		require.EqualValues(t, 9, event.Reminders[1].ID)
 
 		if err := mock.ExpectationsWereMet(); err != nil {
 			t.Errorf("there were unfulfilled expectations: %s", err)
@@ -84,16 +132,37 @@ func TestSqlStorage(t *testing.T) {
 	t.Run("case_lookup", func(t *testing.T) {
 		eID := int64(100)
 		userID := int64(200)
-		mock.ExpectQuery(`SELECT id, userid, title, description, ontime, offtime, notifytime
-						  FROM events WHERE id = $1`).
+		mock.ExpectQuery(`SELECT e.id, e.userid, e.title, e.description, e.ontime, e.offtime, e.allday,
+							r.id, r.offsetsec, r.channel, r.notifytime, r.notified, (SELECT string_agg(t.tag, ',' ORDER BY t.tag) FROM event_tags t WHERE t.eventid = e.id)
+						  FROM events e LEFT JOIN reminders r ON r.eventid = e.id
+						  WHERE e.id = $1 ORDER BY e.id, r.id`).
 			WithArgs(eID).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "userid", "title", "description", "ontime", "offtime", "notifytime"}).
+			WillReturnRows(sqlmock.NewRows(eventColumns).
 				AddRow(eID, userID, "TitleN100", "DescriptionN100",
-					timeValue(time.Now()), timeValue(time.Now().AddDate(0, 0, 7)), timeValue(time.Time{})))
+					timeValue(onTime), timeValue(onTime.AddDate(0, 0, 7)), false,
+					1, 600, "", timeValue(onTime.Add(-10*time.Minute)), false, "project-x,urgent").
+				AddRow(eID, userID, "TitleN100", "DescriptionN100",
+					timeValue(onTime), timeValue(onTime.AddDate(0, 0, 7)), false,
+					2, 86400, "email", timeValue(onTime.AddDate(0, 0, -1)), true, "project-x,urgent"))
 
 		eFound, err := storage.LookupEvent(context.Background(), eID)
 		require.NoError(t, err)
 		require.EqualValues(t, userID, eFound.UserID)
+		require.Len(t, eFound.Reminders, 2)
+		require.Equal(t, 10*time.Minute, eFound.Reminders[0].Offset)
+		require.Equal(t, "email", eFound.Reminders[1].Channel)
+		require.True(t, eFound.Reminders[1].Notified)
+		require.Equal(t, []string{"project-x", "urgent"}, eFound.Tags)
+
+		mock.ExpectQuery(`SELECT e.id, e.userid, e.title, e.description, e.ontime, e.offtime, e.allday,
+							r.id, r.offsetsec, r.channel, r.notifytime, r.notified, (SELECT string_agg(t.tag, ',' ORDER BY t.tag) FROM event_tags t WHERE t.eventid = e.id)
+						  FROM events e LEFT JOIN reminders r ON r.eventid = e.id
+						  WHERE e.id = $1 ORDER BY e.id, r.id`).
+			WithArgs(eID).
+			WillReturnRows(sqlmock.NewRows(eventColumns))
+
+		_, err = storage.LookupEvent(context.Background(), eID)
+		require.ErrorIs(t, err, ErrEventNotFound)
 
 		if err := mock.ExpectationsWereMet(); err != nil {
 			t.Errorf("there were unfulfilled expectations: %s", err)
@@ -104,19 +173,23 @@ func TestSqlStorage(t *testing.T) {
 		eID1 := int64(100)
 		eID2 := int64(101)
 		userID := int64(200)
-		mock.ExpectQuery("SELECT id, userid, title, description, ontime, offtime, notifytime FROM events WHERE userid = $1").
+		mock.ExpectQuery(`SELECT e.id, e.userid, e.title, e.description, e.ontime, e.offtime, e.allday,
+							r.id, r.offsetsec, r.channel, r.notifytime, r.notified, (SELECT string_agg(t.tag, ',' ORDER BY t.tag) FROM event_tags t WHERE t.eventid = e.id)
+						  FROM events e LEFT JOIN reminders r ON r.eventid = e.id
+						  WHERE e.userid = $1 ORDER BY e.id, r.id`).
 			WithArgs(userID).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "userid", "title", "description", "ontime", "offtime", "notifytime"}).
+			WillReturnRows(sqlmock.NewRows(eventColumns).
 				AddRow(eID1, userID, "TitleN100", "DescriptionN100",
-					timeValue(time.Now()), timeValue(time.Now().AddDate(0, 0, 7)), timeValue(time.Time{})).
+					timeValue(time.Now()), timeValue(time.Now().AddDate(0, 0, 7)), false, nil, nil, nil, nil, nil, nil).
 				AddRow(eID2, userID, "TitleN101", "DescriptionN101",
-					timeValue(time.Now()), timeValue(time.Now().AddDate(0, 0, 7)), timeValue(time.Time{})))
+					timeValue(time.Now()), timeValue(time.Now().AddDate(0, 0, 7)), false, nil, nil, nil, nil, nil, nil))
 
-		eFound, err := storage.ListEvents(context.Background(), userID)
+		eFound, err := storage.ListEvents(context.Background(), userID, model.TagFilter{})
 		require.NoError(t, err)
 		require.EqualValues(t, 2, len(eFound))
 		require.EqualValues(t, eID1, eFound[0].ID)
 		require.EqualValues(t, eID2, eFound[1].ID)
+		require.Empty(t, eFound[0].Reminders)
 
 		if err := mock.ExpectationsWereMet(); err != nil {
 			t.Errorf("there were unfulfilled expectations: %s", err)
@@ -128,21 +201,378 @@ func TestSqlStorage(t *testing.T) {
 		eID2 := int64(101)
 		userID := int64(200)
 		currTime := time.Now()
-		mock.ExpectQuery(`SELECT id, userid, title, description, ontime, offtime, notifytime
-							FROM events WHERE userid = $1 AND 
-							(ontime BETWEEN $2 AND $3 OR offtime BETWEEN $2 AND $3)`).
-			WithArgs(userID, timeValue(currTime), timeValue(currTime)).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "userid", "title", "description", "ontime", "offtime", "notifytime"}).
+		mock.ExpectQuery(`SELECT e.id, e.userid, e.title, e.description, e.ontime, e.offtime, e.allday,
+							r.id, r.offsetsec, r.channel, r.notifytime, r.notified, (SELECT string_agg(t.tag, ',' ORDER BY t.tag) FROM event_tags t WHERE t.eventid = e.id)
+						  FROM events e LEFT JOIN reminders r ON r.eventid = e.id
+						  WHERE e.userid = $1 AND
+						  (NOT e.allday AND (e.ontime BETWEEN $2 AND $3 OR e.offtime BETWEEN $2 AND $3) OR
+						   e.allday AND e.ontime <= $5 AND e.offtime > $4)
+						  ORDER BY e.id, r.id`).
+			WithArgs(userID, timeValue(currTime), timeValue(currTime), model.Date(currTime), model.Date(currTime)).
+			WillReturnRows(sqlmock.NewRows(eventColumns).
 				AddRow(eID1, userID, "TitleN100", "DescriptionN100",
-					timeValue(currTime), timeValue(currTime.AddDate(0, 0, 7)), timeValue(time.Time{})).
+					timeValue(currTime), timeValue(currTime.AddDate(0, 0, 7)), false, nil, nil, nil, nil, nil, nil).
 				AddRow(eID2, userID, "TitleN101", "DescriptionN101",
-					timeValue(currTime), timeValue(currTime.AddDate(0, 0, 7)), timeValue(time.Time{})))
+					model.Date(currTime), model.Date(currTime).AddDate(0, 0, 7), true, nil, nil, nil, nil, nil, nil))
 
-		eFound, err := storage.ListEventsRange(context.Background(), userID, currTime, currTime)
+		eFound, err := storage.ListEventsRange(context.Background(), userID, currTime, currTime, model.TagFilter{})
 		require.NoError(t, err)
 		require.EqualValues(t, 2, len(eFound))
 		require.EqualValues(t, eID1, eFound[0].ID)
 		require.EqualValues(t, eID2, eFound[1].ID)
+		require.False(t, eFound[0].AllDay)
+		require.True(t, eFound[1].AllDay)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("case_event_notified", func(t *testing.T) {
+		mock.ExpectExec(`UPDATE reminders SET notified = true WHERE id = $1`).
+			WithArgs(7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE reminders SET notified = true WHERE id = $1`).
+			WithArgs(8).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		require.NoError(t, storage.UpdateEventNotified(context.Background(), 7))
+		require.ErrorIs(t, storage.UpdateEventNotified(context.Background(), 8), ErrReminderNotFound)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("case_deliver_notification", func(t *testing.T) {
+		nID := int64(10)
+		mock.ExpectExec(`UPDATE notifications SET status = $2, lasterror = $3
+						 WHERE id = $1 AND status = 'queued'`).
+			WithArgs(nID, model.NotificationDelivered, stringValue("")).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE notifications SET status = $2, lasterror = $3
+						 WHERE id = $1 AND status = 'queued'`).
+			WithArgs(nID, model.NotificationDelivered, stringValue("")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		require.NoError(t, storage.DeliverNotification(context.Background(), nID))
+		require.ErrorIs(t, storage.DeliverNotification(context.Background(), nID), ErrNotificationNotQueued)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+	t.Run("case_acquire_lease", func(t *testing.T) {
+		query := `INSERT INTO leases (name, holder, expiresat)
+				  VALUES ($1, $2, now() + $3 * interval '1 millisecond')
+				  ON CONFLICT (name) DO UPDATE SET holder = $2, expiresat = now() + $3 * interval '1 millisecond'
+				  WHERE leases.holder = $2 OR leases.expiresat < now()
+				  RETURNING holder`
+		mock.ExpectQuery(query).
+			WithArgs("scheduler", "node-1", int64(30000)).
+			WillReturnRows(sqlmock.NewRows([]string{"holder"}).AddRow("node-1"))
+		mock.ExpectQuery(query).
+			WithArgs("scheduler", "node-2", int64(30000)).
+			WillReturnRows(sqlmock.NewRows([]string{"holder"}))
+
+		leader, err := storage.AcquireLease(context.Background(), "scheduler", "node-1", 30*time.Second)
+		require.NoError(t, err)
+		require.True(t, leader)
+
+		leader, err = storage.AcquireLease(context.Background(), "scheduler", "node-2", 30*time.Second)
+		require.NoError(t, err)
+		require.False(t, leader)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+	t.Run("case_expire_events", func(t *testing.T) {
+		date := time.Now()
+		before := date.AddDate(-1, 0, 0)
+		mock.ExpectQuery(`SELECT e.id, e.userid, e.title, e.description, e.ontime, e.offtime, e.allday,
+							r.id, r.offsetsec, r.channel, r.notifytime, r.notified, (SELECT string_agg(t.tag, ',' ORDER BY t.tag) FROM event_tags t WHERE t.eventid = e.id)
+						  FROM events e LEFT JOIN reminders r ON r.eventid = e.id
+						  WHERE e.id IN (
+						      SELECT ev.id FROM events ev
+						      LEFT JOIN user_retention ur ON ur.userid = ev.userid
+						      WHERE ev.id > $3 AND
+						            ev.offtime < COALESCE($1::timestamp - ur.retentionsec * INTERVAL '1 second', $2::timestamp)
+						      ORDER BY ev.id
+						      LIMIT $4)
+						  ORDER BY e.id, r.id`).
+			WithArgs(date, before, 0, 500).
+			WillReturnRows(sqlmock.NewRows(eventColumns).
+				AddRow(100, 200, "TitleN100", "DescriptionN100",
+					timeValue(before), timeValue(before), false, nil, nil, nil, nil, nil, nil))
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM events WHERE id = $1`).
+			WithArgs(100).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		events, err := storage.ListExpiredEvents(context.Background(), date, before, 0, 500)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		deleted, err := storage.DeleteEvents(context.Background(), []int64{events[0].ID})
+		require.NoError(t, err)
+		require.EqualValues(t, 1, deleted)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+	t.Run("case_user_retention", func(t *testing.T) {
+		mock.ExpectExec(`INSERT INTO user_retention (userid, retentionsec)
+						 VALUES ($1, $2)
+						 ON CONFLICT (userid) DO UPDATE SET retentionsec = EXCLUDED.retentionsec`).
+			WithArgs(200, 86400).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`DELETE FROM user_retention WHERE userid = $1`).
+			WithArgs(200).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`SELECT retentionsec FROM user_retention WHERE userid = $1`).
+			WithArgs(200).
+			WillReturnRows(sqlmock.NewRows([]string{"retentionsec"}))
+
+		require.NoError(t, storage.SetUserRetention(context.Background(), 200, 24*time.Hour))
+		require.NoError(t, storage.SetUserRetention(context.Background(), 200, 0))
+		retention, err := storage.LookupUserRetention(context.Background(), 200)
+		require.NoError(t, err)
+		require.Zero(t, retention)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+	t.Run("case_dedup_keys", func(t *testing.T) {
+		query := `INSERT INTO dedup_keys (key, done, expiresat)
+				  VALUES ($1, false, now() + $2 * interval '1 millisecond')
+				  ON CONFLICT (key) DO UPDATE SET done = false, expiresat = now() + $2 * interval '1 millisecond'
+				  WHERE dedup_keys.expiresat < now()
+				  RETURNING key`
+		mock.ExpectQuery(query).
+			WithArgs("100:1672671600", int64(300000)).
+			WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("100:1672671600"))
+		mock.ExpectExec(`UPDATE dedup_keys SET done = true, expiresat = now() + $2 * interval '1 millisecond'
+			  WHERE key = $1`).
+			WithArgs("100:1672671600", int64(3600000)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(query).
+			WithArgs("100:1672671600", int64(300000)).
+			WillReturnRows(sqlmock.NewRows([]string{"key"}))
+		mock.ExpectQuery(`SELECT done FROM dedup_keys WHERE key = $1`).
+			WithArgs("100:1672671600").
+			WillReturnRows(sqlmock.NewRows([]string{"done"}).AddRow(true))
+		mock.ExpectExec(`DELETE FROM dedup_keys WHERE key = $1 AND NOT done`).
+			WithArgs("100:1672671600").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`DELETE FROM dedup_keys WHERE expiresat < now()`).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		state, err := storage.ClaimDedupKey(context.Background(), "100:1672671600", 5*time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, model.DedupClaimed, state)
+		require.NoError(t, storage.CompleteDedupKey(context.Background(), "100:1672671600", time.Hour))
+		state, err = storage.ClaimDedupKey(context.Background(), "100:1672671600", 5*time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, model.DedupDone, state)
+		require.NoError(t, storage.ReleaseDedupKey(context.Background(), "100:1672671600"))
+		purged, err := storage.PurgeDedupKeys(context.Background())
+		require.NoError(t, err)
+		require.EqualValues(t, 2, purged)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+	t.Run("case_list_due_notifications", func(t *testing.T) {
+		date := time.Now()
+		mock.ExpectQuery(`SELECT id, eventid, reminderid, channel, userid, title, ontime, notifytime, status, attempts
+			  FROM notifications
+			  WHERE attempts < $2 AND (status = 'pending' OR (status = 'queued' AND queuedat <= $1))
+			  ORDER BY id`).
+			WithArgs(date.Add(-time.Minute), 5).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"id", "eventid", "reminderid", "channel", "userid", "title", "ontime", "notifytime", "status", "attempts",
+			}).AddRow(1, 100, 10, "email", 200, "TitleN100", timeValue(date), timeValue(date), "pending", 0))
+
+		notifications, err := storage.ListDueNotifications(context.Background(), date, time.Minute, 5)
+		require.NoError(t, err)
+		require.Len(t, notifications, 1)
+		require.Equal(t, model.NotificationPending, notifications[0].Status)
+		require.EqualValues(t, 10, notifications[0].ReminderID)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+	t.Run("case_search_events", func(t *testing.T) {
+		userID := int64(200)
+		from := time.Now()
+		mock.ExpectQuery(`SELECT e.id, e.userid, e.title, e.description, e.ontime, e.offtime, e.allday,
+				r.id, r.offsetsec, r.channel, r.notifytime, r.notified, (SELECT string_agg(t.tag, ',' ORDER BY t.tag) FROM event_tags t WHERE t.eventid = e.id),
+				f.rank, f.title, f.snippet
+			  FROM (SELECT id, ts_rank(search, q) AS rank,
+						ts_headline('simple', translate(title, E'\x02\x03', ''), q,
+							E'HighlightAll=true, StartSel=\x02, StopSel=\x03') AS title,
+						ts_headline('simple', translate(coalesce(description, ''), E'\x02\x03', ''), q,
+							E'StartSel=\x02, StopSel=\x03') AS snippet
+					FROM events e, websearch_to_tsquery('simple', $2) q
+					WHERE userid = $1 AND search @@ q AND
+					($3::timestamp IS NULL OR offtime >= $3) AND
+					($4::timestamp IS NULL OR ontime <= $4)
+					ORDER BY rank DESC, id
+					LIMIT $5) f
+			  JOIN events e ON e.id = f.id
+			  LEFT JOIN reminders r ON r.eventid = e.id
+			  ORDER BY f.rank DESC, e.id, r.id`).
+			WithArgs(userID, "standup", timeValue(from), nil, 50).
+			WillReturnRows(sqlmock.NewRows(append(eventColumns, "rank", "headline", "snippet")).
+				AddRow(101, userID, "Standup", "Daily standup", timeValue(onTime), timeValue(onTime.Add(time.Hour)), false,
+					1, 600, "", timeValue(onTime.Add(-10*time.Minute)), false, nil,
+					0.66, "\x02Standup\x03", "Daily \x02standup\x03").
+				AddRow(101, userID, "Standup", "Daily standup", timeValue(onTime), timeValue(onTime.Add(time.Hour)), false,
+					2, 3600, "email", timeValue(onTime.Add(-time.Hour)), false, nil,
+					0.66, "\x02Standup\x03", "Daily \x02standup\x03").
+				AddRow(100, userID, "Sync", "After the <i>standup</i>", timeValue(onTime), timeValue(onTime.Add(time.Hour)), false,
+					nil, nil, nil, nil, nil, "team",
+					0.24, "Sync", "After the <i>\x02standup\x03</i>"))
+
+		results, err := storage.SearchEvents(context.Background(), userID, "standup", from, time.Time{}, model.TagFilter{}, 50)
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		require.EqualValues(t, 101, results[0].Event.ID)
+		require.Len(t, results[0].Event.Reminders, 2)
+		require.Equal(t, "<b>Standup</b>", results[0].Title)
+		require.EqualValues(t, 100, results[1].Event.ID)
+		require.Empty(t, results[1].Event.Reminders)
+		require.Equal(t, "After the &lt;i&gt;<b>standup</b>&lt;/i&gt;", results[1].Snippet, "text is escaped")
+		require.Less(t, results[1].Rank, results[0].Rank)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+	t.Run("case_search_events_tags", func(t *testing.T) {
+		userID := int64(200)
+		mock.ExpectQuery(`SELECT e.id, e.userid, e.title, e.description, e.ontime, e.offtime, e.allday,
+				r.id, r.offsetsec, r.channel, r.notifytime, r.notified, (SELECT string_agg(t.tag, ',' ORDER BY t.tag) FROM event_tags t WHERE t.eventid = e.id),
+				f.rank, f.title, f.snippet
+			  FROM (SELECT id, ts_rank(search, q) AS rank,
+						ts_headline('simple', translate(title, E'\x02\x03', ''), q,
+							E'HighlightAll=true, StartSel=\x02, StopSel=\x03') AS title,
+						ts_headline('simple', translate(coalesce(description, ''), E'\x02\x03', ''), q,
+							E'StartSel=\x02, StopSel=\x03') AS snippet
+					FROM events e, websearch_to_tsquery('simple', $2) q
+					WHERE userid = $1 AND search @@ q AND
+					($3::timestamp IS NULL OR offtime >= $3) AND
+					($4::timestamp IS NULL OR ontime <= $4)
+					AND EXISTS (SELECT 1 FROM event_tags t WHERE t.eventid = e.id AND t.tag IN ($6))
+					ORDER BY rank DESC, id
+					LIMIT $5) f
+			  JOIN events e ON e.id = f.id
+			  LEFT JOIN reminders r ON r.eventid = e.id
+			  ORDER BY f.rank DESC, e.id, r.id`).
+			WithArgs(userID, "standup", nil, nil, 50, "work").
+			WillReturnRows(sqlmock.NewRows(append(eventColumns, "rank", "headline", "snippet")))
+
+		results, err := storage.SearchEvents(context.Background(), userID, "standup", time.Time{}, time.Time{},
+			model.TagFilter{Any: []string{"work"}}, 50)
+		require.NoError(t, err)
+		require.Empty(t, results)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+	t.Run("case_tags", func(t *testing.T) {
+		userID := int64(200)
+		mock.ExpectQuery(`SELECT e.id, e.userid, e.title, e.description, e.ontime, e.offtime, e.allday,
+							r.id, r.offsetsec, r.channel, r.notifytime, r.notified, (SELECT string_agg(t.tag, ',' ORDER BY t.tag) FROM event_tags t WHERE t.eventid = e.id)
+						  FROM events e LEFT JOIN reminders r ON r.eventid = e.id
+						  WHERE e.userid = $1
+						  AND EXISTS (SELECT 1 FROM event_tags t WHERE t.eventid = e.id AND t.tag IN ($2, $3))
+						  AND (SELECT count(*) FROM event_tags t WHERE t.eventid = e.id AND t.tag IN ($4)) = 1
+						  ORDER BY e.id, r.id`).
+			WithArgs(userID, "project-x", "project-y", "urgent").
+			WillReturnRows(sqlmock.NewRows(eventColumns).
+				AddRow(100, userID, "TitleN100", "DescriptionN100",
+					timeValue(onTime), timeValue(onTime.Add(time.Hour)), false, nil, nil, nil, nil, nil, "project-x,urgent"))
+
+		filter := model.TagFilter{Any: []string{"project-x", "project-y"}, All: []string{"urgent"}}
+		events, err := storage.ListEvents(context.Background(), userID, filter)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, []string{"project-x", "urgent"}, events[0].Tags)
+
+		mock.ExpectQuery(`SELECT t.tag, count(*)
+						  FROM event_tags t JOIN events e ON e.id = t.eventid
+						  WHERE e.userid = $1
+						  GROUP BY t.tag
+						  ORDER BY count(*) DESC, t.tag`).
+			WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows([]string{"tag", "count"}).
+				AddRow("urgent", 3).
+				AddRow("project-x", 1))
+
+		tags, err := storage.ListUserTags(context.Background(), userID)
+		require.NoError(t, err)
+		require.Equal(t, []model.TagCount{{Tag: "urgent", Count: 3}, {Tag: "project-x", Count: 1}}, tags)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("case_attachments", func(t *testing.T) {
+		createdAt := time.Now()
+		a := model.Attachment{
+			EventID: 1, UserID: 1, Name: "agenda.txt", Size: 6, ContentType: "text/plain",
+			Checksum: "c0ffee", Key: "k1", CreatedAt: createdAt,
+		}
+		insert := `INSERT INTO attachments (eventid, userid, name, size, contenttype, checksum, blobkey, createdat)
+				   SELECT $1, $2, $3, $4, $5, $6, $7, $8
+				   WHERE coalesce((SELECT sum(size) FROM attachments WHERE userid = $2), 0) + $4 <= $9
+				   RETURNING id`
+		lock := `SELECT pg_advisory_xact_lock($1)`
+		mock.ExpectBegin()
+		mock.ExpectExec(lock).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(insert).
+			WithArgs(1, 1, "agenda.txt", 6, "text/plain", "c0ffee", "k1", createdAt, 100).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec(lock).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(insert).
+			WithArgs(1, 1, "agenda.txt", 6, "text/plain", "c0ffee", "k1", createdAt, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+		mock.ExpectQuery(`SELECT id, eventid, userid, name, size, contenttype, checksum, blobkey, createdat
+						  FROM attachments
+						  WHERE eventid = $1
+						  ORDER BY id`).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"id", "eventid", "userid", "name", "size", "contenttype", "checksum", "blobkey", "createdat",
+			}).AddRow(3, 1, 1, "agenda.txt", 6, "text/plain", "c0ffee", "k1", createdAt))
+		mock.ExpectExec(`DELETE FROM attachments WHERE id = $1`).
+			WithArgs(3).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT EXISTS (SELECT 1 FROM attachments WHERE blobkey = $1)`).
+			WithArgs("k1").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		require.NoError(t, storage.InsertAttachment(context.Background(), &a, 100))
+		require.EqualValues(t, 3, a.ID)
+		require.ErrorIs(t, storage.InsertAttachment(context.Background(), &a, 5), ErrQuotaExceeded)
+		attachments, err := storage.ListAttachments(context.Background(), 1)
+		require.NoError(t, err)
+		require.Equal(t, []model.Attachment{a}, attachments)
+		require.ErrorIs(t, storage.DeleteAttachment(context.Background(), 3), ErrAttachmentNotFound)
+		found, err := storage.HasAttachmentKey(context.Background(), "k1")
+		require.NoError(t, err)
+		require.False(t, found)
 
 		if err := mock.ExpectationsWereMet(); err != nil {
 			t.Errorf("there were unfulfilled expectations: %s", err)
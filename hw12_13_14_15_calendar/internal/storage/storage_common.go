@@ -6,6 +6,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
 	memorystorage "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/storage/memory"
 	sqlstorage "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/storage/sql"
@@ -16,6 +17,13 @@ type Conf struct {
 	DSN string `toml:"dsn"`
 }
 
+func (c Conf) Check(v *config.Validator) {
+	v.OneOf("db", c.DB, "in-memory", "sql")
+	if c.DB == "sql" {
+		v.URL("dsn", c.DSN, "postgres", "postgresql")
+	}
+}
+
 type Storage interface {
 	Connect(context.Context) error
 	Close(context.Context) error
@@ -23,16 +31,48 @@ type Storage interface {
 	UpdateEvent(context.Context, *model.Event) error
 	DeleteEvent(context.Context, int64) error
 	LookupEvent(context.Context, int64) (model.Event, error)
-	ListEvents(context.Context, int64) ([]model.Event, error)
-	ListEventsRange(context.Context, int64, time.Time, time.Time) ([]model.Event, error)
+	ListEvents(context.Context, int64, model.TagFilter) ([]model.Event, error)
+	ListEventsRange(context.Context, int64, time.Time, time.Time, model.TagFilter) ([]model.Event, error)
 	IsBusyDateTimeRange(context.Context, int64, int64, time.Time, time.Time) error
+	SearchEvents(
+		context.Context, int64, string, time.Time, time.Time, model.TagFilter, int,
+	) ([]model.SearchResult, error)
 
 	// for producers
 	ListEventsDayOfNotice(context.Context, time.Time) ([]model.Event, error)
-	DeleteEventsOlderDate(context.Context, time.Time) (int64, error)
+	ListExpiredEvents(context.Context, time.Time, time.Time, int64, int) ([]model.Event, error)
+	DeleteEvents(context.Context, []int64) (int64, error)
+	EnqueueNotifications(context.Context, time.Time) (int64, error)
+	ClaimNotifications(context.Context, time.Time, time.Duration, int) ([]model.Notification, error)
+	ListDueNotifications(context.Context, time.Time, time.Duration, int) ([]model.Notification, error)
+	ReleaseNotification(context.Context, int64, string) error
+	AcquireLease(context.Context, string, string, time.Duration) (bool, error)
+	ReleaseLease(context.Context, string, string) error
+	PurgeDedupKeys(context.Context) (int64, error)
 
 	// for consumers
 	UpdateEventNotified(context.Context, int64) error
+	DeliverNotification(context.Context, int64) error
+	FailNotification(context.Context, int64, string) error
+	LookupNotification(context.Context, int64) (model.Notification, error)
+	ListUserChannels(context.Context, int64) ([]model.UserChannel, error)
+	InsertDeliveryFailure(context.Context, *model.DeliveryFailure) error
+	ClaimDedupKey(context.Context, string, time.Duration) (model.DedupState, error)
+	CompleteDedupKey(context.Context, string, time.Duration) error
+	ReleaseDedupKey(context.Context, string) error
+	ListDeliveryFailures(context.Context, int64) ([]model.DeliveryFailure, error)
+
+	// for users
+	SetUserChannels(context.Context, int64, []model.UserChannel) error
+	SetUserRetention(context.Context, int64, time.Duration) error
+	LookupUserRetention(context.Context, int64) (time.Duration, error)
+	ListUserTags(context.Context, int64) ([]model.TagCount, error)
+	InsertAttachment(context.Context, *model.Attachment, int64) error
+	LookupAttachment(context.Context, int64) (model.Attachment, error)
+	ListAttachments(context.Context, int64) ([]model.Attachment, error)
+	DeleteAttachment(context.Context, int64) error
+	UserAttachmentsSize(context.Context, int64) (int64, error)
+	HasAttachmentKey(context.Context, string) (bool, error)
 }
 
 func NewStorage(conf Conf) Storage {
@@ -0,0 +1,129 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+
+	api "github.com/FRiniZ/otus-go-hw-test/hw12_calendar/api/stub"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+
+	// HeaderVersion is the message header with the schema version.
+	HeaderVersion = "x-schema-version"
+
+	// VersionLegacy is the untyped JSON of model.NotificationMsg sent
+	// without the version header.
+	VersionLegacy = 1
+	// Version is api.NotificationMsg encoded as JSON or protobuf.
+	Version = 2
+)
+
+var (
+	ErrContentType = errors.New("unsupported content type")
+	ErrVersion     = errors.New("unsupported schema version")
+)
+
+// ContentType returns the content type used for contentType, JSON if it
+// is empty.
+func ContentType(contentType string) (string, error) {
+	if contentType == "" {
+		return ContentTypeJSON, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrContentType, contentType)
+	}
+	switch mediaType {
+	case ContentTypeJSON:
+		return ContentTypeJSON, nil
+	case ContentTypeProtobuf, "application/protobuf":
+		return ContentTypeProtobuf, nil
+	}
+	return "", fmt.Errorf("%w: %v", ErrContentType, contentType)
+}
+
+// Marshal encodes msg with the current schema version.
+func Marshal(msg model.NotificationMsg, contentType string) ([]byte, error) {
+	contentType, err := ContentType(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	apiMsg := APIFromNotificationMsg(msg)
+	if contentType == ContentTypeProtobuf {
+		return proto.Marshal(apiMsg)
+	}
+	return protojson.Marshal(apiMsg)
+}
+
+// Unmarshal decodes a message of the version, zero is the version of
+// messages without the header, i.e. VersionLegacy.
+func Unmarshal(data []byte, contentType string, version int) (model.NotificationMsg, error) {
+	var msg model.NotificationMsg
+
+	contentType, err := ContentType(contentType)
+	if err != nil {
+		return msg, err
+	}
+
+	switch version {
+	case 0, VersionLegacy:
+		if contentType != ContentTypeJSON {
+			return msg, fmt.Errorf("%w: %v of version %v", ErrContentType, contentType, VersionLegacy)
+		}
+		err = json.Unmarshal(data, &msg)
+		return msg, err
+
+	case Version:
+		apiMsg := &api.NotificationMsg{}
+		if contentType == ContentTypeProtobuf {
+			err = proto.Unmarshal(data, apiMsg)
+		} else {
+			// fields of newer minor changes are skipped
+			err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, apiMsg)
+		}
+		if err != nil {
+			return msg, err
+		}
+		return NotificationMsgFromAPI(apiMsg), nil
+	}
+	return msg, fmt.Errorf("%w: %v", ErrVersion, version)
+}
+
+func APIFromNotificationMsg(msg model.NotificationMsg) *api.NotificationMsg {
+	return &api.NotificationMsg{
+		ID:             &msg.ID,
+		NotificationID: &msg.NotificationID,
+		ReminderID:     &msg.ReminderID,
+		Channel:        &msg.Channel,
+		DedupKey:       &msg.DedupKey,
+		Title:          &msg.Title,
+		Date:           timestamppb.New(msg.Date),
+		UserID:         &msg.UserID,
+	}
+}
+
+func NotificationMsgFromAPI(apiMsg *api.NotificationMsg) model.NotificationMsg {
+	msg := model.NotificationMsg{
+		ID:             apiMsg.GetID(),
+		NotificationID: apiMsg.GetNotificationID(),
+		ReminderID:     apiMsg.GetReminderID(),
+		Channel:        apiMsg.GetChannel(),
+		DedupKey:       apiMsg.GetDedupKey(),
+		Title:          apiMsg.GetTitle(),
+		UserID:         apiMsg.GetUserID(),
+	}
+	if apiMsg.Date != nil {
+		msg.Date = apiMsg.Date.AsTime()
+	}
+	return msg
+}
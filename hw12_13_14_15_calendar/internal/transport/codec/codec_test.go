@@ -0,0 +1,67 @@
+package codec
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec(t *testing.T) {
+	msg := model.NotificationMsg{
+		ID:             100,
+		NotificationID: 1,
+		ReminderID:     10,
+		Channel:        "email",
+		DedupKey:       "100:1672671600:email",
+		Title:          "TitleN1",
+		Date:           time.Date(2023, 1, 2, 15, 0, 0, 0, time.UTC),
+		UserID:         200,
+	}
+
+	t.Run("round_trip", func(t *testing.T) {
+		for _, contentType := range []string{"", ContentTypeJSON, ContentTypeProtobuf, "application/json; charset=utf-8"} {
+			data, err := Marshal(msg, contentType)
+			require.NoError(t, err)
+			got, err := Unmarshal(data, contentType, Version)
+			require.NoError(t, err, contentType)
+			require.Equal(t, msg, got, contentType)
+		}
+	})
+
+	t.Run("legacy", func(t *testing.T) {
+		legacy := msg
+		legacy.DedupKey = ""
+		data, err := json.Marshal(legacy)
+		require.NoError(t, err)
+
+		got, err := Unmarshal(data, ContentTypeJSON, 0)
+		require.NoError(t, err, "message without the version header")
+		require.Equal(t, legacy, got)
+		got, err = Unmarshal(data, "", VersionLegacy)
+		require.NoError(t, err)
+		require.Equal(t, legacy, got)
+
+		_, err = Unmarshal(data, ContentTypeProtobuf, VersionLegacy)
+		require.ErrorIs(t, err, ErrContentType)
+	})
+
+	t.Run("unknown_fields", func(t *testing.T) {
+		got, err := Unmarshal([]byte(`{"ID":"100","Title":"TitleN1","Location":"Room 1"}`), ContentTypeJSON, Version)
+		require.NoError(t, err)
+		require.Equal(t, model.NotificationMsg{ID: 100, Title: "TitleN1"}, got)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		data, err := Marshal(msg, ContentTypeJSON)
+		require.NoError(t, err)
+		_, err = Unmarshal(data, ContentTypeJSON, Version+1)
+		require.ErrorIs(t, err, ErrVersion)
+		_, err = Unmarshal(data, "text/plain", Version)
+		require.ErrorIs(t, err, ErrContentType)
+		_, err = Marshal(msg, "application/xml")
+		require.ErrorIs(t, err, ErrContentType)
+	})
+}
@@ -0,0 +1,15 @@
+package codec
+
+import "time"
+
+// DeadLetter is a message the consumer gave up on, kept for inspection.
+type DeadLetter struct {
+	MessageID string
+	Reason    string
+	Retries   int
+	Timestamp time.Time
+	// ContentType and Version tell the encoding of Body, see Unmarshal.
+	ContentType string
+	Version     int
+	Body        []byte
+}
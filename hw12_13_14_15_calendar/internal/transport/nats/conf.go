@@ -0,0 +1,169 @@
+package internalnats
+
+import (
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/codec"
+)
+
+const (
+	defaultStream         = "NOTIFICATIONS"
+	defaultSubject        = "calendar.notification"
+	defaultDurable        = "sender"
+	defaultAckWait        = 30 * time.Second
+	defaultMaxDeliver     = 6
+	defaultRetryDelay     = time.Second
+	maxRetryDelay         = 24 * time.Hour
+	defaultPublishTimeout = 5 * time.Second
+	defaultDuplicates     = 2 * time.Minute
+	defaultReconnectDelay = time.Second
+	defaultPrefetch       = 10
+	fetchWait             = time.Second
+
+	headerContentType = "Content-Type"
+	headerRetries     = "x-retries"
+	headerDeadReason  = "x-dead-reason"
+)
+
+type Logger interface {
+	Fatalf(format string, a ...interface{})
+	Errorf(format string, a ...interface{})
+	Warningf(format string, a ...interface{})
+	Infof(format string, a ...interface{})
+	Debugf(format string, a ...interface{})
+}
+
+type Conf struct {
+	Stream         string        `toml:"stream"`
+	Subject        string        `toml:"subject"`
+	Durable        string        `toml:"durable"`
+	AckWait        time.Duration `toml:"ack_wait"`
+	MaxDeliver     int           `toml:"max_deliver"`
+	RetryDelay     time.Duration `toml:"retry_delay"`
+	PublishTimeout time.Duration `toml:"publish_timeout"`
+	Duplicates     time.Duration `toml:"duplicates"`
+	ReconnectDelay time.Duration `toml:"reconnect_delay"`
+	Prefetch       int           `toml:"prefetch"`
+	ContentType    string        `toml:"content_type"`
+}
+
+// DefaultConf keeps messages in a file stream, so they survive a server restart.
+func DefaultConf() Conf {
+	return Conf{
+		Stream:         defaultStream,
+		Subject:        defaultSubject,
+		Durable:        defaultDurable,
+		AckWait:        defaultAckWait,
+		MaxDeliver:     defaultMaxDeliver,
+		RetryDelay:     defaultRetryDelay,
+		PublishTimeout: defaultPublishTimeout,
+		Duplicates:     defaultDuplicates,
+		ReconnectDelay: defaultReconnectDelay,
+		Prefetch:       defaultPrefetch,
+	}
+}
+
+func (c Conf) Check(v *config.Validator) {
+	v.NotNegative("ack_wait", c.AckWait)
+	v.Range("max_deliver", int64(c.MaxDeliver), 0, 32)
+	v.NotNegative("retry_delay", c.RetryDelay)
+	if c.RetryDelay > maxRetryDelay {
+		v.AddKeyf("retry_delay", "must be <= %v, got %v", maxRetryDelay, c.RetryDelay)
+	}
+	v.NotNegative("publish_timeout", c.PublishTimeout)
+	v.NotNegative("duplicates", c.Duplicates)
+	v.NotNegative("reconnect_delay", c.ReconnectDelay)
+	v.Range("prefetch", int64(c.Prefetch), 0, 1024)
+	if c.ContentType != "" {
+		v.OneOf("content_type", c.ContentType, codec.ContentTypeJSON, codec.ContentTypeProtobuf)
+	}
+}
+
+func (c Conf) stream() string {
+	if c.Stream == "" {
+		return defaultStream
+	}
+	return c.Stream
+}
+
+func (c Conf) subject() string {
+	if c.Subject == "" {
+		return defaultSubject
+	}
+	return c.Subject
+}
+
+// deadStream keeps the messages the consumer gave up on under deadSubject.
+func (c Conf) deadStream() string {
+	return c.stream() + "_DEAD"
+}
+
+func (c Conf) deadSubject() string {
+	return c.subject() + ".dead"
+}
+
+func (c Conf) durable() string {
+	if c.Durable == "" {
+		return defaultDurable
+	}
+	return c.Durable
+}
+
+func (c Conf) ackWait() time.Duration {
+	if c.AckWait == 0 {
+		return defaultAckWait
+	}
+	return c.AckWait
+}
+
+func (c Conf) maxDeliver() int {
+	if c.MaxDeliver == 0 {
+		return defaultMaxDeliver
+	}
+	return c.MaxDeliver
+}
+
+// retryDelay doubles the delay with every delivery up to maxRetryDelay.
+func (c Conf) retryDelay(delivered uint64) time.Duration {
+	delay := c.RetryDelay
+	if delay == 0 {
+		delay = defaultRetryDelay
+	}
+	for i := uint64(1); i < delivered && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
+
+func (c Conf) publishTimeout() time.Duration {
+	if c.PublishTimeout == 0 {
+		return defaultPublishTimeout
+	}
+	return c.PublishTimeout
+}
+
+func (c Conf) duplicates() time.Duration {
+	if c.Duplicates == 0 {
+		return defaultDuplicates
+	}
+	return c.Duplicates
+}
+
+func (c Conf) reconnectDelay() time.Duration {
+	if c.ReconnectDelay == 0 {
+		return defaultReconnectDelay
+	}
+	return c.ReconnectDelay
+}
+
+// prefetch is the number of messages fetched at once.
+func (c Conf) prefetch() int {
+	if c.Prefetch == 0 {
+		return defaultPrefetch
+	}
+	return c.Prefetch
+}
@@ -0,0 +1,97 @@
+package internalnats
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+var ErrNotConnected = errors.New("not connected to NATS")
+
+// connect dials the server and declares the stream. The client reconnects
+// by itself, messages published meanwhile fail and stay in the outbox.
+func connect(log Logger, url string, conf Conf, name string) (*nats.Conn, nats.JetStreamContext, error) {
+	conn, err := nats.Connect(url,
+		nats.Name("calendar-"+name),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(conf.reconnectDelay()),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Warningf("NATS %v disconnected: %v\n", name, err)
+			}
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			log.Infof("NATS %v reconnected to %v\n", name, conn.ConnectedUrl())
+		}),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	js, err := conn.JetStream()
+	if err == nil {
+		err = declareStream(js, conf)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, js, nil
+}
+
+func streamConfig(conf Conf) *nats.StreamConfig {
+	return &nats.StreamConfig{
+		Name:       conf.stream(),
+		Subjects:   []string{conf.subject()},
+		Storage:    nats.FileStorage,
+		Retention:  nats.WorkQueuePolicy,
+		Duplicates: conf.duplicates(),
+	}
+}
+
+func declareStream(js nats.JetStreamContext, conf Conf) error {
+	_, err := js.StreamInfo(conf.stream())
+	switch {
+	case errors.Is(err, nats.ErrStreamNotFound):
+		_, err = js.AddStream(streamConfig(conf))
+	case err == nil:
+		_, err = js.UpdateStream(streamConfig(conf))
+	}
+	if err != nil {
+		return fmt.Errorf("can't declare stream %v: %w", conf.stream(), err)
+	}
+	return nil
+}
+
+// declareDeadLetters declares the dead-letter stream. Unlike the work queue
+// it keeps messages until they are requeued or removed by an operator.
+func declareDeadLetters(js nats.JetStreamContext, conf Conf) error {
+	cfg := &nats.StreamConfig{
+		Name:       conf.deadStream(),
+		Subjects:   []string{conf.deadSubject()},
+		Storage:    nats.FileStorage,
+		Duplicates: conf.duplicates(),
+	}
+	_, err := js.StreamInfo(cfg.Name)
+	switch {
+	case errors.Is(err, nats.ErrStreamNotFound):
+		_, err = js.AddStream(cfg)
+	case err == nil:
+		_, err = js.UpdateStream(cfg)
+	}
+	if err != nil {
+		return fmt.Errorf("can't declare stream %v: %w", cfg.Name, err)
+	}
+	return nil
+}
+
+func health(conn *nats.Conn) error {
+	if conn == nil {
+		return ErrNotConnected
+	}
+	if status := conn.Status(); status != nats.CONNECTED {
+		return fmt.Errorf("%w: %v", ErrNotConnected, status)
+	}
+	return nil
+}
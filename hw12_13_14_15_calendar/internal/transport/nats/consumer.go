@@ -0,0 +1,261 @@
+package internalnats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/codec"
+	"github.com/nats-io/nats.go"
+)
+
+var ErrUnknownDelivery = errors.New("unknown delivery tag")
+
+type Consumer struct {
+	log           Logger
+	url           string
+	conf          Conf
+	conn          *nats.Conn
+	js            nats.JetStreamContext
+	sub           *nats.Subscription
+	notifyChannel chan model.NotificationMsg
+	done          chan struct{}
+	wg            sync.WaitGroup
+	mu            sync.Mutex
+	lastTag       uint64
+	inflight      map[uint64]*nats.Msg
+}
+
+func NewConsumer(log Logger, url string, conf Conf) *Consumer {
+	return &Consumer{
+		log:           log,
+		url:           url,
+		conf:          conf,
+		notifyChannel: make(chan model.NotificationMsg, 1),
+		done:          make(chan struct{}),
+		inflight:      make(map[uint64]*nats.Msg),
+	}
+}
+
+func consumerConfig(conf Conf) *nats.ConsumerConfig {
+	return &nats.ConsumerConfig{
+		Durable:       conf.durable(),
+		FilterSubject: conf.subject(),
+		AckPolicy:     nats.AckExplicitPolicy,
+		AckWait:       conf.ackWait(),
+		MaxDeliver:    conf.maxDeliver(),
+		DeliverPolicy: nats.DeliverAllPolicy,
+	}
+}
+
+// declareConsumer creates the durable consumer or updates its settings. It is
+// bound by name, so closing the subscription keeps the consumer and its
+// unacknowledged messages.
+func declareConsumer(js nats.JetStreamContext, conf Conf) error {
+	_, err := js.ConsumerInfo(conf.stream(), conf.durable())
+	switch {
+	case errors.Is(err, nats.ErrConsumerNotFound):
+		_, err = js.AddConsumer(conf.stream(), consumerConfig(conf))
+	case err == nil:
+		_, err = js.UpdateConsumer(conf.stream(), consumerConfig(conf))
+	}
+	return err
+}
+
+func (c *Consumer) Connect(ctx context.Context) error {
+	c.log.Debugf("Connecting to NATS...\n")
+	conn, js, err := connect(c.log, c.url, c.conf, "consumer")
+	if err != nil {
+		return err
+	}
+
+	err = declareDeadLetters(js, c.conf)
+	if err == nil {
+		err = declareConsumer(js, c.conf)
+	}
+	if err == nil {
+		c.sub, err = js.PullSubscribe(c.conf.subject(), c.conf.durable(),
+			nats.Bind(c.conf.stream(), c.conf.durable()), nats.ManualAck())
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	c.conn, c.js = conn, js
+	c.log.Debugf("Connected to NATS\n")
+
+	c.wg.Add(1)
+	go c.receive()
+	return nil
+}
+
+// receive fetches messages one by one and passes them to NotifyChannel
+// until the consumer is closed.
+func (c *Consumer) receive() {
+	defer c.wg.Done()
+	for {
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+
+		msgs, err := c.sub.Fetch(c.conf.prefetch(), nats.MaxWait(fetchWait))
+		switch {
+		case errors.Is(err, nats.ErrTimeout):
+			continue
+		case err != nil:
+			c.log.Debugf("Fetch:%v\n", err)
+			select {
+			case <-c.done:
+				return
+			case <-time.After(c.conf.reconnectDelay()):
+			}
+			continue
+		}
+
+		for _, msg := range msgs {
+			if !c.dispatch(msg) {
+				return
+			}
+		}
+	}
+}
+
+func (c *Consumer) dispatch(msg *nats.Msg) bool {
+	notify, err := unpackMsg(msg)
+	if err != nil {
+		c.log.Errorf("RecvNotification:%v\n", err)
+		if err := c.deadLetter(context.Background(), msg, err); err != nil {
+			c.log.Errorf("Can't dead-letter message:%v\n", err)
+		}
+		return true
+	}
+
+	c.mu.Lock()
+	c.lastTag++
+	notify.DeliveryTag = c.lastTag
+	c.inflight[notify.DeliveryTag] = msg
+	c.mu.Unlock()
+
+	select {
+	case c.notifyChannel <- notify:
+	case <-c.done:
+		return false
+	}
+	c.log.Debugf("Received notification: %v\n", notify)
+	return true
+}
+
+// unpackMsg decodes the message by its headers, ones without the version
+// header are of the legacy schema.
+func unpackMsg(msg *nats.Msg) (model.NotificationMsg, error) {
+	version := 0
+	if v := msg.Header.Get(codec.HeaderVersion); v != "" {
+		var err error
+		if version, err = strconv.Atoi(v); err != nil {
+			return model.NotificationMsg{}, fmt.Errorf("%w: %v", codec.ErrVersion, v)
+		}
+	}
+	return codec.Unmarshal(msg.Data, msg.Header.Get(headerContentType), version)
+}
+
+func (c *Consumer) Health() error {
+	return health(c.conn)
+}
+
+func (c *Consumer) NotifyChannel() <-chan model.NotificationMsg {
+	return c.notifyChannel
+}
+
+// Close stops fetching, messages not acknowledged yet are delivered again
+// after ack_wait.
+func (c *Consumer) Close(ctx context.Context) error {
+	select {
+	case <-c.done:
+		return nil
+	default:
+		close(c.done)
+	}
+	c.wg.Wait()
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	return nil
+}
+
+func (c *Consumer) takeDelivery(tag uint64) (*nats.Msg, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	msg, ok := c.inflight[tag]
+	if !ok {
+		return nil, ErrUnknownDelivery
+	}
+	delete(c.inflight, tag)
+	return msg, nil
+}
+
+// Ack acknowledges a handled message and waits for the server to confirm it.
+func (c *Consumer) Ack(ctx context.Context, msg model.NotificationMsg) error {
+	m, err := c.takeDelivery(msg.DeliveryTag)
+	if err != nil {
+		return err
+	}
+	return m.AckSync(nats.Context(ctx))
+}
+
+// Retry asks the server to deliver the message again after a delay doubling
+// with every delivery. After max_deliver deliveries the message is moved to
+// the dead-letter stream.
+func (c *Consumer) Retry(ctx context.Context, msg model.NotificationMsg, reason error) error {
+	m, err := c.takeDelivery(msg.DeliveryTag)
+	if err != nil {
+		return err
+	}
+
+	meta, err := m.Metadata()
+	if err != nil {
+		return err
+	}
+	if meta.NumDelivered >= uint64(c.conf.maxDeliver()) {
+		return c.deadLetter(ctx, m, reason)
+	}
+
+	delay := c.conf.retryDelay(meta.NumDelivered)
+	c.log.Warningf("Notification %v retry %v in %v: %v\n", msg.NotificationID, meta.NumDelivered, delay, reason)
+	return m.NakWithDelay(delay)
+}
+
+// deadLetter copies the message to the dead-letter stream and terminates it.
+// If the copy fails, the message is delivered again after a delay, unless it
+// is out of deliveries; its payload is logged in any case.
+func (c *Consumer) deadLetter(ctx context.Context, m *nats.Msg, reason error) error {
+	delivered := uint64(1)
+	if meta, err := m.Metadata(); err == nil {
+		delivered = meta.NumDelivered
+	}
+	c.log.Errorf("Message %v moved to %v: %v\n", m.Header.Get(nats.MsgIdHdr), c.conf.deadStream(), reason)
+
+	dead := nats.NewMsg(c.conf.deadSubject())
+	for key, values := range m.Header {
+		dead.Header[key] = values
+	}
+	dead.Header.Set(headerRetries, strconv.FormatUint(delivered-1, 10))
+	dead.Header.Set(headerDeadReason, reason.Error())
+	dead.Data = m.Data
+
+	ctx, cancel := context.WithTimeout(ctx, c.conf.publishTimeout())
+	defer cancel()
+	if _, err := c.js.PublishMsg(dead, nats.Context(ctx)); err != nil {
+		c.log.Errorf("Can't dead-letter message %v %q: %v\n", m.Header, m.Data, err)
+		if errNak := m.NakWithDelay(c.conf.retryDelay(delivered)); errNak != nil {
+			c.log.Errorf("Can't nack message:%v\n", errNak)
+		}
+		return err
+	}
+	return m.Term()
+}
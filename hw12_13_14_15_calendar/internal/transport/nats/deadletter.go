@@ -0,0 +1,124 @@
+package internalnats
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/codec"
+	"github.com/nats-io/nats.go"
+)
+
+// DeadLetters inspects and requeues messages of the dead-letter stream.
+type DeadLetters struct {
+	log  Logger
+	url  string
+	conf Conf
+	conn *nats.Conn
+	js   nats.JetStreamContext
+}
+
+func NewDeadLetters(log Logger, url string, conf Conf) *DeadLetters {
+	return &DeadLetters{log: log, url: url, conf: conf}
+}
+
+func (c *DeadLetters) Connect(ctx context.Context) error {
+	conn, js, err := connect(c.log, c.url, c.conf, "dlq")
+	if err != nil {
+		return err
+	}
+	if err := declareDeadLetters(js, c.conf); err != nil {
+		conn.Close()
+		return err
+	}
+	c.conn, c.js = conn, js
+	return nil
+}
+
+func (c *DeadLetters) Close(ctx context.Context) error {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	return nil
+}
+
+// List returns up to limit dead letters, all of them if limit is 0.
+// The messages stay in the stream.
+func (c *DeadLetters) List(ctx context.Context, limit int) ([]codec.DeadLetter, error) {
+	letters := []codec.DeadLetter{}
+	err := c.each(ctx, limit, func(m *nats.RawStreamMsg) error {
+		letters = append(letters, deadLetter(m))
+		return nil
+	})
+	return letters, err
+}
+
+// Requeue moves up to limit dead letters, all of them if limit is 0, back to
+// the notification subject with a fresh delivery count.
+func (c *DeadLetters) Requeue(ctx context.Context, limit int) (int, error) {
+	requeued := 0
+	err := c.each(ctx, limit, func(m *nats.RawStreamMsg) error {
+		pub := nats.NewMsg(c.conf.subject())
+		for key, values := range m.Header {
+			switch key {
+			case nats.MsgIdHdr, headerRetries, headerDeadReason:
+			default:
+				pub.Header[key] = values
+			}
+		}
+		pub.Data = m.Data
+		if _, err := c.js.PublishMsg(pub, nats.Context(ctx)); err != nil {
+			return err
+		}
+		if err := c.js.DeleteMsg(c.conf.deadStream(), m.Sequence, nats.Context(ctx)); err != nil {
+			return err
+		}
+		requeued++
+		return nil
+	})
+
+	c.log.Infof("Requeued %v messages to %v\n", requeued, c.conf.subject())
+	return requeued, err
+}
+
+// each calls fn with up to limit messages of the dead-letter stream, oldest
+// first, all of them if limit is 0.
+func (c *DeadLetters) each(ctx context.Context, limit int, fn func(*nats.RawStreamMsg) error) error {
+	info, err := c.js.StreamInfo(c.conf.deadStream(), nats.Context(ctx))
+	if err != nil {
+		return err
+	}
+	if info.State.Msgs == 0 {
+		return nil
+	}
+
+	done := 0
+	for seq := info.State.FirstSeq; seq <= info.State.LastSeq && (limit == 0 || done < limit); seq++ {
+		m, err := c.js.GetMsg(c.conf.deadStream(), seq, nats.Context(ctx))
+		if errors.Is(err, nats.ErrMsgNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+		done++
+	}
+	return nil
+}
+
+func deadLetter(m *nats.RawStreamMsg) codec.DeadLetter {
+	retries, _ := strconv.Atoi(m.Header.Get(headerRetries))
+	version, _ := strconv.Atoi(m.Header.Get(codec.HeaderVersion))
+	return codec.DeadLetter{
+		MessageID:   m.Header.Get(nats.MsgIdHdr),
+		Reason:      m.Header.Get(headerDeadReason),
+		Retries:     retries,
+		Timestamp:   m.Time,
+		ContentType: m.Header.Get(headerContentType),
+		Version:     version,
+		Body:        m.Data,
+	}
+}
@@ -0,0 +1,188 @@
+package internalnats
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/logger"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/codec"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/stretchr/testify/require"
+)
+
+func runServer(t *testing.T) *server.Server {
+	t.Helper()
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	require.NoError(t, err)
+	go srv.Start()
+	require.True(t, srv.ReadyForConnections(5*time.Second), "NATS server is not ready")
+	t.Cleanup(srv.Shutdown)
+	return srv
+}
+
+func receive(t *testing.T, c *Consumer) model.NotificationMsg {
+	t.Helper()
+	select {
+	case msg := <-c.NotifyChannel():
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+	return model.NotificationMsg{}
+}
+
+func TestTransport(t *testing.T) {
+	srv := runServer(t)
+	log := logger.NewLogger("DEBUG", os.Stdout)
+	ctx := context.Background()
+	conf := DefaultConf()
+	conf.AckWait = time.Second
+	conf.MaxDeliver = 2
+	conf.RetryDelay = 10 * time.Millisecond
+
+	producer := NewProducer(log, srv.ClientURL(), conf)
+	require.ErrorIs(t, producer.Health(), ErrNotConnected)
+	require.NoError(t, producer.Connect(ctx))
+	defer producer.Close(ctx)
+	require.NoError(t, producer.Health())
+
+	consumer := NewConsumer(log, srv.ClientURL(), conf)
+	require.NoError(t, consumer.Connect(ctx))
+	require.NoError(t, consumer.Health())
+
+	t.Run("ack", func(t *testing.T) {
+		notification := &model.Notification{ID: 1, EventID: 10, UserID: 100, Title: "Title", Attempts: 1}
+		require.NoError(t, producer.SendNotification(ctx, notification))
+		require.NoError(t, producer.SendNotification(ctx, notification), "duplicate is dropped by the stream")
+
+		msg := receive(t, consumer)
+		require.Equal(t, int64(1), msg.NotificationID)
+		require.Equal(t, int64(10), msg.ID)
+		require.NoError(t, consumer.Ack(ctx, msg))
+		require.ErrorIs(t, consumer.Ack(ctx, msg), ErrUnknownDelivery)
+
+		notification.Attempts++
+		require.NoError(t, producer.SendNotification(ctx, notification))
+		msg = receive(t, consumer)
+		require.Equal(t, int64(1), msg.NotificationID, "claimed again is not a duplicate")
+		require.NoError(t, consumer.Ack(ctx, msg))
+
+		require.NoError(t, producer.SendNotification(ctx, &model.Notification{ID: 2}))
+		msg = receive(t, consumer)
+		require.Equal(t, int64(2), msg.NotificationID, "duplicate is not delivered")
+		require.NoError(t, consumer.Ack(ctx, msg))
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		require.NoError(t, producer.SendNotification(ctx, &model.Notification{ID: 3}))
+		msg := receive(t, consumer)
+		require.Equal(t, int64(3), msg.NotificationID)
+		require.NoError(t, consumer.Retry(ctx, msg, errors.New("failed")))
+
+		msg = receive(t, consumer)
+		require.Equal(t, int64(3), msg.NotificationID, "redelivered")
+		require.NoError(t, consumer.Retry(ctx, msg, errors.New("failed")), "dead-lettered after max_deliver")
+
+		select {
+		case msg := <-consumer.NotifyChannel():
+			t.Fatalf("terminated message is delivered again: %v", msg)
+		case <-time.After(2 * conf.AckWait):
+		}
+	})
+
+	t.Run("dead_letters", func(t *testing.T) {
+		dlq := NewDeadLetters(log, srv.ClientURL(), conf)
+		require.NoError(t, dlq.Connect(ctx))
+		defer dlq.Close(ctx)
+
+		_, err := producer.js.Publish(conf.subject(), []byte("garbage"))
+		require.NoError(t, err)
+		var letters []codec.DeadLetter
+		require.Eventually(t, func() bool {
+			letters, err = dlq.List(ctx, 0)
+			return err == nil && len(letters) == 2
+		}, 5*time.Second, 10*time.Millisecond, "undecodable message is dead-lettered")
+
+		require.Equal(t, "3:0", letters[0].MessageID, "retried out")
+		require.Equal(t, "failed", letters[0].Reason)
+		require.Equal(t, 1, letters[0].Retries)
+		require.Equal(t, []byte("garbage"), letters[1].Body)
+		require.NotEmpty(t, letters[1].Reason)
+
+		requeued, err := dlq.Requeue(ctx, 1)
+		require.NoError(t, err)
+		require.Equal(t, 1, requeued)
+		msg := receive(t, consumer)
+		require.Equal(t, int64(3), msg.NotificationID, "requeued")
+		require.NoError(t, consumer.Ack(ctx, msg))
+
+		letters, err = dlq.List(ctx, 0)
+		require.NoError(t, err)
+		require.Len(t, letters, 1)
+		require.Equal(t, []byte("garbage"), letters[0].Body)
+	})
+
+	t.Run("content_types", func(t *testing.T) {
+		pbConf := conf
+		pbConf.ContentType = codec.ContentTypeProtobuf
+		pbProducer := NewProducer(log, srv.ClientURL(), pbConf)
+		require.NoError(t, pbProducer.Connect(ctx))
+		defer pbProducer.Close(ctx)
+		require.NoError(t, pbProducer.SendNotification(ctx, &model.Notification{ID: 5, Title: "Title"}))
+
+		msg := receive(t, consumer)
+		require.Equal(t, int64(5), msg.NotificationID)
+		require.Equal(t, "Title", msg.Title)
+		require.NoError(t, consumer.Ack(ctx, msg))
+
+		// a message of the previous version, untyped JSON without headers
+		_, err := producer.js.Publish(conf.subject(), []byte(`{"ID":60,"NotificationID":6,"Title":"Title"}`))
+		require.NoError(t, err)
+		msg = receive(t, consumer)
+		require.Equal(t, int64(6), msg.NotificationID)
+		require.Equal(t, int64(60), msg.ID)
+		require.NoError(t, consumer.Ack(ctx, msg))
+	})
+
+	t.Run("durable", func(t *testing.T) {
+		require.NoError(t, producer.SendNotification(ctx, &model.Notification{ID: 4}))
+		msg := receive(t, consumer)
+		require.Equal(t, int64(4), msg.NotificationID)
+		require.NoError(t, consumer.Close(ctx))
+
+		consumer = NewConsumer(log, srv.ClientURL(), conf)
+		require.NoError(t, consumer.Connect(ctx))
+		defer consumer.Close(ctx)
+
+		msg = receive(t, consumer)
+		require.Equal(t, int64(4), msg.NotificationID, "unacked message is delivered to the new consumer")
+		require.NoError(t, consumer.Ack(ctx, msg))
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	conf := Conf{RetryDelay: 2 * time.Second}
+	require.Equal(t, 2*time.Second, conf.retryDelay(1))
+	require.Equal(t, 8*time.Second, conf.retryDelay(3))
+
+	conf.RetryDelay = time.Hour
+	require.Equal(t, 16*time.Hour, conf.retryDelay(5))
+	require.Equal(t, maxRetryDelay, conf.retryDelay(6))
+	require.Equal(t, maxRetryDelay, conf.retryDelay(32), "no overflow")
+
+	v := &config.Validator{}
+	Conf{RetryDelay: maxRetryDelay + time.Second}.Check(v)
+	require.Error(t, v.Err())
+}
@@ -0,0 +1,85 @@
+package internalnats
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/codec"
+	"github.com/nats-io/nats.go"
+)
+
+type Producer struct {
+	log  Logger
+	url  string
+	conf Conf
+	conn *nats.Conn
+	js   nats.JetStreamContext
+}
+
+func NewProducer(log Logger, url string, conf Conf) *Producer {
+	return &Producer{log: log, url: url, conf: conf}
+}
+
+func (c *Producer) Connect(ctx context.Context) error {
+	c.log.Debugf("Connecting to NATS...\n")
+	conn, js, err := connect(c.log, c.url, c.conf, "producer")
+	if err != nil {
+		return err
+	}
+	c.conn, c.js = conn, js
+	c.log.Debugf("Connected to NATS\n")
+
+	return nil
+}
+
+func (c *Producer) Health() error {
+	return health(c.conn)
+}
+
+func (c *Producer) Close(ctx context.Context) error {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	return nil
+}
+
+// SendNotification publishes the message and waits for the stream to store
+// it. The message ID is the notification ID and the claim attempt, so the
+// stream drops copies of one attempt sent again within the duplicates window,
+// but not the notification claimed again after the inflight timeout.
+func (c *Producer) SendNotification(ctx context.Context, notification *model.Notification) error {
+	contentType, err := codec.ContentType(c.conf.ContentType)
+	if err != nil {
+		return err
+	}
+	data, err := codec.Marshal(model.NewNotificationMsg(notification), contentType)
+	if err != nil {
+		return err
+	}
+
+	pub := nats.NewMsg(c.conf.subject())
+	pub.Header.Set(nats.MsgIdHdr, msgID(notification))
+	pub.Header.Set(headerContentType, contentType)
+	pub.Header.Set(codec.HeaderVersion, strconv.Itoa(codec.Version))
+	pub.Data = data
+
+	ctx, cancel := context.WithTimeout(ctx, c.conf.publishTimeout())
+	defer cancel()
+
+	ack, err := c.js.PublishMsg(pub, nats.Context(ctx))
+	if err != nil {
+		return fmt.Errorf("SendNotification: %w", err)
+	}
+	if ack.Duplicate {
+		c.log.Debugf("notification %v attempt %v already sent\n", notification.ID, notification.Attempts)
+		return nil
+	}
+	c.log.Debugf("sent notification msg\n")
+	return nil
+}
+
+func msgID(notification *model.Notification) string {
+	return strconv.FormatInt(notification.ID, 10) + ":" + strconv.Itoa(notification.Attempts)
+}
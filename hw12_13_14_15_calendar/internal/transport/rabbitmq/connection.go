@@ -0,0 +1,181 @@
+package internalrmq
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	amqp091 "github.com/rabbitmq/amqp091-go"
+)
+
+type ConnState int32
+
+const (
+	Disconnected ConnState = iota
+	Connected
+	Closed
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case Connected:
+		return "connected"
+	case Closed:
+		return "closed"
+	}
+	return "disconnected"
+}
+
+var ErrNotConnected = errors.New("not connected to RabbitMQ")
+
+var connStates = expvar.NewMap("rabbitmq")
+
+// session keeps a connection and a channel open. When the broker closes
+// either of them, session reconnects with backoff and runs setup again
+// to redeclare the topology on the new channel.
+type session struct {
+	log     Logger
+	url     string
+	conf    Conf
+	name    string
+	setup   func(*amqp091.Channel) error
+	mu      sync.RWMutex
+	connect *amqp091.Connection
+	channel *amqp091.Channel
+	state   int32
+	expvar  *expvar.String
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newSession(log Logger, url string, conf Conf, name string, setup func(*amqp091.Channel) error) *session {
+	s := &session{
+		log:    log,
+		url:    url,
+		conf:   conf,
+		name:   name,
+		setup:  setup,
+		expvar: new(expvar.String),
+		done:   make(chan struct{}),
+	}
+	s.setState(Disconnected)
+	connStates.Set(name, s.expvar)
+	return s
+}
+
+func (s *session) State() ConnState {
+	return ConnState(atomic.LoadInt32(&s.state))
+}
+
+func (s *session) setState(state ConnState) {
+	atomic.StoreInt32(&s.state, int32(state))
+	s.expvar.Set(state.String())
+}
+
+// Health fails unless the session is connected.
+func (s *session) Health() error {
+	if state := s.State(); state != Connected {
+		return fmt.Errorf("%w: %v", ErrNotConnected, state)
+	}
+	return nil
+}
+
+// Channel returns the current channel or ErrNotConnected while reconnecting.
+func (s *session) Channel() (*amqp091.Channel, error) {
+	if s.State() != Connected {
+		return nil, ErrNotConnected
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.channel, nil
+}
+
+func (s *session) dial() error {
+	connect, err := amqp091.Dial(s.url)
+	if err != nil {
+		return err
+	}
+
+	channel, err := connect.Channel()
+	if err != nil {
+		connect.Close()
+		return err
+	}
+
+	connClosed := connect.NotifyClose(make(chan *amqp091.Error, 1))
+	chanClosed := channel.NotifyClose(make(chan *amqp091.Error, 1))
+
+	if err := s.setup(channel); err != nil {
+		connect.Close()
+		return err
+	}
+
+	s.mu.Lock()
+	s.connect, s.channel = connect, channel
+	s.mu.Unlock()
+	s.setState(Connected)
+
+	go s.watch(connect, connClosed, chanClosed)
+	return nil
+}
+
+func (s *session) watch(connect *amqp091.Connection, connClosed, chanClosed chan *amqp091.Error) {
+	var reason *amqp091.Error
+	select {
+	case reason = <-connClosed:
+	case reason = <-chanClosed:
+	case <-s.done:
+		return
+	}
+
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+
+	s.setState(Disconnected)
+	s.log.Warningf("RabbitMQ %v disconnected:%v\n", s.name, reason)
+	connect.Close()
+	s.reconnect()
+}
+
+func (s *session) reconnect() {
+	delay := s.conf.reconnectDelay()
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-s.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		err := s.dial()
+		if err == nil {
+			s.log.Infof("RabbitMQ %v reconnected\n", s.name)
+			return
+		}
+		s.log.Errorf("Can't reconnect RabbitMQ %v:%v, next try in %v\n", s.name, err, delay)
+
+		delay *= 2
+		if maxDelay := s.conf.maxReconnectDelay(); delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
+
+func (s *session) close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.setState(Closed)
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		if s.connect != nil {
+			s.connect.Close()
+		}
+	})
+}
@@ -2,68 +2,111 @@ package internalrmq
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"sync"
+	"time"
 
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/codec"
 	amqp091 "github.com/rabbitmq/amqp091-go"
 )
 
 type Consumer struct {
-	log             Logger
-	url             string
-	channel         *amqp091.Channel
-	connect         *amqp091.Connection
-	queue           amqp091.Queue
-	deliveryChannel <-chan amqp091.Delivery
-	notifyChannel   chan model.NotificationMsg
+	log           Logger
+	conf          Conf
+	session       *session
+	notifyChannel chan model.NotificationMsg
+	mu            sync.Mutex
+	lastTag       uint64
+	inflight      map[uint64]amqp091.Delivery
 }
 
-var ErrCantRecvMsg = errors.New("can't receive message")
+var (
+	ErrCantRecvMsg     = errors.New("can't receive message")
+	ErrUnknownDelivery = errors.New("unknown delivery tag")
+)
 
-func NewConsumer(log Logger, url string) *Consumer {
+func NewConsumer(log Logger, url string, conf Conf) *Consumer {
 	notifyChannel := make(chan model.NotificationMsg, 1)
-	return &Consumer{log: log, url: url, notifyChannel: notifyChannel}
+	c := &Consumer{
+		log:           log,
+		conf:          conf,
+		notifyChannel: notifyChannel,
+		inflight:      make(map[uint64]amqp091.Delivery),
+	}
+	c.session = newSession(log, url, conf, "consumer", c.setup)
+	return c
 }
 
 func (c *Consumer) Connect(ctx context.Context) error {
-	var err error
 	c.log.Debugf("Connecting to RabbitMQ...\n")
-	c.connect, err = amqp091.Dial(c.url)
-	if err != nil {
+	if err := c.session.dial(); err != nil {
 		return err
 	}
+	c.log.Debugf("Connected to RabbitMQ\n")
 
-	c.channel, err = c.connect.Channel()
+	return nil
+}
+
+// setup runs on every (re)connect and resumes consuming on the new channel.
+func (c *Consumer) setup(channel *amqp091.Channel) error {
+	queue, err := channel.QueueDeclare(getQueueDeclated(c.conf))
 	if err != nil {
 		return err
 	}
 
-	c.queue, err = c.channel.QueueDeclare(getQueueDeclated())
-	if err != nil {
+	if err := declareRetries(channel, c.conf); err != nil {
 		return err
 	}
 
-	c.deliveryChannel, err = c.channel.Consume(c.queue.Name, "", true, false, false, false, nil)
+	// republished retries and dead letters are confirmed before the ack
+	if err := channel.Confirm(false); err != nil {
+		return err
+	}
+
+	if err := channel.Qos(c.conf.prefetch(), 0, false); err != nil {
+		return err
+	}
+
+	deliveryChannel, err := channel.Consume(queue.Name, "", false, false, false, false, nil)
 	if err != nil {
 		return err
 	}
 
-	go func() {
-		for msg := range c.deliveryChannel {
-			notify, err := c.unpackMsg(msg)
-			if err != nil {
-				c.log.Errorf("RecvNotification:%v\n", err)
-			} else {
-				c.notifyChannel <- notify
-				c.log.Debugf("Received notification: %v\n", notify)
-			}
+	go c.receive(deliveryChannel)
+	return nil
+}
+
+// receive passes deliveries to NotifyChannel until the channel is closed.
+// Messages are tagged by the consumer, because delivery tags of a new
+// channel start over.
+func (c *Consumer) receive(deliveryChannel <-chan amqp091.Delivery) {
+	for msg := range deliveryChannel {
+		notify, err := c.unpackMsg(msg)
+		if err != nil {
+			c.log.Errorf("RecvNotification:%v\n", err)
+			c.deadLetter(context.Background(), msg, err)
+			continue
 		}
-	}()
 
-	c.log.Debugf("Connected to RabbitMQ\n")
+		c.mu.Lock()
+		c.lastTag++
+		notify.DeliveryTag = c.lastTag
+		c.inflight[notify.DeliveryTag] = msg
+		c.mu.Unlock()
 
-	return nil
+		c.notifyChannel <- notify
+		c.log.Debugf("Received notification: %v\n", notify)
+	}
+}
+
+// State reports the connection state for health checks.
+func (c *Consumer) State() ConnState {
+	return c.session.State()
+}
+
+func (c *Consumer) Health() error {
+	return c.session.Health()
 }
 
 func (c *Consumer) NotifyChannel() <-chan model.NotificationMsg {
@@ -71,16 +114,99 @@ func (c *Consumer) NotifyChannel() <-chan model.NotificationMsg {
 }
 
 func (c *Consumer) Close(ctx context.Context) error {
-	c.connect.Close()
-	c.channel.Close()
+	c.session.close()
 	return nil
 }
 
-func (c *Consumer) unpackMsg(msg amqp091.Delivery) (model.NotificationMsg, error) {
-	var notify model.NotificationMsg
+func (c *Consumer) takeDelivery(tag uint64) (amqp091.Delivery, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d, ok := c.inflight[tag]
+	if !ok {
+		return d, ErrUnknownDelivery
+	}
+	delete(c.inflight, tag)
+	return d, nil
+}
+
+// Ack acknowledges a handled message.
+func (c *Consumer) Ack(ctx context.Context, msg model.NotificationMsg) error {
+	d, err := c.takeDelivery(msg.DeliveryTag)
+	if err != nil {
+		return err
+	}
+	return d.Ack(false)
+}
+
+// Retry moves a failed message to the delay queue of its next retry. When the
+// retries are exhausted the message goes to the dead-letter exchange.
+func (c *Consumer) Retry(ctx context.Context, msg model.NotificationMsg, reason error) error {
+	d, err := c.takeDelivery(msg.DeliveryTag)
+	if err != nil {
+		return err
+	}
+
+	retry := retries(d.Headers) + 1
+	if retry > c.conf.maxRetries() {
+		return c.deadLetter(ctx, d, reason)
+	}
+
+	c.log.Warningf("Notification %v retry %v in %v: %v\n", msg.NotificationID, retry, c.conf.retryDelay(retry), reason)
+	headers := amqp091.Table{headerRetries: int32(retry)}
+	return c.republish(ctx, d, "", c.conf.retryQueue(retry), headers)
+}
+
+func (c *Consumer) deadLetter(ctx context.Context, d amqp091.Delivery, reason error) error {
+	c.log.Errorf("Message %v moved to %v: %v\n", d.MessageId, c.conf.deadLetterQueue(), reason)
+	headers := amqp091.Table{
+		headerRetries:    int32(retries(d.Headers)),
+		headerDeadReason: reason.Error(),
+	}
+	return c.republish(ctx, d, c.conf.deadLetterExchange(), "", headers)
+}
+
+// republish acks the delivery once the broker confirms its copy, otherwise
+// the delivery is returned to the queue.
+func (c *Consumer) republish(ctx context.Context, d amqp091.Delivery, exchange, key string,
+	headers amqp091.Table,
+) error {
+	channel, err := c.session.Channel()
+	if err == nil {
+		err = publishConfirmed(ctx, channel, c.conf.confirmTimeout(), exchange, key, republishing(d, headers))
+	}
+	if err != nil {
+		if errNack := d.Nack(false, true); errNack != nil {
+			c.log.Errorf("Can't nack message:%v\n", errNack)
+		}
+		return err
+	}
+	return d.Ack(false)
+}
+
+// publishConfirmed publishes on a channel in confirm mode and waits for the
+// broker to confirm the message.
+func publishConfirmed(ctx context.Context, channel *amqp091.Channel, timeout time.Duration,
+	exchange, key string, pub amqp091.Publishing,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	if err := json.Unmarshal(msg.Body, &notify); err != nil {
-		return notify, err
+	confirm, err := channel.PublishWithDeferredConfirmWithContext(ctx, exchange, key, false, false, pub)
+	if err != nil {
+		return err
 	}
-	return notify, nil
+	if confirm == nil {
+		return ErrCantSendMsg
+	}
+	if !confirm.Wait() {
+		if ctx.Err() != nil {
+			return ErrConfirmTimeout
+		}
+		return ErrMsgNacked
+	}
+	return nil
+}
+
+func (c *Consumer) unpackMsg(msg amqp091.Delivery) (model.NotificationMsg, error) {
+	return codec.Unmarshal(msg.Body, msg.ContentType, schemaVersion(msg.Headers))
 }
@@ -0,0 +1,113 @@
+package internalrmq
+
+import (
+	"context"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/codec"
+	amqp091 "github.com/rabbitmq/amqp091-go"
+)
+
+// DeadLetters inspects and requeues messages of the dead-letter queue.
+type DeadLetters struct {
+	log     Logger
+	url     string
+	conf    Conf
+	channel *amqp091.Channel
+	connect *amqp091.Connection
+}
+
+func NewDeadLetters(log Logger, url string, conf Conf) *DeadLetters {
+	return &DeadLetters{log: log, url: url, conf: conf}
+}
+
+func (c *DeadLetters) Connect(ctx context.Context) error {
+	var err error
+	c.connect, err = amqp091.Dial(c.url)
+	if err != nil {
+		return err
+	}
+
+	c.channel, err = c.connect.Channel()
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.channel.QueueDeclare(getQueueDeclated(c.conf)); err != nil {
+		return err
+	}
+
+	if err := c.channel.Confirm(false); err != nil {
+		return err
+	}
+
+	return declareRetries(c.channel, c.conf)
+}
+
+func (c *DeadLetters) Close(ctx context.Context) error {
+	c.connect.Close()
+	c.channel.Close()
+	return nil
+}
+
+// List returns up to limit dead letters, all of them if limit is 0.
+// The messages stay in the queue.
+func (c *DeadLetters) List(ctx context.Context, limit int) ([]codec.DeadLetter, error) {
+	letters := []codec.DeadLetter{}
+	last := uint64(0)
+
+	for limit == 0 || len(letters) < limit {
+		d, ok, err := c.channel.Get(c.conf.deadLetterQueue(), false)
+		if err != nil {
+			return letters, err
+		}
+		if !ok {
+			break
+		}
+		reason, _ := d.Headers[headerDeadReason].(string)
+		letters = append(letters, codec.DeadLetter{
+			MessageID:   d.MessageId,
+			Reason:      reason,
+			Retries:     retries(d.Headers),
+			Timestamp:   d.Timestamp,
+			ContentType: d.ContentType,
+			Version:     schemaVersion(d.Headers),
+			Body:        d.Body,
+		})
+		last = d.DeliveryTag
+	}
+
+	if last != 0 {
+		if err := c.channel.Nack(last, true, true); err != nil {
+			return letters, err
+		}
+	}
+	return letters, nil
+}
+
+// Requeue moves up to limit dead letters, all of them if limit is 0, back to
+// the main queue with a fresh retry count.
+func (c *DeadLetters) Requeue(ctx context.Context, limit int) (int, error) {
+	requeued := 0
+
+	for limit == 0 || requeued < limit {
+		d, ok, err := c.channel.Get(c.conf.deadLetterQueue(), false)
+		if err != nil {
+			return requeued, err
+		}
+		if !ok {
+			break
+		}
+		err = publishConfirmed(ctx, c.channel, c.conf.confirmTimeout(), "", c.conf.queue(), republishing(d, nil))
+		if err != nil {
+			d.Nack(false, true)
+			return requeued, err
+		}
+		if err := d.Ack(false); err != nil {
+			return requeued, err
+		}
+		requeued++
+	}
+
+	c.log.Infof("Requeued %v messages to %v\n", requeued, c.conf.queue())
+	return requeued, nil
+}
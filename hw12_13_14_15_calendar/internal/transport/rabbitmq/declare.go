@@ -1,6 +1,29 @@
 package internalrmq
 
-import amqp091 "github.com/rabbitmq/amqp091-go"
+import (
+	"fmt"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/codec"
+	amqp091 "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	defaultQueue          = "notification"
+	defaultConfirmTimeout = 5 * time.Second
+	defaultMaxRetries     = 5
+	defaultRetryDelay     = time.Second
+	// maxRetryDelay caps the backoff well below the x-message-ttl limit
+	// of 2^32-1 ms.
+	maxRetryDelay         = 24 * time.Hour
+	defaultReconnectDelay = time.Second
+	defaultMaxReconnect   = 30 * time.Second
+	defaultPrefetch       = 10
+
+	headerRetries    = "x-retries"
+	headerDeadReason = "x-dead-reason"
+)
 
 type Logger interface {
 	Fatalf(format string, a ...interface{})
@@ -10,6 +33,188 @@ type Logger interface {
 	Debugf(format string, a ...interface{})
 }
 
-func getQueueDeclated() (string, bool, bool, bool, bool, amqp091.Table) {
-	return "notification", false, false, false, false, nil
+type Conf struct {
+	Queue          string        `toml:"queue"`
+	Durable        bool          `toml:"durable"`
+	ConfirmTimeout time.Duration `toml:"confirm_timeout"`
+	MaxRetries     int           `toml:"max_retries"`
+	RetryDelay     time.Duration `toml:"retry_delay"`
+	ReconnectDelay time.Duration `toml:"reconnect_delay"`
+	MaxReconnect   time.Duration `toml:"max_reconnect_delay"`
+	Prefetch       int           `toml:"prefetch"`
+	ContentType    string        `toml:"content_type"`
+}
+
+// DefaultConf declares a durable queue, so messages survive a broker restart.
+func DefaultConf() Conf {
+	return Conf{
+		Queue:          defaultQueue,
+		Durable:        true,
+		ConfirmTimeout: defaultConfirmTimeout,
+		MaxRetries:     defaultMaxRetries,
+		RetryDelay:     defaultRetryDelay,
+		ReconnectDelay: defaultReconnectDelay,
+		MaxReconnect:   defaultMaxReconnect,
+		Prefetch:       defaultPrefetch,
+	}
+}
+
+func (c Conf) Check(v *config.Validator) {
+	v.NotNegative("confirm_timeout", c.ConfirmTimeout)
+	v.Range("max_retries", int64(c.MaxRetries), 0, 32)
+	v.NotNegative("retry_delay", c.RetryDelay)
+	if c.RetryDelay > maxRetryDelay {
+		v.AddKeyf("retry_delay", "must be <= %v, got %v", maxRetryDelay, c.RetryDelay)
+	}
+	v.NotNegative("reconnect_delay", c.ReconnectDelay)
+	v.NotNegative("max_reconnect_delay", c.MaxReconnect)
+	v.Range("prefetch", int64(c.Prefetch), 0, 65535)
+	if c.ContentType != "" {
+		v.OneOf("content_type", c.ContentType, codec.ContentTypeJSON, codec.ContentTypeProtobuf)
+	}
+}
+
+func (c Conf) queue() string {
+	if c.Queue == "" {
+		return defaultQueue
+	}
+	return c.Queue
+}
+
+func (c Conf) confirmTimeout() time.Duration {
+	if c.ConfirmTimeout == 0 {
+		return defaultConfirmTimeout
+	}
+	return c.ConfirmTimeout
+}
+
+func (c Conf) reconnectDelay() time.Duration {
+	if c.ReconnectDelay == 0 {
+		return defaultReconnectDelay
+	}
+	return c.ReconnectDelay
+}
+
+func (c Conf) maxReconnectDelay() time.Duration {
+	if c.MaxReconnect == 0 {
+		return defaultMaxReconnect
+	}
+	return c.MaxReconnect
+}
+
+// prefetch limits the unacknowledged messages delivered to the consumer.
+func (c Conf) prefetch() int {
+	if c.Prefetch == 0 {
+		return defaultPrefetch
+	}
+	return c.Prefetch
+}
+
+func (c Conf) maxRetries() int {
+	if c.MaxRetries == 0 {
+		return defaultMaxRetries
+	}
+	return c.MaxRetries
+}
+
+// retryDelay doubles the delay with every retry up to maxRetryDelay.
+func (c Conf) retryDelay(retry int) time.Duration {
+	delay := c.RetryDelay
+	if delay == 0 {
+		delay = defaultRetryDelay
+	}
+	for i := 1; i < retry && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
+
+// retryQueue is named by its delay, so changing retry_delay declares
+// new queues instead of conflicting with the existing ones.
+func (c Conf) retryQueue(retry int) string {
+	return fmt.Sprintf("%s.retry.%v", c.queue(), c.retryDelay(retry))
+}
+
+func (c Conf) deadLetterExchange() string {
+	return c.queue() + ".dlx"
+}
+
+func (c Conf) deadLetterQueue() string {
+	return c.queue() + ".dead"
+}
+
+func getQueueDeclated(conf Conf) (string, bool, bool, bool, bool, amqp091.Table) {
+	return conf.queue(), conf.Durable, false, false, false, nil
+}
+
+// declareRetries declares a delay queue per retry, which moves expired
+// messages back to the main queue, and the dead-letter exchange with its queue.
+func declareRetries(channel *amqp091.Channel, conf Conf) error {
+	for retry := 1; retry <= conf.maxRetries(); retry++ {
+		args := amqp091.Table{
+			"x-message-ttl":             conf.retryDelay(retry).Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": conf.queue(),
+		}
+		if _, err := channel.QueueDeclare(conf.retryQueue(retry), conf.Durable, false, false, false, args); err != nil {
+			return err
+		}
+	}
+
+	if err := channel.ExchangeDeclare(conf.deadLetterExchange(), amqp091.ExchangeFanout,
+		conf.Durable, false, false, false, nil); err != nil {
+		return err
+	}
+	if _, err := channel.QueueDeclare(conf.deadLetterQueue(), conf.Durable, false, false, false, nil); err != nil {
+		return err
+	}
+	return channel.QueueBind(conf.deadLetterQueue(), "", conf.deadLetterExchange(), false, nil)
+}
+
+// retries returns the number of retries recorded in the message headers.
+func retries(headers amqp091.Table) int {
+	switch v := headers[headerRetries].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
+
+// schemaVersion is zero for messages without the header.
+func schemaVersion(headers amqp091.Table) int {
+	switch v := headers[codec.HeaderVersion].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
+
+// republishing copies the delivery with the headers, keeping its schema
+// version.
+func republishing(d amqp091.Delivery, headers amqp091.Table) amqp091.Publishing {
+	if version, ok := d.Headers[codec.HeaderVersion]; ok {
+		if headers == nil {
+			headers = amqp091.Table{}
+		}
+		headers[codec.HeaderVersion] = version
+	}
+	return amqp091.Publishing{
+		Headers:      headers,
+		ContentType:  d.ContentType,
+		DeliveryMode: amqp091.Persistent,
+		MessageId:    d.MessageId,
+		Timestamp:    d.Timestamp,
+		Body:         d.Body,
+	}
 }
@@ -0,0 +1,54 @@
+package internalrmq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/config"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/codec"
+	amqp091 "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetries(t *testing.T) {
+	conf := Conf{RetryDelay: 2 * time.Second}
+
+	require.Equal(t, defaultMaxRetries, conf.maxRetries())
+	require.Equal(t, 2*time.Second, conf.retryDelay(1))
+	require.Equal(t, 8*time.Second, conf.retryDelay(3))
+	require.Equal(t, "notification.retry.8s", conf.retryQueue(3))
+	require.Equal(t, "notification.dead", conf.deadLetterQueue())
+
+	conf = Conf{RetryDelay: time.Hour, MaxRetries: 32}
+	require.Equal(t, 16*time.Hour, conf.retryDelay(5))
+	require.Equal(t, maxRetryDelay, conf.retryDelay(6))
+	require.Equal(t, maxRetryDelay, conf.retryDelay(32), "no overflow")
+	require.Less(t, conf.retryDelay(32).Milliseconds(), int64(1)<<32, "within the x-message-ttl limit")
+
+	v := &config.Validator{}
+	conf.Check(v)
+	require.NoError(t, v.Err())
+	conf.RetryDelay = maxRetryDelay + time.Second
+	conf.Check(v)
+	require.Error(t, v.Err())
+
+	require.Equal(t, 0, retries(nil))
+	require.Equal(t, 3, retries(amqp091.Table{headerRetries: int32(3)}))
+	require.Equal(t, 4, retries(amqp091.Table{headerRetries: int64(4)}))
+}
+
+func TestRepublishing(t *testing.T) {
+	d := amqp091.Delivery{
+		Headers:     amqp091.Table{codec.HeaderVersion: int32(codec.Version), headerRetries: int32(1)},
+		ContentType: codec.ContentTypeProtobuf,
+		MessageId:   "1",
+	}
+	require.Equal(t, codec.Version, schemaVersion(d.Headers))
+	require.Equal(t, 0, schemaVersion(nil), "legacy message")
+
+	pub := republishing(d, amqp091.Table{headerRetries: int32(2)})
+	require.Equal(t, codec.ContentTypeProtobuf, pub.ContentType)
+	require.Equal(t, amqp091.Table{codec.HeaderVersion: int32(codec.Version), headerRetries: int32(2)}, pub.Headers)
+	require.Equal(t, amqp091.Table{codec.HeaderVersion: int32(codec.Version)}, republishing(d, nil).Headers)
+	require.Nil(t, republishing(amqp091.Delivery{}, nil).Headers)
+}
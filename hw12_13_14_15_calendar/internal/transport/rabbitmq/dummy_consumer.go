@@ -25,3 +25,11 @@ func (c *DummyConsumer) NotifyChannel() <-chan model.NotificationMsg {
 func (c *DummyConsumer) Close(ctx context.Context) error {
 	return nil
 }
+
+func (c *DummyConsumer) Ack(ctx context.Context, msg model.NotificationMsg) error {
+	return nil
+}
+
+func (c *DummyConsumer) Retry(ctx context.Context, msg model.NotificationMsg, reason error) error {
+	return nil
+}
@@ -20,6 +20,6 @@ func (c *DummyProducer) Close(ctx context.Context) error {
 	return nil
 }
 
-func (c *DummyProducer) SendNotification(ctx context.Context, event *model.Event) error {
+func (c *DummyProducer) SendNotification(ctx context.Context, notification *model.Notification) error {
 	return nil
 }
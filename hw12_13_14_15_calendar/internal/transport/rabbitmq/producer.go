@@ -2,54 +2,82 @@ package internalrmq
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/model"
+	"github.com/FRiniZ/otus-go-hw-test/hw12_calendar/internal/transport/codec"
 	amqp091 "github.com/rabbitmq/amqp091-go"
 )
 
 type Producer struct {
-	log     Logger
-	url     string
-	channel *amqp091.Channel
-	connect *amqp091.Connection
-	queue   amqp091.Queue
+	log      Logger
+	conf     Conf
+	session  *session
+	queue    amqp091.Queue
+	mu       sync.Mutex
+	confirms chan amqp091.Confirmation
+	returns  chan amqp091.Return
 }
 
-var ErrCantSendMsg = errors.New("can't send message")
+var (
+	ErrCantSendMsg    = errors.New("can't send message")
+	ErrMsgNacked      = errors.New("message nacked by broker")
+	ErrMsgReturned    = errors.New("message returned by broker")
+	ErrConfirmTimeout = errors.New("publisher confirm timed out")
+)
 
-func NewProducer(log Logger, url string) *Producer {
-	return &Producer{log: log, url: url}
+func NewProducer(log Logger, url string, conf Conf) *Producer {
+	c := &Producer{log: log, conf: conf}
+	c.session = newSession(log, url, conf, "producer", c.setup)
+	return c
 }
 
 func (c *Producer) Connect(ctx context.Context) error {
-	var err error
 	c.log.Debugf("Connecting to RabbitMQ...\n")
-	c.connect, err = amqp091.Dial(c.url)
-	if err != nil {
+	if err := c.session.dial(); err != nil {
 		return err
 	}
+	c.log.Debugf("Connected to RabbitMQ\n")
+
+	return nil
+}
+
+// setup runs on every (re)connect. It waits for the publish in progress,
+// which fails as soon as the old channel is closed.
+func (c *Producer) setup(channel *amqp091.Channel) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	c.channel, err = c.connect.Channel()
+	var err error
+	c.queue, err = channel.QueueDeclare(getQueueDeclated(c.conf))
 	if err != nil {
 		return err
 	}
 
-	c.queue, err = c.channel.QueueDeclare(getQueueDeclated())
-	if err != nil {
+	if err := channel.Confirm(false); err != nil {
 		return err
 	}
-
-	c.log.Debugf("Connected to RabbitMQ\n")
+	c.confirms = channel.NotifyPublish(make(chan amqp091.Confirmation, 16))
+	c.returns = channel.NotifyReturn(make(chan amqp091.Return, 16))
 
 	return nil
 }
 
+// State reports the connection state for health checks.
+func (c *Producer) State() ConnState {
+	return c.session.State()
+}
+
+func (c *Producer) Health() error {
+	return c.session.Health()
+}
+
 func (c *Producer) Close(ctx context.Context) error {
-	c.connect.Close()
-	c.channel.Close()
+	c.session.close()
 	return nil
 }
 
@@ -62,27 +90,84 @@ func (c *Producer) Stop(ctx context.Context) error {
 	return nil
 }
 
-func (c *Producer) SendNotification(ctx context.Context, event *model.Event) error {
-	msg := model.NotificationMsg{
-		ID:     event.ID,
-		Title:  event.Title,
-		Date:   event.OnTime,
-		UserID: event.UserID,
+// SendNotification publishes a persistent mandatory message and waits for
+// the broker to confirm it. Publishes are serialized, so confirms of earlier
+// timed out messages are skipped by delivery tag. While reconnecting it fails
+// with ErrNotConnected, the notification stays in the outbox for the next try.
+func (c *Producer) SendNotification(ctx context.Context, notification *model.Notification) error {
+	contentType, err := codec.ContentType(c.conf.ContentType)
+	if err != nil {
+		return err
 	}
-
-	jdata, err := json.Marshal(msg)
+	data, err := codec.Marshal(model.NewNotificationMsg(notification), contentType)
 	if err != nil {
 		return err
 	}
 
+	messageID := strconv.FormatInt(notification.ID, 10)
 	pub := amqp091.Publishing{
-		ContentType: "application/json",
-		Body:        jdata,
+		Headers:      amqp091.Table{codec.HeaderVersion: int32(codec.Version)},
+		ContentType:  contentType,
+		DeliveryMode: amqp091.Persistent,
+		MessageId:    messageID,
+		Body:         data,
 	}
 
-	if err := c.channel.PublishWithContext(ctx, "", c.queue.Name, false, false, pub); err != nil {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	channel, err := c.session.Channel()
+	if err != nil {
+		return fmt.Errorf("SendNotification: %w", err)
+	}
+
+	tag := channel.GetNextPublishSeqNo()
+	if err := channel.PublishWithContext(ctx, "", c.queue.Name, true, false, pub); err != nil {
+		return fmt.Errorf("SendNotification: %w", err)
+	}
+
+	if err := c.waitConfirm(ctx, tag, messageID); err != nil {
 		return fmt.Errorf("SendNotification: %w", err)
 	}
 	c.log.Debugf("sent notification msg\n")
 	return nil
 }
+
+func (c *Producer) waitConfirm(ctx context.Context, tag uint64, messageID string) error {
+	timer := time.NewTimer(c.conf.confirmTimeout())
+	defer timer.Stop()
+
+	returned := false
+	for {
+		select {
+		case ret, ok := <-c.returns:
+			if !ok {
+				return ErrCantSendMsg
+			}
+			// the broker sends the return before the ack of the same message
+			if ret.MessageId == messageID {
+				returned = true
+				c.log.Warningf("Message %v returned: %v %v\n", messageID, ret.ReplyCode, ret.ReplyText)
+			}
+
+		case confirm, ok := <-c.confirms:
+			switch {
+			case !ok:
+				return ErrCantSendMsg
+			case confirm.DeliveryTag < tag:
+				continue
+			case !confirm.Ack:
+				return ErrMsgNacked
+			case returned:
+				return ErrMsgReturned
+			}
+			return nil
+
+		case <-timer.C:
+			return ErrConfirmTimeout
+
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS notifications_status_idx;
+DROP TABLE IF EXISTS notifications;
+
+COMMIT;
\ No newline at end of file
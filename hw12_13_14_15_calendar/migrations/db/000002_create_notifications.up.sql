@@ -0,0 +1,19 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS notifications(
+   id               SERIAL PRIMARY KEY,
+   eventid          INTEGER NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+   userid           BIGINT NOT NULL,
+   title            VARCHAR (150) NOT NULL,
+   ontime           TIMESTAMP NOT NULL,
+   notifytime       TIMESTAMP NOT NULL,
+   status           VARCHAR (16) NOT NULL DEFAULT 'pending',
+   attempts         INTEGER NOT NULL DEFAULT 0,
+   queuedat         TIMESTAMP,
+   lasterror        TEXT,
+   UNIQUE (eventid, notifytime)
+);
+
+CREATE INDEX IF NOT EXISTS notifications_status_idx ON notifications (status, queuedat);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,9 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS leases(
+   name             VARCHAR (64) PRIMARY KEY,
+   holder           VARCHAR (255) NOT NULL,
+   expiresat        TIMESTAMP NOT NULL
+);
+
+COMMIT;
\ No newline at end of file
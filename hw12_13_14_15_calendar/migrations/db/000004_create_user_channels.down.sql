@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS delivery_failures_notification_idx;
+DROP TABLE IF EXISTS delivery_failures;
+DROP TABLE IF EXISTS user_channels;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,21 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS user_channels(
+   userid           BIGINT NOT NULL,
+   channel          VARCHAR (32) NOT NULL,
+   address          VARCHAR (255) NOT NULL DEFAULT '',
+   PRIMARY KEY (userid, channel, address)
+);
+
+CREATE TABLE IF NOT EXISTS delivery_failures(
+   id               SERIAL PRIMARY KEY,
+   notificationid   INTEGER NOT NULL REFERENCES notifications (id) ON DELETE CASCADE,
+   channel          VARCHAR (32) NOT NULL,
+   address          VARCHAR (255) NOT NULL DEFAULT '',
+   error            TEXT NOT NULL,
+   failedat         TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS delivery_failures_notification_idx ON delivery_failures (notificationid);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE user_channels DROP COLUMN IF EXISTS timezone;
+ALTER TABLE user_channels DROP COLUMN IF EXISTS locale;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE user_channels ADD COLUMN IF NOT EXISTS locale VARCHAR (16) NOT NULL DEFAULT '';
+ALTER TABLE user_channels ADD COLUMN IF NOT EXISTS timezone VARCHAR (64) NOT NULL DEFAULT '';
+
+COMMIT;
\ No newline at end of file
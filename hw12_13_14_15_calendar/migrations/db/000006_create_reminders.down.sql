@@ -0,0 +1,23 @@
+BEGIN;
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS notifytime TIMESTAMP;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS notified BOOLEAN DEFAULT false;
+CREATE INDEX IF NOT EXISTS events_notify_idx ON events (ontime, notified);
+
+UPDATE events e SET notifytime = r.notifytime, notified = r.notified
+FROM (SELECT DISTINCT ON (eventid) eventid, notifytime, notified
+      FROM reminders
+      ORDER BY eventid, notifytime) r
+WHERE r.eventid = e.id;
+
+ALTER TABLE notifications DROP CONSTRAINT IF EXISTS notifications_reminderid_notifytime_key;
+DELETE FROM notifications a USING notifications b
+WHERE a.eventid = b.eventid AND a.notifytime = b.notifytime AND a.id > b.id;
+ALTER TABLE notifications ADD CONSTRAINT notifications_eventid_notifytime_key UNIQUE (eventid, notifytime);
+ALTER TABLE notifications DROP COLUMN IF EXISTS channel;
+ALTER TABLE notifications DROP COLUMN IF EXISTS reminderid;
+
+DROP INDEX IF EXISTS reminders_notify_idx;
+DROP TABLE IF EXISTS reminders;
+
+COMMIT;
\ No newline at end of file
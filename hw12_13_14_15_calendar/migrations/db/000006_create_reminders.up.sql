@@ -0,0 +1,34 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS reminders(
+   id               SERIAL PRIMARY KEY,
+   eventid          INTEGER NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+   offsetsec        BIGINT NOT NULL,
+   channel          VARCHAR (32) NOT NULL DEFAULT '',
+   notifytime       TIMESTAMP NOT NULL,
+   notified         BOOLEAN NOT NULL DEFAULT false,
+   UNIQUE (eventid, offsetsec, channel)
+);
+
+CREATE INDEX IF NOT EXISTS reminders_notify_idx ON reminders (notified, notifytime);
+
+INSERT INTO reminders (eventid, offsetsec, notifytime, notified)
+SELECT id, GREATEST(EXTRACT(EPOCH FROM ontime - notifytime)::BIGINT, 0), notifytime, COALESCE(notified, false)
+FROM events
+WHERE notifytime IS NOT NULL;
+
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS reminderid INTEGER REFERENCES reminders (id) ON DELETE CASCADE;
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS channel VARCHAR (32) NOT NULL DEFAULT '';
+
+UPDATE notifications n SET reminderid = r.id
+FROM reminders r
+WHERE r.eventid = n.eventid AND r.notifytime = n.notifytime;
+
+ALTER TABLE notifications DROP CONSTRAINT IF EXISTS notifications_eventid_notifytime_key;
+ALTER TABLE notifications ADD CONSTRAINT notifications_reminderid_notifytime_key UNIQUE (reminderid, notifytime);
+
+DROP INDEX IF EXISTS events_notify_idx;
+ALTER TABLE events DROP COLUMN IF EXISTS notifytime;
+ALTER TABLE events DROP COLUMN IF EXISTS notified;
+
+COMMIT;
\ No newline at end of file
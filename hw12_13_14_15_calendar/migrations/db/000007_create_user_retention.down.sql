@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS events_offtime_idx;
+DROP TABLE IF EXISTS user_retention;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,10 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS user_retention(
+   userid           BIGINT PRIMARY KEY,
+   retentionsec     BIGINT NOT NULL CHECK (retentionsec > 0)
+);
+
+CREATE INDEX IF NOT EXISTS events_offtime_idx ON events (offtime);
+
+COMMIT;
\ No newline at end of file
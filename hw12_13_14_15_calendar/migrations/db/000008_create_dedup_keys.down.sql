@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS dedup_keys;
+
+COMMIT;
\ No newline at end of file
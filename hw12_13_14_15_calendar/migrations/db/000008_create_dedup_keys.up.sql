@@ -0,0 +1,11 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS dedup_keys(
+   key              VARCHAR (128) PRIMARY KEY,
+   done             BOOLEAN NOT NULL DEFAULT false,
+   expiresat        TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS dedup_keys_expires_idx ON dedup_keys (expiresat);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS events_search_idx;
+ALTER TABLE events DROP COLUMN IF EXISTS search;
+
+COMMIT;
\ No newline at end of file
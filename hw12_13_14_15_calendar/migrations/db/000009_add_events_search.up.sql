@@ -0,0 +1,10 @@
+BEGIN;
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+   setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+   setweight(to_tsvector('simple', coalesce(description, '')), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS events_search_idx ON events USING GIN (search);
+
+COMMIT;
\ No newline at end of file
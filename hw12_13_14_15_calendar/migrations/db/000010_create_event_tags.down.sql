@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS event_tags;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,11 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS event_tags(
+   eventid          INTEGER NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+   tag              VARCHAR (32) NOT NULL,
+   PRIMARY KEY (eventid, tag)
+);
+
+CREATE INDEX IF NOT EXISTS event_tags_tag_idx ON event_tags (tag);
+
+COMMIT;
\ No newline at end of file
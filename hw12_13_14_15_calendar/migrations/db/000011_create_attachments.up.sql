@@ -0,0 +1,18 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS attachments(
+   id               SERIAL PRIMARY KEY,
+   eventid          INTEGER NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+   userid           BIGINT NOT NULL,
+   name             VARCHAR (255) NOT NULL,
+   size             BIGINT NOT NULL,
+   contenttype      VARCHAR (255) NOT NULL,
+   checksum         CHAR (64) NOT NULL,
+   blobkey          VARCHAR (64) NOT NULL UNIQUE,
+   createdat        TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS attachments_eventid_idx ON attachments (eventid);
+CREATE INDEX IF NOT EXISTS attachments_userid_idx ON attachments (userid);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE events DROP COLUMN IF EXISTS allday;
+
+COMMIT;
\ No newline at end of file
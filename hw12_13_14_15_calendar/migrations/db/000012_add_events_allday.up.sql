@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS allday BOOLEAN NOT NULL DEFAULT false;
+
+COMMIT;
\ No newline at end of file